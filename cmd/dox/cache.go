@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/samber/oops"
+	"github.com/urfave/cli/v3"
+
+	"github.com/g5becks/dox/internal/cache"
+	"github.com/g5becks/dox/internal/config"
+)
+
+func newCacheCommand() *cli.Command {
+	configFlag := &cli.StringFlag{
+		Name:    "config",
+		Aliases: []string{"c"},
+		Usage:   "Path to config file",
+	}
+
+	return &cli.Command{
+		Name:  "cache",
+		Usage: "Inspect and trim the download cache shared across projects",
+		Commands: []*cli.Command{
+			{
+				Name:  "stats",
+				Usage: "Show the cache location, object count, and size",
+				Flags: []cli.Flag{
+					configFlag,
+					&cli.BoolFlag{Name: "json", Usage: "Output as JSON"},
+				},
+				Action: cacheStatsAction,
+			},
+			{
+				Name:   "prune",
+				Usage:  "Evict least recently used objects until the cache fits max_size",
+				Flags:  []cli.Flag{configFlag},
+				Action: cachePruneAction,
+			},
+			{
+				Name:   "clear",
+				Usage:  "Remove every object from the cache",
+				Flags:  []cli.Flag{configFlag},
+				Action: cacheClearAction,
+			},
+		},
+	}
+}
+
+func cacheStatsAction(_ context.Context, cmd *cli.Command) error {
+	sharedCache, err := openCache(cmd.String("config"))
+	if err != nil {
+		return err
+	}
+
+	stats, err := sharedCache.Stats()
+	if err != nil {
+		return err
+	}
+
+	if cmd.Bool("json") {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if encodeErr := encoder.Encode(stats); encodeErr != nil {
+			return oops.
+				Code("JSON_ERROR").
+				Wrapf(encodeErr, "encoding cache stats")
+		}
+		return nil
+	}
+
+	_, _ = fmt.Fprintf(os.Stdout, "Cache:    %s\n", stats.Dir)
+	_, _ = fmt.Fprintf(os.Stdout, "Objects:  %d\n", stats.Objects)
+	_, _ = fmt.Fprintf(os.Stdout, "Size:     %s of %s\n", formatSize(stats.Size), formatSize(stats.MaxSize))
+	return nil
+}
+
+func cachePruneAction(_ context.Context, cmd *cli.Command) error {
+	sharedCache, err := openCache(cmd.String("config"))
+	if err != nil {
+		return err
+	}
+
+	result, err := sharedCache.Prune()
+	if err != nil {
+		return err
+	}
+
+	_, _ = fmt.Fprintf(os.Stdout, "Evicted %d object(s), freed %s\n", result.Objects, formatSize(result.Size))
+	return nil
+}
+
+func cacheClearAction(_ context.Context, cmd *cli.Command) error {
+	sharedCache, err := openCache(cmd.String("config"))
+	if err != nil {
+		return err
+	}
+
+	result, err := sharedCache.Clear()
+	if err != nil {
+		return err
+	}
+
+	_, _ = fmt.Fprintf(os.Stdout, "Removed %d object(s), freed %s\n", result.Objects, formatSize(result.Size))
+	return nil
+}
+
+// openCache opens the cache configured by the project config. The cache is
+// shared across projects, so outside of one it falls back to the defaults.
+func openCache(configPath string) (*cache.Cache, error) {
+	cacheCfg := config.Cache{}
+
+	if configPath == "" {
+		if found, findErr := config.FindConfigFile(); findErr == nil {
+			configPath = found
+		}
+	}
+
+	if configPath != "" {
+		resolvedPath, err := resolveConfigPath(configPath)
+		if err != nil {
+			return nil, err
+		}
+
+		cfg, err := config.Load(resolvedPath)
+		if err != nil {
+			return nil, err
+		}
+		cacheCfg = cfg.Cache
+	}
+
+	return cache.Open(cacheCfg)
+}
@@ -43,6 +43,9 @@ type collectionOutput struct {
 	Type     string    `json:"type"`
 	Source   string    `json:"source"`
 	Path     string    `json:"path,omitempty"`
+	Version  string    `json:"version,omitempty"`
+	Ref      string    `json:"ref,omitempty"`
+	Commit   string    `json:"commit,omitempty"`
 	Files    int       `json:"files"`
 	Size     int64     `json:"size"`
 	LastSync time.Time `json:"last_sync"`
@@ -83,6 +86,9 @@ func collectionsAction(_ context.Context, cmd *cli.Command) error {
 			Type:     coll.Type,
 			Source:   coll.Source,
 			Path:     coll.Path,
+			Version:  coll.Version,
+			Ref:      coll.Ref,
+			Commit:   coll.Commit,
 			Files:    coll.FileCount,
 			Size:     coll.TotalSize,
 			LastSync: coll.LastSync,
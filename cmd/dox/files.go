@@ -27,6 +27,10 @@ func newFilesCommand() *cli.Command {
 				Aliases: []string{"c"},
 				Usage:   "Path to config file",
 			},
+			&cli.StringFlag{
+				Name:  "version",
+				Usage: "Version of a collection that syncs several versions",
+			},
 			&cli.BoolFlag{
 				Name:  "json",
 				Usage: "Output as JSON",
@@ -81,13 +85,9 @@ func filesAction(_ context.Context, cmd *cli.Command) error {
 		return err
 	}
 
-	collection, ok := m.Collections[collectionName]
-	if !ok {
-		return oops.
-			Code("COLLECTION_NOT_FOUND").
-			With("collection", collectionName).
-			Hint("Run 'dox collections' to see available collections").
-			Errorf("collection %q not found", collectionName)
+	collection, err := m.Lookup(collectionName, cmd.String("version"))
+	if err != nil {
+		return err
 	}
 
 	limit := resolveLimit(cmd, cfg)
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"cmp"
 	"context"
 	"errors"
 	"fmt"
@@ -35,10 +36,27 @@ const initTemplate = `# dox.toml - Documentation source configuration
 # Can also be set via GITHUB_TOKEN or GH_TOKEN environment variable
 # github_token = ""
 
+# GitLab token for private projects on gitlab.com
+# Can also be set via GITLAB_TOKEN environment variable
+# gitlab_token = ""
+
+# Gitea/Forgejo token for Codeberg
+# Can also be set via GITEA_TOKEN environment variable
+# gitea_token = ""
+
 # Max parallel source syncs (default: 4x CPU cores, min 10)
 # Override per-command with: dox sync --parallel N
 # max_parallel = 20
 
+# Concurrent file downloads within one source (default: 8)
+# Downloads of all sources share the max_parallel request budget
+# Override per source with: file_parallel = N
+# file_parallel = 8
+
+# Wait for the GitHub API rate limit to reset instead of failing (default: false)
+# rate_limit_wait = true
+# rate_limit_max_wait = "15m"
+
 # ============================================================================
 # GLOBAL EXCLUDES (applied to all git hosting sources)
 # ============================================================================
@@ -118,6 +136,49 @@ excludes = [
 # Default fields for 'dox files' table
 # list_fields = ["path", "type", "lines", "size", "description"]
 
+# ============================================================================
+# HTTP SETTINGS (every source; override per source with [sources.<name>.http])
+# ============================================================================
+# [http]
+# Proxy for all requests (defaults to HTTP_PROXY / HTTPS_PROXY / NO_PROXY)
+# proxy = "http://proxy.corp:3128"
+# no_proxy = ["localhost", ".corp"]
+#
+# Extra CA bundle and mutual TLS client certificate (relative to this file)
+# ca_bundle = "certs/corp-ca.pem"
+# client_cert = "certs/client.pem"
+# client_key = "certs/client.key"
+#
+# Request and connect timeouts, and retries for failed requests
+# timeout = "60s"
+# connect_timeout = "10s"
+# max_retries = 3
+
+# ============================================================================
+# SHARED CACHE (downloads reused across projects; see 'dox cache')
+# ============================================================================
+# [cache]
+# enabled = true
+#
+# Defaults to dox in the user cache directory (relative to this file if relative)
+# dir = "/var/cache/dox"
+#
+# Size cap in bytes; least recently used objects are evicted after each sync
+# max_size = 2147483648
+#
+# Place cached files by "hardlink" or "copy"
+# mode = "hardlink"
+
+# ============================================================================
+# CREDENTIALS (per-host tokens, e.g. GitHub Enterprise or self-hosted GitLab)
+# ============================================================================
+# Each entry sets one of token, token_env, or helper ("git" or "netrc").
+# github.com, gitlab.com, and codeberg.org without an entry use github_token,
+# gitlab_token, or gitea_token; every host then falls back to ~/.netrc.
+# [[credentials]]
+# host = "github.example.com"
+# token_env = "GHE_TOKEN"
+
 # ============================================================================
 # SOURCES - Type is inferred from 'repo' or 'url' presence
 # ============================================================================
@@ -127,9 +188,12 @@ excludes = [
 # repo = "owner/repo"
 # path = "docs"
 # ref = "main"                                       # optional (default: repo default branch)
+# version = "^5.0"                                   # optional: highest matching semver tag (instead of ref)
+# versions = ["v4.2.0", { name = "v5", version = "^5.0" }]  # optional: sync versions side by side as <name>@<version>
 # patterns = ["**/*.md", "**/*.mdx", "**/*.txt"]     # optional (these are the defaults)
 # exclude = ["custom-pattern/**"]                    # optional (adds to global excludes, no duplicates)
 # out = "custom-dir-name"                             # optional (default: source key name)
+# download = "auto"                                  # optional: auto, api, raw, tarball (GitHub only)
 
 # --- GitLab - specify host to use gitlab.com ---
 # [sources.gitlab-project]
@@ -143,17 +207,95 @@ excludes = [
 # path = "documentation"
 # host = "codeberg.org"
 
+# --- Self-hosted Gitea/Forgejo - same API as Codeberg ---
+# [sources.forgejo-project]
+# type = "forgejo"                                   # or "gitea"
+# repo = "owner/repo"
+# path = "docs"
+# host = "git.example.org"
+
+# --- GitHub Enterprise Server - API at https://{host}/api/v3 ---
+# [sources.enterprise-docs]
+# type = "github"
+# repo = "platform/handbook"
+# path = "docs"
+# host = "github.mycorp.com"
+# api_url = "https://github.mycorp.com/api/v3"       # optional (only if the API lives elsewhere)
+
 # --- Self-Hosted Git (GitHub Enterprise, GitLab CE/EE, Gitea, etc.) ---
 # [sources.internal-docs]
 # repo = "company/documentation"
 # path = "guides"
 # host = "git.company.com"
 
+# --- Any git server or local repository - fetched over the git protocol ---
+# [sources.mirror]
+# type = "git"
+# url = "https://git.example.org/project.git"        # or file:// URL / local path
+# path = "docs"
+# ref = "main"                                       # optional (default: remote HEAD)
+
+# --- Local directory - type inferred from 'dir' ---
+# [sources.design]
+# dir = "../design-docs"                              # relative to this file
+# mode = "copy"                                       # optional: copy, hardlink, symlink
+
+# --- Release archive (.tar.gz, .tgz, .tar, .zip) over HTTP or from disk ---
+# [sources.site-docs]
+# type = "archive"
+# url = "https://example.com/releases/docs.tar.gz"   # or a local path
+# strip_components = 1                               # optional: drop leading directories
+# path = "docs"                                      # optional: directory inside the archive
+
+# --- npm package README, docs, and .d.ts typings ---
+# [sources.zod]
+# type = "npm"
+# package = "zod"
+# version = "^3.22.0"                                # optional: semver range or dist-tag (default: latest)
+# registry = "https://registry.npmjs.org"            # optional
+
+# --- Go module docs plus rendered API reference, via GOPROXY ---
+# [sources.cobra]
+# type = "gomod"
+# module = "github.com/spf13/cobra"
+# version = "^1.8"                                   # optional: version, semver range, or latest
+# goproxy = "https://proxy.golang.org"               # optional (default: $GOPROXY, then proxy.golang.org)
+
+# --- Python package docs from the sdist or wheel on PyPI ---
+# [sources.requests]
+# type = "pypi"
+# package = "requests"
+# version = ">=2.31,<3"                              # optional: PEP 440 specifier (default: latest)
+# dist = "auto"                                      # optional: auto (sdist, then wheel), sdist, wheel
+# docstrings = true                                  # optional: render module docstrings to api/*.md
+# registry = "https://pypi.org/pypi"                 # optional: JSON API, or a PEP 691 index ending in /simple
+
+# --- Documentation website crawled and converted to markdown ---
+# [sources.guide]
+# type = "site"
+# url = "https://example.com/docs/"                  # crawl root (or set only sitemap)
+# sitemap = "https://example.com/sitemap.xml"        # optional: seed the crawl from a sitemap
+# prefixes = ["/docs/"]                              # optional (default: directory of url)
+# max_depth = 3                                      # optional: link depth from the seeds
+# max_pages = 200                                    # optional
+# selector = "main, article, [role=main]"            # optional: CSS selector for the content
+
 # --- Direct URL download - type inferred from 'url' ---
 # [sources.my-framework]
 # url = "https://example.com/llms-full.txt"
 # filename = "my-framework.txt"                       # optional (default: basename from URL)
+# sha256 = "..."                                      # optional: fail sync if the file changes
+# expand = true                                      # optional: mirror the files an llms.txt links to
+# headers = { "X-Docs-Version" = "2" }               # optional: extra request headers
+# auth = { type = "bearer", token_env = "DOCS_TOKEN" } # optional: basic, bearer, or header auth
 # out = "custom-dir"                                  # optional (default: source key name)
+
+# --- List of URL downloads - type inferred from 'urls' ---
+# [sources.my-api]
+# urls = [
+#     "https://example.com/llms.txt",
+#     { url = "https://example.com/openapi", filename = "openapi.yaml" },
+# ]
 `
 
 //nolint:gochecknoglobals // Build metadata is injected at build time with ldflags.
@@ -190,6 +332,8 @@ func newRootCommand() *cli.Command {
 			newCatCommand(),
 			newOutlineCommand(),
 			newSearchCommand(),
+			newVerifyCommand(),
+			newCacheCommand(),
 		},
 	}
 }
@@ -208,6 +352,11 @@ func newSyncCommand() *cli.Command {
 			},
 			&cli.BoolFlag{Name: "clean", Usage: "Delete output directory before syncing"},
 			&cli.BoolFlag{Name: "dry-run", Usage: "Show planned changes without writing files"},
+			&cli.BoolFlag{
+				Name:  "frozen",
+				Usage: "Sync exactly the refs and versions in the lock file; fail if config or content disagree",
+			},
+			&cli.BoolFlag{Name: "locked", Usage: "Fail if a sync would change the lock file (writes nothing)"},
 			&cli.IntFlag{
 				Name: "parallel", Aliases: []string{"p"},
 				Usage: "Maximum parallel source syncs", Value: defaultParallel,
@@ -291,7 +440,14 @@ func syncAction(ctx context.Context, cmd *cli.Command) error {
 		return err
 	}
 
-	printer := ui.NewSyncPrinter(cmd.Bool("dry-run"))
+	if cmd.Bool("frozen") && cmd.Bool("locked") {
+		return oops.
+			Code("INVALID_ARGS").
+			Hint("Use --frozen to reproduce the lock file, or --locked to check it").
+			Errorf("--frozen and --locked cannot be used together")
+	}
+
+	printer := ui.NewSyncPrinter(cmd.Bool("dry-run") || cmd.Bool("locked"))
 
 	result, runErr := doxsync.Run(ctx, cfg, doxsync.Options{
 		SourceNames: commandArgs(cmd),
@@ -299,6 +455,8 @@ func syncAction(ctx context.Context, cmd *cli.Command) error {
 		DryRun:      cmd.Bool("dry-run"),
 		MaxParallel: cmd.Int("parallel"),
 		Clean:       cmd.Bool("clean"),
+		Frozen:      cmd.Bool("frozen"),
+		Locked:      cmd.Bool("locked"),
 		OnEvent:     printer.HandleEvent,
 	})
 
@@ -333,26 +491,37 @@ func listAction(_ context.Context, cmd *cli.Command) error {
 	}
 	slices.Sort(sourceNames)
 
-	includeFiles := cmd.Bool("files")
-	statuses := make([]ui.SourceStatus, 0, len(sourceNames))
+	targets := make([]config.Target, 0, len(sourceNames))
 	for _, sourceName := range sourceNames {
-		sourceCfg := cfg.Sources[sourceName]
+		targets = append(targets, cfg.Targets(sourceName)...)
+	}
+
+	includeFiles := cmd.Bool("files")
+	statuses := make([]ui.SourceStatus, 0, len(targets))
+	for _, target := range targets {
+		sourceCfg := target.Config
 		status := ui.SourceStatus{
-			Name:      sourceName,
+			Name:      target.Name,
 			Type:      sourceCfg.Type,
 			Repo:      sourceCfg.Repo,
 			Path:      sourceCfg.Path,
-			URL:       sourceCfg.URL,
+			URL:       sourceURL(sourceCfg),
+			Dir:       sourceCfg.Dir,
+			Package:   sourceCfg.Package,
+			Module:    sourceCfg.Module,
+			Version:   sourceCfg.Version,
 			Ref:       sourceCfg.Ref,
 			Patterns:  sourceCfg.Patterns,
-			OutputDir: cfg.OutputDir(sourceName, sourceCfg),
+			OutputDir: cfg.OutputDir(target.Name, sourceCfg),
 			Status:    "not synced",
 		}
 
-		lockEntry := lock.GetEntry(sourceName)
+		lockEntry := lock.GetEntry(target.Name)
 		if lockEntry != nil {
 			status.Status = "synced"
 			status.SyncedAt = lockEntry.SyncedAt
+			status.ResolvedRef = lockEntry.Ref
+			status.Commit = lockEntry.Commit()
 			status.FileCount = len(lockEntry.Files)
 			if lockEntry.Type == "url" && status.FileCount == 0 {
 				status.FileCount = 1
@@ -581,6 +750,19 @@ func renderSourceSection(sourceName string, sourceCfg config.Source) string {
 	return strings.Join(lines, "\n") + "\n"
 }
 
+// sourceURL returns the URL shown for a source in the list: its url or
+// sitemap, or the first entry of its urls.
+func sourceURL(sourceCfg config.Source) string {
+	switch {
+	case len(sourceCfg.URLs) == 1:
+		return sourceCfg.URLs[0].URL
+	case len(sourceCfg.URLs) > 1:
+		return fmt.Sprintf("%s (+%d more)", sourceCfg.URLs[0].URL, len(sourceCfg.URLs)-1)
+	}
+
+	return cmp.Or(sourceCfg.URL, sourceCfg.Sitemap)
+}
+
 func formatTOMLStringArray(values []string) string {
 	parts := make([]string, 0, len(values))
 	for _, value := range values {
@@ -676,7 +858,9 @@ func cleanAction(_ context.Context, cmd *cli.Command) error {
 				Wrapf(removeErr, "removing source output directory")
 		}
 
-		lock.RemoveEntry(sourceName)
+		for _, target := range cfg.Targets(sourceName) {
+			lock.RemoveEntry(target.Name)
+		}
 	}
 
 	return lock.Save(outputDir)
@@ -37,6 +37,10 @@ func newSearchCommand() *cli.Command {
 				Name:  "collection",
 				Usage: "Search only within one collection",
 			},
+			&cli.StringFlag{
+				Name:  "version",
+				Usage: "Version of a collection that syncs several versions",
+			},
 			&cli.BoolFlag{
 				Name:  "content",
 				Usage: "Search file contents instead of metadata",
@@ -104,26 +108,53 @@ func searchAction(_ context.Context, cmd *cli.Command) error {
 		return err
 	}
 
+	collection, err := resolveSearchCollection(m, cmd)
+	if err != nil {
+		return err
+	}
+
 	format := resolveFormat(cmd, cfg)
 	limit := resolveLimit(cmd, cfg)
 	descLength := resolveDescLength(cmd, cfg)
 
 	if cmd.Bool("content") {
-		return runContentSearch(m, cfg, cmd, query, format, limit, descLength)
+		return runContentSearch(m, cfg, cmd, query, collection, format, limit, descLength)
 	}
 
-	return runMetadataSearch(m, cmd, query, format, limit, descLength)
+	return runMetadataSearch(m, query, collection, format, limit, descLength)
+}
+
+// resolveSearchCollection returns the collection a search is limited to,
+// applying --version to the collection named by --collection.
+func resolveSearchCollection(m *manifest.Manifest, cmd *cli.Command) (string, error) {
+	name := cmd.String("collection")
+	version := cmd.String("version")
+	if name == "" {
+		if version != "" {
+			return "", oops.
+				Code("INVALID_ARGS").
+				Hint("Use --version together with --collection").
+				Errorf("--version requires --collection")
+		}
+		return "", nil
+	}
+
+	collection, err := m.Lookup(name, version)
+	if err != nil {
+		return "", err
+	}
+
+	return collection.Name, nil
 }
 
 func runMetadataSearch(
 	m *manifest.Manifest,
-	cmd *cli.Command,
-	query, format string,
+	query, collection, format string,
 	limit, descLength int,
 ) error {
 	results, err := search.Metadata(m, search.MetadataOptions{
 		Query:      query,
-		Collection: cmd.String("collection"),
+		Collection: collection,
 		Limit:      limit,
 	})
 	if err != nil {
@@ -144,13 +175,13 @@ func runContentSearch(
 	m *manifest.Manifest,
 	cfg *config.Config,
 	cmd *cli.Command,
-	query, format string,
+	query, collection, format string,
 	limit, descLength int,
 ) error {
 	results, err := search.Content(m, search.ContentOptions{
 		OutputDir:  cfg.Output,
 		Query:      query,
-		Collection: cmd.String("collection"),
+		Collection: collection,
 		UseRegex:   cmd.Bool("regex"),
 		Limit:      limit,
 	})
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/samber/oops"
+	"github.com/urfave/cli/v3"
+
+	"github.com/g5becks/dox/internal/config"
+	doxsync "github.com/g5becks/dox/internal/sync"
+)
+
+func newVerifyCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "verify",
+		Usage:     "Check synced files against the hashes in the lock file",
+		ArgsUsage: "[source-name...]",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:    "config",
+				Aliases: []string{"c"},
+				Usage:   "Path to config file",
+			},
+			&cli.BoolFlag{
+				Name:  "json",
+				Usage: "Output as JSON",
+			},
+		},
+		Action: verifyAction,
+	}
+}
+
+func verifyAction(_ context.Context, cmd *cli.Command) error {
+	configPath, err := resolveConfigPath(cmd.String("config"))
+	if err != nil {
+		return err
+	}
+
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		return err
+	}
+
+	result, err := doxsync.Verify(cfg, commandArgs(cmd))
+	if err != nil {
+		return err
+	}
+
+	if cmd.Bool("json") {
+		if encodeErr := outputVerifyJSON(result); encodeErr != nil {
+			return encodeErr
+		}
+	} else {
+		outputVerifyTable(result)
+	}
+
+	if len(result.Problems) > 0 {
+		return oops.
+			Code("VERIFY_FAILED").
+			With("problems", len(result.Problems)).
+			Hint("Run 'dox sync --force' to restore the synced files").
+			Errorf("%d file(s) do not match the lock file", len(result.Problems))
+	}
+
+	return nil
+}
+
+func outputVerifyJSON(result *doxsync.VerifyResult) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+
+	if err := encoder.Encode(result); err != nil {
+		return oops.
+			Code("JSON_ERROR").
+			Wrapf(err, "encoding verify result")
+	}
+
+	return nil
+}
+
+func outputVerifyTable(result *doxsync.VerifyResult) {
+	if len(result.Problems) > 0 {
+		t := table.NewWriter()
+		t.SetOutputMirror(os.Stdout)
+		t.SetStyle(table.StyleRounded)
+		t.AppendHeader(table.Row{"SOURCE", "PATH", "STATUS"})
+
+		for _, problem := range result.Problems {
+			t.AppendRow(table.Row{problem.Source, problem.Path, problem.Status})
+		}
+
+		t.Render()
+	}
+
+	_, _ = fmt.Fprintf(
+		os.Stdout,
+		"Verified %d file(s) in %d source(s): %d problem(s)\n",
+		result.Files,
+		result.Sources,
+		len(result.Problems),
+	)
+}
@@ -0,0 +1,434 @@
+// Package cache implements the content-addressed store that projects on one
+// machine share, so documentation synced by one project is linked or copied
+// into the next instead of being downloaded again.
+package cache
+
+import (
+	"cmp"
+	"crypto/sha1" //nolint:gosec // git names blobs by SHA-1; used to verify cached blobs only.
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/samber/oops"
+
+	"github.com/g5becks/dox/internal/config"
+)
+
+const (
+	objectsDir = "objects"
+	appDir     = "dox"
+
+	gitBlobPrefix = "git-blob:"
+	sha256Prefix  = "sha256:"
+)
+
+// Cache stores files under keys derived from their content: git blob SHAs,
+// SHA-256 digests, or the integrity hash a registry published. Objects are
+// read-only and their modification time records when they were last used,
+// which drives LRU eviction.
+//
+// A nil Cache is valid and never holds anything.
+type Cache struct {
+	dir     string
+	maxSize int64
+	mode    string
+}
+
+// Stats describes the contents of a cache.
+type Stats struct {
+	Dir     string `json:"dir"`
+	Objects int    `json:"objects"`
+	Size    int64  `json:"size"`
+	MaxSize int64  `json:"max_size"`
+}
+
+// PruneResult counts the objects an eviction removed.
+type PruneResult struct {
+	Objects int   `json:"objects"`
+	Size    int64 `json:"size"`
+}
+
+type object struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// Open returns the cache described by cfg, creating its directory.
+func Open(cfg config.Cache) (*Cache, error) {
+	dir, err := Dir(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if mkdirErr := os.MkdirAll(filepath.Join(dir, objectsDir), 0o750); mkdirErr != nil {
+		return nil, oops.
+			Code("CACHE_ERROR").
+			With("path", dir).
+			Hint("Set cache.dir to a writable directory").
+			Wrapf(mkdirErr, "creating cache directory")
+	}
+
+	return &Cache{
+		dir:     dir,
+		maxSize: cmp.Or(cfg.MaxSize, config.DefaultCacheMaxSize),
+		mode:    cmp.Or(cfg.Mode, config.CacheModeHardlink),
+	}, nil
+}
+
+// Dir returns the configured cache directory, or dox's directory in the
+// user cache directory.
+func Dir(cfg config.Cache) (string, error) {
+	if cfg.Dir != "" {
+		return cfg.Dir, nil
+	}
+
+	userDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", oops.
+			Code("CACHE_ERROR").
+			Hint("Set cache.dir in your config").
+			Wrapf(err, "locating user cache directory")
+	}
+
+	return filepath.Join(userDir, appDir), nil
+}
+
+// GitBlobKey is the key of a file identified by its git blob SHA.
+func GitBlobKey(sha string) string {
+	return gitBlobPrefix + strings.ToLower(sha)
+}
+
+// SHA256Key is the key of a file identified by its hex SHA-256 digest.
+func SHA256Key(digest string) string {
+	return sha256Prefix + strings.ToLower(digest)
+}
+
+// Key is the key of a file identified by another immutable name, such as a
+// registry's integrity string or a module version.
+func Key(kind string, id string) string {
+	return kind + ":" + id
+}
+
+// Path returns the path of the object stored under key and marks it used.
+// The object must not be modified.
+func (c *Cache) Path(key string) (string, bool) {
+	if c == nil || key == "" {
+		return "", false
+	}
+
+	objectPath := c.objectPath(key)
+	if _, err := os.Stat(objectPath); err != nil {
+		return "", false
+	}
+
+	c.touch(objectPath)
+	return objectPath, true
+}
+
+// Link places the object stored under key at destPath, by hard link or copy
+// depending on the cache mode. Hard links fall back to copies across
+// filesystems. It reports false when the object is not cached or could not
+// be placed, in which case the caller downloads the file instead.
+func (c *Cache) Link(key string, destPath string) bool {
+	objectPath, ok := c.Path(key)
+	if !ok {
+		return false
+	}
+
+	dir := filepath.Dir(destPath)
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return false
+	}
+
+	tempPath, err := reserveTemp(dir)
+	if err != nil {
+		return false
+	}
+	defer func() {
+		_ = os.Remove(tempPath)
+	}()
+
+	linked := c.mode == config.CacheModeHardlink && os.Link(objectPath, tempPath) == nil
+	if !linked && copyFile(objectPath, tempPath, 0o600) != nil {
+		return false
+	}
+
+	return os.Rename(tempPath, destPath) == nil
+}
+
+// Store copies the file at srcPath into the cache under key. Files keyed by
+// a git blob SHA or SHA-256 digest are verified against it first.
+func (c *Cache) Store(key string, srcPath string) error {
+	if c == nil || key == "" {
+		return nil
+	}
+
+	objectPath := c.objectPath(key)
+	if _, err := os.Stat(objectPath); err == nil {
+		c.touch(objectPath)
+		return nil
+	}
+
+	if err := verify(key, srcPath); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(objectPath), 0o750); err != nil {
+		return oops.
+			Code("CACHE_ERROR").
+			With("path", filepath.Dir(objectPath)).
+			Wrapf(err, "creating cache directory")
+	}
+
+	tempPath, err := reserveTemp(filepath.Dir(objectPath))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = os.Remove(tempPath)
+	}()
+
+	// Objects are read-only so a hard-linked project file cannot be edited
+	// in place and change what other projects get.
+	if copyErr := copyFile(srcPath, tempPath, 0o444); copyErr != nil {
+		return copyErr
+	}
+
+	if renameErr := os.Rename(tempPath, objectPath); renameErr != nil {
+		return oops.
+			Code("CACHE_ERROR").
+			With("path", objectPath).
+			Wrapf(renameErr, "storing cache object")
+	}
+
+	return nil
+}
+
+// Stats counts the objects in the cache and their total size.
+func (c *Cache) Stats() (Stats, error) {
+	if c == nil {
+		return Stats{}, nil
+	}
+
+	objects, err := c.objects()
+	if err != nil {
+		return Stats{}, err
+	}
+
+	stats := Stats{Dir: c.dir, Objects: len(objects), MaxSize: c.maxSize}
+	for _, obj := range objects {
+		stats.Size += obj.size
+	}
+
+	return stats, nil
+}
+
+// Prune evicts the least recently used objects until the cache fits its
+// size cap.
+func (c *Cache) Prune() (PruneResult, error) {
+	if c == nil {
+		return PruneResult{}, nil
+	}
+
+	return c.evict(c.maxSize)
+}
+
+// Clear removes every object from the cache.
+func (c *Cache) Clear() (PruneResult, error) {
+	return c.evict(0)
+}
+
+func (c *Cache) evict(limit int64) (PruneResult, error) {
+	objects, err := c.objects()
+	if err != nil {
+		return PruneResult{}, err
+	}
+
+	var total int64
+	for _, obj := range objects {
+		total += obj.size
+	}
+
+	slices.SortFunc(objects, func(a object, b object) int {
+		return a.modTime.Compare(b.modTime)
+	})
+
+	result := PruneResult{}
+	for _, obj := range objects {
+		// A limit of zero removes everything, empty objects included.
+		if limit > 0 && total <= limit {
+			break
+		}
+
+		if removeErr := os.Remove(obj.path); removeErr != nil && !errors.Is(removeErr, fs.ErrNotExist) {
+			return result, oops.
+				Code("CACHE_ERROR").
+				With("path", obj.path).
+				Wrapf(removeErr, "removing cache object")
+		}
+
+		total -= obj.size
+		result.Objects++
+		result.Size += obj.size
+	}
+
+	return result, nil
+}
+
+func (c *Cache) objects() ([]object, error) {
+	if c == nil {
+		return nil, nil
+	}
+
+	root := filepath.Join(c.dir, objectsDir)
+	objects := []object{}
+
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			if errors.Is(walkErr, fs.ErrNotExist) {
+				return nil
+			}
+			return walkErr
+		}
+
+		if entry.IsDir() || strings.HasSuffix(entry.Name(), ".tmp") {
+			return nil
+		}
+
+		info, infoErr := entry.Info()
+		if infoErr != nil {
+			return nil //nolint:nilerr // objects evicted concurrently are skipped
+		}
+
+		objects = append(objects, object{path: path, size: info.Size(), modTime: info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return nil, oops.
+			Code("CACHE_ERROR").
+			With("path", root).
+			Wrapf(err, "reading cache directory")
+	}
+
+	return objects, nil
+}
+
+// objectPath spreads objects over subdirectories named by the first byte of
+// the hashed key, which also keeps arbitrary keys out of file names.
+func (c *Cache) objectPath(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	name := hex.EncodeToString(sum[:])
+
+	return filepath.Join(c.dir, objectsDir, name[:2], name)
+}
+
+func (c *Cache) touch(path string) {
+	now := time.Now()
+	_ = os.Chtimes(path, now, now)
+}
+
+// verify checks a file against the digest in its key. Keys of other kinds
+// are trusted, as their sources verify downloads before storing them.
+func verify(key string, path string) error {
+	var hasher hash.Hash
+	var want string
+
+	switch {
+	case strings.HasPrefix(key, gitBlobPrefix):
+		info, err := os.Stat(path)
+		if err != nil {
+			return readError(path, err)
+		}
+		hasher = sha1.New() //nolint:gosec // git blob names are SHA-1
+		_, _ = hasher.Write([]byte("blob " + strconv.FormatInt(info.Size(), 10) + "\x00"))
+		want = strings.TrimPrefix(key, gitBlobPrefix)
+	case strings.HasPrefix(key, sha256Prefix):
+		hasher = sha256.New()
+		want = strings.TrimPrefix(key, sha256Prefix)
+	default:
+		return nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return readError(path, err)
+	}
+	defer file.Close()
+
+	if _, copyErr := io.Copy(hasher, file); copyErr != nil {
+		return readError(path, copyErr)
+	}
+
+	if got := hex.EncodeToString(hasher.Sum(nil)); got != want {
+		return oops.
+			Code("CACHE_MISMATCH").
+			With("path", path).
+			With("expected", want).
+			With("actual", got).
+			Errorf("file %q does not match cache key %q", path, key)
+	}
+
+	return nil
+}
+
+// reserveTemp picks an unused temporary file name in dir.
+func reserveTemp(dir string) (string, error) {
+	tempFile, err := os.CreateTemp(dir, ".dox-cache-*.tmp")
+	if err != nil {
+		return "", oops.
+			Code("CACHE_ERROR").
+			With("path", dir).
+			Wrapf(err, "creating temporary file")
+	}
+
+	tempPath := tempFile.Name()
+	_ = tempFile.Close()
+	_ = os.Remove(tempPath)
+
+	return tempPath, nil
+}
+
+func copyFile(srcPath string, destPath string, perm os.FileMode) error {
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return readError(srcPath, err)
+	}
+	defer src.Close()
+
+	dest, err := os.OpenFile(destPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return oops.
+			Code("CACHE_ERROR").
+			With("path", destPath).
+			Wrapf(err, "creating file")
+	}
+
+	_, copyErr := io.Copy(dest, src)
+	closeErr := dest.Close()
+	if err = cmp.Or(copyErr, closeErr); err != nil {
+		return oops.
+			Code("CACHE_ERROR").
+			With("path", destPath).
+			Wrapf(err, "copying file")
+	}
+
+	return nil
+}
+
+func readError(path string, err error) error {
+	return oops.
+		Code("CACHE_ERROR").
+		With("path", path).
+		Wrapf(err, "reading file")
+}
@@ -0,0 +1,181 @@
+package cache_test
+
+import (
+	"crypto/sha1" //nolint:gosec // git blob SHAs are SHA-1
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/g5becks/dox/internal/cache"
+	"github.com/g5becks/dox/internal/config"
+)
+
+func gitBlobSHA(content string) string {
+	sum := sha1.Sum([]byte("blob " + strconv.Itoa(len(content)) + "\x00" + content)) //nolint:gosec // git blob SHA
+	return hex.EncodeToString(sum[:])
+}
+
+func writeFile(t *testing.T, dir string, name string, content string) string {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	return path
+}
+
+func openCache(t *testing.T, cfg config.Cache) *cache.Cache {
+	t.Helper()
+
+	if cfg.Dir == "" {
+		cfg.Dir = t.TempDir()
+	}
+
+	c, err := cache.Open(cfg)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+
+	return c
+}
+
+func TestStoreAndLinkGitBlob(t *testing.T) {
+	t.Parallel()
+
+	for _, mode := range []string{config.CacheModeHardlink, config.CacheModeCopy} {
+		t.Run(mode, func(t *testing.T) {
+			t.Parallel()
+
+			c := openCache(t, config.Cache{Mode: mode})
+			key := cache.GitBlobKey(gitBlobSHA("# Guide\n"))
+			src := writeFile(t, t.TempDir(), "guide.md", "# Guide\n")
+
+			dest := filepath.Join(t.TempDir(), "nested", "guide.md")
+			if c.Link(key, dest) {
+				t.Fatal("Link() = true before the blob was stored")
+			}
+
+			if err := c.Store(key, src); err != nil {
+				t.Fatalf("Store() error = %v", err)
+			}
+
+			if !c.Link(key, dest) {
+				t.Fatal("Link() = false after the blob was stored")
+			}
+
+			content, err := os.ReadFile(dest)
+			if err != nil {
+				t.Fatalf("ReadFile() error = %v", err)
+			}
+			if string(content) != "# Guide\n" {
+				t.Fatalf("linked content = %q, want %q", content, "# Guide\n")
+			}
+
+			objectPath, _ := c.Path(key)
+			objectInfo, _ := os.Stat(objectPath)
+			destInfo, _ := os.Stat(dest)
+			if os.SameFile(objectInfo, destInfo) != (mode == config.CacheModeHardlink) {
+				t.Errorf("SameFile(object, dest) = %v in %s mode", os.SameFile(objectInfo, destInfo), mode)
+			}
+		})
+	}
+}
+
+func TestStoreRejectsContentNotMatchingKey(t *testing.T) {
+	t.Parallel()
+
+	c := openCache(t, config.Cache{})
+	src := writeFile(t, t.TempDir(), "guide.md", "tampered")
+
+	if err := c.Store(cache.GitBlobKey(gitBlobSHA("original")), src); err == nil {
+		t.Fatal("Store() with mismatched git blob: got nil error")
+	}
+
+	sum := sha256.Sum256([]byte("original"))
+	if err := c.Store(cache.SHA256Key(hex.EncodeToString(sum[:])), src); err == nil {
+		t.Fatal("Store() with mismatched sha256: got nil error")
+	}
+
+	if err := c.Store(cache.Key("npm", "sha512-abc"), src); err != nil {
+		t.Fatalf("Store() with opaque key error = %v", err)
+	}
+
+	stats, err := c.Stats()
+	if err != nil {
+		t.Fatalf("Stats() error = %v", err)
+	}
+	if stats.Objects != 1 || stats.Size != int64(len("tampered")) {
+		t.Fatalf("Stats() = %+v, want only the opaque key stored", stats)
+	}
+}
+
+func TestPruneEvictsLeastRecentlyUsed(t *testing.T) {
+	t.Parallel()
+
+	c := openCache(t, config.Cache{MaxSize: 10})
+	srcDir := t.TempDir()
+
+	keys := []string{cache.Key("test", "old"), cache.Key("test", "used"), cache.Key("test", "new")}
+	for index, key := range keys {
+		if err := c.Store(key, writeFile(t, srcDir, strconv.Itoa(index), "12345")); err != nil {
+			t.Fatalf("Store(%s) error = %v", key, err)
+		}
+
+		objectPath, _ := c.Path(key)
+		stamp := time.Now().Add(time.Duration(index-len(keys)) * time.Hour)
+		if err := os.Chtimes(objectPath, stamp, stamp); err != nil {
+			t.Fatalf("Chtimes() error = %v", err)
+		}
+	}
+
+	// Using the oldest object makes it the most recently used.
+	if _, ok := c.Path(keys[0]); !ok {
+		t.Fatal("Path() = false for a stored object")
+	}
+
+	result, err := c.Prune()
+	if err != nil {
+		t.Fatalf("Prune() error = %v", err)
+	}
+	if result.Objects != 1 || result.Size != 5 {
+		t.Fatalf("Prune() = %+v, want one 5 byte object evicted", result)
+	}
+
+	if _, ok := c.Path(keys[1]); ok {
+		t.Error("least recently used object survived Prune()")
+	}
+	for _, key := range []string{keys[0], keys[2]} {
+		if _, ok := c.Path(key); !ok {
+			t.Errorf("object %s evicted, want kept", key)
+		}
+	}
+
+	cleared, err := c.Clear()
+	if err != nil {
+		t.Fatalf("Clear() error = %v", err)
+	}
+	if cleared.Objects != 2 {
+		t.Fatalf("Clear() removed %d objects, want 2", cleared.Objects)
+	}
+}
+
+func TestNilCacheHoldsNothing(t *testing.T) {
+	t.Parallel()
+
+	var c *cache.Cache
+	if c.Link(cache.Key("test", "a"), filepath.Join(t.TempDir(), "a")) {
+		t.Error("Link() on nil cache = true")
+	}
+	if err := c.Store(cache.Key("test", "a"), "missing"); err != nil {
+		t.Errorf("Store() on nil cache error = %v", err)
+	}
+	if _, err := c.Prune(); err != nil {
+		t.Errorf("Prune() on nil cache error = %v", err)
+	}
+}
@@ -47,6 +47,7 @@ func Load(configPath string) (*Config, error) {
 
 	cfg.ConfigDir = filepath.Dir(absConfigPath)
 	cfg.ApplyDefaults()
+	cfg.resolveLocalPaths()
 
 	if valErr := cfg.Validate(); valErr != nil {
 		return nil, valErr
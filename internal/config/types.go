@@ -1,30 +1,62 @@
 package config
 
 import (
+	"cmp"
 	"errors"
+	"fmt"
+	"net/url"
 	"path/filepath"
+	"reflect"
+	"slices"
 	"strings"
+	"time"
 
+	"github.com/Masterminds/semver/v3"
 	"github.com/go-playground/validator/v10"
 	"github.com/samber/oops"
 )
 
 const (
-	DefaultOutput = ".dox"
-	repoPartCount = 2
+	DefaultOutput           = ".dox"
+	DefaultFileParallel     = 8
+	DefaultRateLimitMaxWait = 15 * time.Minute
+	DefaultCacheMaxSize     = 2 << 30
+	CacheModeHardlink       = "hardlink"
+	CacheModeCopy           = "copy"
+	repoPartCount           = 2
 
 	// Source type constants.
 	sourceTypeGitHub   = "github"
 	sourceTypeGitLab   = "gitlab"
 	sourceTypeCodeberg = "codeberg"
+	sourceTypeGitea    = "gitea"
+	sourceTypeForgejo  = "forgejo"
 	sourceTypeGit      = "git"
 	sourceTypeURL      = "url"
+	sourceTypeLocal    = "local"
+	sourceTypeArchive  = "archive"
+	sourceTypeNPM      = "npm"
+	sourceTypeGoMod    = "gomod"
+	sourceTypePyPI     = "pypi"
+	sourceTypeSite     = "site"
 )
 
 func DefaultPatterns() []string {
 	return []string{"**/*.md", "**/*.mdx", "**/*.txt"}
 }
 
+// DefaultNPMPatterns returns the default patterns for npm packages, which
+// add TypeScript declaration files to the documentation patterns.
+func DefaultNPMPatterns() []string {
+	return append(DefaultPatterns(), "**/*.d.ts")
+}
+
+// DefaultPyPIPatterns returns the default patterns for Python packages:
+// markdown and reStructuredText docs, the README, and text files under docs/.
+func DefaultPyPIPatterns() []string {
+	return []string{"**/*.md", "**/*.mdx", "**/*.rst", "README*", "docs/**/*.txt"}
+}
+
 // DefaultExcludes returns common patterns to exclude from syncing.
 // These are populated in the config template by `dox init`.
 func DefaultExcludes() []string {
@@ -93,34 +125,169 @@ type Display struct {
 	ListFields        []string `koanf:"list_fields"`
 }
 
+// HTTP configures the transport of the clients sources download with. The
+// global [http] table applies to every source, and a source's own http
+// table overrides it field by field. Paths are relative to the config file.
+type HTTP struct {
+	Proxy          string        `koanf:"proxy"           validate:"omitempty,url"`
+	NoProxy        []string      `koanf:"no_proxy"`
+	CABundle       string        `koanf:"ca_bundle"`
+	ClientCert     string        `koanf:"client_cert"`
+	ClientKey      string        `koanf:"client_key"`
+	Timeout        time.Duration `koanf:"timeout"         validate:"omitempty,min=0"`
+	ConnectTimeout time.Duration `koanf:"connect_timeout" validate:"omitempty,min=0"`
+	MaxRetries     *int          `koanf:"max_retries"     validate:"omitempty,min=0,max=10"`
+}
+
+// Cache configures the content-addressed cache shared by every project on
+// the machine. Files are linked or copied from it instead of downloaded
+// when another project already fetched them. It is off unless enabled.
+type Cache struct {
+	Enabled bool   `koanf:"enabled"`
+	Dir     string `koanf:"dir"`
+	MaxSize int64  `koanf:"max_size" validate:"omitempty,min=0"`
+	Mode    string `koanf:"mode"     validate:"omitempty,oneof=hardlink copy"`
+}
+
+// Credential supplies the token for git hosting sources on one host, in
+// place of the github_token, gitlab_token, and gitea_token defaults. The token
+// comes from exactly one of token, token_env, or a helper: "netrc" reads the
+// host's entry from ~/.netrc and "git" asks git's credential helpers.
+type Credential struct {
+	Host     string `koanf:"host"`
+	Token    string `koanf:"token"`
+	TokenEnv string `koanf:"token_env"`
+	Helper   string `koanf:"helper"`
+}
+
 type Config struct {
-	Output      string            `koanf:"output"       validate:"omitempty,dirpath"`
-	GitHubToken string            `koanf:"github_token"`
-	MaxParallel int               `koanf:"max_parallel" validate:"omitempty,min=1,max=100"`
-	Excludes    []string          `koanf:"excludes"`
-	Display     Display           `koanf:"display"`
-	Sources     map[string]Source `koanf:"sources"      validate:"required,dive"`
-	ConfigDir   string            `koanf:"-"`
+	Output           string            `koanf:"output"              validate:"omitempty,dirpath"`
+	GitHubToken      string            `koanf:"github_token"`
+	GitLabToken      string            `koanf:"gitlab_token"`
+	GiteaToken       string            `koanf:"gitea_token"`
+	MaxParallel      int               `koanf:"max_parallel"        validate:"omitempty,min=1,max=100"`
+	FileParallel     int               `koanf:"file_parallel"       validate:"omitempty,min=1,max=100"`
+	RateLimitWait    bool              `koanf:"rate_limit_wait"`
+	RateLimitMaxWait time.Duration     `koanf:"rate_limit_max_wait"`
+	Excludes         []string          `koanf:"excludes"`
+	Display          Display           `koanf:"display"`
+	HTTP             HTTP              `koanf:"http"`
+	Cache            Cache             `koanf:"cache"`
+	Credentials      []Credential      `koanf:"credentials"`
+	Sources          map[string]Source `koanf:"sources"             validate:"required,dive"`
+	ConfigDir        string            `koanf:"-"`
 }
 
 type Source struct {
-	Type     string   `koanf:"type"     validate:"omitempty,oneof=github url git gitlab codeberg"`
-	Repo     string   `koanf:"repo"     validate:"omitempty,github_repo"`
-	Host     string   `koanf:"host"`
-	Path     string   `koanf:"path"`
-	Ref      string   `koanf:"ref"`
-	Patterns []string `koanf:"patterns"`
-	Exclude  []string `koanf:"exclude"`
-	URL      string   `koanf:"url"      validate:"omitempty,url"`
-	Filename string   `koanf:"filename"`
-	Out      string   `koanf:"out"`
+	Type            string            `koanf:"type"             validate:"omitempty,oneof=github url git gitlab codeberg gitea forgejo local archive npm gomod pypi site"`
+	Repo            string            `koanf:"repo"             validate:"omitempty,github_repo"`
+	Host            string            `koanf:"host"`
+	APIURL          string            `koanf:"api_url"          validate:"omitempty,http_url"`
+	Path            string            `koanf:"path"`
+	Ref             string            `koanf:"ref"`
+	Patterns        []string          `koanf:"patterns"`
+	Exclude         []string          `koanf:"exclude"`
+	URL             string            `koanf:"url"              validate:"omitempty,source_url"`
+	Filename        string            `koanf:"filename"`
+	Out             string            `koanf:"out"`
+	FileParallel    int               `koanf:"file_parallel"    validate:"omitempty,min=1,max=100"`
+	Download        string            `koanf:"download"         validate:"omitempty,oneof=auto api raw tarball"`
+	Dir             string            `koanf:"dir"`
+	Mode            string            `koanf:"mode"             validate:"omitempty,oneof=copy hardlink symlink"`
+	StripComponents int               `koanf:"strip_components" validate:"omitempty,min=0"`
+	MaxSize         int64             `koanf:"max_size"         validate:"omitempty,min=0"`
+	MaxFileSize     int64             `koanf:"max_file_size"    validate:"omitempty,min=0"`
+	Package         string            `koanf:"package"`
+	Version         string            `koanf:"version"`
+	Registry        string            `koanf:"registry"         validate:"omitempty,url"`
+	Module          string            `koanf:"module"`
+	GoProxy         string            `koanf:"goproxy"          validate:"omitempty,url"`
+	Dist            string            `koanf:"dist"             validate:"omitempty,oneof=auto sdist wheel"`
+	Docstrings      bool              `koanf:"docstrings"`
+	Sitemap         string            `koanf:"sitemap"          validate:"omitempty,url"`
+	Prefixes        []string          `koanf:"prefixes"         validate:"omitempty,dive,startswith=/"`
+	MaxDepth        int               `koanf:"max_depth"        validate:"omitempty,min=0"`
+	MaxPages        int               `koanf:"max_pages"        validate:"omitempty,min=0"`
+	Selector        string            `koanf:"selector"`
+	Expand          bool              `koanf:"expand"`
+	URLs            []URLFile         `koanf:"urls"             validate:"omitempty,dive"`
+	Headers         map[string]string `koanf:"headers"`
+	Auth            URLAuth           `koanf:"auth"`
+	SHA256          string            `koanf:"sha256"           validate:"omitempty,sha256"`
+	HTTP            HTTP              `koanf:"http"`
+	Versions        []SourceVersion   `koanf:"versions"`
+}
+
+// SourceVersion is one version of a source that syncs several side by side.
+// In TOML it is either a bare name, used as the ref of a git source or the
+// version of a package, or a table with a name and a ref or version.
+type SourceVersion struct {
+	Name    string `koanf:"name"`
+	Ref     string `koanf:"ref"`
+	Version string `koanf:"version"`
+}
+
+// UnmarshalText lets a versions entry be written as a bare name.
+func (v *SourceVersion) UnmarshalText(text []byte) error {
+	v.Name = string(text)
+	return nil
+}
+
+// Target is one unit a sync run fetches: a source, or one version of a
+// source that lists versions. Name keys its lock entry and its collection.
+type Target struct {
+	Name    string
+	Source  string
+	Version string
+	Config  Source
+}
+
+// URLAuth holds the credentials a url source sends with its requests.
+// Secrets may be read from environment variables so they stay out of the
+// config file; they are never written to the lock file or the manifest.
+type URLAuth struct {
+	Type        string `koanf:"type"`
+	Username    string `koanf:"username"`
+	Password    string `koanf:"password"`
+	PasswordEnv string `koanf:"password_env"`
+	Token       string `koanf:"token"`
+	TokenEnv    string `koanf:"token_env"`
+	Header      string `koanf:"header"`
+}
+
+// URLFile is one file of a url source that downloads several files. In
+// TOML it is either a plain URL or a table with url and filename.
+type URLFile struct {
+	URL      string `koanf:"url"      validate:"required,url"`
+	Filename string `koanf:"filename"`
+	SHA256   string `koanf:"sha256"   validate:"omitempty,sha256"`
+}
+
+// UnmarshalText lets a urls entry be written as a bare URL string.
+func (f *URLFile) UnmarshalText(text []byte) error {
+	f.URL = string(text)
+	return nil
 }
 
 func newValidator() *validator.Validate {
 	v := validator.New(validator.WithRequiredStructEnabled())
 
 	_ = v.RegisterValidation("github_repo", func(fl validator.FieldLevel) bool {
-		return isValidRepo(fl.Field().String())
+		return isValidRepo(fl.Field().String(), fl.Parent().FieldByName("Type").String())
+	})
+
+	// Generic git sources may point at a local path or file:// repository and
+	// archives may be read from disk, everything else must be an HTTP(S) URL.
+	_ = v.RegisterValidation("source_url", func(fl validator.FieldLevel) bool {
+		switch fl.Parent().FieldByName("Type").String() {
+		case sourceTypeGit:
+			return strings.TrimSpace(fl.Field().String()) != ""
+		case sourceTypeArchive:
+			if IsLocalURL(fl.Field().String()) {
+				return strings.TrimSpace(fl.Field().String()) != ""
+			}
+		}
+		return v.Var(fl.Field().String(), "url") == nil
 	})
 
 	return v
@@ -170,28 +337,108 @@ func (c *Config) ApplyDefaults() {
 		c.Display.ListFields = []string{"path", "type", "lines", "size", "description"}
 	}
 
+	if c.FileParallel == 0 {
+		c.FileParallel = DefaultFileParallel
+	}
+	if c.RateLimitMaxWait == 0 {
+		c.RateLimitMaxWait = DefaultRateLimitMaxWait
+	}
+	if c.Cache.MaxSize == 0 {
+		c.Cache.MaxSize = DefaultCacheMaxSize
+	}
+	if c.Cache.Mode == "" {
+		c.Cache.Mode = CacheModeHardlink
+	}
+
 	for sourceName, sourceCfg := range c.Sources {
 		sourceCfg = applySourceDefaults(sourceCfg, c.Excludes)
+		if sourceCfg.FileParallel == 0 {
+			sourceCfg.FileParallel = c.FileParallel
+		}
+		sourceCfg.HTTP = c.HTTP.merge(sourceCfg.HTTP)
 		c.Sources[sourceName] = sourceCfg
 	}
 }
 
+// merge returns h with the fields set in override replacing its own. The
+// client certificate and key are replaced together.
+func (h HTTP) merge(override HTTP) HTTP {
+	merged := h
+	merged.Proxy = cmp.Or(override.Proxy, h.Proxy)
+	merged.CABundle = cmp.Or(override.CABundle, h.CABundle)
+	merged.Timeout = cmp.Or(override.Timeout, h.Timeout)
+	merged.ConnectTimeout = cmp.Or(override.ConnectTimeout, h.ConnectTimeout)
+
+	if override.NoProxy != nil {
+		merged.NoProxy = override.NoProxy
+	}
+	if override.ClientCert != "" || override.ClientKey != "" {
+		merged.ClientCert, merged.ClientKey = override.ClientCert, override.ClientKey
+	}
+	if override.MaxRetries != nil {
+		merged.MaxRetries = override.MaxRetries
+	}
+
+	return merged
+}
+
 func applySourceDefaults(src Source, globalExcludes []string) Source {
 	// Infer type if not explicitly set
 	if src.Type == "" {
 		src.Type = inferSourceType(src)
 	}
 
+	applyVersionDefaults(src)
+
 	// Handle git hosting sources
 	if isGitSource(src.Type) {
 		src = applyGitSourceDefaults(src, globalExcludes)
 	}
 
+	switch src.Type {
+	case sourceTypeNPM:
+		if len(src.Patterns) == 0 {
+			src.Patterns = DefaultNPMPatterns()
+		}
+		src = applyFilterDefaults(src, globalExcludes)
+	case sourceTypePyPI:
+		if len(src.Patterns) == 0 {
+			src.Patterns = DefaultPyPIPatterns()
+		}
+		src = applyFilterDefaults(src, globalExcludes)
+	case sourceTypeLocal, sourceTypeArchive, sourceTypeGoMod:
+		src = applyFilterDefaults(src, globalExcludes)
+	case sourceTypeSite:
+		// Site pages are always written as markdown, so only excludes apply.
+		if len(globalExcludes) > 0 || len(src.Exclude) > 0 {
+			src.Exclude = mergeExcludes(globalExcludes, src.Exclude)
+		}
+	}
+
 	return src
 }
 
+// applyVersionDefaults turns a versions entry given only a name into the
+// ref of a git source or the version of a package.
+func applyVersionDefaults(src Source) {
+	for i, entry := range src.Versions {
+		if entry.Ref != "" || entry.Version != "" {
+			continue
+		}
+
+		if isGitSource(src.Type) {
+			src.Versions[i].Ref = entry.Name
+		} else {
+			src.Versions[i].Version = entry.Name
+		}
+	}
+}
+
 func inferSourceType(src Source) string {
-	if src.URL != "" {
+	if src.Dir != "" {
+		return sourceTypeLocal
+	}
+	if src.URL != "" || len(src.URLs) > 0 {
 		return sourceTypeURL
 	}
 	if src.Repo != "" {
@@ -204,20 +451,31 @@ func isGitSource(sourceType string) bool {
 	return sourceType == sourceTypeGitHub ||
 		sourceType == sourceTypeGit ||
 		sourceType == sourceTypeGitLab ||
-		sourceType == sourceTypeCodeberg
+		sourceType == sourceTypeCodeberg ||
+		sourceType == sourceTypeGitea ||
+		sourceType == sourceTypeForgejo
 }
 
 func applyGitSourceDefaults(src Source, globalExcludes []string) Source {
-	// Default host to github.com if not specified
-	if src.Host == "" {
-		src.Host = "github.com"
-	}
+	// A generic git source with an explicit clone URL has no host to infer from
+	if src.URL == "" {
+		// Default host based on the hosting type if not specified
+		if src.Host == "" {
+			src.Host = DefaultHost(src.Type)
+		}
 
-	// Normalize type based on host if type is generic "git"
-	if src.Type == sourceTypeGit {
-		src.Type = normalizeGitType(src.Host)
+		// Normalize type based on host if type is generic "git"
+		if src.Type == sourceTypeGit {
+			src.Type = normalizeGitType(src.Host)
+		}
 	}
 
+	return applyFilterDefaults(src, globalExcludes)
+}
+
+// applyFilterDefaults fills in the default patterns and merges the global
+// excludes for sources that select files from a tree.
+func applyFilterDefaults(src Source, globalExcludes []string) Source {
 	// Apply patterns if not set
 	if len(src.Patterns) == 0 {
 		src.Patterns = DefaultPatterns()
@@ -231,6 +489,19 @@ func applyGitSourceDefaults(src Source, globalExcludes []string) Source {
 	return src
 }
 
+// DefaultHost returns the public host of a git hosting type, which sources of
+// that type use when they set no host.
+func DefaultHost(sourceType string) string {
+	switch sourceType {
+	case sourceTypeGitLab:
+		return "gitlab.com"
+	case sourceTypeCodeberg, sourceTypeGitea, sourceTypeForgejo:
+		return "codeberg.org"
+	default:
+		return "github.com"
+	}
+}
+
 func normalizeGitType(host string) string {
 	switch {
 	case strings.Contains(host, "github"):
@@ -239,6 +510,10 @@ func normalizeGitType(host string) string {
 		return sourceTypeGitLab
 	case strings.Contains(host, "codeberg"):
 		return sourceTypeCodeberg
+	case strings.Contains(host, "gitea"):
+		return sourceTypeGitea
+	case strings.Contains(host, "forgejo"):
+		return sourceTypeForgejo
 	default:
 		return sourceTypeGit
 	}
@@ -268,35 +543,47 @@ func (c *Config) Validate() error {
 			Wrapf(err, "validating display config")
 	}
 
+	if err := validateCredentials(c.Credentials); err != nil {
+		return err
+	}
+
+	if err := validateCache(v, c.Cache); err != nil {
+		return err
+	}
+
 	for sourceName, sourceCfg := range c.Sources {
-		// Validate that source has either repo or url (not both, not neither)
-		hasRepo := sourceCfg.Repo != ""
-		hasURL := sourceCfg.URL != ""
+		if err := validateSourceLocation(sourceName, sourceCfg); err != nil {
+			return err
+		}
 
-		if !hasRepo && !hasURL {
-			return oops.
-				Code("CONFIG_INVALID").
-				With("source", sourceName).
-				Hint("Each source must have either 'repo' (for git hosting) or 'url' (for direct downloads)").
-				Errorf("source %q has neither 'repo' nor 'url'", sourceName)
+		if err := validateURLOptions(sourceName, sourceCfg); err != nil {
+			return err
+		}
+
+		if err := validateGitVersion(sourceName, sourceCfg); err != nil {
+			return err
 		}
 
-		if hasRepo && hasURL {
+		if err := c.validateVersions(sourceName, sourceCfg); err != nil {
+			return err
+		}
+
+		if sourceCfg.APIURL != "" && sourceCfg.Type != sourceTypeGitHub {
 			return oops.
 				Code("CONFIG_INVALID").
 				With("source", sourceName).
-				Hint("Use 'repo' for git hosting OR 'url' for direct downloads, not both").
-				Errorf("source %q has both 'repo' and 'url'", sourceName)
+				With("field", "api_url").
+				Hint("api_url points github sources at a GitHub Enterprise Server API; other types derive theirs from host").
+				Errorf("api_url is only supported for github sources, not %s source %q", sourceCfg.Type, sourceName)
 		}
 
-		// For git hosting sources, require path
-		if hasRepo && sourceCfg.Path == "" {
+		if (sourceCfg.HTTP.ClientCert == "") != (sourceCfg.HTTP.ClientKey == "") {
 			return oops.
 				Code("CONFIG_INVALID").
 				With("source", sourceName).
-				With("field", "path").
-				Hint("Set path to a file or directory in the repository").
-				Errorf("missing 'path' for source %q", sourceName)
+				With("field", "http").
+				Hint("Set both http.client_cert and http.client_key, or neither").
+				Errorf("source %q sets only one of http.client_cert and http.client_key", sourceName)
 		}
 
 		// Struct validation for URL format, repo format, etc.
@@ -321,35 +608,549 @@ func (c *Config) Validate() error {
 	return nil
 }
 
+// validateSourceLocation checks that a source names exactly one place to
+// fetch from: a local directory, a repository, or a URL.
+func validateSourceLocation(sourceName string, sourceCfg Source) error {
+	switch sourceCfg.Type {
+	case sourceTypeLocal:
+		return validateOwnLocation(sourceName, sourceCfg, "dir", sourceCfg.Dir,
+			"Set dir to the directory holding the documentation")
+	case sourceTypeNPM:
+		return validateOwnLocation(sourceName, sourceCfg, "package", sourceCfg.Package,
+			"Set package to the npm package name, e.g. \"zod\" or \"@tanstack/query-core\"")
+	case sourceTypePyPI:
+		return validateOwnLocation(sourceName, sourceCfg, "package", sourceCfg.Package,
+			"Set package to the PyPI project name, e.g. \"requests\"")
+	case sourceTypeGoMod:
+		return validateOwnLocation(sourceName, sourceCfg, "module", sourceCfg.Module,
+			"Set module to the Go module path, e.g. \"github.com/spf13/cobra\"")
+	case sourceTypeSite:
+		return validateSiteLocation(sourceName, sourceCfg)
+	case sourceTypeURL:
+		if len(sourceCfg.URLs) > 0 {
+			return validateURLList(sourceName, sourceCfg)
+		}
+	}
+
+	// Validate that source has either repo or url (not both, not neither)
+	hasRepo := sourceCfg.Repo != ""
+	hasURL := sourceCfg.URL != ""
+
+	if !hasRepo && !hasURL {
+		return oops.
+			Code("CONFIG_INVALID").
+			With("source", sourceName).
+			Hint("Each source must have 'repo' (for git hosting), 'url' (for direct downloads), or 'dir' (for local files)").
+			Errorf("source %q has neither 'repo' nor 'url'", sourceName)
+	}
+
+	if hasRepo && hasURL {
+		return oops.
+			Code("CONFIG_INVALID").
+			With("source", sourceName).
+			Hint("Use 'repo' for git hosting OR 'url' for direct downloads, not both").
+			Errorf("source %q has both 'repo' and 'url'", sourceName)
+	}
+
+	// For git hosting sources, require path
+	if (hasRepo || sourceCfg.Type == sourceTypeGit) && sourceCfg.Path == "" {
+		return oops.
+			Code("CONFIG_INVALID").
+			With("source", sourceName).
+			With("field", "path").
+			Hint("Set path to a file or directory in the repository").
+			Errorf("missing 'path' for source %q", sourceName)
+	}
+
+	return nil
+}
+
+// validateURLOptions rejects the url source options on other source types
+// and checks the credentials of url sources.
+func validateURLOptions(sourceName string, sourceCfg Source) error {
+	if sourceCfg.Type == sourceTypeURL {
+		return validateURLAuth(sourceName, sourceCfg.Auth)
+	}
+
+	var field, hint string
+	switch {
+	case len(sourceCfg.URLs) > 0:
+		field, hint = "urls", "urls lists files for a url source; remove 'type' or set it to \"url\""
+	case sourceCfg.Expand:
+		field, hint = "expand", "expand mirrors the files an llms.txt index links to; use it with type = \"url\""
+	case len(sourceCfg.Headers) > 0:
+		field, hint = "headers", "headers are sent by url sources; remove them from this source"
+	case sourceCfg.Auth != (URLAuth{}):
+		field, hint = "auth", "auth is sent by url sources; remove it from this source"
+	case sourceCfg.SHA256 != "":
+		field, hint = "sha256", "sha256 pins the file of a url source; remove it from this source"
+	default:
+		return nil
+	}
+
+	return oops.
+		Code("CONFIG_INVALID").
+		With("source", sourceName).
+		With("field", field).
+		Hint(hint).
+		Errorf("%s is only supported for url sources, not %s source %q", field, sourceCfg.Type, sourceName)
+}
+
+// validateGitVersion checks the semver constraint a git hosting source
+// picks its tag with; it replaces ref rather than combining with it.
+func validateGitVersion(sourceName string, sourceCfg Source) error {
+	if sourceCfg.Version == "" || !isGitSource(sourceCfg.Type) {
+		return nil
+	}
+
+	if sourceCfg.Ref != "" {
+		return oops.
+			Code("CONFIG_INVALID").
+			With("source", sourceName).
+			With("field", "version").
+			Hint("Use ref for a fixed branch, tag, or commit, or version to follow matching tags").
+			Errorf("source %q sets both 'ref' and 'version'", sourceName)
+	}
+
+	if _, err := semver.NewConstraint(sourceCfg.Version); err != nil {
+		return oops.
+			Code("CONFIG_INVALID").
+			With("source", sourceName).
+			With("field", "version").
+			With("value", sourceCfg.Version).
+			Hint("Use a semver range like \"^5.0\" or \"~1.4.2\"").
+			Wrapf(err, "invalid version %q for source %q", sourceCfg.Version, sourceName)
+	}
+
+	return nil
+}
+
+// validateVersions checks the versions list of a source: each entry needs a
+// name that is safe as a directory and selects a ref or a package version
+// the way the source's own ref and version would.
+func (c *Config) validateVersions(sourceName string, sourceCfg Source) error {
+	if len(sourceCfg.Versions) == 0 {
+		return nil
+	}
+
+	if err := validateVersionsSource(sourceName, sourceCfg); err != nil {
+		return err
+	}
+
+	seen := make(map[string]struct{}, len(sourceCfg.Versions))
+	for _, entry := range sourceCfg.Versions {
+		if err := validateVersionName(sourceName, entry.Name); err != nil {
+			return err
+		}
+
+		if _, exists := seen[entry.Name]; exists {
+			return oops.
+				Code("CONFIG_INVALID").
+				With("source", sourceName).
+				With("field", "versions").
+				With("value", entry.Name).
+				Errorf("source %q lists version %q more than once", sourceName, entry.Name)
+		}
+		seen[entry.Name] = struct{}{}
+
+		targetName := TargetName(sourceName, entry.Name)
+		if _, exists := c.Sources[targetName]; exists {
+			return oops.
+				Code("CONFIG_INVALID").
+				With("source", sourceName).
+				With("field", "versions").
+				With("value", entry.Name).
+				Hint("Rename the source or the version").
+				Errorf("version %q of source %q clashes with source %q", entry.Name, sourceName, targetName)
+		}
+
+		if err := validateVersionSelector(sourceName, sourceCfg, entry); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validateVersionsSource checks that a source listing versions is of a type
+// that has versions and does not also pin its own ref or version.
+func validateVersionsSource(sourceName string, sourceCfg Source) error {
+	switch sourceCfg.Type {
+	case sourceTypeNPM, sourceTypePyPI, sourceTypeGoMod:
+	default:
+		if !isGitSource(sourceCfg.Type) {
+			return oops.
+				Code("CONFIG_INVALID").
+				With("source", sourceName).
+				With("field", "versions").
+				Hint("versions is supported by git hosting, npm, pypi, and gomod sources").
+				Errorf("versions is not supported for %s source %q", sourceCfg.Type, sourceName)
+		}
+	}
+
+	if sourceCfg.Ref != "" || sourceCfg.Version != "" {
+		return oops.
+			Code("CONFIG_INVALID").
+			With("source", sourceName).
+			With("field", "versions").
+			Hint("Set the ref or version of each entry in versions instead").
+			Errorf("source %q sets 'versions' together with 'ref' or 'version'", sourceName)
+	}
+
+	return nil
+}
+
+// validateVersionName checks that a version name can be used as the name of
+// the subdirectory it syncs into and as the suffix of its collection name.
+func validateVersionName(sourceName string, name string) error {
+	if name != "" && name != "." && name != ".." &&
+		strings.TrimSpace(name) == name && !strings.ContainsAny(name, `/\@`) {
+		return nil
+	}
+
+	return oops.
+		Code("CONFIG_INVALID").
+		With("source", sourceName).
+		With("field", "versions").
+		With("value", name).
+		Hint("Version names become directory names; use names like \"v5\" or \"1.x\" without slashes or '@'").
+		Errorf("invalid version name %q for source %q", name, sourceName)
+}
+
+// validateVersionSelector checks the ref or version a versions entry picks.
+func validateVersionSelector(sourceName string, sourceCfg Source, entry SourceVersion) error {
+	if entry.Ref != "" && !isGitSource(sourceCfg.Type) {
+		return oops.
+			Code("CONFIG_INVALID").
+			With("source", sourceName).
+			With("field", "versions").
+			With("value", entry.Name).
+			Hint("Package sources select releases with version").
+			Errorf("version %q of %s source %q sets a ref", entry.Name, sourceCfg.Type, sourceName)
+	}
+
+	targetCfg := sourceCfg
+	targetCfg.Ref = entry.Ref
+	targetCfg.Version = entry.Version
+
+	return validateGitVersion(TargetName(sourceName, entry.Name), targetCfg)
+}
+
+// validateURLAuth checks that the auth table of a url source names a type
+// and carries the credentials that type needs.
+func validateURLAuth(sourceName string, auth URLAuth) error {
+	if auth == (URLAuth{}) {
+		return nil
+	}
+
+	invalid := func(hint string, format string, args ...any) error {
+		return oops.
+			Code("CONFIG_INVALID").
+			With("source", sourceName).
+			With("field", "auth").
+			Hint(hint).
+			Errorf(format, args...)
+	}
+
+	switch {
+	case auth.Type != "basic" && auth.Type != "bearer" && auth.Type != "header":
+		return invalid("Supported auth types: basic, bearer, header",
+			"invalid auth type %q for source %q", auth.Type, sourceName)
+	case auth.Token != "" && auth.TokenEnv != "", auth.Password != "" && auth.PasswordEnv != "":
+		return invalid(
+			"Use either the literal secret or the *_env variable, not both",
+			"source %q sets a secret in auth both literally and from the environment", sourceName)
+	case auth.Type == "basic" && auth.Username == "":
+		return invalid("Set auth.username for basic auth", "source %q has basic auth without a username", sourceName)
+	case auth.Type != "basic" && auth.Token == "" && auth.TokenEnv == "":
+		return invalid(
+			"Set auth.token_env to the environment variable holding the token",
+			"source %q has %s auth without a token", sourceName, auth.Type)
+	case auth.Type == "header" && auth.Header == "":
+		return invalid(
+			"Set auth.header to the header carrying the token, e.g. \"X-API-Key\"",
+			"source %q has header auth without a header name", sourceName)
+	}
+
+	return nil
+}
+
+// validateCache checks the cache table.
+func validateCache(v *validator.Validate, cache Cache) error {
+	err := v.Struct(cache)
+	if err == nil {
+		return nil
+	}
+
+	return oops.
+		Code("CONFIG_INVALID").
+		With("field", "cache").
+		With("mode", cache.Mode).
+		Hint("Set cache.mode to hardlink or copy and cache.max_size to a byte count").
+		Wrapf(err, "validating cache config")
+}
+
+// validateCredentials checks that every [[credentials]] entry names one
+// bare host, at most once, and exactly one place to read its token from.
+func validateCredentials(credentials []Credential) error {
+	seen := make(map[string]bool, len(credentials))
+	for index, credential := range credentials {
+		invalid := func(hint string, format string, args ...any) error {
+			return oops.
+				Code("CONFIG_INVALID").
+				With("field", "credentials").
+				With("host", credential.Host).
+				Hint(hint).
+				Errorf(format, args...)
+		}
+
+		host := strings.ToLower(credential.Host)
+		sources := 0
+		for _, set := range []bool{credential.Token != "", credential.TokenEnv != "", credential.Helper != ""} {
+			if set {
+				sources++
+			}
+		}
+
+		switch {
+		case host == "":
+			return invalid("Set host to the host name the token is for, e.g. \"github.example.com\"",
+				"credentials entry %d has no host", index+1)
+		case strings.ContainsAny(host, "/@") || strings.Contains(host, "://"):
+			return invalid("Use the bare host name, without scheme, user, or path",
+				"invalid credentials host %q", credential.Host)
+		case seen[host]:
+			return invalid("Keep one credentials entry per host",
+				"duplicate credentials entry for host %q", credential.Host)
+		case credential.Helper != "" && credential.Helper != "git" && credential.Helper != "netrc":
+			return invalid("Supported helpers: git, netrc",
+				"invalid credentials helper %q for host %q", credential.Helper, credential.Host)
+		case sources != 1:
+			return invalid("Set exactly one of token, token_env, or helper",
+				"credentials entry for host %q must set exactly one of token, token_env, or helper", credential.Host)
+		}
+
+		seen[host] = true
+	}
+
+	return nil
+}
+
+// validateURLList checks a url source that downloads the files listed in
+// urls instead of a single url.
+func validateURLList(sourceName string, sourceCfg Source) error {
+	if sourceCfg.URL != "" || sourceCfg.Repo != "" {
+		return oops.
+			Code("CONFIG_INVALID").
+			With("source", sourceName).
+			Hint("List every file in 'urls', or use 'url' for a single file").
+			Errorf("source %q has both 'urls' and 'url' or 'repo'", sourceName)
+	}
+
+	if sourceCfg.SHA256 != "" {
+		return oops.
+			Code("CONFIG_INVALID").
+			With("source", sourceName).
+			With("field", "sha256").
+			Hint("Pin each file with the sha256 of its urls entry").
+			Errorf("source %q sets sha256 next to 'urls'", sourceName)
+	}
+
+	if sourceCfg.Expand {
+		return oops.
+			Code("CONFIG_INVALID").
+			With("source", sourceName).
+			With("field", "expand").
+			Hint("expand reads a single llms.txt index; set it with 'url' instead of 'urls'").
+			Errorf("source %q cannot expand 'urls'", sourceName)
+	}
+
+	return nil
+}
+
+// validateSiteLocation checks that a site source starts from a root URL, a
+// sitemap, or both.
+func validateSiteLocation(sourceName string, sourceCfg Source) error {
+	if sourceCfg.URL == "" && sourceCfg.Sitemap == "" {
+		return oops.
+			Code("CONFIG_INVALID").
+			With("source", sourceName).
+			With("field", "url").
+			Hint("Set url to the page the crawl starts from, or sitemap to the site's sitemap.xml").
+			Errorf("missing 'url' for site source %q", sourceName)
+	}
+
+	if sourceCfg.Repo != "" {
+		return oops.
+			Code("CONFIG_INVALID").
+			With("source", sourceName).
+			Hint("site sources are located by 'url' or 'sitemap'; remove 'repo'").
+			Errorf("site source %q has 'repo'", sourceName)
+	}
+
+	return nil
+}
+
+// validateOwnLocation checks sources that are located by a field of their
+// own instead of repo or url.
+func validateOwnLocation(sourceName string, sourceCfg Source, field string, value string, hint string) error {
+	if strings.TrimSpace(value) == "" {
+		return oops.
+			Code("CONFIG_INVALID").
+			With("source", sourceName).
+			With("field", field).
+			Hint(hint).
+			Errorf("missing '%s' for %s source %q", field, sourceCfg.Type, sourceName)
+	}
+
+	if sourceCfg.Repo != "" || sourceCfg.URL != "" {
+		return oops.
+			Code("CONFIG_INVALID").
+			With("source", sourceName).
+			Hint(fmt.Sprintf("%s sources are located by '%s'; remove 'repo' and 'url'", sourceCfg.Type, field)).
+			Errorf("%s source %q has 'repo' or 'url'", sourceCfg.Type, sourceName)
+	}
+
+	return nil
+}
+
 func mapValidationError(sourceName string, sourceCfg Source, fe validator.FieldError) error {
 	field := strings.ToLower(fe.Field())
+	value := fe.Value()
+	if text, ok := value.(string); ok {
+		value = RedactURL(text)
+	}
 
 	switch {
+	case strings.Contains(fe.Namespace(), ".URLs["):
+		return oops.
+			Code("CONFIG_INVALID").
+			With("source", sourceName).
+			With("field", "urls").
+			With("value", value).
+			Hint("Each urls entry needs an HTTP/HTTPS url and may pin a lowercase hex sha256").
+			Errorf("invalid urls entry %q for source %q", value, sourceName)
+
 	case fe.Tag() == "oneof" && field == "type":
 		return oops.
 			Code("UNKNOWN_SOURCE_TYPE").
 			With("source", sourceName).
 			With("type", sourceCfg.Type).
-			Hint("Supported types: github, gitlab, codeberg, git, url (or omit 'type' to infer)").
+			Hint("Supported types: github, gitlab, codeberg, gitea, forgejo, git, url, local, archive, npm, gomod, pypi, site (or omit 'type' to infer)").
 			Errorf("unknown source type %q for source %q", sourceCfg.Type, sourceName)
 
+	case strings.Contains(fe.Namespace(), ".HTTP."):
+		key := fe.Field()
+		if structField, ok := reflect.TypeFor[HTTP]().FieldByName(fe.StructField()); ok {
+			key = structField.Tag.Get("koanf")
+		}
+
+		return oops.
+			Code("CONFIG_INVALID").
+			With("source", sourceName).
+			With("field", "http."+key).
+			With("value", value).
+			Hint("proxy must be a URL, timeouts must not be negative, and max_retries must be between 0 and 10").
+			Errorf("invalid http.%s %v for source %q", key, value, sourceName)
+
+	case field == "sha256":
+		return oops.
+			Code("CONFIG_INVALID").
+			With("source", sourceName).
+			With("field", "sha256").
+			With("value", sourceCfg.SHA256).
+			Hint("sha256 is the lowercase hex SHA-256 of the file, e.g. the output of 'sha256sum'").
+			Errorf("invalid sha256 %q for source %q", sourceCfg.SHA256, sourceName)
+
+	case fe.Tag() == "oneof" && field == "download":
+		return oops.
+			Code("CONFIG_INVALID").
+			With("source", sourceName).
+			With("field", "download").
+			With("value", sourceCfg.Download).
+			Hint("Supported download strategies: auto, api, raw, tarball").
+			Errorf("invalid download strategy %q for source %q", sourceCfg.Download, sourceName)
+
+	case fe.Tag() == "oneof" && field == "mode":
+		return oops.
+			Code("CONFIG_INVALID").
+			With("source", sourceName).
+			With("field", "mode").
+			With("value", sourceCfg.Mode).
+			Hint("Supported modes: copy, hardlink, symlink").
+			Errorf("invalid mode %q for source %q", sourceCfg.Mode, sourceName)
+
+	case fe.Tag() == "oneof" && field == "dist":
+		return oops.
+			Code("CONFIG_INVALID").
+			With("source", sourceName).
+			With("field", "dist").
+			With("value", sourceCfg.Dist).
+			Hint("Supported distributions: auto, sdist, wheel").
+			Errorf("invalid dist %q for source %q", sourceCfg.Dist, sourceName)
+
 	case fe.Tag() == "github_repo":
 		return oops.
 			Code("CONFIG_INVALID").
 			With("source", sourceName).
 			With("field", "repo").
 			With("value", sourceCfg.Repo).
-			Hint("Expected repo format: owner/repo").
+			Hint("Expected repo format: owner/repo (group/subgroup/project for gitlab)").
 			Errorf("invalid repo format %q for source %q", sourceCfg.Repo, sourceName)
 
-	case fe.Tag() == "url" && field == "url":
+	case fe.Tag() == "source_url" && field == "url":
 		return oops.
 			Code("CONFIG_INVALID").
 			With("source", sourceName).
 			With("field", "url").
-			With("value", sourceCfg.URL).
+			With("value", RedactURL(sourceCfg.URL)).
 			Hint("URL must be a valid HTTP/HTTPS URL").
-			Errorf("invalid url %q for source %q", sourceCfg.URL, sourceName)
+			Errorf("invalid url %q for source %q", RedactURL(sourceCfg.URL), sourceName)
+
+	case fe.Tag() == "url" && field == "registry":
+		return oops.
+			Code("CONFIG_INVALID").
+			With("source", sourceName).
+			With("field", "registry").
+			With("value", RedactURL(sourceCfg.Registry)).
+			Hint("Registry must be an HTTP/HTTPS URL such as https://registry.npmjs.org or https://pypi.org/pypi").
+			Errorf("invalid registry %q for source %q", RedactURL(sourceCfg.Registry), sourceName)
+
+	case fe.Tag() == "url" && field == "sitemap":
+		return oops.
+			Code("CONFIG_INVALID").
+			With("source", sourceName).
+			With("field", "sitemap").
+			With("value", RedactURL(sourceCfg.Sitemap)).
+			Hint("sitemap must be an HTTP/HTTPS URL such as https://example.com/sitemap.xml").
+			Errorf("invalid sitemap %q for source %q", RedactURL(sourceCfg.Sitemap), sourceName)
+
+	case fe.Tag() == "startswith" && strings.HasPrefix(field, "prefixes"):
+		return oops.
+			Code("CONFIG_INVALID").
+			With("source", sourceName).
+			With("field", "prefixes").
+			With("value", sourceCfg.Prefixes).
+			Hint("Prefixes are URL paths starting with '/', e.g. \"/docs/\"").
+			Errorf("invalid prefixes %v for source %q", sourceCfg.Prefixes, sourceName)
+
+	case fe.Tag() == "http_url" && field == "apiurl":
+		return oops.
+			Code("CONFIG_INVALID").
+			With("source", sourceName).
+			With("field", "api_url").
+			With("value", RedactURL(sourceCfg.APIURL)).
+			Hint("api_url must be an HTTP/HTTPS URL such as https://github.example.com/api/v3").
+			Errorf("invalid api_url %q for source %q", RedactURL(sourceCfg.APIURL), sourceName)
+
+	case fe.Tag() == "url" && field == "goproxy":
+		return oops.
+			Code("CONFIG_INVALID").
+			With("source", sourceName).
+			With("field", "goproxy").
+			With("value", RedactURL(sourceCfg.GoProxy)).
+			Hint("goproxy must be a module proxy URL such as https://proxy.golang.org or file:///path/to/proxy").
+			Errorf("invalid goproxy %q for source %q", RedactURL(sourceCfg.GoProxy), sourceName)
 
 	default:
 		return oops.
@@ -361,6 +1162,110 @@ func mapValidationError(sourceName string, sourceCfg Source, fe validator.FieldE
 	}
 }
 
+// resolveLocalPaths makes relative local repository paths of generic git
+// sources, local archive paths, directories of local sources, certificate
+// paths of http settings, and the cache directory relative to the config
+// file directory.
+func (c *Config) resolveLocalPaths() {
+	c.HTTP = c.HTTP.resolvePaths(c.ConfigDir)
+	if c.Cache.Dir != "" && !filepath.IsAbs(c.Cache.Dir) {
+		c.Cache.Dir = filepath.Clean(filepath.Join(c.ConfigDir, c.Cache.Dir))
+	}
+	for sourceName, sourceCfg := range c.Sources {
+		sourceCfg.HTTP = sourceCfg.HTTP.resolvePaths(c.ConfigDir)
+		c.Sources[sourceName] = sourceCfg
+
+		if sourceCfg.Type == sourceTypeLocal && sourceCfg.Dir != "" && !filepath.IsAbs(sourceCfg.Dir) {
+			sourceCfg.Dir = filepath.Clean(filepath.Join(c.ConfigDir, sourceCfg.Dir))
+			c.Sources[sourceName] = sourceCfg
+			continue
+		}
+
+		if (sourceCfg.Type != sourceTypeGit && sourceCfg.Type != sourceTypeArchive) || !IsLocalURL(sourceCfg.URL) {
+			continue
+		}
+		if strings.HasPrefix(sourceCfg.URL, "file://") || filepath.IsAbs(sourceCfg.URL) {
+			continue
+		}
+
+		sourceCfg.URL = filepath.Clean(filepath.Join(c.ConfigDir, sourceCfg.URL))
+		c.Sources[sourceName] = sourceCfg
+	}
+}
+
+// resolvePaths makes the relative certificate paths of h relative to dir.
+func (h HTTP) resolvePaths(dir string) HTTP {
+	for _, path := range []*string{&h.CABundle, &h.ClientCert, &h.ClientKey} {
+		if *path != "" && !filepath.IsAbs(*path) {
+			*path = filepath.Clean(filepath.Join(dir, *path))
+		}
+	}
+
+	return h
+}
+
+// IsLocalURL reports whether a git clone URL or archive location refers to
+// the local filesystem, either as a file:// URL or as a plain path.
+func IsLocalURL(rawURL string) bool {
+	if strings.HasPrefix(rawURL, "file://") {
+		return true
+	}
+	if strings.Contains(rawURL, "://") {
+		return false
+	}
+
+	// scp-like syntax (git@host:owner/repo.git) is a remote ssh URL
+	colon := strings.Index(rawURL, ":")
+	return colon < 0 || strings.ContainsAny(rawURL[:colon], `/\`) || filepath.VolumeName(rawURL) != ""
+}
+
+// RedactURL hides the credentials a URL may carry before it is reported in
+// an error: the user info of http(s) URLs, any user info with a password,
+// and the values of query parameters that look like secrets. Anything that
+// does not parse as a URL is returned unchanged.
+func RedactURL(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.Host == "" {
+		return rawURL
+	}
+
+	if parsed.User != nil {
+		_, hasPassword := parsed.User.Password()
+		if hasPassword || parsed.Scheme == "http" || parsed.Scheme == "https" {
+			parsed.User = url.User(redacted)
+		}
+	}
+
+	if parsed.RawQuery != "" {
+		query := parsed.Query()
+		changed := false
+		for key := range query {
+			if isSecretParam(key) {
+				query.Set(key, redacted)
+				changed = true
+			}
+		}
+		if changed {
+			parsed.RawQuery = query.Encode()
+		}
+	}
+
+	return parsed.String()
+}
+
+const redacted = "xxxxx"
+
+func isSecretParam(key string) bool {
+	key = strings.ToLower(key)
+	for _, marker := range []string{"token", "key", "secret", "password", "sig", "auth"} {
+		if strings.Contains(key, marker) {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (c *Config) OutputDir(sourceName string, sourceCfg Source) string {
 	baseOutputDir := c.Output
 	if !filepath.IsAbs(baseOutputDir) {
@@ -374,11 +1279,52 @@ func (c *Config) OutputDir(sourceName string, sourceCfg Source) string {
 	return filepath.Join(baseOutputDir, sourceName)
 }
 
-func isValidRepo(repo string) bool {
+// TargetName is the lock entry and collection name of one version of a
+// source.
+func TargetName(sourceName string, version string) string {
+	return sourceName + "@" + version
+}
+
+// Targets returns what a sync of the source fetches: the source itself, or
+// one target per entry of its versions list. A version syncs into its own
+// subdirectory of the source's output directory.
+func (c *Config) Targets(sourceName string) []Target {
+	sourceCfg := c.Sources[sourceName]
+	if len(sourceCfg.Versions) == 0 {
+		return []Target{{Name: sourceName, Source: sourceName, Config: sourceCfg}}
+	}
+
+	dirName := sourceName
+	if sourceCfg.Out != "" {
+		dirName = sourceCfg.Out
+	}
+
+	targets := make([]Target, 0, len(sourceCfg.Versions))
+	for _, entry := range sourceCfg.Versions {
+		targetCfg := sourceCfg
+		targetCfg.Ref = entry.Ref
+		targetCfg.Version = entry.Version
+		targetCfg.Out = filepath.Join(dirName, entry.Name)
+		targetCfg.Versions = nil
+
+		targets = append(targets, Target{
+			Name:    TargetName(sourceName, entry.Name),
+			Source:  sourceName,
+			Version: entry.Name,
+			Config:  targetCfg,
+		})
+	}
+
+	return targets
+}
+
+// isValidRepo reports whether repo is an owner/repo path. GitLab projects
+// may sit in nested subgroups, so they take any number of segments past two.
+func isValidRepo(repo string, sourceType string) bool {
 	parts := strings.Split(repo, "/")
-	if len(parts) != repoPartCount {
+	if len(parts) < repoPartCount || (len(parts) > repoPartCount && sourceType != sourceTypeGitLab) {
 		return false
 	}
 
-	return parts[0] != "" && parts[1] != ""
+	return !slices.Contains(parts, "")
 }
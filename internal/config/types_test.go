@@ -2,10 +2,12 @@
 package config
 
 import (
+	"path/filepath"
 	"reflect"
 	"slices"
 	"sort"
 	"testing"
+	"time"
 )
 
 func TestMergeExcludes(t *testing.T) {
@@ -150,6 +152,22 @@ func TestApplyDefaultsTypeInference(t *testing.T) {
 			wantType: "url",
 			wantHost: "",
 		},
+		{
+			name: "infer url from urls field",
+			source: Source{
+				URLs: []URLFile{{URL: "https://example.com/doc.txt"}},
+			},
+			wantType: "url",
+			wantHost: "",
+		},
+		{
+			name: "infer local from dir field",
+			source: Source{
+				Dir: "../design-docs",
+			},
+			wantType: "local",
+			wantHost: "",
+		},
 		{
 			name: "explicit type kept",
 			source: Source{
@@ -158,7 +176,7 @@ func TestApplyDefaultsTypeInference(t *testing.T) {
 				Path: "docs",
 			},
 			wantType: "gitlab",
-			wantHost: "github.com",
+			wantHost: "gitlab.com",
 		},
 		{
 			name: "explicit host kept",
@@ -237,6 +255,39 @@ func TestApplyDefaultsTypeInference(t *testing.T) {
 	}
 }
 
+func TestApplyDefaultsNPMPatterns(t *testing.T) {
+	cfg := &Config{
+		Sources: map[string]Source{
+			"zod": {Type: "npm", Package: "zod"},
+		},
+	}
+
+	cfg.ApplyDefaults()
+
+	if got := cfg.Sources["zod"].Patterns; !slices.Contains(got, "**/*.d.ts") || !slices.Contains(got, "**/*.md") {
+		t.Fatalf("Patterns = %v, want docs and typings", got)
+	}
+}
+
+func TestApplyDefaultsPyPIPatterns(t *testing.T) {
+	cfg := &Config{
+		Excludes: []string{"**/*.png"},
+		Sources: map[string]Source{
+			"requests": {Type: "pypi", Package: "requests"},
+		},
+	}
+
+	cfg.ApplyDefaults()
+
+	got := cfg.Sources["requests"]
+	if !slices.Contains(got.Patterns, "**/*.rst") || !slices.Contains(got.Patterns, "README*") {
+		t.Fatalf("Patterns = %v, want reStructuredText docs and the README", got.Patterns)
+	}
+	if !slices.Contains(got.Exclude, "**/*.png") {
+		t.Fatalf("Exclude = %v, want global excludes merged", got.Exclude)
+	}
+}
+
 func TestApplyDefaultsPatterns(t *testing.T) {
 	cfg := &Config{
 		Sources: map[string]Source{
@@ -422,3 +473,135 @@ func TestValidateDisplayFormat(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateGitSourceURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  Source
+		wantErr bool
+	}{
+		{
+			name:   "git source with local path",
+			source: Source{Type: "git", URL: "../mirrors/docs.git", Path: "docs"},
+		},
+		{
+			name:   "git source with file url",
+			source: Source{Type: "git", URL: "file:///srv/git/docs.git", Path: "docs"},
+		},
+		{
+			name:    "git source without path",
+			source:  Source{Type: "git", URL: "https://git.example.com/docs.git"},
+			wantErr: true,
+		},
+		{
+			name:    "url source with local path",
+			source:  Source{Type: "url", URL: "../docs.md"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{Sources: map[string]Source{"test": tt.source}}
+			cfg.ApplyDefaults()
+
+			if got := cfg.Sources["test"].Type; got != "git" && tt.source.Type == "git" {
+				t.Fatalf("Type = %q, want git", got)
+			}
+
+			err := cfg.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestApplyDefaultsMergesHTTPSettings(t *testing.T) {
+	globalRetries, sourceRetries := 5, 0
+	cfg := &Config{
+		HTTP: HTTP{
+			Proxy:      "http://proxy.corp:3128",
+			NoProxy:    []string{"internal.corp"},
+			CABundle:   "certs/corp.pem",
+			ClientCert: "certs/client.pem",
+			ClientKey:  "certs/client.key",
+			Timeout:    time.Minute,
+			MaxRetries: &globalRetries,
+		},
+		Sources: map[string]Source{
+			"inherits": {Type: "url", URL: "https://example.com/doc.md"},
+			"overrides": {
+				Type: "url",
+				URL:  "https://example.com/doc.md",
+				HTTP: HTTP{
+					Proxy:      "http://ci-proxy:8080",
+					ClientCert: "/etc/ci/client.pem",
+					ClientKey:  "/etc/ci/client.key",
+					MaxRetries: &sourceRetries,
+				},
+			},
+		},
+		ConfigDir: "/project",
+	}
+
+	cfg.ApplyDefaults()
+	cfg.resolveLocalPaths()
+
+	inherited := cfg.Sources["inherits"].HTTP
+	if inherited.Proxy != "http://proxy.corp:3128" || inherited.Timeout != time.Minute ||
+		*inherited.MaxRetries != 5 || inherited.CABundle != filepath.Join("/project", "certs/corp.pem") {
+		t.Errorf("inherited HTTP = %+v, want the global settings", inherited)
+	}
+
+	overridden := cfg.Sources["overrides"].HTTP
+	want := HTTP{
+		Proxy:      "http://ci-proxy:8080",
+		NoProxy:    []string{"internal.corp"},
+		CABundle:   filepath.Join("/project", "certs/corp.pem"),
+		ClientCert: "/etc/ci/client.pem",
+		ClientKey:  "/etc/ci/client.key",
+		Timeout:    time.Minute,
+		MaxRetries: &sourceRetries,
+	}
+	if !reflect.DeepEqual(overridden, want) {
+		t.Errorf("overridden HTTP = %+v, want %+v", overridden, want)
+	}
+}
+
+func TestResolveLocalPaths(t *testing.T) {
+	cfg := &Config{
+		ConfigDir: "/project",
+		Sources: map[string]Source{
+			"relative": {Type: "git", URL: "mirrors/docs.git", Path: "docs"},
+			"file":     {Type: "git", URL: "file:///srv/docs.git", Path: "docs"},
+			"remote":   {Type: "git", URL: "git@example.com:org/docs.git", Path: "docs"},
+			"design":   {Type: "local", Dir: "../design/docs"},
+			"absolute": {Type: "local", Dir: "/srv/handbook"},
+			"archive":  {Type: "archive", URL: "dist/site.zip"},
+			"download": {Type: "archive", URL: "https://example.com/site.zip"},
+		},
+	}
+
+	cfg.resolveLocalPaths()
+
+	if got, want := cfg.Sources["design"].Dir, filepath.Join("/", "design/docs"); got != want {
+		t.Errorf("Sources[design].Dir = %q, want %q", got, want)
+	}
+	if got := cfg.Sources["absolute"].Dir; got != "/srv/handbook" {
+		t.Errorf("Sources[absolute].Dir = %q, want %q", got, "/srv/handbook")
+	}
+
+	want := map[string]string{
+		"relative": filepath.Join("/project", "mirrors/docs.git"),
+		"file":     "file:///srv/docs.git",
+		"remote":   "git@example.com:org/docs.git",
+		"archive":  filepath.Join("/project", "dist/site.zip"),
+		"download": "https://example.com/site.zip",
+	}
+	for name, wantURL := range want {
+		if got := cfg.Sources[name].URL; got != wantURL {
+			t.Errorf("Sources[%q].URL = %q, want %q", name, got, wantURL)
+		}
+	}
+}
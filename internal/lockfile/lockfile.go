@@ -21,13 +21,33 @@ type LockFile struct {
 }
 
 type LockEntry struct {
-	Type        string            `json:"type"`
-	TreeSHA     string            `json:"tree_sha,omitempty"`
-	RefResolved string            `json:"ref_resolved,omitempty"`
-	ETag        string            `json:"etag,omitempty"`
-	LastMod     string            `json:"last_modified,omitempty"`
-	SyncedAt    time.Time         `json:"synced_at"`
-	Files       map[string]string `json:"files,omitempty"`
+	Type        string                `json:"type"`
+	TreeSHA     string                `json:"tree_sha,omitempty"`
+	RefResolved string                `json:"ref_resolved,omitempty"`
+	Ref         string                `json:"ref,omitempty"`
+	ETag        string                `json:"etag,omitempty"`
+	LastMod     string                `json:"last_modified,omitempty"`
+	Digest      string                `json:"digest,omitempty"`
+	ConfigHash  string                `json:"config_hash,omitempty"`
+	SyncedAt    time.Time             `json:"synced_at"`
+	Files       map[string]string     `json:"files,omitempty"`
+	Pages       map[string]*PageEntry `json:"pages,omitempty"`
+}
+
+// PageEntry records a page fetched by a site source or listed in an
+// expanded llms.txt index, keyed by its URL in LockEntry.Pages: where the
+// page was written and the validators for fetching it conditionally. Site
+// pages keep the in-scope links they contained so an unchanged page still
+// leads the crawl to its children; llms.txt pages keep the section, title,
+// and description the index gives them.
+type PageEntry struct {
+	Path        string   `json:"path"`
+	ETag        string   `json:"etag,omitempty"`
+	LastMod     string   `json:"last_modified,omitempty"`
+	Links       []string `json:"links,omitempty"`
+	Section     string   `json:"section,omitempty"`
+	Title       string   `json:"title,omitempty"`
+	Description string   `json:"description,omitempty"`
 }
 
 func Load(outputDir string) (*LockFile, error) {
@@ -143,6 +163,17 @@ func (l *LockFile) Save(outputDir string) error {
 	return nil
 }
 
+// Commit returns the commit SHA a git source synced. Git sources record
+// the branch or tag they resolved in Ref and its commit in RefResolved;
+// package sources record their version in RefResolved and leave Ref empty.
+func (e *LockEntry) Commit() string {
+	if e == nil || e.Ref == "" {
+		return ""
+	}
+
+	return e.RefResolved
+}
+
 func (l *LockFile) GetEntry(name string) *LockEntry {
 	if l == nil {
 		return nil
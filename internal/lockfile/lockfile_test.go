@@ -180,3 +180,22 @@ func TestSaveOnNilLockReturnsError(t *testing.T) {
 		t.Fatalf("Save() error = %q, expected nil-lock message", err.Error())
 	}
 }
+
+func TestEntryCommit(t *testing.T) {
+	t.Parallel()
+
+	var missing *lockfile.LockEntry
+	if got := missing.Commit(); got != "" {
+		t.Fatalf("Commit() on nil entry = %q, want empty", got)
+	}
+
+	git := &lockfile.LockEntry{Type: "github", Ref: "main", RefResolved: "4f2a9c1e0b7d3a5f6c8e9d0b1a2c3e4f5a6b7c8d"}
+	if got := git.Commit(); got != git.RefResolved {
+		t.Fatalf("Commit() = %q, want %q", got, git.RefResolved)
+	}
+
+	npm := &lockfile.LockEntry{Type: "npm", RefResolved: "1.4.2"}
+	if got := npm.Commit(); got != "" {
+		t.Fatalf("Commit() for package entry = %q, want empty", got)
+	}
+}
@@ -30,64 +30,98 @@ func Generate(_ context.Context, cfg *config.Config, lock *lockfile.LockFile) er
 		parser.NewTypeScriptParser(),
 	}
 
-	for sourceName, sourceCfg := range cfg.Sources {
-		sourceDir := resolveSourceDir(outputDir, sourceName, sourceCfg)
+	for sourceName := range cfg.Sources {
+		for _, target := range cfg.Targets(sourceName) {
+			collection, err := generateCollection(outputDir, target, lock, parsers)
+			if err != nil {
+				return err
+			}
 
-		if _, err := os.Stat(sourceDir); os.IsNotExist(err) {
-			continue
+			if collection != nil {
+				m.Collections[target.Name] = collection
+			}
 		}
+	}
 
-		dirName := sourceName
-		if sourceCfg.Out != "" {
-			dirName = sourceCfg.Out
-		}
+	return m.Save(outputDir)
+}
 
-		collection := &Collection{
-			Name:     sourceName,
-			Dir:      dirName,
-			Type:     sourceCfg.Type,
-			Source:   resolveSourceLocation(sourceCfg),
-			Path:     sourceCfg.Path,
-			Ref:      sourceCfg.Ref,
-			LastSync: resolveLastSync(lock, sourceName),
-		}
+// generateCollection indexes the files synced for a target. It returns nil
+// when the target has not been synced yet.
+func generateCollection(
+	outputDir string,
+	target config.Target,
+	lock *lockfile.LockFile,
+	parsers []parser.Parser,
+) (*Collection, error) {
+	sourceCfg := target.Config
+	sourceDir := resolveSourceDir(outputDir, target.Name, sourceCfg)
+
+	if _, err := os.Stat(sourceDir); os.IsNotExist(err) {
+		return nil, nil //nolint:nilnil // unsynced targets have no collection
+	}
 
-		var skipped int
+	dirName := target.Name
+	if sourceCfg.Out != "" {
+		dirName = sourceCfg.Out
+	}
 
-		err := filepath.WalkDir(sourceDir, func(path string, d os.DirEntry, walkErr error) error {
-			if walkErr != nil || d.IsDir() {
-				return walkErr
-			}
+	entry := lock.GetEntry(target.Name)
+	ref := sourceCfg.Ref
+	if entry != nil && entry.Ref != "" {
+		ref = entry.Ref
+	}
 
-			if d.Name() == ManifestFile || d.Name() == ".dox.lock" {
-				return nil
-			}
+	collection := &Collection{
+		Name:     target.Name,
+		Dir:      filepath.ToSlash(dirName),
+		Type:     sourceCfg.Type,
+		Source:   resolveSourceLocation(sourceCfg),
+		Path:     sourceCfg.Path,
+		Ref:      ref,
+		Commit:   entry.Commit(),
+		Version:  target.Version,
+		LastSync: resolveLastSync(lock, target.Name),
+	}
 
-			relPath, _ := filepath.Rel(sourceDir, path)
-			fileInfo, parseErr := parseFile(path, relPath, parsers)
-			if parseErr != nil {
-				skipped++
-				return nil //nolint:nilerr // intentionally skip unparseable files (binary, etc.)
-			}
+	pages := indexedPages(lock, target.Name)
+	var skipped int
+
+	err := filepath.WalkDir(sourceDir, func(path string, d os.DirEntry, walkErr error) error {
+		if walkErr != nil || d.IsDir() {
+			return walkErr
+		}
 
-			collection.Files = append(collection.Files, *fileInfo)
-			collection.TotalSize += fileInfo.Size
+		if d.Name() == ManifestFile || d.Name() == ".dox.lock" {
 			return nil
-		})
+		}
 
-		if err != nil {
-			return oops.
-				Code("MANIFEST_GENERATION_ERROR").
-				With("source", sourceName).
-				Wrapf(err, "walking source directory")
+		relPath, _ := filepath.Rel(sourceDir, path)
+		fileInfo, parseErr := parseFile(path, relPath, parsers)
+		if parseErr != nil {
+			skipped++
+			return nil //nolint:nilerr // intentionally skip unparseable files (binary, etc.)
 		}
 
-		collection.FileCount = len(collection.Files)
-		collection.Skipped = skipped
-		m.Collections[sourceName] = collection
+		if page, ok := pages[filepath.ToSlash(relPath)]; ok {
+			applyIndexEntry(fileInfo, page)
+		}
+
+		collection.Files = append(collection.Files, *fileInfo)
+		collection.TotalSize += fileInfo.Size
+		return nil
+	})
+
+	if err != nil {
+		return nil, oops.
+			Code("MANIFEST_GENERATION_ERROR").
+			With("source", target.Name).
+			Wrapf(err, "walking source directory")
 	}
 
-	return m.Save(outputDir)
+	collection.FileCount = len(collection.Files)
+	collection.Skipped = skipped
+	return collection, nil
 }
 
 func parseFile(absPath string, relPath string, parsers []parser.Parser) (*FileInfo, error) {
@@ -159,6 +193,21 @@ func resolveSourceLocation(src config.Source) string {
 	if src.URL != "" {
 		return src.URL
 	}
+	if src.Dir != "" {
+		return src.Dir
+	}
+	if src.Package != "" {
+		return src.Package
+	}
+	if src.Module != "" {
+		return src.Module
+	}
+	if src.Sitemap != "" {
+		return src.Sitemap
+	}
+	if len(src.URLs) > 0 {
+		return src.URLs[0].URL
+	}
 	return unknownFileType
 }
 
@@ -172,6 +221,38 @@ func countLines(content []byte) int {
 	return count + 1
 }
 
+// indexedPages returns the pages of a source that an index such as
+// llms.txt describes, keyed by their output path.
+func indexedPages(lock *lockfile.LockFile, sourceName string) map[string]*lockfile.PageEntry {
+	pages := map[string]*lockfile.PageEntry{}
+
+	entry := lock.GetEntry(sourceName)
+	if entry == nil {
+		return pages
+	}
+
+	for _, page := range entry.Pages {
+		if page.Section != "" || page.Title != "" || page.Description != "" {
+			pages[page.Path] = page
+		}
+	}
+
+	return pages
+}
+
+// applyIndexEntry prefers the section and description an index gives a
+// file over the ones parsed from its content.
+func applyIndexEntry(fileInfo *FileInfo, page *lockfile.PageEntry) {
+	fileInfo.Section = page.Section
+
+	switch {
+	case page.Description != "":
+		fileInfo.Description = page.Description
+	case page.Title != "":
+		fileInfo.Description = page.Title
+	}
+}
+
 func resolveLastSync(lock *lockfile.LockFile, sourceName string) time.Time {
 	if lock != nil {
 		if entry := lock.GetEntry(sourceName); entry != nil {
@@ -7,6 +7,7 @@ import (
 	"testing"
 
 	"github.com/g5becks/dox/internal/config"
+	"github.com/g5becks/dox/internal/lockfile"
 	"github.com/g5becks/dox/internal/manifest"
 )
 
@@ -160,3 +161,168 @@ func TestGenerate_MixedFileTypes(t *testing.T) {
 		}
 	}
 }
+
+func TestGenerate_UsesIndexDescriptions(t *testing.T) {
+	dir := t.TempDir()
+	sourceDir := filepath.Join(dir, "example")
+
+	if err := os.MkdirAll(filepath.Join(sourceDir, "api"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string][]byte{
+		"guide.md":         []byte("# Guide\n\nParsed description.\n"),
+		"api/reference.md": []byte("# API\n\nParsed description.\n"),
+		"notes.md":         []byte("# Notes\n\nParsed description.\n"),
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(sourceDir, filepath.FromSlash(name)), content, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg := &config.Config{
+		Output: dir,
+		Sources: map[string]config.Source{
+			"example": {
+				Type:   "url",
+				URL:    "https://example.test/docs/llms.txt",
+				Expand: true,
+			},
+		},
+	}
+
+	lock := lockfile.New()
+	lock.SetEntry("example", &lockfile.LockEntry{
+		Type: "url",
+		Pages: map[string]*lockfile.PageEntry{
+			"https://example.test/docs/guide.md": {
+				Path: "guide.md", Section: "Docs", Title: "Guide", Description: "Start here",
+			},
+			"https://example.test/docs/api/reference.md": {
+				Path: "api/reference.md", Section: "Docs", Title: "API reference",
+			},
+		},
+	})
+
+	if err := manifest.Generate(context.Background(), cfg, lock); err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+
+	m, err := manifest.Load(dir)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	want := map[string][2]string{
+		"guide.md":                           {"Docs", "Start here"},
+		filepath.Join("api", "reference.md"): {"Docs", "API reference"},
+		"notes.md":                           {"", "Notes - Parsed description."},
+	}
+	for _, file := range m.Collections["example"].Files {
+		expected, ok := want[file.Path]
+		if !ok {
+			t.Fatalf("unexpected file %q", file.Path)
+		}
+		if file.Section != expected[0] || file.Description != expected[1] {
+			t.Errorf("%s: section = %q, description = %q, want %q and %q",
+				file.Path, file.Section, file.Description, expected[0], expected[1])
+		}
+	}
+}
+
+func TestGenerate_VersionedSource(t *testing.T) {
+	dir := t.TempDir()
+
+	for _, version := range []string{"v4", "v5"} {
+		versionDir := filepath.Join(dir, "widgets", version)
+		if err := os.MkdirAll(versionDir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		content := []byte("# Widgets " + version + "\n")
+		if err := os.WriteFile(filepath.Join(versionDir, "index.md"), content, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg := &config.Config{
+		Output: dir,
+		Sources: map[string]config.Source{
+			"widgets": {
+				Type: "github",
+				Repo: "owner/widgets",
+				Versions: []config.SourceVersion{
+					{Name: "v4", Ref: "v4.2.0"},
+					{Name: "v5", Ref: "v5.0.0"},
+					{Name: "v6", Ref: "v6.0.0"},
+				},
+			},
+		},
+	}
+
+	if err := manifest.Generate(context.Background(), cfg, nil); err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+
+	m, err := manifest.Load(dir)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if len(m.Collections) != 2 {
+		t.Fatalf("Collections count = %d, want 2 (unsynced versions are skipped)", len(m.Collections))
+	}
+
+	coll := m.Collections["widgets@v5"]
+	if coll == nil {
+		t.Fatal("Collection 'widgets@v5' not found")
+	}
+
+	if coll.Version != "v5" || coll.Ref != "v5.0.0" || coll.Dir != "widgets/v5" {
+		t.Errorf("Collection = version %q ref %q dir %q, want v5 v5.0.0 widgets/v5", coll.Version, coll.Ref, coll.Dir)
+	}
+
+	if coll.FileCount != 1 || coll.Files[0].Path != "index.md" {
+		t.Errorf("Collection files = %+v, want index.md", coll.Files)
+	}
+}
+
+func TestGenerate_RecordsResolvedCommit(t *testing.T) {
+	dir := t.TempDir()
+	sourceDir := filepath.Join(dir, "widgets")
+	if err := os.MkdirAll(sourceDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(sourceDir, "index.md"), []byte("# Widgets\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{
+		Output: dir,
+		Sources: map[string]config.Source{
+			"widgets": {Type: "github", Repo: "owner/widgets", Version: "^5.0"},
+		},
+	}
+
+	commit := "4f2a9c1e0b7d3a5f6c8e9d0b1a2c3e4f5a6b7c8d"
+	lock := lockfile.New()
+	lock.SetEntry("widgets", &lockfile.LockEntry{Type: "github", RefResolved: commit, Ref: "v5.2.1"})
+
+	if err := manifest.Generate(context.Background(), cfg, lock); err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+
+	m, err := manifest.Load(dir)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	coll := m.Collections["widgets"]
+	if coll == nil {
+		t.Fatal("Collection 'widgets' not found")
+	}
+
+	if coll.Ref != "v5.2.1" || coll.Commit != commit {
+		t.Errorf("Collection ref = %q, commit = %q, want v5.2.1 and %q", coll.Ref, coll.Commit, commit)
+	}
+}
@@ -5,10 +5,13 @@ import (
 	"errors"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/samber/oops"
 
+	"github.com/g5becks/dox/internal/config"
 	"github.com/g5becks/dox/internal/parser"
 )
 
@@ -30,6 +33,8 @@ type Collection struct {
 	Source    string     `json:"source"`
 	Path      string     `json:"path,omitempty"`
 	Ref       string     `json:"ref,omitempty"`
+	Commit    string     `json:"commit,omitempty"`
+	Version   string     `json:"version,omitempty"`
 	LastSync  time.Time  `json:"last_sync"`
 	FileCount int        `json:"file_count"`
 	TotalSize int64      `json:"total_size"`
@@ -44,6 +49,7 @@ type FileInfo struct {
 	Lines         int                  `json:"lines"`
 	Modified      time.Time            `json:"modified"`
 	Description   string               `json:"description"`
+	Section       string               `json:"section,omitempty"`
 	ComponentType parser.ComponentType `json:"component_type,omitempty"`
 	Warning       string               `json:"warning,omitempty"`
 	Outline       *parser.Outline      `json:"outline,omitempty"`
@@ -155,6 +161,57 @@ func (m *Manifest) Save(outputDir string) error {
 	return nil
 }
 
+// Lookup finds a collection by name, or the given version of a source that
+// syncs several versions side by side.
+func (m *Manifest) Lookup(name string, version string) (*Collection, error) {
+	key := name
+	if version != "" {
+		key = config.TargetName(name, version)
+	}
+
+	if collection, ok := m.Collections[key]; ok {
+		return collection, nil
+	}
+
+	versions := m.versionsOf(name)
+	if len(versions) == 0 {
+		return nil, oops.
+			Code("COLLECTION_NOT_FOUND").
+			With("collection", key).
+			Hint("Run 'dox collections' to see available collections").
+			Errorf("collection %q not found", key)
+	}
+
+	hint := "Pass --version with one of: " + strings.Join(versions, ", ")
+	if version == "" {
+		return nil, oops.
+			Code("COLLECTION_NOT_FOUND").
+			With("collection", name).
+			Hint(hint).
+			Errorf("collection %q has several versions", name)
+	}
+
+	return nil, oops.
+		Code("COLLECTION_NOT_FOUND").
+		With("collection", name).
+		With("version", version).
+		Hint(hint).
+		Errorf("collection %q has no version %q", name, version)
+}
+
+// versionsOf returns the sorted version names synced for a source.
+func (m *Manifest) versionsOf(sourceName string) []string {
+	versions := []string{}
+	for name, collection := range m.Collections {
+		if collection.Version != "" && name == config.TargetName(sourceName, collection.Version) {
+			versions = append(versions, collection.Version)
+		}
+	}
+
+	slices.Sort(versions)
+	return versions
+}
+
 func Path(outputDir string) string {
 	return filepath.Join(outputDir, ManifestFile)
 }
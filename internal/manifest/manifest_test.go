@@ -174,3 +174,44 @@ func TestAtomicWrite(t *testing.T) {
 		t.Errorf("Manifest file should exist at %q", manifestPath)
 	}
 }
+
+func TestLookup(t *testing.T) {
+	m := manifest.New()
+	m.Collections["guide"] = &manifest.Collection{Name: "guide"}
+	m.Collections["widgets@v4"] = &manifest.Collection{Name: "widgets@v4", Version: "v4"}
+	m.Collections["widgets@v5"] = &manifest.Collection{Name: "widgets@v5", Version: "v5"}
+
+	tests := []struct {
+		name            string
+		collection      string
+		version         string
+		want            string
+		wantErrContains string
+	}{
+		{name: "plain collection", collection: "guide", want: "guide"},
+		{name: "version selector", collection: "widgets", version: "v5", want: "widgets@v5"},
+		{name: "full versioned name", collection: "widgets@v4", want: "widgets@v4"},
+		{name: "versioned without selector", collection: "widgets", wantErrContains: "has several versions"},
+		{name: "unknown version", collection: "widgets", version: "v6", wantErrContains: "has no version \"v6\""},
+		{name: "unknown collection", collection: "missing", wantErrContains: "not found"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := m.Lookup(tt.collection, tt.version)
+			if tt.wantErrContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErrContains) {
+					t.Fatalf("Lookup() error = %v, want %q", err, tt.wantErrContains)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Lookup() error = %v", err)
+			}
+			if got.Name != tt.want {
+				t.Errorf("Lookup() = %q, want %q", got.Name, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,285 @@
+package source
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/samber/oops"
+	"resty.dev/v3"
+
+	"github.com/g5becks/dox/internal/config"
+	"github.com/g5becks/dox/internal/lockfile"
+)
+
+const sourceTypeArchive = "archive"
+
+// archiveSource syncs documentation published as a release artifact: a
+// .tar.gz, .tar, or .zip archive downloaded over HTTP or read from disk.
+// Remote archives are fetched conditionally like url sources, and the
+// archive's SHA-256 skips extraction when its content did not change.
+type archiveSource struct {
+	name   string
+	source config.Source
+	client *resty.Client
+}
+
+func NewArchive(name string, cfg config.Source) (Source, error) {
+	client, err := newHTTPClient(name, cfg.HTTP)
+	if err != nil {
+		return nil, err
+	}
+
+	return &archiveSource{
+		name:   name,
+		source: cfg,
+		client: client,
+	}, nil
+}
+
+func (s *archiveSource) Close() error {
+	return s.client.Close()
+}
+
+// fetchedArchive is an archive stored in a local file.
+type fetchedArchive struct {
+	path    string
+	digest  string
+	etag    string
+	lastMod string
+	cleanup func()
+}
+
+func (s *archiveSource) Sync(
+	ctx context.Context,
+	destDir string,
+	prevLock *lockfile.LockEntry,
+	opts SyncOptions,
+) (*SyncResult, error) {
+	fetched, notModified, err := s.fetch(ctx, prevLock, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if notModified {
+		return skippedResult(prevLock, sourceTypeArchive, ""), nil
+	}
+	defer fetched.cleanup()
+
+	if !opts.Force && prevLock != nil && prevLock.Digest == fetched.digest {
+		result := skippedResult(prevLock, sourceTypeArchive, "")
+		result.LockEntry.ETag = fetched.etag
+		result.LockEntry.LastMod = fetched.lastMod
+		return result, nil
+	}
+
+	oldFiles := map[string]string{}
+	if prevLock != nil && prevLock.Files != nil {
+		oldFiles = prevLock.Files
+	}
+
+	extraction := newArchiveExtraction(s.name, s.source, destDir, oldFiles, opts)
+	deleted, err := extraction.run(ctx, fetched.path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SyncResult{
+		Downloaded: extraction.written,
+		Deleted:    deleted,
+		LockEntry: &lockfile.LockEntry{
+			Type:     sourceTypeArchive,
+			ETag:     fetched.etag,
+			LastMod:  fetched.lastMod,
+			Digest:   fetched.digest,
+			SyncedAt: time.Now().UTC(),
+			Files:    extraction.files,
+		},
+	}, nil
+}
+
+// fetch makes the archive available as a local file. Local archives are
+// used in place; remote archives are downloaded to a temporary file unless
+// the server reports them unchanged.
+func (s *archiveSource) fetch(
+	ctx context.Context,
+	prevLock *lockfile.LockEntry,
+	opts SyncOptions,
+) (*fetchedArchive, bool, error) {
+	limits := archiveLimitsFor(s.source)
+
+	if config.IsLocalURL(s.source.URL) {
+		localPath := localFilePath(s.source.URL)
+		digest, err := s.hashLocalArchive(localPath, limits.maxSize)
+		if err != nil {
+			return nil, false, err
+		}
+
+		return &fetchedArchive{path: localPath, digest: digest, cleanup: func() {}}, false, nil
+	}
+
+	request := s.client.R().
+		SetContext(ctx).
+		SetDoNotParseResponse(true)
+	if !opts.Force && prevLock != nil {
+		if prevLock.ETag != "" {
+			request.SetHeader("If-None-Match", prevLock.ETag)
+		}
+		if prevLock.LastMod != "" {
+			request.SetHeader("If-Modified-Since", prevLock.LastMod)
+		}
+	}
+
+	response, err := request.Get(s.source.URL)
+	if err != nil {
+		return nil, false, oops.
+			Code("DOWNLOAD_FAILED").
+			With("source", s.name).
+			With("url", config.RedactURL(s.source.URL)).
+			Wrapf(redactURLError(err), "downloading archive")
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode() == http.StatusNotModified && prevLock != nil {
+		return nil, true, nil
+	}
+
+	if !response.IsSuccess() {
+		return nil, false, oops.
+			Code("DOWNLOAD_FAILED").
+			With("source", s.name).
+			With("url", config.RedactURL(s.source.URL)).
+			With("status", response.StatusCode()).
+			Errorf("archive source returned non-success status %d", response.StatusCode())
+	}
+
+	fetched, err := spoolArchive(s.name, s.source.URL, response.Body, limits.maxSize)
+	if err != nil {
+		return nil, false, err
+	}
+
+	fetched.etag = response.Header().Get("ETag")
+	fetched.lastMod = response.Header().Get("Last-Modified")
+	return fetched, false, nil
+}
+
+// fetchArchiveCached returns the archive the shared cache holds under key,
+// or fetches it with download and adds it to the cache. A cached archive
+// is read in place and carries no digest.
+func fetchArchiveCached(
+	opts SyncOptions,
+	key string,
+	download func() (*fetchedArchive, error),
+) (*fetchedArchive, error) {
+	if objectPath, ok := opts.Cache.Path(key); ok {
+		return &fetchedArchive{path: objectPath, cleanup: func() {}}, nil
+	}
+
+	fetched, err := download()
+	if err != nil {
+		return nil, err
+	}
+
+	// The cache is best effort; an archive it cannot store is downloaded again.
+	_ = opts.Cache.Store(key, fetched.path)
+	return fetched, nil
+}
+
+// spoolArchive streams a downloaded archive to a temporary file, hashing it
+// on the way with SHA-256 and any extra hashers, and refuses archives larger
+// than maxSize.
+func spoolArchive(
+	sourceName string,
+	location string,
+	body io.Reader,
+	maxSize int64,
+	hashers ...hash.Hash,
+) (*fetchedArchive, error) {
+	tempFile, err := os.CreateTemp("", "dox-archive-*")
+	if err != nil {
+		return nil, oops.
+			Code("WRITE_FAILED").
+			With("source", sourceName).
+			Wrapf(err, "creating temporary archive file")
+	}
+
+	tempPath := tempFile.Name()
+	cleanup := func() { _ = os.Remove(tempPath) }
+
+	digest := sha256.New()
+	writers := []io.Writer{tempFile, digest}
+	for _, hasher := range hashers {
+		writers = append(writers, hasher)
+	}
+
+	written, copyErr := io.Copy(io.MultiWriter(writers...), io.LimitReader(body, maxSize+1))
+	closeErr := tempFile.Close()
+
+	switch {
+	case copyErr != nil:
+		cleanup()
+		return nil, oops.
+			Code("DOWNLOAD_FAILED").
+			With("source", sourceName).
+			With("url", config.RedactURL(location)).
+			Wrapf(copyErr, "downloading archive")
+	case closeErr != nil:
+		cleanup()
+		return nil, oops.
+			Code("WRITE_FAILED").
+			With("source", sourceName).
+			With("path", tempPath).
+			Wrapf(closeErr, "writing temporary archive file")
+	case written > maxSize:
+		cleanup()
+		return nil, archiveTooLarge(sourceName, location, maxSize)
+	}
+
+	return &fetchedArchive{
+		path:    tempPath,
+		digest:  hex.EncodeToString(digest.Sum(nil)),
+		cleanup: cleanup,
+	}, nil
+}
+
+func (s *archiveSource) hashLocalArchive(localPath string, maxSize int64) (string, error) {
+	info, err := os.Stat(localPath)
+	if err != nil {
+		return "", oops.
+			Code("ARCHIVE_ERROR").
+			With("source", s.name).
+			With("path", localPath).
+			Hint("Check url in your config").
+			Wrapf(err, "reading local archive")
+	}
+
+	if info.Size() > maxSize {
+		return "", archiveTooLarge(s.name, s.source.URL, maxSize)
+	}
+
+	digest, err := fileSHA256(localPath)
+	if err != nil {
+		return "", oops.
+			Code("ARCHIVE_ERROR").
+			With("source", s.name).
+			With("path", localPath).
+			Wrapf(err, "hashing local archive")
+	}
+
+	return digest, nil
+}
+
+func archiveTooLarge(sourceName string, location string, maxSize int64) error {
+	return oops.
+		Code("ARCHIVE_TOO_LARGE").
+		With("source", sourceName).
+		With("url", config.RedactURL(location)).
+		With("limit", maxSize).
+		Hint("Raise max_size for this source").
+		Errorf("archive is larger than the limit of %d bytes", maxSize)
+}
@@ -0,0 +1,423 @@
+package source
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/samber/oops"
+
+	"github.com/g5becks/dox/internal/config"
+)
+
+const (
+	// defaultArchiveMaxSize bounds both the archive itself and the total size
+	// of the files extracted from it.
+	defaultArchiveMaxSize = 512 << 20
+	// defaultArchiveMaxFileSize bounds a single extracted file.
+	defaultArchiveMaxFileSize = 16 << 20
+
+	archiveSniffSize = 512
+	tarMagicOffset   = 257
+)
+
+var (
+	gzipMagic     = []byte{0x1f, 0x8b}
+	zipMagic      = []byte("PK\x03\x04")
+	zipEmptyMagic = []byte("PK\x05\x06")
+	tarMagic      = []byte("ustar")
+)
+
+// archiveMemberFunc is called for every regular file of an archive with its
+// cleaned, slash-separated path and a reader for its content.
+type archiveMemberFunc func(name string, size int64, content io.Reader) error
+
+// archiveLimits bounds how much an archive may expand to.
+type archiveLimits struct {
+	maxSize     int64
+	maxFileSize int64
+}
+
+func archiveLimitsFor(cfg config.Source) archiveLimits {
+	limits := archiveLimits{maxSize: cfg.MaxSize, maxFileSize: cfg.MaxFileSize}
+	if limits.maxSize <= 0 {
+		limits.maxSize = defaultArchiveMaxSize
+	}
+	if limits.maxFileSize <= 0 {
+		limits.maxFileSize = defaultArchiveMaxFileSize
+	}
+
+	return limits
+}
+
+// archiveExtraction extracts the files of an archive selected by a source's
+// strip_components, path, patterns, and exclude settings, recording the
+// SHA-256 of every selected file and writing those that changed.
+type archiveExtraction struct {
+	sourceName string
+	source     config.Source
+	destDir    string
+	oldFiles   map[string]string
+	opts       SyncOptions
+	limits     archiveLimits
+
+	files   map[string]string
+	written int
+	total   int64
+}
+
+func newArchiveExtraction(
+	sourceName string,
+	cfg config.Source,
+	destDir string,
+	oldFiles map[string]string,
+	opts SyncOptions,
+) *archiveExtraction {
+	return &archiveExtraction{
+		sourceName: sourceName,
+		source:     cfg,
+		destDir:    destDir,
+		oldFiles:   oldFiles,
+		opts:       opts,
+		limits:     archiveLimitsFor(cfg),
+		files:      make(map[string]string),
+	}
+}
+
+// run extracts the archive at archivePath and deletes output files that are
+// no longer in it. It returns the number of deleted files.
+func (x *archiveExtraction) run(ctx context.Context, archivePath string) (int, error) {
+	if err := x.extract(ctx, archivePath, x.member); err != nil {
+		return 0, err
+	}
+
+	return x.finish()
+}
+
+// extract passes every member of the archive at archivePath to visit.
+// Sources that inspect members beyond the selected files wrap member.
+func (x *archiveExtraction) extract(ctx context.Context, archivePath string, visit archiveMemberFunc) error {
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return oops.
+			Code("ARCHIVE_ERROR").
+			With("source", x.sourceName).
+			With("path", archivePath).
+			Wrapf(err, "opening archive")
+	}
+	defer file.Close()
+
+	if walkErr := walkArchive(ctx, file, visit); walkErr != nil {
+		return oops.
+			With("source", x.sourceName).
+			Wrap(walkErr)
+	}
+
+	return nil
+}
+
+// finish deletes output files that were not produced by this extraction
+// and returns how many there were.
+func (x *archiveExtraction) finish() (int, error) {
+	toDelete := diffDeletes(x.oldFiles, x.files)
+	if !x.opts.DryRun {
+		if deleteErr := deleteStaleFiles(x.sourceName, x.destDir, toDelete); deleteErr != nil {
+			return 0, deleteErr
+		}
+	}
+
+	return len(toDelete), nil
+}
+
+func (x *archiveExtraction) member(name string, size int64, content io.Reader) error {
+	relativePath, ok, err := x.selected(name)
+	if err != nil || !ok {
+		return err
+	}
+
+	data, err := x.read(name, size, content)
+	if err != nil {
+		return err
+	}
+
+	return x.add(relativePath, data)
+}
+
+// collecting returns a visitor that extracts the selected members like
+// member and also keeps the content of every member whose stripped path
+// satisfies keep, for sources that render docs from code.
+func (x *archiveExtraction) collecting(keep func(string) bool, collected map[string][]byte) archiveMemberFunc {
+	return func(name string, size int64, content io.Reader) error {
+		strippedPath, ok := stripArchivePath(name, x.source.StripComponents)
+		if !ok || !keep(strippedPath) {
+			return x.member(name, size, content)
+		}
+
+		relativePath, selected, err := x.selected(name)
+		if err != nil {
+			return err
+		}
+
+		data, err := x.read(name, size, content)
+		if err != nil {
+			return err
+		}
+		collected[strippedPath] = data
+
+		if !selected {
+			return nil
+		}
+
+		return x.add(relativePath, data)
+	}
+}
+
+// selected maps a member to its output path when the source's
+// strip_components, path, patterns, and exclude settings select it.
+func (x *archiveExtraction) selected(name string) (string, bool, error) {
+	relativePath, ok := stripArchivePath(name, x.source.StripComponents)
+	if !ok {
+		return "", false, nil
+	}
+
+	relativePath, ok = relativePathWithinBase(relativePath, x.source.Path)
+	if !ok {
+		return "", false, nil
+	}
+
+	patterns := x.source.Patterns
+	if len(patterns) == 0 {
+		patterns = config.DefaultPatterns()
+	}
+
+	include, err := shouldIncludeFile(relativePath, patterns, x.source.Exclude)
+	if err != nil || !include {
+		return "", false, err
+	}
+
+	return relativePath, true, nil
+}
+
+// read reads a member's content within the per-file and total size limits.
+func (x *archiveExtraction) read(name string, size int64, content io.Reader) ([]byte, error) {
+	if size > x.limits.maxFileSize {
+		return nil, x.tooLarge(name, "file", x.limits.maxFileSize)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(content, x.limits.maxFileSize+1))
+	if err != nil {
+		return nil, oops.
+			Code("ARCHIVE_ERROR").
+			With("member", name).
+			Wrapf(err, "reading archive member")
+	}
+	if int64(len(data)) > x.limits.maxFileSize {
+		return nil, x.tooLarge(name, "file", x.limits.maxFileSize)
+	}
+
+	x.total += int64(len(data))
+	if x.total > x.limits.maxSize {
+		return nil, x.tooLarge(name, "extracted content", x.limits.maxSize)
+	}
+
+	return data, nil
+}
+
+// add records an output file and writes it when its content changed.
+func (x *archiveExtraction) add(relativePath string, data []byte) error {
+	sha := contentSHA256(data)
+	x.files[relativePath] = sha
+
+	if !x.opts.Force && x.oldFiles[relativePath] == sha {
+		return nil
+	}
+
+	x.written++
+	if x.opts.DryRun {
+		return nil
+	}
+
+	return writeSourceFile(x.sourceName, x.destDir, relativePath, data)
+}
+
+func (x *archiveExtraction) tooLarge(name string, what string, limit int64) error {
+	return oops.
+		Code("ARCHIVE_TOO_LARGE").
+		With("member", name).
+		With("limit", limit).
+		Hint("Raise max_file_size or max_size, or narrow patterns").
+		Errorf("archive %s exceeds the limit of %d bytes at %q", what, limit, name)
+}
+
+// walkArchive calls visit for every regular file of a .tar.gz, .tar, or .zip
+// archive, detected from its content. Links and other special members are
+// skipped; a member whose path escapes the archive root fails the walk.
+func walkArchive(ctx context.Context, file *os.File, visit archiveMemberFunc) error {
+	header := make([]byte, archiveSniffSize)
+	n, err := io.ReadFull(file, header)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return oops.
+			Code("ARCHIVE_ERROR").
+			Wrapf(err, "reading archive")
+	}
+	header = header[:n]
+
+	if _, seekErr := file.Seek(0, io.SeekStart); seekErr != nil {
+		return oops.
+			Code("ARCHIVE_ERROR").
+			Wrapf(seekErr, "reading archive")
+	}
+
+	switch {
+	case bytes.HasPrefix(header, gzipMagic):
+		gzipReader, gzipErr := gzip.NewReader(file)
+		if gzipErr != nil {
+			return oops.
+				Code("ARCHIVE_ERROR").
+				Wrapf(gzipErr, "reading gzip stream")
+		}
+		defer gzipReader.Close()
+
+		return walkTar(ctx, tar.NewReader(gzipReader), visit)
+
+	case bytes.HasPrefix(header, zipMagic), bytes.HasPrefix(header, zipEmptyMagic):
+		info, statErr := file.Stat()
+		if statErr != nil {
+			return oops.
+				Code("ARCHIVE_ERROR").
+				Wrapf(statErr, "reading archive")
+		}
+
+		return walkZip(ctx, file, info.Size(), visit)
+
+	case len(header) > tarMagicOffset+len(tarMagic) &&
+		bytes.Equal(header[tarMagicOffset:tarMagicOffset+len(tarMagic)], tarMagic):
+		return walkTar(ctx, tar.NewReader(file), visit)
+
+	default:
+		return oops.
+			Code("ARCHIVE_ERROR").
+			Hint("Supported archive formats: .tar.gz, .tgz, .tar, .zip").
+			Errorf("unrecognized archive format")
+	}
+}
+
+func walkTar(ctx context.Context, reader *tar.Reader, visit archiveMemberFunc) error {
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		header, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return oops.
+				Code("ARCHIVE_ERROR").
+				Wrapf(err, "reading tar archive")
+		}
+
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		name, ok := cleanArchivePath(header.Name)
+		if !ok {
+			return unsafeArchivePath(header.Name)
+		}
+
+		if visitErr := visit(name, header.Size, reader); visitErr != nil {
+			return visitErr
+		}
+	}
+}
+
+func walkZip(ctx context.Context, file io.ReaderAt, size int64, visit archiveMemberFunc) error {
+	reader, err := zip.NewReader(file, size)
+	if err != nil {
+		return oops.
+			Code("ARCHIVE_ERROR").
+			Wrapf(err, "reading zip archive")
+	}
+
+	for _, member := range reader.File {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+
+		if !member.Mode().IsRegular() {
+			continue
+		}
+
+		name, ok := cleanArchivePath(member.Name)
+		if !ok {
+			return unsafeArchivePath(member.Name)
+		}
+
+		if visitErr := visitZipMember(member, name, visit); visitErr != nil {
+			return visitErr
+		}
+	}
+
+	return nil
+}
+
+func visitZipMember(member *zip.File, name string, visit archiveMemberFunc) error {
+	content, err := member.Open()
+	if err != nil {
+		return oops.
+			Code("ARCHIVE_ERROR").
+			With("member", member.Name).
+			Wrapf(err, "reading zip member")
+	}
+	defer content.Close()
+
+	return visit(name, int64(member.UncompressedSize64), content) //nolint:gosec // Sizes above MaxInt64 fail the limit checks anyway.
+}
+
+// cleanArchivePath normalizes a member path and rejects absolute paths and
+// paths that climb out of the archive root ("zip slip").
+func cleanArchivePath(name string) (string, bool) {
+	name = strings.ReplaceAll(name, `\`, "/")
+	if strings.HasPrefix(name, "/") || (len(name) > 1 && name[1] == ':') {
+		return "", false
+	}
+
+	cleaned := path.Clean(name)
+	if cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", false
+	}
+
+	return cleaned, cleaned != "."
+}
+
+// stripArchivePath drops the first count path components of a member path.
+// Members without anything left after stripping are skipped.
+func stripArchivePath(name string, count int) (string, bool) {
+	if count <= 0 {
+		return name, true
+	}
+
+	parts := strings.Split(name, "/")
+	if len(parts) <= count {
+		return "", false
+	}
+
+	return strings.Join(parts[count:], "/"), true
+}
+
+func unsafeArchivePath(name string) error {
+	return oops.
+		Code("ARCHIVE_UNSAFE").
+		With("member", name).
+		Hint("The archive contains a path outside its root and was not extracted").
+		Errorf("archive member %q escapes the archive root", name)
+}
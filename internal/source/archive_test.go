@@ -0,0 +1,227 @@
+package source_test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/g5becks/dox/internal/config"
+	"github.com/g5becks/dox/internal/source"
+)
+
+type archiveMember struct {
+	name    string
+	content string
+}
+
+func buildTarGz(t *testing.T, members ...archiveMember) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	gzipWriter := gzip.NewWriter(&buf)
+	tarWriter := tar.NewWriter(gzipWriter)
+
+	for _, member := range members {
+		header := &tar.Header{
+			Name:     member.name,
+			Mode:     0o644,
+			Size:     int64(len(member.content)),
+			Typeflag: tar.TypeReg,
+		}
+		if err := tarWriter.WriteHeader(header); err != nil {
+			t.Fatalf("WriteHeader() error = %v", err)
+		}
+		if _, err := tarWriter.Write([]byte(member.content)); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+
+	if err := tarWriter.Close(); err != nil {
+		t.Fatalf("tar Close() error = %v", err)
+	}
+	if err := gzipWriter.Close(); err != nil {
+		t.Fatalf("gzip Close() error = %v", err)
+	}
+
+	return buf.Bytes()
+}
+
+func writeZip(t *testing.T, path string, members ...archiveMember) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	zipWriter := zip.NewWriter(&buf)
+	for _, member := range members {
+		writer, err := zipWriter.Create(member.name)
+		if err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		if _, err := writer.Write([]byte(member.content)); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	if err := zipWriter.Close(); err != nil {
+		t.Fatalf("zip Close() error = %v", err)
+	}
+
+	if err := os.WriteFile(path, buf.Bytes(), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+}
+
+func TestArchiveSyncDownloadsAndStripsTarball(t *testing.T) {
+	t.Parallel()
+
+	archive := buildTarGz(t,
+		archiveMember{name: "site-1.0/docs/guide.md", content: "# Guide"},
+		archiveMember{name: "site-1.0/docs/api/ref.md", content: "# Ref"},
+		archiveMember{name: "site-1.0/assets/logo.png", content: "png"},
+		archiveMember{name: "README.md", content: "dropped by strip_components"},
+	)
+
+	requests := 0
+	client := source.NewMockRestyClient(func(req *http.Request) *http.Response {
+		requests++
+		if req.Header.Get("If-None-Match") == `"v1"` {
+			return source.NewHTTPResponse(req, http.StatusNotModified, "", nil)
+		}
+
+		header := http.Header{}
+		header.Set("ETag", `"v1"`)
+		header.Set("Content-Type", "application/gzip")
+		return source.NewHTTPResponse(req, http.StatusOK, string(archive), header)
+	})
+
+	src, setClient := source.TestableArchiveSource(t, "site", config.Source{
+		Type:            "archive",
+		URL:             "https://example.com/docs.tar.gz",
+		StripComponents: 1,
+		Path:            "docs",
+		Patterns:        config.DefaultPatterns(),
+	})
+	setClient(client)
+
+	destDir := t.TempDir()
+	first, err := src.Sync(context.Background(), destDir, nil, source.SyncOptions{})
+	if err != nil {
+		t.Fatalf("first Sync() error = %v", err)
+	}
+
+	if first.Downloaded != 2 {
+		t.Fatalf("Downloaded = %d, want 2", first.Downloaded)
+	}
+
+	if first.LockEntry.ETag != `"v1"` || first.LockEntry.Digest == "" {
+		t.Fatalf("LockEntry = %+v, want ETag and Digest", first.LockEntry)
+	}
+
+	content, err := os.ReadFile(filepath.Join(destDir, "api", "ref.md"))
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	if string(content) != "# Ref" {
+		t.Fatalf("content = %q, want %q", string(content), "# Ref")
+	}
+
+	second, err := src.Sync(context.Background(), destDir, first.LockEntry, source.SyncOptions{})
+	if err != nil {
+		t.Fatalf("second Sync() error = %v", err)
+	}
+
+	if !second.Skipped || len(second.LockEntry.Files) != 2 || requests != 2 {
+		t.Fatalf("second Sync() = %+v after %d requests, want skipped with files kept", second, requests)
+	}
+}
+
+func TestArchiveSyncLocalZipUpdatesIncrementally(t *testing.T) {
+	t.Parallel()
+
+	archivePath := filepath.Join(t.TempDir(), "docs.zip")
+	writeZip(t, archivePath,
+		archiveMember{name: "guide.md", content: "# Guide"},
+		archiveMember{name: "faq.md", content: "# FAQ"},
+	)
+
+	src, err := source.New("docs", config.Source{Type: "archive", URL: archivePath}, "")
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	destDir := t.TempDir()
+	first, err := src.Sync(context.Background(), destDir, nil, source.SyncOptions{})
+	if err != nil {
+		t.Fatalf("first Sync() error = %v", err)
+	}
+
+	writeZip(t, archivePath,
+		archiveMember{name: "guide.md", content: "# Guide"},
+		archiveMember{name: "changelog.md", content: "# Changes"},
+	)
+
+	second, err := src.Sync(context.Background(), destDir, first.LockEntry, source.SyncOptions{})
+	if err != nil {
+		t.Fatalf("second Sync() error = %v", err)
+	}
+
+	if second.Downloaded != 1 || second.Deleted != 1 {
+		t.Fatalf("second Sync() = %+v, want 1 download and 1 delete", second)
+	}
+
+	if _, statErr := os.Stat(filepath.Join(destDir, "faq.md")); !os.IsNotExist(statErr) {
+		t.Fatalf("expected faq.md to be deleted")
+	}
+
+	if second.LockEntry.Files["guide.md"] != first.LockEntry.Files["guide.md"] {
+		t.Fatalf("guide.md hash changed although its content did not")
+	}
+}
+
+func TestArchiveSyncRejectsUnsafePaths(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	archivePath := filepath.Join(dir, "evil.tar.gz")
+	archive := buildTarGz(t, archiveMember{name: "docs/../../escape.md", content: "# Escape"})
+	if err := os.WriteFile(archivePath, archive, 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	src, err := source.New("evil", config.Source{Type: "archive", URL: archivePath}, "")
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	destDir := filepath.Join(dir, "out", "evil")
+	_, syncErr := src.Sync(context.Background(), destDir, nil, source.SyncOptions{})
+	if syncErr == nil || !strings.Contains(syncErr.Error(), "escapes the archive root") {
+		t.Fatalf("Sync() error = %v, want zip slip error", syncErr)
+	}
+
+	if _, statErr := os.Stat(filepath.Join(dir, "out", "escape.md")); !os.IsNotExist(statErr) {
+		t.Fatalf("expected escape.md not to be written")
+	}
+}
+
+func TestArchiveSyncEnforcesFileSizeLimit(t *testing.T) {
+	t.Parallel()
+
+	archivePath := filepath.Join(t.TempDir(), "big.zip")
+	writeZip(t, archivePath, archiveMember{name: "big.md", content: strings.Repeat("x", 2048)})
+
+	src, err := source.New("big", config.Source{Type: "archive", URL: archivePath, MaxFileSize: 1024}, "")
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	_, syncErr := src.Sync(context.Background(), t.TempDir(), nil, source.SyncOptions{})
+	if syncErr == nil || !strings.Contains(syncErr.Error(), "exceeds the limit") {
+		t.Fatalf("Sync() error = %v, want size limit error", syncErr)
+	}
+}
@@ -0,0 +1,173 @@
+package source
+
+import (
+	"context"
+	"maps"
+	"path/filepath"
+	stdsync "sync"
+	"time"
+
+	"github.com/samber/oops"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/g5becks/dox/internal/cache"
+	"github.com/g5becks/dox/internal/lockfile"
+)
+
+// fetchFileFunc downloads and writes a single file of a source.
+type fetchFileFunc func(ctx context.Context, relativePath string, sha string) error
+
+// fetchConcurrently runs fetch for every file in toDownload, bounded by the
+// per-source file parallelism and the request budget shared across sources.
+//
+// Every file is attempted even when some fail, so a rerun only has to fetch
+// the failures. It returns the files that were written; on failure the error
+// for the first failing path in sorted order is returned, which keeps error
+// output stable between runs.
+func fetchConcurrently(
+	ctx context.Context,
+	opts SyncOptions,
+	toDownload map[string]string,
+	fetch fetchFileFunc,
+) (map[string]string, error) {
+	var mu stdsync.Mutex
+	written := make(map[string]string, len(toDownload))
+	failures := make(map[string]error)
+
+	group := &errgroup.Group{}
+	group.SetLimit(max(opts.FileParallel, 1))
+
+	for _, relativePath := range sortedKeys(toDownload) {
+		sha := toDownload[relativePath]
+
+		group.Go(func() error {
+			err := fetchWithBudget(ctx, opts, relativePath, sha, fetch)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				failures[relativePath] = err
+			} else {
+				written[relativePath] = sha
+			}
+
+			return nil
+		})
+	}
+
+	_ = group.Wait()
+
+	if len(failures) == 0 {
+		return written, nil
+	}
+
+	firstPath := sortedKeys(failures)[0]
+	return written, oops.
+		Code("DOWNLOAD_FAILED").
+		With("path", firstPath).
+		With("failed_files", len(failures)).
+		Wrapf(failures[firstPath], "downloading %d of %d file(s) failed", len(failures), len(toDownload))
+}
+
+// downloadCached places the files of toDownload that the shared cache holds
+// into destDir, hands the rest to download, and adds every file download
+// wrote to the cache, whether it came from single requests or an archive.
+// Values of toDownload are git blob SHAs.
+func downloadCached(
+	opts SyncOptions,
+	destDir string,
+	toDownload map[string]string,
+	download func(pending map[string]string) (map[string]string, error),
+) (map[string]string, error) {
+	if opts.Cache == nil {
+		return download(toDownload)
+	}
+
+	written := make(map[string]string, len(toDownload))
+	pending := make(map[string]string, len(toDownload))
+	for relativePath, sha := range toDownload {
+		if linkCachedFile(opts, destDir, relativePath, cache.GitBlobKey(sha)) {
+			written[relativePath] = sha
+		} else {
+			pending[relativePath] = sha
+		}
+	}
+
+	if len(pending) == 0 {
+		return written, nil
+	}
+
+	downloaded, err := download(pending)
+	for relativePath, sha := range downloaded {
+		cacheFile(opts, destDir, relativePath, cache.GitBlobKey(sha))
+		written[relativePath] = sha
+	}
+
+	return written, err
+}
+
+// linkCachedFile places the object stored under key in the shared cache at
+// relativePath and reports whether the cache held it.
+func linkCachedFile(opts SyncOptions, destDir string, relativePath string, key string) bool {
+	return opts.Cache.Link(key, filepath.Join(destDir, filepath.FromSlash(relativePath)))
+}
+
+// cacheFile adds a downloaded file to the shared cache under key. The cache
+// is best effort: a file it cannot store is simply downloaded again next
+// time.
+func cacheFile(opts SyncOptions, destDir string, relativePath string, key string) {
+	_ = opts.Cache.Store(key, filepath.Join(destDir, filepath.FromSlash(relativePath)))
+}
+
+func fetchWithBudget(
+	ctx context.Context,
+	opts SyncOptions,
+	relativePath string,
+	sha string,
+	fetch fetchFileFunc,
+) error {
+	if opts.Requests != nil {
+		if err := opts.Requests.Acquire(ctx, 1); err != nil {
+			return err
+		}
+		defer opts.Requests.Release(1)
+	}
+
+	return fetch(ctx, relativePath, sha)
+}
+
+// partialResult records the progress of a sync whose downloads partly failed.
+// Unchanged and successfully written files take their new SHA, failed files
+// keep their previous SHA (or are left out), and TreeSHA is cleared so the
+// next sync diffs the tree again and retries only what is missing.
+func partialResult(
+	sourceType string,
+	ref string,
+	oldFiles map[string]string,
+	newFiles map[string]string,
+	toDownload map[string]string,
+	written map[string]string,
+) *SyncResult {
+	files := maps.Clone(oldFiles)
+	if files == nil {
+		files = make(map[string]string, len(newFiles))
+	}
+
+	for relativePath, sha := range newFiles {
+		_, pending := toDownload[relativePath]
+		_, done := written[relativePath]
+		if !pending || done {
+			files[relativePath] = sha
+		}
+	}
+
+	return &SyncResult{
+		Downloaded: len(written),
+		LockEntry: &lockfile.LockEntry{
+			Type:        sourceType,
+			RefResolved: ref,
+			SyncedAt:    time.Now().UTC(),
+			Files:       files,
+		},
+	}
+}
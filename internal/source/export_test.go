@@ -1,11 +1,13 @@
 package source
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"net/http"
 	"strings"
 	"testing"
+	"time"
 
 	"resty.dev/v3"
 
@@ -22,7 +24,57 @@ var IsSingleFilePath = isSingleFilePath
 //nolint:gochecknoglobals // Test-only exports
 var FilenameFromURL = filenameFromURL
 
-// TestableGitHubSource creates a githubSource for external tests.
+// NewHTTPClient exports newHTTPClient for testing.
+//
+//nolint:gochecknoglobals // Test-only exports
+var NewHTTPClient = newHTTPClient
+
+// GitHubAPIBaseURL, GitHubRawBaseURL, and MatchVersionTag export the GitHub
+// endpoint and version tag helpers for testing.
+//
+//nolint:gochecknoglobals // Test-only exports
+var (
+	GitHubAPIBaseURL = githubAPIBaseURL
+	GitHubRawBaseURL = githubRawBaseURL
+	MatchVersionTag  = matchVersionTag
+)
+
+// GitBlobSHA exports gitBlobSHA for testing.
+//
+//nolint:gochecknoglobals // Test-only exports
+var GitBlobSHA = gitBlobSHA
+
+// GitShallowCommits fetches the repository of a git source the way Sync does
+// and returns the commits its history is cut at, which is empty for a full
+// clone.
+func GitShallowCommits(t *testing.T, cfg config.Source) []string {
+	t.Helper()
+
+	src, err := newGitSource("test", cfg, "")
+	if err != nil {
+		t.Fatalf("newGitSource() error = %v", err)
+	}
+
+	repo, err := src.openRepository(context.Background(), "")
+	if err != nil {
+		t.Fatalf("openRepository() error = %v", err)
+	}
+
+	shallow, err := repo.Storer.Shallow()
+	if err != nil {
+		t.Fatalf("Shallow() error = %v", err)
+	}
+
+	commits := make([]string, 0, len(shallow))
+	for _, hash := range shallow {
+		commits = append(commits, hash.String())
+	}
+
+	return commits
+}
+
+// TestableGitHubSource creates a githubSource for external tests. The
+// resolved ref doubles as the commit, so syncs skip resolving either.
 func TestableGitHubSource(
 	t *testing.T,
 	name string,
@@ -43,7 +95,10 @@ func TestableGitHubSource(
 		owner:       owner,
 		repo:        repo,
 		client:      client,
+		rawBaseURL:  "https://raw.github.test",
 		resolvedRef: resolvedRef,
+		commit:      resolvedRef,
+		quota:       NewQuotaTracker(false, 0, nil),
 	}
 }
 
@@ -59,7 +114,80 @@ func TestableURLSource(t *testing.T, name string, cfg config.Source) (Source, fu
 	u := src.(*urlSource)
 
 	return u, func(client *resty.Client) {
-		u.client = client
+		u.useClient(client)
+		u.client.SetRetryWaitTime(time.Millisecond).SetRetryMaxWaitTime(time.Millisecond)
+	}
+}
+
+// TestableArchiveSource creates an archiveSource and returns it with a setter for the client.
+func TestableArchiveSource(t *testing.T, name string, cfg config.Source) (Source, func(*resty.Client)) {
+	t.Helper()
+
+	src, err := NewArchive(name, cfg)
+	if err != nil {
+		t.Fatalf("NewArchive() error = %v", err)
+	}
+
+	a := src.(*archiveSource)
+
+	return a, func(client *resty.Client) {
+		a.client = client
+	}
+}
+
+// TestableNPMSource creates an npmSource and returns it with a setter for the client.
+func TestableNPMSource(t *testing.T, name string, cfg config.Source) (Source, func(*resty.Client)) {
+	t.Helper()
+
+	src, err := newNPMSource(name, cfg)
+	if err != nil {
+		t.Fatalf("newNPMSource() error = %v", err)
+	}
+
+	return src, func(client *resty.Client) {
+		src.client = client.SetBaseURL(src.registry)
+	}
+}
+
+// TestableGoModSource creates a goModSource and returns it with a setter for the client.
+func TestableGoModSource(t *testing.T, name string, cfg config.Source) (Source, func(*resty.Client)) {
+	t.Helper()
+
+	src, err := newGoModSource(name, cfg)
+	if err != nil {
+		t.Fatalf("newGoModSource() error = %v", err)
+	}
+
+	return src, func(client *resty.Client) {
+		src.client = client.SetBaseURL(src.proxy)
+	}
+}
+
+// TestablePyPISource creates a pypiSource and returns it with a setter for the client.
+func TestablePyPISource(t *testing.T, name string, cfg config.Source) (Source, func(*resty.Client)) {
+	t.Helper()
+
+	src, err := newPyPISource(name, cfg)
+	if err != nil {
+		t.Fatalf("newPyPISource() error = %v", err)
+	}
+
+	return src, func(client *resty.Client) {
+		src.client = client.SetBaseURL(src.index)
+	}
+}
+
+// TestableSiteSource creates a siteSource and returns it with a setter for the client.
+func TestableSiteSource(t *testing.T, name string, cfg config.Source) (Source, func(*resty.Client)) {
+	t.Helper()
+
+	src, err := newSiteSource(name, cfg)
+	if err != nil {
+		t.Fatalf("newSiteSource() error = %v", err)
+	}
+
+	return src, func(client *resty.Client) {
+		src.client = client.SetHeader("User-Agent", siteUserAgent)
 	}
 }
 
@@ -161,3 +289,31 @@ func NewHTTPResponse(
 		Request:       req,
 	}
 }
+
+// TestableGitLabSource creates a gitlabSource whose API client points at baseURL.
+func TestableGitLabSource(t *testing.T, name string, cfg config.Source, baseURL string, token string) Source {
+	t.Helper()
+
+	src, err := newGitLabSource(name, cfg, token)
+	if err != nil {
+		t.Fatalf("newGitLabSource() error = %v", err)
+	}
+
+	src.client.SetBaseURL(baseURL)
+
+	return src
+}
+
+// TestableGiteaSource creates a giteaSource whose API client points at baseURL.
+func TestableGiteaSource(t *testing.T, name string, cfg config.Source, baseURL string, token string) Source {
+	t.Helper()
+
+	src, err := newGiteaSource(name, cfg, token)
+	if err != nil {
+		t.Fatalf("newGiteaSource() error = %v", err)
+	}
+
+	src.client.SetBaseURL(baseURL)
+
+	return src
+}
@@ -0,0 +1,604 @@
+package source
+
+import (
+	"context"
+	"errors"
+	"io"
+	neturl "net/url"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	gitconfig "github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/go-git/go-git/v5/storage/memory"
+	"github.com/samber/oops"
+
+	"github.com/g5becks/dox/internal/config"
+	"github.com/g5becks/dox/internal/lockfile"
+)
+
+const (
+	sourceTypeGit     = "git"
+	gitShallowDepth   = 1
+	gitAuthUsername   = "dox"
+	gitPeeledSuffix   = "^{}"
+	gitHeadRevision   = "HEAD"
+	gitRemoteProtocol = "https://"
+	// gitPinnedRef receives a commit fetched by SHA.
+	gitPinnedRef = "refs/heads/dox-pinned"
+)
+
+var commitSHAPattern = regexp.MustCompile(`^[0-9a-fA-F]{40}$`)
+
+// gitSource syncs documentation from any git remote speaking the smart
+// protocol, or from a repository on the local filesystem. Remote repositories
+// are fetched shallowly into memory, so no git binary or working tree is
+// needed.
+type gitSource struct {
+	name   string
+	source config.Source
+	remote string
+	auth   transport.AuthMethod
+	// transport carries the proxy and TLS files of the http settings.
+	transport gitTransportOptions
+	// resolvedRef is the branch or tag name the synced commit came from.
+	resolvedRef string
+}
+
+func NewGit(name string, cfg config.Source, token string) (Source, error) {
+	return newGitSource(name, cfg, token)
+}
+
+func newGitSource(name string, cfg config.Source, token string) (*gitSource, error) {
+	remote := strings.TrimSpace(cfg.URL)
+	if remote == "" {
+		repo := strings.Trim(strings.TrimSpace(cfg.Repo), "/")
+		if repo == "" || cfg.Host == "" {
+			return nil, oops.
+				Code("CONFIG_INVALID").
+				With("source", name).
+				Hint("Set url to a clone URL or local path, or set host and repo").
+				Errorf("git source %q has no clone URL", name)
+		}
+
+		remote = gitRemoteProtocol + cfg.Host + "/" + strings.TrimSuffix(repo, ".git") + ".git"
+	}
+
+	var auth transport.AuthMethod
+	if token != "" && !config.IsLocalURL(remote) {
+		auth = &githttp.BasicAuth{Username: gitAuthUsername, Password: token}
+	}
+
+	transportOptions, err := newGitTransportOptions(name, remote, cfg.HTTP)
+	if err != nil {
+		return nil, err
+	}
+
+	return &gitSource{
+		name:      name,
+		source:    cfg,
+		remote:    remote,
+		auth:      auth,
+		transport: transportOptions,
+	}, nil
+}
+
+func (s *gitSource) Close() error {
+	return nil
+}
+
+func (s *gitSource) Sync(
+	ctx context.Context,
+	destDir string,
+	prevLock *lockfile.LockEntry,
+	opts SyncOptions,
+) (*SyncResult, error) {
+	result, err := s.syncRepository(ctx, destDir, prevLock, opts)
+	return withRef(result, s.resolvedRef), err
+}
+
+func (s *gitSource) syncRepository(
+	ctx context.Context,
+	destDir string,
+	prevLock *lockfile.LockEntry,
+	opts SyncOptions,
+) (*SyncResult, error) {
+	var refName plumbing.ReferenceName
+	if !config.IsLocalURL(s.remote) {
+		name, advertised, err := s.advertisedRef(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		s.resolvedRef = s.symbolicRef(name)
+		if !opts.Force && prevLock != nil && advertised != "" && advertised == prevLock.RefResolved {
+			return skippedResult(prevLock, sourceTypeGit, advertised), nil
+		}
+		refName = name
+	}
+
+	repo, err := s.openRepository(ctx, refName)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.IsLocalURL(s.remote) {
+		if s.source.Version != "" {
+			if tagErr := s.matchLocalTag(repo); tagErr != nil {
+				return nil, tagErr
+			}
+		}
+		s.resolvedRef = s.symbolicRef(localHead(repo, s.source.Ref))
+	}
+
+	commit, err := s.resolveCommit(repo)
+	if err != nil {
+		return nil, err
+	}
+
+	treeSHA, blobs, err := s.collectBlobs(commit)
+	if err != nil {
+		return nil, err
+	}
+
+	commitSHA := commit.Hash.String()
+	if !opts.Force && prevLock != nil && prevLock.TreeSHA == treeSHA {
+		return skippedResult(prevLock, sourceTypeGit, commitSHA), nil
+	}
+
+	newFiles, err := filterTreeFiles(blobs, s.source)
+	if err != nil {
+		return nil, err
+	}
+
+	oldFiles := map[string]string{}
+	if prevLock != nil && prevLock.Files != nil {
+		oldFiles = prevLock.Files
+	}
+
+	toDownload := diffDownloads(newFiles, oldFiles, opts.Force)
+	toDelete := diffDeletes(oldFiles, newFiles)
+
+	if !opts.DryRun {
+		write := func(pending map[string]string) (map[string]string, error) {
+			return s.writeFiles(repo, destDir, pending)
+		}
+		if _, writeErr := downloadCached(opts, destDir, toDownload, write); writeErr != nil {
+			return nil, writeErr
+		}
+
+		if deleteErr := deleteStaleFiles(s.name, destDir, toDelete); deleteErr != nil {
+			return nil, deleteErr
+		}
+	}
+
+	return &SyncResult{
+		Downloaded: len(toDownload),
+		Deleted:    len(toDelete),
+		LockEntry: &lockfile.LockEntry{
+			Type:        sourceTypeGit,
+			TreeSHA:     treeSHA,
+			RefResolved: commitSHA,
+			SyncedAt:    time.Now().UTC(),
+			Files:       newFiles,
+		},
+	}, nil
+}
+
+// advertisedRef asks the remote which object the configured ref points at
+// without fetching anything and returns the matching ref name with its SHA.
+// Commit SHAs are returned as-is; refs the remote does not advertise yield
+// empty results and are resolved after fetching.
+func (s *gitSource) advertisedRef(ctx context.Context) (plumbing.ReferenceName, string, error) {
+	if commitSHAPattern.MatchString(s.source.Ref) {
+		return "", strings.ToLower(s.source.Ref), nil
+	}
+
+	remote := git.NewRemote(memory.NewStorage(), &gitconfig.RemoteConfig{
+		Name: git.DefaultRemoteName,
+		URLs: []string{s.remote},
+	})
+	refs, err := remote.ListContext(ctx, &git.ListOptions{
+		Auth:          s.auth,
+		PeelingOption: git.AppendPeeled,
+		CABundle:      s.transport.caBundle,
+		ClientCert:    s.transport.clientCert,
+		ClientKey:     s.transport.clientKey,
+		ProxyOptions:  s.transport.proxy,
+	})
+	if err != nil {
+		return "", "", s.remoteError(err, "listing remote refs")
+	}
+
+	if s.source.Version != "" {
+		tags := []string{}
+		for _, ref := range refs {
+			if ref.Name().IsTag() && !strings.HasSuffix(ref.Name().String(), gitPeeledSuffix) {
+				tags = append(tags, ref.Name().Short())
+			}
+		}
+
+		// The matched tag stands in for ref from here on.
+		if s.source.Ref, err = matchVersionTag(s.name, config.RedactURL(s.remote), s.source.Version, tags); err != nil {
+			return "", "", err
+		}
+	}
+
+	byName := make(map[string]*plumbing.Reference, len(refs))
+	for _, ref := range refs {
+		byName[ref.Name().String()] = ref
+	}
+
+	for _, candidate := range s.refCandidates() {
+		ref, ok := byName[candidate]
+		if !ok {
+			continue
+		}
+
+		name := plumbing.ReferenceName(strings.TrimSuffix(candidate, gitPeeledSuffix))
+		if ref.Type() == plumbing.SymbolicReference {
+			name = ref.Target()
+			if ref, ok = byName[name.String()]; !ok {
+				continue
+			}
+		}
+
+		return name, ref.Hash().String(), nil
+	}
+
+	return "", "", nil
+}
+
+// symbolicRef names the branch or tag a sync resolves its commit from,
+// preferring the ref the remote advertised over the configured one.
+func (s *gitSource) symbolicRef(name plumbing.ReferenceName) string {
+	if name != "" && name != plumbing.HEAD {
+		return name.Short()
+	}
+
+	if ref := strings.TrimSpace(s.source.Ref); ref != "" {
+		return ref
+	}
+
+	return gitHeadRevision
+}
+
+// localHead returns the branch a local repository has checked out when no
+// ref is configured.
+func localHead(repo *git.Repository, ref string) plumbing.ReferenceName {
+	if strings.TrimSpace(ref) != "" {
+		return ""
+	}
+
+	head, err := repo.Head()
+	if err != nil || !head.Name().IsBranch() {
+		return ""
+	}
+
+	return head.Name()
+}
+
+// matchLocalTag picks the tag of a local repository matching the configured
+// version, which then stands in for ref.
+func (s *gitSource) matchLocalTag(repo *git.Repository) error {
+	iter, err := repo.Tags()
+	if err != nil {
+		return oops.
+			Code("GIT_ERROR").
+			With("source", s.name).
+			Wrapf(err, "listing tags")
+	}
+
+	tags := []string{}
+	if err = iter.ForEach(func(ref *plumbing.Reference) error {
+		tags = append(tags, ref.Name().Short())
+		return nil
+	}); err != nil {
+		return oops.
+			Code("GIT_ERROR").
+			With("source", s.name).
+			Wrapf(err, "listing tags")
+	}
+
+	s.source.Ref, err = matchVersionTag(s.name, s.remote, s.source.Version, tags)
+	return err
+}
+
+// refCandidates lists the advertised ref names the configured ref may match,
+// preferring peeled tags so annotated tags compare against commit SHAs.
+func (s *gitSource) refCandidates() []string {
+	ref := strings.TrimSpace(s.source.Ref)
+	if ref == "" || ref == gitHeadRevision {
+		return []string{gitHeadRevision}
+	}
+
+	return []string{
+		plumbing.NewBranchReferenceName(ref).String(),
+		plumbing.NewTagReferenceName(ref).String() + gitPeeledSuffix,
+		plumbing.NewTagReferenceName(ref).String(),
+		ref + gitPeeledSuffix,
+		ref,
+	}
+}
+
+func (s *gitSource) openRepository(ctx context.Context, refName plumbing.ReferenceName) (*git.Repository, error) {
+	if config.IsLocalURL(s.remote) {
+		localPath := localFilePath(s.remote)
+		repo, err := git.PlainOpen(localPath)
+		if err != nil {
+			return nil, oops.
+				Code("GIT_ERROR").
+				With("source", s.name).
+				With("path", localPath).
+				Hint("Check that url points at a git repository").
+				Wrapf(err, "opening local repository")
+		}
+
+		return repo, nil
+	}
+
+	if ref := strings.TrimSpace(s.source.Ref); commitSHAPattern.MatchString(ref) {
+		return s.fetchCommit(ctx, strings.ToLower(ref))
+	}
+
+	repo, err := git.CloneContext(ctx, memory.NewStorage(), nil, s.cloneOptions(refName))
+	if err != nil {
+		return nil, s.remoteError(err, "fetching repository")
+	}
+
+	return repo, nil
+}
+
+// fetchCommit fetches a pinned commit SHA at depth 1. Servers only hand out
+// commits by SHA when they advertise allow-reachable-sha1-in-want or
+// allow-tip-sha1-in-want, as GitHub, GitLab, and Gitea do; from others the
+// commit is taken from a clone with full history.
+func (s *gitSource) fetchCommit(ctx context.Context, sha string) (*git.Repository, error) {
+	repo, err := git.Init(memory.NewStorage(), nil)
+	if err != nil {
+		return nil, oops.
+			Code("GIT_ERROR").
+			With("source", s.name).
+			Wrapf(err, "initializing repository")
+	}
+
+	remote, err := repo.CreateRemote(&gitconfig.RemoteConfig{
+		Name: git.DefaultRemoteName,
+		URLs: []string{s.remote},
+	})
+	if err != nil {
+		return nil, oops.
+			Code("GIT_ERROR").
+			With("source", s.name).
+			Wrapf(err, "configuring remote")
+	}
+
+	fetchErr := remote.FetchContext(ctx, &git.FetchOptions{
+		RefSpecs:     []gitconfig.RefSpec{gitconfig.RefSpec(sha + ":" + gitPinnedRef)},
+		Depth:        gitShallowDepth,
+		Auth:         s.auth,
+		Tags:         git.NoTags,
+		CABundle:     s.transport.caBundle,
+		ClientCert:   s.transport.clientCert,
+		ClientKey:    s.transport.clientKey,
+		ProxyOptions: s.transport.proxy,
+	})
+	if fetchErr == nil {
+		return repo, nil
+	}
+
+	repo, err = git.CloneContext(ctx, memory.NewStorage(), nil, s.cloneOptions(""))
+	if err != nil {
+		return nil, s.remoteError(err, "fetching repository")
+	}
+
+	return repo, nil
+}
+
+// cloneOptions requests a depth-1, single-branch fetch of the advertised ref.
+// A pinned commit SHA the server would not fetch by SHA needs full history.
+//
+// The fetch is not sparse: go-git cannot ask for a partial pack, so the whole
+// tree of the one commit is held in memory. Nothing is checked out; only the
+// files below the configured path are written.
+func (s *gitSource) cloneOptions(refName plumbing.ReferenceName) *git.CloneOptions {
+	options := &git.CloneOptions{
+		URL:          s.remote,
+		Auth:         s.auth,
+		NoCheckout:   true,
+		Tags:         git.NoTags,
+		CABundle:     s.transport.caBundle,
+		ClientCert:   s.transport.clientCert,
+		ClientKey:    s.transport.clientKey,
+		ProxyOptions: s.transport.proxy,
+	}
+
+	ref := strings.TrimSpace(s.source.Ref)
+	if commitSHAPattern.MatchString(ref) {
+		return options
+	}
+
+	options.Depth = gitShallowDepth
+	options.SingleBranch = true
+	switch {
+	case refName != "" && refName != plumbing.HEAD:
+		options.ReferenceName = refName
+	case ref != "" && ref != gitHeadRevision:
+		options.ReferenceName = plumbing.NewBranchReferenceName(ref)
+	}
+
+	return options
+}
+
+func (s *gitSource) resolveCommit(repo *git.Repository) (*object.Commit, error) {
+	revision := strings.TrimSpace(s.source.Ref)
+	if revision == "" || (!config.IsLocalURL(s.remote) && !commitSHAPattern.MatchString(revision)) {
+		// A shallow clone checks the requested ref out as HEAD.
+		revision = gitHeadRevision
+	}
+
+	hash, err := repo.ResolveRevision(plumbing.Revision(revision))
+	if err != nil {
+		return nil, oops.
+			Code("GIT_ERROR").
+			With("source", s.name).
+			With("ref", s.source.Ref).
+			Hint("Check that ref names an existing branch, tag, or commit").
+			Wrapf(err, "resolving ref")
+	}
+
+	commit, err := repo.CommitObject(*hash)
+	if err != nil {
+		return nil, oops.
+			Code("GIT_ERROR").
+			With("source", s.name).
+			With("commit", hash.String()).
+			Wrapf(err, "reading commit")
+	}
+
+	return commit, nil
+}
+
+// collectBlobs returns the SHA of the object at the configured path and the
+// blob SHAs of every regular file below it, keyed by repository path.
+func (s *gitSource) collectBlobs(commit *object.Commit) (string, map[string]string, error) {
+	root, err := commit.Tree()
+	if err != nil {
+		return "", nil, oops.
+			Code("GIT_ERROR").
+			With("source", s.name).
+			With("commit", commit.Hash.String()).
+			Wrapf(err, "reading commit tree")
+	}
+
+	basePath := normalizeRepoPath(s.source.Path)
+	if basePath == "" {
+		blobs, walkErr := treeBlobs(root, "")
+		return root.Hash.String(), blobs, walkErr
+	}
+
+	entry, err := root.FindEntry(basePath)
+	if err != nil {
+		return "", nil, oops.
+			Code("GIT_ERROR").
+			With("source", s.name).
+			With("path", basePath).
+			Hint("Check repository path and ref in your config").
+			Wrapf(err, "finding path in commit %s", commit.Hash.String())
+	}
+
+	if entry.Mode != filemode.Dir {
+		return entry.Hash.String(), map[string]string{basePath: entry.Hash.String()}, nil
+	}
+
+	subtree, err := root.Tree(basePath)
+	if err != nil {
+		return "", nil, oops.
+			Code("GIT_ERROR").
+			With("source", s.name).
+			With("path", basePath).
+			Wrapf(err, "reading tree")
+	}
+
+	blobs, err := treeBlobs(subtree, basePath)
+	return entry.Hash.String(), blobs, err
+}
+
+// writeFiles writes the blobs of toDownload from the fetched repository and
+// returns the files it wrote. The fetch itself cannot be skipped, since the
+// tree listing comes from it, but blobs the shared cache already holds are
+// linked instead of rewritten.
+func (s *gitSource) writeFiles(
+	repo *git.Repository,
+	destDir string,
+	toDownload map[string]string,
+) (map[string]string, error) {
+	written := make(map[string]string, len(toDownload))
+	for _, relativePath := range sortedKeys(toDownload) {
+		content, err := readBlob(repo, toDownload[relativePath])
+		if err != nil {
+			return written, oops.
+				Code("GIT_ERROR").
+				With("source", s.name).
+				With("path", relativePath).
+				Wrapf(err, "reading blob")
+		}
+
+		if writeErr := writeSourceFile(s.name, destDir, relativePath, content); writeErr != nil {
+			return written, writeErr
+		}
+		written[relativePath] = toDownload[relativePath]
+	}
+
+	return written, nil
+}
+
+func (s *gitSource) remoteError(err error, action string) error {
+	builder := oops.
+		Code("GIT_ERROR").
+		With("source", s.name).
+		With("remote", config.RedactURL(s.remote))
+
+	if errors.Is(err, transport.ErrAuthenticationRequired) || errors.Is(err, transport.ErrAuthorizationFailed) {
+		builder = builder.Hint("The remote requires credentials; check access to the repository")
+	} else if errors.Is(err, transport.ErrRepositoryNotFound) {
+		builder = builder.Hint("Check the clone URL in your config")
+	}
+
+	return builder.Wrapf(err, "%s", action)
+}
+
+func treeBlobs(tree *object.Tree, basePath string) (map[string]string, error) {
+	blobs := make(map[string]string)
+	err := tree.Files().ForEach(func(file *object.File) error {
+		if file.Mode != filemode.Regular && file.Mode != filemode.Executable {
+			return nil
+		}
+
+		blobs[path.Join(basePath, file.Name)] = file.Hash.String()
+		return nil
+	})
+	if err != nil {
+		return nil, oops.
+			Code("GIT_ERROR").
+			With("path", basePath).
+			Wrapf(err, "walking tree")
+	}
+
+	return blobs, nil
+}
+
+func readBlob(repo *git.Repository, sha string) ([]byte, error) {
+	blob, err := repo.BlobObject(plumbing.NewHash(sha))
+	if err != nil {
+		return nil, err
+	}
+
+	reader, err := blob.Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	return io.ReadAll(reader)
+}
+
+func localFilePath(rawURL string) string {
+	if !strings.HasPrefix(rawURL, "file://") {
+		return rawURL
+	}
+
+	parsed, err := neturl.Parse(rawURL)
+	if err != nil {
+		return strings.TrimPrefix(rawURL, "file://")
+	}
+
+	return parsed.Path
+}
@@ -0,0 +1,415 @@
+package source_test
+
+import (
+	"context"
+	"net/http/cgi"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/osfs"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/cache"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/filesystem"
+
+	doxcache "github.com/g5becks/dox/internal/cache"
+	"github.com/g5becks/dox/internal/config"
+	"github.com/g5becks/dox/internal/source"
+)
+
+// testGitRepo is a bare repository on disk whose commits are staged through
+// an in-memory worktree.
+type testGitRepo struct {
+	dir      string
+	repo     *git.Repository
+	worktree billy.Filesystem
+}
+
+func newTestGitRepo(t *testing.T) *testGitRepo {
+	t.Helper()
+
+	dir := t.TempDir()
+	storage := filesystem.NewStorage(osfs.New(dir), cache.NewObjectLRUDefault())
+	worktree := memfs.New()
+
+	repo, err := git.Init(storage, worktree)
+	if err != nil {
+		t.Fatalf("git.Init() error = %v", err)
+	}
+
+	return &testGitRepo{dir: dir, repo: repo, worktree: worktree}
+}
+
+func (r *testGitRepo) commit(t *testing.T, files map[string]string, removed ...string) string {
+	t.Helper()
+
+	tree, err := r.repo.Worktree()
+	if err != nil {
+		t.Fatalf("Worktree() error = %v", err)
+	}
+
+	for name, content := range files {
+		if mkdirErr := r.worktree.MkdirAll(filepath.Dir(name), 0o755); mkdirErr != nil {
+			t.Fatalf("MkdirAll() error = %v", mkdirErr)
+		}
+
+		file, createErr := r.worktree.Create(name)
+		if createErr != nil {
+			t.Fatalf("Create() error = %v", createErr)
+		}
+		if _, writeErr := file.Write([]byte(content)); writeErr != nil {
+			t.Fatalf("Write() error = %v", writeErr)
+		}
+		_ = file.Close()
+
+		if _, addErr := tree.Add(name); addErr != nil {
+			t.Fatalf("Add() error = %v", addErr)
+		}
+	}
+
+	for _, name := range removed {
+		if _, removeErr := tree.Remove(name); removeErr != nil {
+			t.Fatalf("Remove() error = %v", removeErr)
+		}
+	}
+
+	hash, err := tree.Commit("update docs", &git.CommitOptions{
+		Author: &object.Signature{Name: "dox", Email: "dox@example.test", When: time.Now()},
+	})
+	if err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+
+	return hash.String()
+}
+
+// serveHTTP serves the repository over git's smart HTTP protocol through
+// git http-backend. allowSHA lets clients fetch any reachable commit by SHA.
+func (r *testGitRepo) serveHTTP(t *testing.T, allowSHA bool) string {
+	t.Helper()
+
+	gitPath, err := exec.LookPath("git")
+	if err != nil {
+		t.Skip("git is not installed")
+	}
+
+	cfg, err := r.repo.Config()
+	if err != nil {
+		t.Fatalf("Config() error = %v", err)
+	}
+	cfg.Raw.Section("uploadpack").SetOption("allowReachableSHA1InWant", strconv.FormatBool(allowSHA))
+	if err = r.repo.SetConfig(cfg); err != nil {
+		t.Fatalf("SetConfig() error = %v", err)
+	}
+
+	server := httptest.NewServer(&cgi.Handler{
+		Path: gitPath,
+		Args: []string{"http-backend"},
+		Env:  []string{"GIT_PROJECT_ROOT=" + filepath.Dir(r.dir), "GIT_HTTP_EXPORT_ALL=1"},
+	})
+	t.Cleanup(server.Close)
+
+	return server.URL + "/" + filepath.Base(r.dir)
+}
+
+func TestGitSyncLocalRepositoryDownloadsPath(t *testing.T) {
+	t.Parallel()
+
+	repo := newTestGitRepo(t)
+	commitSHA := repo.commit(t, map[string]string{
+		"docs/guide.md":       "# Guide",
+		"docs/nested/api.md":  "# API",
+		"docs/logo.png":       "png",
+		"src/main.go":         "package main",
+		"README.md":           "# Readme",
+		"docs/nested/ref.txt": "reference",
+	})
+
+	src, err := source.New("local", config.Source{Type: "git", URL: repo.dir, Path: "docs"}, "")
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	destDir := t.TempDir()
+	result, err := src.Sync(context.Background(), destDir, nil, source.SyncOptions{})
+	if err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+
+	if result.Downloaded != 3 {
+		t.Fatalf("Downloaded = %d, want 3", result.Downloaded)
+	}
+
+	if result.LockEntry.RefResolved != commitSHA {
+		t.Fatalf("RefResolved = %q, want %q", result.LockEntry.RefResolved, commitSHA)
+	}
+
+	if result.LockEntry.Ref != "master" {
+		t.Fatalf("Ref = %q, want checked out branch master", result.LockEntry.Ref)
+	}
+
+	if result.LockEntry.TreeSHA == "" {
+		t.Fatalf("TreeSHA is empty, want docs tree SHA")
+	}
+
+	content, err := os.ReadFile(filepath.Join(destDir, "nested", "api.md"))
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+
+	if string(content) != "# API" {
+		t.Fatalf("content = %q, want %q", string(content), "# API")
+	}
+
+	if _, statErr := os.Stat(filepath.Join(destDir, "logo.png")); !os.IsNotExist(statErr) {
+		t.Fatalf("expected logo.png to be filtered out")
+	}
+}
+
+func TestGitSyncSkipsWhenPathTreeUnchanged(t *testing.T) {
+	t.Parallel()
+
+	repo := newTestGitRepo(t)
+	repo.commit(t, map[string]string{"docs/guide.md": "# Guide", "src/main.go": "v1"})
+
+	src, err := source.New("local", config.Source{Type: "git", URL: "file://" + repo.dir, Path: "docs"}, "")
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	destDir := t.TempDir()
+	first, err := src.Sync(context.Background(), destDir, nil, source.SyncOptions{})
+	if err != nil {
+		t.Fatalf("first Sync() error = %v", err)
+	}
+
+	secondSHA := repo.commit(t, map[string]string{"src/main.go": "v2"})
+
+	second, err := src.Sync(context.Background(), destDir, first.LockEntry, source.SyncOptions{})
+	if err != nil {
+		t.Fatalf("second Sync() error = %v", err)
+	}
+
+	if !second.Skipped {
+		t.Fatalf("Skipped = false, want true when docs tree is unchanged")
+	}
+
+	if second.LockEntry.RefResolved != secondSHA {
+		t.Fatalf("RefResolved = %q, want %q", second.LockEntry.RefResolved, secondSHA)
+	}
+
+	repo.commit(t, map[string]string{"docs/new.md": "# New"}, "docs/guide.md")
+
+	third, err := src.Sync(context.Background(), destDir, second.LockEntry, source.SyncOptions{})
+	if err != nil {
+		t.Fatalf("third Sync() error = %v", err)
+	}
+
+	if third.Skipped || third.Downloaded != 1 || third.Deleted != 1 {
+		t.Fatalf("third Sync() = %+v, want 1 download and 1 delete", third)
+	}
+
+	if _, statErr := os.Stat(filepath.Join(destDir, "guide.md")); !os.IsNotExist(statErr) {
+		t.Fatalf("expected guide.md to be deleted")
+	}
+}
+
+func TestGitSyncResolvesTagRef(t *testing.T) {
+	t.Parallel()
+
+	repo := newTestGitRepo(t)
+	taggedSHA := repo.commit(t, map[string]string{"docs/guide.md": "# v1"})
+
+	head, err := repo.repo.Head()
+	if err != nil {
+		t.Fatalf("Head() error = %v", err)
+	}
+
+	if _, tagErr := repo.repo.CreateTag("v1.0.0", head.Hash(), &git.CreateTagOptions{
+		Tagger:  &object.Signature{Name: "dox", Email: "dox@example.test", When: time.Now()},
+		Message: "v1.0.0",
+	}); tagErr != nil {
+		t.Fatalf("CreateTag() error = %v", tagErr)
+	}
+
+	repo.commit(t, map[string]string{"docs/guide.md": "# v2"})
+
+	src, err := source.New("local", config.Source{Type: "git", URL: repo.dir, Path: "docs", Ref: "v1.0.0"}, "")
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	destDir := t.TempDir()
+	result, err := src.Sync(context.Background(), destDir, nil, source.SyncOptions{})
+	if err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+
+	if result.LockEntry.RefResolved != taggedSHA {
+		t.Fatalf("RefResolved = %q, want tagged commit %q", result.LockEntry.RefResolved, taggedSHA)
+	}
+
+	if result.LockEntry.Ref != "v1.0.0" {
+		t.Fatalf("Ref = %q, want v1.0.0", result.LockEntry.Ref)
+	}
+
+	content, err := os.ReadFile(filepath.Join(destDir, "guide.md"))
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+
+	if string(content) != "# v1" {
+		t.Fatalf("content = %q, want %q", string(content), "# v1")
+	}
+}
+
+func TestGitSyncResolvesVersionTag(t *testing.T) {
+	t.Parallel()
+
+	repo := newTestGitRepo(t)
+	for _, version := range []string{"v1.4.0", "v1.5.0", "v2.0.0"} {
+		repo.commit(t, map[string]string{"docs/guide.md": "# " + version})
+
+		head, err := repo.repo.Head()
+		if err != nil {
+			t.Fatalf("Head() error = %v", err)
+		}
+		if _, err = repo.repo.CreateTag(version, head.Hash(), nil); err != nil {
+			t.Fatalf("CreateTag() error = %v", err)
+		}
+	}
+
+	src, err := source.New("local", config.Source{Type: "git", URL: repo.dir, Path: "docs", Version: "^1.4"}, "")
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	destDir := t.TempDir()
+	if _, err = src.Sync(context.Background(), destDir, nil, source.SyncOptions{}); err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(destDir, "guide.md"))
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	if string(content) != "# v1.5.0" {
+		t.Fatalf("content = %q, want the highest tag matching ^1.4", string(content))
+	}
+}
+
+func TestGitSyncFetchesPinnedCommitShallowly(t *testing.T) {
+	t.Parallel()
+
+	for _, allowSHA := range []bool{true, false} {
+		t.Run("allow sha "+strconv.FormatBool(allowSHA), func(t *testing.T) {
+			t.Parallel()
+
+			repo := newTestGitRepo(t)
+			pinnedSHA := repo.commit(t, map[string]string{"docs/guide.md": "# v1"})
+			repo.commit(t, map[string]string{"docs/guide.md": "# v2"})
+			cfg := config.Source{Type: "git", URL: repo.serveHTTP(t, allowSHA), Path: "docs", Ref: pinnedSHA}
+
+			src, err := source.New("remote", cfg, "")
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+
+			destDir := t.TempDir()
+			result, err := src.Sync(context.Background(), destDir, nil, source.SyncOptions{})
+			if err != nil {
+				t.Fatalf("Sync() error = %v", err)
+			}
+			if result.LockEntry.RefResolved != pinnedSHA {
+				t.Fatalf("RefResolved = %q, want %q", result.LockEntry.RefResolved, pinnedSHA)
+			}
+
+			content, err := os.ReadFile(filepath.Join(destDir, "guide.md"))
+			if err != nil {
+				t.Fatalf("ReadFile() error = %v", err)
+			}
+			if string(content) != "# v1" {
+				t.Fatalf("content = %q, want the pinned commit's", string(content))
+			}
+
+			// Servers that hand out commits by SHA are fetched at depth 1,
+			// others fall back to full history.
+			shallow := source.GitShallowCommits(t, cfg)
+			if allowSHA != (len(shallow) == 1 && shallow[0] == pinnedSHA) {
+				t.Fatalf("shallow commits = %v with allowReachableSHA1InWant = %v", shallow, allowSHA)
+			}
+		})
+	}
+}
+
+func TestGitSyncSharesBlobsThroughCache(t *testing.T) {
+	t.Parallel()
+
+	content := "# Guide"
+	blobSHA := plumbing.ComputeHash(plumbing.BlobObject, []byte(content)).String()
+
+	repo := newTestGitRepo(t)
+	repo.commit(t, map[string]string{"docs/guide.md": content})
+
+	sharedCache, err := doxcache.Open(config.Cache{Dir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("cache.Open() error = %v", err)
+	}
+
+	for project := range 2 {
+		src, newErr := source.New("local", config.Source{Type: "git", URL: repo.dir, Path: "docs"}, "")
+		if newErr != nil {
+			t.Fatalf("New() error = %v", newErr)
+		}
+
+		destDir := t.TempDir()
+		result, syncErr := src.Sync(context.Background(), destDir, nil, source.SyncOptions{Cache: sharedCache})
+		if syncErr != nil {
+			t.Fatalf("project %d: Sync() error = %v", project, syncErr)
+		}
+		if result.Downloaded != 1 {
+			t.Fatalf("project %d: Downloaded = %d, want 1", project, result.Downloaded)
+		}
+
+		objectPath, cached := sharedCache.Path(doxcache.GitBlobKey(blobSHA))
+		if !cached {
+			t.Fatalf("project %d: blob %s missing from the cache", project, blobSHA)
+		}
+
+		written, statErr := os.Stat(filepath.Join(destDir, "guide.md"))
+		if statErr != nil {
+			t.Fatalf("project %d: Stat() error = %v", project, statErr)
+		}
+		object, statErr := os.Stat(objectPath)
+		if statErr != nil {
+			t.Fatalf("project %d: Stat() error = %v", project, statErr)
+		}
+		if project == 1 && !os.SameFile(written, object) {
+			t.Fatalf("guide.md of the second project is not linked from the cache")
+		}
+	}
+}
+
+func TestGitSyncMissingPathReturnsError(t *testing.T) {
+	t.Parallel()
+
+	repo := newTestGitRepo(t)
+	repo.commit(t, map[string]string{"docs/guide.md": "# Guide"})
+
+	src, err := source.New("local", config.Source{Type: "git", URL: repo.dir, Path: "missing"}, "")
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	if _, syncErr := src.Sync(context.Background(), t.TempDir(), nil, source.SyncOptions{}); syncErr == nil {
+		t.Fatalf("Sync() error = nil, want error for missing path")
+	}
+}
@@ -0,0 +1,628 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	neturl "net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/samber/oops"
+	"resty.dev/v3"
+
+	"github.com/g5becks/dox/internal/cache"
+	"github.com/g5becks/dox/internal/config"
+	"github.com/g5becks/dox/internal/lockfile"
+)
+
+const (
+	giteaDefaultHost  = "codeberg.org"
+	giteaAPIPath      = "/api/v1"
+	giteaTreePerPage  = 1000
+	giteaMaxTreePages = 100
+)
+
+// giteaSource syncs from any Gitea-compatible API, which covers Codeberg and
+// self-hosted Gitea or Forgejo instances.
+type giteaSource struct {
+	name        string
+	source      config.Source
+	owner       string
+	repo        string
+	client      *resty.Client
+	resolvedRef string
+	commit      string
+}
+
+type giteaTreeResponse struct {
+	SHA       string            `json:"sha"`
+	Truncated bool              `json:"truncated"`
+	Tree      []githubTreeEntry `json:"tree"`
+}
+
+// giteaContentEntry is one entry of a directory listing from the contents
+// endpoint.
+type giteaContentEntry struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	SHA  string `json:"sha"`
+}
+
+type giteaCommitResponse struct {
+	SHA string `json:"sha"`
+}
+
+func NewGitea(name string, cfg config.Source, token string) (Source, error) {
+	return newGiteaSource(name, cfg, token)
+}
+
+func newGiteaSource(name string, cfg config.Source, token string) (*giteaSource, error) {
+	owner, repo, err := parseRepo(cfg.Repo)
+	if err != nil {
+		return nil, err
+	}
+
+	client := newGiteaClient(giteaAPIBaseURL(cfg.Host), token)
+	if httpErr := applyHTTPSettings(client, name, cfg.HTTP); httpErr != nil {
+		return nil, httpErr
+	}
+
+	return &giteaSource{
+		name:   name,
+		source: cfg,
+		owner:  owner,
+		repo:   repo,
+		client: client,
+	}, nil
+}
+
+func (s *giteaSource) Close() error {
+	return s.client.Close()
+}
+
+func (s *giteaSource) Sync(
+	ctx context.Context,
+	destDir string,
+	prevLock *lockfile.LockEntry,
+	opts SyncOptions,
+) (*SyncResult, error) {
+	var result *SyncResult
+	var err error
+	if isSingleFilePath(s.source.Path) {
+		result, err = s.syncSingleFile(ctx, destDir, prevLock, opts)
+	} else {
+		result, err = s.syncDirectory(ctx, destDir, prevLock, opts)
+	}
+
+	return withRef(result, s.resolvedRef), err
+}
+
+func (s *giteaSource) syncSingleFile(
+	ctx context.Context,
+	destDir string,
+	prevLock *lockfile.LockEntry,
+	opts SyncOptions,
+) (*SyncResult, error) {
+	ref, err := s.resolveCommit(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	filePath := normalizeRepoPath(s.source.Path)
+	relativePath := path.Base(filePath)
+	sha, err := s.fetchContentSHA(ctx, ref, filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	oldSHA := ""
+	if prevLock != nil && prevLock.Files != nil {
+		oldSHA = prevLock.Files[relativePath]
+	}
+
+	if !opts.Force && oldSHA != "" && oldSHA == sha {
+		return skippedResult(prevLock, s.source.Type, ref), nil
+	}
+
+	if !opts.DryRun && !linkCachedFile(opts, destDir, relativePath, cache.GitBlobKey(sha)) {
+		content, fetchErr := s.fetchRawFile(ctx, ref, filePath)
+		if fetchErr != nil {
+			return nil, fetchErr
+		}
+
+		if writeErr := writeSourceFile(s.name, destDir, relativePath, content); writeErr != nil {
+			return nil, writeErr
+		}
+		cacheFile(opts, destDir, relativePath, cache.GitBlobKey(sha))
+	}
+
+	return &SyncResult{
+		Downloaded: 1,
+		LockEntry: &lockfile.LockEntry{
+			Type:        s.source.Type,
+			RefResolved: ref,
+			SyncedAt:    time.Now().UTC(),
+			Files: map[string]string{
+				relativePath: sha,
+			},
+		},
+	}, nil
+}
+
+func (s *giteaSource) syncDirectory(
+	ctx context.Context,
+	destDir string,
+	prevLock *lockfile.LockEntry,
+	opts SyncOptions,
+) (*SyncResult, error) {
+	ref, err := s.resolveCommit(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	treeSHA, blobs, err := s.fetchPathTree(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	if !opts.Force && prevLock != nil && prevLock.TreeSHA == treeSHA {
+		return skippedResult(prevLock, s.source.Type, ref), nil
+	}
+
+	newFiles, err := filterTreeFiles(blobs, s.source)
+	if err != nil {
+		return nil, err
+	}
+
+	oldFiles := map[string]string{}
+	if prevLock != nil && prevLock.Files != nil {
+		oldFiles = prevLock.Files
+	}
+
+	toDownload := diffDownloads(newFiles, oldFiles, opts.Force)
+	toDelete := diffDeletes(oldFiles, newFiles)
+
+	if !opts.DryRun {
+		if written, downloadErr := s.downloadFiles(ctx, destDir, toDownload, opts); downloadErr != nil {
+			return partialResult(s.source.Type, ref, oldFiles, newFiles, toDownload, written), downloadErr
+		}
+
+		if deleteErr := deleteStaleFiles(s.name, destDir, toDelete); deleteErr != nil {
+			return nil, deleteErr
+		}
+	}
+
+	return &SyncResult{
+		Downloaded: len(toDownload),
+		Deleted:    len(toDelete),
+		LockEntry: &lockfile.LockEntry{
+			Type:        s.source.Type,
+			TreeSHA:     treeSHA,
+			RefResolved: ref,
+			SyncedAt:    time.Now().UTC(),
+			Files:       newFiles,
+		},
+	}, nil
+}
+
+func (s *giteaSource) downloadFiles(
+	ctx context.Context,
+	destDir string,
+	toDownload map[string]string,
+	opts SyncOptions,
+) (map[string]string, error) {
+	return downloadCached(opts, destDir, toDownload, func(pending map[string]string) (map[string]string, error) {
+		return fetchConcurrently(ctx, opts, pending, func(ctx context.Context, relativePath string, sha string) error {
+			content, fetchErr := s.fetchBlobContent(ctx, sha)
+			if fetchErr != nil {
+				return fetchErr
+			}
+
+			return writeSourceFile(s.name, destDir, relativePath, content)
+		})
+	})
+}
+
+func (s *giteaSource) resolveRef(ctx context.Context) (string, error) {
+	if s.resolvedRef != "" {
+		return s.resolvedRef, nil
+	}
+
+	if s.source.Ref != "" {
+		s.resolvedRef = s.source.Ref
+		return s.resolvedRef, nil
+	}
+
+	if s.source.Version != "" {
+		tags, err := s.listTags(ctx)
+		if err != nil {
+			return "", err
+		}
+
+		s.resolvedRef, err = matchVersionTag(s.name, s.source.Repo, s.source.Version, tags)
+		return s.resolvedRef, err
+	}
+
+	result := &githubRepoResponse{}
+	response, err := s.client.R().
+		SetContext(ctx).
+		SetResult(result).
+		Get(s.repoEndpoint(""))
+	if err != nil {
+		return "", oops.
+			Code("GITEA_API_ERROR").
+			With("repo", s.source.Repo).
+			Wrapf(err, "fetching repository metadata")
+	}
+
+	if !response.IsSuccess() {
+		return "", oops.
+			Code("GITEA_API_ERROR").
+			With("repo", s.source.Repo).
+			With("status", response.StatusCode()).
+			Hint("Check that the repository exists and set gitea_token for private repositories").
+			Errorf("gitea API returned status %d for repository metadata", response.StatusCode())
+	}
+
+	if result.DefaultBranch == "" {
+		return "", oops.
+			Code("GITEA_API_ERROR").
+			With("repo", s.source.Repo).
+			Errorf("gitea repository metadata did not include default branch")
+	}
+
+	s.resolvedRef = result.DefaultBranch
+	return s.resolvedRef, nil
+}
+
+// resolveCommit pins the resolved ref to a commit SHA so every request of a
+// sync reads the same snapshot.
+func (s *giteaSource) resolveCommit(ctx context.Context) (string, error) {
+	if s.commit != "" {
+		return s.commit, nil
+	}
+
+	ref, err := s.resolveRef(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	if commitSHAPattern.MatchString(ref) {
+		s.commit = strings.ToLower(ref)
+		return s.commit, nil
+	}
+
+	result := []giteaCommitResponse{}
+	response, err := s.client.R().
+		SetContext(ctx).
+		SetQueryParam("sha", ref).
+		SetQueryParam("limit", "1").
+		SetQueryParam("stat", "false").
+		SetQueryParam("verification", "false").
+		SetQueryParam("files", "false").
+		SetResult(&result).
+		Get(s.repoEndpoint("/commits"))
+	if err != nil {
+		return "", oops.
+			Code("GITEA_API_ERROR").
+			With("repo", s.source.Repo).
+			With("ref", ref).
+			Wrapf(err, "resolving commit")
+	}
+
+	if !response.IsSuccess() {
+		return "", oops.
+			Code("GITEA_API_ERROR").
+			With("repo", s.source.Repo).
+			With("ref", ref).
+			With("status", response.StatusCode()).
+			Hint("Check that the branch, tag, or commit exists in the repository").
+			Errorf("gitea API returned status %d for commit of %q", response.StatusCode(), ref)
+	}
+
+	if len(result) == 0 || !commitSHAPattern.MatchString(result[0].SHA) {
+		return "", oops.
+			Code("GITEA_API_ERROR").
+			With("repo", s.source.Repo).
+			With("ref", ref).
+			Errorf("gitea API returned no commit SHA for %q", ref)
+	}
+
+	s.commit = strings.ToLower(result[0].SHA)
+	return s.commit, nil
+}
+
+// listTags returns the names of the repository's tags. Instances may cap
+// the page size below the requested limit, so pages are read until one
+// comes back empty.
+func (s *giteaSource) listTags(ctx context.Context) ([]string, error) {
+	tags := []string{}
+
+	for page := 1; page <= maxTagPages; page++ {
+		result := []githubTagResponse{}
+		response, err := s.client.R().
+			SetContext(ctx).
+			SetQueryParam("page", strconv.Itoa(page)).
+			SetQueryParam("limit", strconv.Itoa(tagsPerPage)).
+			SetResult(&result).
+			Get(s.repoEndpoint("/tags"))
+		if err != nil {
+			return nil, oops.
+				Code("GITEA_API_ERROR").
+				With("repo", s.source.Repo).
+				Wrapf(err, "listing tags")
+		}
+
+		if !response.IsSuccess() {
+			return nil, oops.
+				Code("GITEA_API_ERROR").
+				With("repo", s.source.Repo).
+				With("status", response.StatusCode()).
+				Hint("Check that the repository exists and set gitea_token for private repositories").
+				Errorf("gitea API returned status %d for tags", response.StatusCode())
+		}
+
+		if len(result) == 0 {
+			break
+		}
+		for _, tag := range result {
+			tags = append(tags, tag.Name)
+		}
+	}
+
+	return tags, nil
+}
+
+// fetchPathTree lists the blobs under the configured path, keyed by their
+// path in the repository, and returns the SHA of that path's own tree so
+// commits outside it leave the SHA the skip compares unchanged.
+func (s *giteaSource) fetchPathTree(ctx context.Context, ref string) (string, map[string]string, error) {
+	basePath := normalizeRepoPath(s.source.Path)
+	treeish := ref
+	if basePath != "" {
+		dirSHA, err := s.fetchDirSHA(ctx, ref, basePath)
+		if err != nil {
+			return "", nil, err
+		}
+		treeish = dirSHA
+	}
+
+	treeSHA, entries, err := s.fetchTree(ctx, treeish)
+	if err != nil {
+		return "", nil, err
+	}
+
+	blobs := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		if entry.Type == "blob" && entry.Path != "" && entry.SHA != "" {
+			blobs[path.Join(basePath, entry.Path)] = entry.SHA
+		}
+	}
+
+	return treeSHA, blobs, nil
+}
+
+// fetchDirSHA finds the tree SHA of dirPath at ref in the listing of its
+// parent directory, since Gitea's tree endpoint only accepts SHAs.
+func (s *giteaSource) fetchDirSHA(ctx context.Context, ref string, dirPath string) (string, error) {
+	endpoint := "/contents"
+	if parent := path.Dir(dirPath); parent != "." {
+		endpoint += "/" + escapeRepoPath(parent)
+	}
+
+	var listing []giteaContentEntry
+	response, err := s.client.R().
+		SetContext(ctx).
+		SetQueryParam("ref", ref).
+		SetResult(&listing).
+		Get(s.repoEndpoint(endpoint))
+	if err != nil {
+		return "", oops.
+			Code("GITEA_API_ERROR").
+			With("repo", s.source.Repo).
+			With("path", dirPath).
+			Wrapf(err, "listing parent directory")
+	}
+
+	if !response.IsSuccess() {
+		return "", oops.
+			Code("GITEA_API_ERROR").
+			With("repo", s.source.Repo).
+			With("path", dirPath).
+			With("status", response.StatusCode()).
+			Hint("Check repository path and ref in your config").
+			Errorf("gitea API returned status %d for the parent of %q", response.StatusCode(), dirPath)
+	}
+
+	name := path.Base(dirPath)
+	for _, entry := range listing {
+		if entry.Name == name && entry.Type == "dir" && entry.SHA != "" {
+			return entry.SHA, nil
+		}
+	}
+
+	return "", oops.
+		Code("GITEA_API_ERROR").
+		With("repo", s.source.Repo).
+		With("path", dirPath).
+		With("ref", ref).
+		Hint("Check repository path and ref in your config").
+		Errorf("directory %q not found at ref %s", dirPath, ref)
+}
+
+// fetchTree collects the recursive tree of a commit or tree SHA. Gitea
+// paginates large trees and marks every page but the last as truncated.
+func (s *giteaSource) fetchTree(ctx context.Context, ref string) (string, []githubTreeEntry, error) {
+	treeSHA := ""
+	var entries []githubTreeEntry
+
+	for page := 1; page <= giteaMaxTreePages; page++ {
+		result, err := s.fetchTreePage(ctx, ref, page)
+		if err != nil {
+			return "", nil, err
+		}
+
+		treeSHA = result.SHA
+		entries = append(entries, result.Tree...)
+
+		if !result.Truncated || len(result.Tree) == 0 {
+			return treeSHA, entries, nil
+		}
+	}
+
+	return "", nil, oops.
+		Code("GITEA_API_ERROR").
+		With("repo", s.source.Repo).
+		With("ref", ref).
+		Hint("Narrow the configured path to reduce tree size").
+		Errorf("gitea tree exceeded %d pages", giteaMaxTreePages)
+}
+
+func (s *giteaSource) fetchTreePage(ctx context.Context, ref string, page int) (*giteaTreeResponse, error) {
+	result := &giteaTreeResponse{}
+	response, err := s.client.R().
+		SetContext(ctx).
+		SetQueryParam("recursive", "true").
+		SetQueryParam("page", strconv.Itoa(page)).
+		SetQueryParam("per_page", strconv.Itoa(giteaTreePerPage)).
+		SetResult(result).
+		Get(s.repoEndpoint("/git/trees/" + neturl.PathEscape(ref)))
+	if err != nil {
+		return nil, oops.
+			Code("GITEA_API_ERROR").
+			With("repo", s.source.Repo).
+			With("ref", ref).
+			Wrapf(err, "fetching tree")
+	}
+
+	if !response.IsSuccess() {
+		return nil, oops.
+			Code("GITEA_API_ERROR").
+			With("repo", s.source.Repo).
+			With("ref", ref).
+			With("status", response.StatusCode()).
+			Hint("Check repository, path, and ref in your config").
+			Errorf("gitea API returned status %d for tree", response.StatusCode())
+	}
+
+	return result, nil
+}
+
+func (s *giteaSource) fetchContentSHA(ctx context.Context, ref string, filePath string) (string, error) {
+	result := &githubContentResponse{}
+	response, err := s.client.R().
+		SetContext(ctx).
+		SetQueryParam("ref", ref).
+		SetResult(result).
+		Get(s.repoEndpoint("/contents/" + escapeRepoPath(filePath)))
+	if err != nil {
+		return "", oops.
+			Code("GITEA_API_ERROR").
+			With("repo", s.source.Repo).
+			With("path", filePath).
+			Wrapf(err, "fetching content metadata")
+	}
+
+	if !response.IsSuccess() {
+		return "", oops.
+			Code("GITEA_API_ERROR").
+			With("repo", s.source.Repo).
+			With("path", filePath).
+			With("status", response.StatusCode()).
+			Hint("Check repository path and ref in your config").
+			Errorf("gitea API returned status %d for content metadata", response.StatusCode())
+	}
+
+	if result.Type != "file" || result.SHA == "" {
+		return "", oops.
+			Code("GITEA_API_ERROR").
+			With("repo", s.source.Repo).
+			With("path", filePath).
+			Errorf("expected file metadata for %q", filePath)
+	}
+
+	return result.SHA, nil
+}
+
+func (s *giteaSource) fetchBlobContent(ctx context.Context, sha string) ([]byte, error) {
+	result := &githubBlobResponse{}
+	response, err := s.client.R().
+		SetContext(ctx).
+		SetResult(result).
+		Get(s.repoEndpoint("/git/blobs/" + sha))
+	if err != nil {
+		return nil, oops.
+			Code("DOWNLOAD_FAILED").
+			With("repo", s.source.Repo).
+			With("sha", sha).
+			Wrapf(err, "downloading blob")
+	}
+
+	if !response.IsSuccess() {
+		return nil, oops.
+			Code("GITEA_API_ERROR").
+			With("repo", s.source.Repo).
+			With("sha", sha).
+			With("status", response.StatusCode()).
+			Errorf("gitea API returned status %d for blob", response.StatusCode())
+	}
+
+	return decodeBlobContent(s.source.Repo, sha, result)
+}
+
+func (s *giteaSource) fetchRawFile(ctx context.Context, ref string, filePath string) ([]byte, error) {
+	response, err := s.client.R().
+		SetContext(ctx).
+		SetQueryParam("ref", ref).
+		Get(s.repoEndpoint("/raw/" + escapeRepoPath(filePath)))
+	if err != nil {
+		return nil, oops.
+			Code("DOWNLOAD_FAILED").
+			With("repo", s.source.Repo).
+			With("path", filePath).
+			Wrapf(err, "downloading raw file")
+	}
+
+	if !response.IsSuccess() {
+		return nil, oops.
+			Code("GITEA_API_ERROR").
+			With("repo", s.source.Repo).
+			With("path", filePath).
+			With("status", response.StatusCode()).
+			Errorf("gitea API returned status %d for raw file", response.StatusCode())
+	}
+
+	return response.Bytes(), nil
+}
+
+func (s *giteaSource) repoEndpoint(suffix string) string {
+	return fmt.Sprintf("/repos/%s/%s%s", s.owner, s.repo, suffix)
+}
+
+func giteaAPIBaseURL(host string) string {
+	host = strings.TrimSpace(host)
+	if host == "" {
+		host = giteaDefaultHost
+	}
+
+	return "https://" + host + giteaAPIPath
+}
+
+func newGiteaClient(baseURL string, token string) *resty.Client {
+	client := resty.New()
+	client.SetBaseURL(baseURL)
+	client.SetHeader("Accept", "application/json")
+	client.SetHeader("User-Agent", userAgent)
+	client.SetRetryCount(httpRetryCount)
+	client.SetRetryWaitTime(1 * time.Second)
+	client.SetRetryMaxWaitTime(httpRetryMaxWaitSec * time.Second)
+
+	if token != "" {
+		client.SetAuthScheme("token")
+		client.SetAuthToken(token)
+	}
+
+	return client
+}
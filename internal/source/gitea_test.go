@@ -0,0 +1,326 @@
+package source_test
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/go-git/go-git/v5/plumbing"
+
+	"github.com/g5becks/dox/internal/cache"
+	"github.com/g5becks/dox/internal/config"
+	"github.com/g5becks/dox/internal/lockfile"
+	"github.com/g5becks/dox/internal/source"
+)
+
+const giteaMainCommit = "9c3e1f0a7b5d2c4e6f8a0b1c3d5e7f9a2b4c6d8e"
+
+func newGiteaTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	writeJSON := func(w http.ResponseWriter, body string) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(body))
+	}
+
+	mux.HandleFunc("GET /api/v1/repos/acme/widgets", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "token secret" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		writeJSON(w, `{"default_branch":"main"}`)
+	})
+
+	mux.HandleFunc("GET /api/v1/repos/acme/widgets/commits", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("sha") != "main" || r.URL.Query().Get("limit") != "1" {
+			writeJSON(w, `[]`)
+			return
+		}
+
+		writeJSON(w, `[{"sha":"`+giteaMainCommit+`"}]`)
+	})
+
+	mux.HandleFunc("GET /api/v1/repos/acme/widgets/contents", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("ref") != giteaMainCommit {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		writeJSON(w, `[
+  {"name":"README.md","type":"file","sha":"sha-readme"},
+  {"name":"docs","type":"dir","sha":"tree-docs"}
+]`)
+	})
+
+	mux.HandleFunc("GET /api/v1/repos/acme/widgets/git/trees/tree-docs", func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("page") {
+		case "1":
+			writeJSON(w, `{"sha":"tree-docs","truncated":true,"tree":[
+  {"path":"a.md","type":"blob","sha":"sha-a"},
+  {"path":"sub","type":"tree","sha":"sha-sub"}
+]}`)
+		case "2":
+			writeJSON(w, `{"sha":"tree-docs","truncated":false,"tree":[
+  {"path":"sub/b.md","type":"blob","sha":"sha-b"}
+]}`)
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	})
+
+	mux.HandleFunc("GET /api/v1/repos/acme/widgets/contents/docs", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, `[
+  {"name":"a.md","type":"file","sha":"sha-a"},
+  {"name":"sub","type":"dir","sha":"sha-sub"}
+]`)
+	})
+
+	mux.HandleFunc("GET /api/v1/repos/acme/widgets/git/trees/sha-sub", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, `{"sha":"sha-sub","truncated":false,"tree":[{"path":"b.md","type":"blob","sha":"sha-b"}]}`)
+	})
+
+	blobs := map[string]string{"sha-a": "alpha", "sha-b": "bravo"}
+	mux.HandleFunc("GET /api/v1/repos/acme/widgets/git/blobs/{sha}", func(w http.ResponseWriter, r *http.Request) {
+		content, ok := blobs[r.PathValue("sha")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		writeJSON(w, `{"encoding":"base64","content":"`+base64.StdEncoding.EncodeToString([]byte(content))+`"}`)
+	})
+
+	mux.HandleFunc("GET /api/v1/repos/acme/widgets/contents/docs/guide.md", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, `{"type":"file","sha":"sha-guide"}`)
+	})
+
+	mux.HandleFunc("GET /api/v1/repos/acme/widgets/raw/docs/guide.md", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("ref") != giteaMainCommit {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		_, _ = w.Write([]byte("guide body"))
+	})
+
+	return server
+}
+
+func TestGiteaSyncDirectoryFollowsTreePages(t *testing.T) {
+	t.Parallel()
+
+	server := newGiteaTestServer(t)
+	src := source.TestableGiteaSource(t, "widgets", config.Source{
+		Type:     "codeberg",
+		Repo:     "acme/widgets",
+		Path:     "docs",
+		Patterns: []string{"**/*.md"},
+	}, server.URL+"/api/v1", "secret")
+
+	destDir := t.TempDir()
+
+	result, err := src.Sync(context.Background(), destDir, nil, source.SyncOptions{})
+	if err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+
+	if result.Downloaded != 2 {
+		t.Fatalf("Downloaded = %d, want 2", result.Downloaded)
+	}
+
+	if result.LockEntry.Type != "codeberg" || result.LockEntry.TreeSHA != "tree-docs" {
+		t.Fatalf("LockEntry = %+v, want codeberg entry with the docs tree tree-docs", result.LockEntry)
+	}
+
+	if result.LockEntry.RefResolved != giteaMainCommit || result.LockEntry.Ref != "main" {
+		t.Fatalf("LockEntry refs = %q/%q, want commit %q of main",
+			result.LockEntry.Ref, result.LockEntry.RefResolved, giteaMainCommit)
+	}
+
+	if result.LockEntry.Files["sub/b.md"] != "sha-b" {
+		t.Fatalf("Files[sub/b.md] = %q, want sha-b", result.LockEntry.Files["sub/b.md"])
+	}
+
+	content, err := os.ReadFile(filepath.Join(destDir, "sub", "b.md"))
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+
+	if string(content) != "bravo" {
+		t.Fatalf("file content = %q, want bravo", string(content))
+	}
+}
+
+func TestGiteaSyncSkipsWhenTreeSHAUnchanged(t *testing.T) {
+	t.Parallel()
+
+	server := newGiteaTestServer(t)
+	src := source.TestableGiteaSource(t, "widgets", config.Source{
+		Type: "gitea",
+		Repo: "acme/widgets",
+		Path: "docs",
+		Ref:  "main",
+	}, server.URL+"/api/v1", "")
+
+	prevLock := &lockfile.LockEntry{
+		Type:    "gitea",
+		TreeSHA: "tree-docs",
+		Files:   map[string]string{"a.md": "sha-a"},
+	}
+
+	result, err := src.Sync(context.Background(), t.TempDir(), prevLock, source.SyncOptions{})
+	if err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+
+	if !result.Skipped {
+		t.Fatalf("Skipped = %v, want true", result.Skipped)
+	}
+
+	if result.LockEntry.Files["a.md"] != "sha-a" {
+		t.Fatalf("Files = %v, want previous files preserved", result.LockEntry.Files)
+	}
+}
+
+func TestGiteaSyncResyncsWhenPathChangesAtSameCommit(t *testing.T) {
+	t.Parallel()
+
+	server := newGiteaTestServer(t)
+	src := source.TestableGiteaSource(t, "widgets", config.Source{
+		Type: "gitea",
+		Repo: "acme/widgets",
+		Path: "docs/sub",
+		Ref:  "main",
+	}, server.URL+"/api/v1", "")
+
+	destDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(destDir, "a.md"), []byte("alpha"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	prevLock := &lockfile.LockEntry{
+		Type:        "gitea",
+		TreeSHA:     "tree-docs",
+		RefResolved: giteaMainCommit,
+		Files:       map[string]string{"a.md": "sha-a", "sub/b.md": "sha-b"},
+	}
+
+	result, err := src.Sync(context.Background(), destDir, prevLock, source.SyncOptions{})
+	if err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+
+	if result.Skipped || result.LockEntry.TreeSHA != "sha-sub" {
+		t.Fatalf("Sync() = %+v, want a sync of the docs/sub tree sha-sub", result)
+	}
+
+	if len(result.LockEntry.Files) != 1 || result.LockEntry.Files["b.md"] != "sha-b" {
+		t.Fatalf("Files = %v, want only b.md", result.LockEntry.Files)
+	}
+
+	if _, statErr := os.Stat(filepath.Join(destDir, "a.md")); !os.IsNotExist(statErr) {
+		t.Fatalf("expected a.md of the old path to be deleted")
+	}
+}
+
+func TestGiteaSyncSingleFileUsesRawEndpoint(t *testing.T) {
+	t.Parallel()
+
+	server := newGiteaTestServer(t)
+	src := source.TestableGiteaSource(t, "widgets", config.Source{
+		Type: "forgejo",
+		Repo: "acme/widgets",
+		Path: "docs/guide.md",
+		Ref:  "main",
+	}, server.URL+"/api/v1", "")
+
+	destDir := t.TempDir()
+
+	result, err := src.Sync(context.Background(), destDir, nil, source.SyncOptions{})
+	if err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+
+	if result.LockEntry.Files["guide.md"] != "sha-guide" {
+		t.Fatalf("Files = %v, want guide.md => sha-guide", result.LockEntry.Files)
+	}
+
+	content, err := os.ReadFile(filepath.Join(destDir, "guide.md"))
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+
+	if string(content) != "guide body" {
+		t.Fatalf("file content = %q, want %q", string(content), "guide body")
+	}
+}
+
+func TestGiteaSyncLinksBlobsFromSharedCache(t *testing.T) {
+	t.Parallel()
+
+	content := "# Shared\n"
+	blobSHA := plumbing.ComputeHash(plumbing.BlobObject, []byte(content)).String()
+
+	var blobRequests atomic.Int32
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	mux.HandleFunc("GET /api/v1/repos/acme/widgets/contents", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`[{"name":"docs","type":"dir","sha":"tree-docs"}]`))
+	})
+	mux.HandleFunc("GET /api/v1/repos/acme/widgets/git/trees/tree-docs", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"sha":"tree-docs","tree":[{"path":"shared.md","type":"blob","sha":"` + blobSHA + `"}]}`))
+	})
+	mux.HandleFunc("GET /api/v1/repos/acme/widgets/git/blobs/{sha}", func(w http.ResponseWriter, _ *http.Request) {
+		blobRequests.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"encoding":"base64","content":"` + base64.StdEncoding.EncodeToString([]byte(content)) + `"}`))
+	})
+
+	sharedCache, err := cache.Open(config.Cache{Dir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("cache.Open() error = %v", err)
+	}
+
+	cfg := config.Source{
+		Type:     "gitea",
+		Repo:     "acme/widgets",
+		Path:     "docs",
+		Ref:      giteaMainCommit,
+		Patterns: []string{"**/*.md"},
+	}
+	for project := range 2 {
+		src := source.TestableGiteaSource(t, "widgets", cfg, server.URL+"/api/v1", "")
+		destDir := t.TempDir()
+
+		result, syncErr := src.Sync(context.Background(), destDir, nil, source.SyncOptions{Cache: sharedCache})
+		if syncErr != nil {
+			t.Fatalf("project %d: Sync() error = %v", project, syncErr)
+		}
+		if result.Downloaded != 1 {
+			t.Fatalf("project %d: Downloaded = %d, want 1", project, result.Downloaded)
+		}
+
+		written, readErr := os.ReadFile(filepath.Join(destDir, "shared.md"))
+		if readErr != nil || string(written) != content {
+			t.Fatalf("project %d: shared.md = %q, %v, want %q", project, written, readErr, content)
+		}
+	}
+
+	if got := blobRequests.Load(); got != 1 {
+		t.Fatalf("blob requests = %d, want 1 (second project served from the cache)", got)
+	}
+}
@@ -17,17 +17,20 @@ import (
 	"github.com/samber/oops"
 	"resty.dev/v3"
 
+	"github.com/g5becks/dox/internal/cache"
 	"github.com/g5becks/dox/internal/config"
 	"github.com/g5becks/dox/internal/lockfile"
 )
 
 const (
-	sourceTypeGitHub    = "github"
-	githubAPIBaseURL    = "https://api.github.com"
-	userAgent           = "dox"
-	httpRetryCount      = 3
-	httpRetryMaxWaitSec = 5
-	rateLimitWarnThresh = 10
+	sourceTypeGitHub        = "github"
+	githubDefaultHost       = "github.com"
+	githubPublicAPIURL      = "https://api.github.com"
+	githubEnterpriseAPIPath = "/api/v3"
+	userAgent               = "dox"
+	httpRetryCount          = 3
+	httpRetryMaxWaitSec     = 5
+	rateLimitWarnThresh     = 10
 )
 
 type githubSource struct {
@@ -36,8 +39,10 @@ type githubSource struct {
 	owner       string
 	repo        string
 	client      *resty.Client
+	rawBaseURL  string
 	resolvedRef string
-	warnedLowRL bool
+	commit      string
+	quota       *QuotaTracker
 }
 
 type githubTreeResponse struct {
@@ -56,6 +61,10 @@ type githubRepoResponse struct {
 	DefaultBranch string `json:"default_branch"`
 }
 
+type githubTagResponse struct {
+	Name string `json:"name"`
+}
+
 type githubContentResponse struct {
 	Type string `json:"type"`
 	SHA  string `json:"sha"`
@@ -72,12 +81,19 @@ func newGitHubSource(name string, cfg config.Source, token string) (Source, erro
 		return nil, err
 	}
 
+	client := newGitHubClient(githubAPIBaseURL(cfg.Host, cfg.APIURL), token)
+	if httpErr := applyHTTPSettings(client, name, cfg.HTTP); httpErr != nil {
+		return nil, httpErr
+	}
+
 	return &githubSource{
-		name:   name,
-		source: cfg,
-		owner:  owner,
-		repo:   repo,
-		client: newGitHubClient(token),
+		name:       name,
+		source:     cfg,
+		owner:      owner,
+		repo:       repo,
+		client:     client,
+		rawBaseURL: githubRawBaseURL(cfg.Host),
+		quota:      NewQuotaTracker(false, 0, nil),
 	}, nil
 }
 
@@ -91,11 +107,19 @@ func (s *githubSource) Sync(
 	prevLock *lockfile.LockEntry,
 	opts SyncOptions,
 ) (*SyncResult, error) {
+	if opts.GitHubQuota != nil {
+		s.quota = opts.GitHubQuota
+	}
+
+	var result *SyncResult
+	var err error
 	if isSingleFilePath(s.source.Path) {
-		return s.syncSingleFile(ctx, destDir, prevLock, opts)
+		result, err = s.syncSingleFile(ctx, destDir, prevLock, opts)
+	} else {
+		result, err = s.syncDirectory(ctx, destDir, prevLock, opts)
 	}
 
-	return s.syncDirectory(ctx, destDir, prevLock, opts)
+	return withRef(result, s.resolvedRef), err
 }
 
 func (s *githubSource) syncSingleFile(
@@ -104,7 +128,7 @@ func (s *githubSource) syncSingleFile(
 	prevLock *lockfile.LockEntry,
 	opts SyncOptions,
 ) (*SyncResult, error) {
-	ref, err := s.resolveRef(ctx)
+	ref, err := s.resolveCommit(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -122,39 +146,21 @@ func (s *githubSource) syncSingleFile(
 	}
 
 	if !opts.Force && oldSHA != "" && oldSHA == sha {
-		lockEntry := cloneLockEntry(prevLock)
-		if lockEntry == nil {
-			lockEntry = &lockfile.LockEntry{Type: sourceTypeGitHub}
-		}
-
-		lockEntry.Type = sourceTypeGitHub
-		lockEntry.RefResolved = ref
-		lockEntry.SyncedAt = time.Now().UTC()
-
-		return &SyncResult{
-			Skipped:   true,
-			LockEntry: lockEntry,
-		}, nil
+		return skippedResult(prevLock, sourceTypeGitHub, ref), nil
 	}
 
-	if !opts.DryRun {
-		content, fetchErr := s.fetchBlobContent(ctx, sha)
+	if !opts.DryRun && !linkCachedFile(opts, destDir, relativePath, cache.GitBlobKey(sha)) {
+		// A tarball never pays off for a single file.
+		strategy := s.downloadStrategy(1)
+		content, fetchErr := s.fetchFileContent(ctx, strategy, ref, filePath, sha)
 		if fetchErr != nil {
 			return nil, fetchErr
 		}
 
-		localPath := filepath.Join(destDir, filepath.FromSlash(relativePath))
-		if mkdirErr := os.MkdirAll(filepath.Dir(localPath), 0o750); mkdirErr != nil {
-			return nil, oops.
-				Code("WRITE_FAILED").
-				With("source", s.name).
-				With("path", filepath.Dir(localPath)).
-				Wrapf(mkdirErr, "creating destination directory")
-		}
-
-		if writeErr := writeFileAtomic(localPath, content); writeErr != nil {
+		if writeErr := writeSourceFile(s.name, destDir, relativePath, content); writeErr != nil {
 			return nil, writeErr
 		}
+		cacheFile(opts, destDir, relativePath, cache.GitBlobKey(sha))
 	}
 
 	return &SyncResult{
@@ -176,33 +182,21 @@ func (s *githubSource) syncDirectory(
 	prevLock *lockfile.LockEntry,
 	opts SyncOptions,
 ) (*SyncResult, error) {
-	ref, err := s.resolveRef(ctx)
+	ref, err := s.resolveCommit(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	tree, err := s.fetchTree(ctx, ref)
+	treeSHA, blobs, err := s.fetchPathTree(ctx, ref)
 	if err != nil {
 		return nil, err
 	}
 
-	if !opts.Force && prevLock != nil && prevLock.TreeSHA == tree.SHA {
-		lockEntry := cloneLockEntry(prevLock)
-		if lockEntry == nil {
-			lockEntry = &lockfile.LockEntry{Type: sourceTypeGitHub}
-		}
-
-		lockEntry.Type = sourceTypeGitHub
-		lockEntry.RefResolved = ref
-		lockEntry.SyncedAt = time.Now().UTC()
-
-		return &SyncResult{
-			Skipped:   true,
-			LockEntry: lockEntry,
-		}, nil
+	if !opts.Force && prevLock != nil && prevLock.TreeSHA == treeSHA {
+		return skippedResult(prevLock, sourceTypeGitHub, ref), nil
 	}
 
-	newFiles, err := s.buildFileMap(tree.Tree)
+	newFiles, err := filterTreeFiles(blobs, s.source)
 	if err != nil {
 		return nil, err
 	}
@@ -224,11 +218,11 @@ func (s *githubSource) syncDirectory(
 				Wrapf(mkdirErr, "creating destination directory")
 		}
 
-		if downloadErr := s.downloadFiles(ctx, destDir, toDownload); downloadErr != nil {
-			return nil, downloadErr
+		if written, downloadErr := s.downloadFiles(ctx, destDir, ref, toDownload, opts); downloadErr != nil {
+			return partialResult(sourceTypeGitHub, ref, oldFiles, newFiles, toDownload, written), downloadErr
 		}
 
-		if deleteErr := s.deleteStaleFiles(destDir, toDelete); deleteErr != nil {
+		if deleteErr := deleteStaleFiles(s.name, destDir, toDelete); deleteErr != nil {
 			return nil, deleteErr
 		}
 	}
@@ -238,7 +232,7 @@ func (s *githubSource) syncDirectory(
 		Deleted:    len(toDelete),
 		LockEntry: &lockfile.LockEntry{
 			Type:        sourceTypeGitHub,
-			TreeSHA:     tree.SHA,
+			TreeSHA:     treeSHA,
 			RefResolved: ref,
 			SyncedAt:    time.Now().UTC(),
 			Files:       newFiles,
@@ -246,46 +240,41 @@ func (s *githubSource) syncDirectory(
 	}, nil
 }
 
-func (s *githubSource) downloadFiles(ctx context.Context, destDir string, toDownload map[string]string) error {
-	for _, relativePath := range sortedKeys(toDownload) {
-		sha := toDownload[relativePath]
-		content, fetchErr := s.fetchBlobContent(ctx, sha)
-		if fetchErr != nil {
-			return fetchErr
-		}
-
-		localPath := filepath.Join(destDir, filepath.FromSlash(relativePath))
-		if mkdirErr := os.MkdirAll(filepath.Dir(localPath), 0o750); mkdirErr != nil {
-			return oops.
-				Code("WRITE_FAILED").
-				With("source", s.name).
-				With("path", filepath.Dir(localPath)).
-				Wrapf(mkdirErr, "creating destination directory")
-		}
-
-		if writeErr := writeFileAtomic(localPath, content); writeErr != nil {
-			return writeErr
+func (s *githubSource) downloadFiles(
+	ctx context.Context,
+	destDir string,
+	ref string,
+	toDownload map[string]string,
+	opts SyncOptions,
+) (map[string]string, error) {
+	return downloadCached(opts, destDir, toDownload, func(pending map[string]string) (map[string]string, error) {
+		strategy := s.downloadStrategy(len(pending))
+		if strategy == downloadTarball {
+			return s.downloadTarball(ctx, destDir, ref, pending, opts)
 		}
-	}
 
-	return nil
+		return s.fetchFiles(ctx, destDir, ref, strategy, pending, opts)
+	})
 }
 
-func (s *githubSource) deleteStaleFiles(destDir string, toDelete map[string]struct{}) error {
-	for _, relativePath := range sortedKeys(toDelete) {
-		localPath := filepath.Join(destDir, filepath.FromSlash(relativePath))
-		if removeErr := os.Remove(localPath); removeErr != nil && !os.IsNotExist(removeErr) {
-			return oops.
-				Code("WRITE_FAILED").
-				With("source", s.name).
-				With("path", localPath).
-				Wrapf(removeErr, "deleting stale file")
+// fetchFiles downloads files one by one with the blob API or the raw endpoint.
+func (s *githubSource) fetchFiles(
+	ctx context.Context,
+	destDir string,
+	ref string,
+	strategy string,
+	toDownload map[string]string,
+	opts SyncOptions,
+) (map[string]string, error) {
+	basePath := normalizeRepoPath(s.source.Path)
+	return fetchConcurrently(ctx, opts, toDownload, func(ctx context.Context, relativePath string, sha string) error {
+		content, fetchErr := s.fetchFileContent(ctx, strategy, ref, path.Join(basePath, relativePath), sha)
+		if fetchErr != nil {
+			return fetchErr
 		}
 
-		cleanupEmptyDirs(filepath.Dir(localPath), destDir)
-	}
-
-	return nil
+		return writeSourceFile(s.name, destDir, relativePath, content)
+	})
 }
 
 func (s *githubSource) resolveRef(ctx context.Context) (string, error) {
@@ -298,13 +287,25 @@ func (s *githubSource) resolveRef(ctx context.Context) (string, error) {
 		return s.resolvedRef, nil
 	}
 
+	if s.source.Version != "" {
+		tags, err := s.listTags(ctx)
+		if err != nil {
+			return "", err
+		}
+
+		s.resolvedRef, err = matchVersionTag(s.name, s.source.Repo, s.source.Version, tags)
+		return s.resolvedRef, err
+	}
+
 	endpoint := fmt.Sprintf("/repos/%s/%s", s.owner, s.repo)
 	result := &githubRepoResponse{}
 
-	response, err := s.client.R().
-		SetContext(ctx).
-		SetResult(result).
-		Get(endpoint)
+	response, err := s.do(ctx, func() (*resty.Response, error) {
+		return s.client.R().
+			SetContext(ctx).
+			SetResult(result).
+			Get(endpoint)
+	})
 	if err != nil {
 		return "", oops.
 			Code("GITHUB_API_ERROR").
@@ -321,10 +322,6 @@ func (s *githubSource) resolveRef(ctx context.Context) (string, error) {
 			Errorf("github API returned status %d for repository metadata", response.StatusCode())
 	}
 
-	if rlErr := s.checkRateLimit(response); rlErr != nil {
-		return "", rlErr
-	}
-
 	if result.DefaultBranch == "" {
 		return "", oops.
 			Code("GITHUB_API_ERROR").
@@ -336,44 +333,205 @@ func (s *githubSource) resolveRef(ctx context.Context) (string, error) {
 	return s.resolvedRef, nil
 }
 
-func (s *githubSource) fetchTree(ctx context.Context, ref string) (*githubTreeResponse, error) {
-	endpoint := fmt.Sprintf("/repos/%s/%s/git/trees/%s", s.owner, s.repo, neturl.PathEscape(ref))
-	result := &githubTreeResponse{}
+// resolveCommit resolves the ref to the commit it points at, so every
+// request of a sync reads the same snapshot and the lock records exactly
+// what was synced.
+func (s *githubSource) resolveCommit(ctx context.Context) (string, error) {
+	if s.commit != "" {
+		return s.commit, nil
+	}
 
-	response, err := s.client.R().
-		SetContext(ctx).
-		SetQueryParam("recursive", "1").
-		SetResult(result).
-		Get(endpoint)
+	ref, err := s.resolveRef(ctx)
 	if err != nil {
-		return nil, oops.
+		return "", err
+	}
+
+	if commitSHAPattern.MatchString(ref) {
+		s.commit = strings.ToLower(ref)
+		return s.commit, nil
+	}
+
+	endpoint := fmt.Sprintf("/repos/%s/%s/commits/%s", s.owner, s.repo, ref)
+	response, err := s.do(ctx, func() (*resty.Response, error) {
+		return s.client.R().
+			SetContext(ctx).
+			SetHeader("Accept", "application/vnd.github.sha").
+			Get(endpoint)
+	})
+	if err != nil {
+		return "", oops.
 			Code("GITHUB_API_ERROR").
 			With("repo", s.source.Repo).
 			With("ref", ref).
-			Wrapf(err, "fetching tree")
+			Wrapf(err, "resolving commit")
 	}
 
 	if !response.IsSuccess() {
-		return nil, oops.
+		return "", oops.
 			Code("GITHUB_API_ERROR").
 			With("repo", s.source.Repo).
 			With("ref", ref).
 			With("status", response.StatusCode()).
-			Hint("Check repository, path, and ref in your config").
-			Errorf("github API returned status %d for tree", response.StatusCode())
+			Hint("Check that the branch, tag, or commit exists in the repository").
+			Errorf("github API returned status %d for commit of %q", response.StatusCode(), ref)
 	}
 
-	if rlErr := s.checkRateLimit(response); rlErr != nil {
-		return nil, rlErr
+	commit := strings.TrimSpace(response.String())
+	if !commitSHAPattern.MatchString(commit) {
+		return "", oops.
+			Code("GITHUB_API_ERROR").
+			With("repo", s.source.Repo).
+			With("ref", ref).
+			Errorf("github API returned no commit SHA for %q", ref)
 	}
 
-	if result.Truncated {
+	s.commit = strings.ToLower(commit)
+	return s.commit, nil
+}
+
+// listTags returns the names of the repository's tags.
+func (s *githubSource) listTags(ctx context.Context) ([]string, error) {
+	endpoint := fmt.Sprintf("/repos/%s/%s/tags", s.owner, s.repo)
+	tags := []string{}
+
+	for page := 1; page <= maxTagPages; page++ {
+		result := []githubTagResponse{}
+		response, err := s.do(ctx, func() (*resty.Response, error) {
+			return s.client.R().
+				SetContext(ctx).
+				SetQueryParam("per_page", strconv.Itoa(tagsPerPage)).
+				SetQueryParam("page", strconv.Itoa(page)).
+				SetResult(&result).
+				Get(endpoint)
+		})
+		if err != nil {
+			return nil, oops.
+				Code("GITHUB_API_ERROR").
+				With("repo", s.source.Repo).
+				Wrapf(err, "listing tags")
+		}
+
+		if !response.IsSuccess() {
+			return nil, oops.
+				Code("GITHUB_API_ERROR").
+				With("repo", s.source.Repo).
+				With("status", response.StatusCode()).
+				Hint("Check that the repository exists and is accessible").
+				Errorf("github API returned status %d for tags", response.StatusCode())
+		}
+
+		for _, tag := range result {
+			tags = append(tags, tag.Name)
+		}
+		if len(result) < tagsPerPage {
+			break
+		}
+	}
+
+	return tags, nil
+}
+
+// fetchPathTree lists the blobs below the configured path, keyed by repository
+// path, and returns the SHA of the path's own tree. The subtree is requested
+// directly so the size of the rest of the repository does not matter; when
+// GitHub still truncates the recursive listing, the subtree is walked one
+// tree at a time instead.
+func (s *githubSource) fetchPathTree(ctx context.Context, ref string) (string, map[string]string, error) {
+	basePath := normalizeRepoPath(s.source.Path)
+	treeish := neturl.PathEscape(ref)
+	if basePath != "" {
+		treeish += ":" + escapeRepoPath(basePath)
+	}
+
+	tree, err := s.fetchTree(ctx, treeish, true)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if !tree.Truncated {
+		blobs := make(map[string]string, len(tree.Tree))
+		collectTreeBlobs(blobs, basePath, tree.Tree)
+		return tree.SHA, blobs, nil
+	}
+
+	blobs, err := s.walkTree(ctx, tree.SHA, basePath)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return tree.SHA, blobs, nil
+}
+
+// walkTree lists a tree breadth-first with non-recursive requests, which
+// GitHub never truncates for trees of realistic size.
+func (s *githubSource) walkTree(ctx context.Context, rootSHA string, basePath string) (map[string]string, error) {
+	type pendingTree struct {
+		sha    string
+		prefix string
+	}
+
+	blobs := make(map[string]string)
+	queue := []pendingTree{{sha: rootSHA, prefix: basePath}}
+
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+
+		tree, err := s.fetchTree(ctx, current.sha, false)
+		if err != nil {
+			return nil, err
+		}
+
+		if tree.Truncated {
+			return nil, oops.
+				Code("GITHUB_API_ERROR").
+				With("repo", s.source.Repo).
+				With("path", current.prefix).
+				Hint("Narrow the configured path to reduce tree size").
+				Errorf("github returned a truncated listing for directory %q", current.prefix)
+		}
+
+		collectTreeBlobs(blobs, current.prefix, tree.Tree)
+		for _, entry := range tree.Tree {
+			if entry.Type == "tree" && entry.SHA != "" {
+				queue = append(queue, pendingTree{sha: entry.SHA, prefix: path.Join(current.prefix, entry.Path)})
+			}
+		}
+	}
+
+	return blobs, nil
+}
+
+func (s *githubSource) fetchTree(ctx context.Context, treeish string, recursive bool) (*githubTreeResponse, error) {
+	endpoint := fmt.Sprintf("/repos/%s/%s/git/trees/%s", s.owner, s.repo, treeish)
+	result := &githubTreeResponse{}
+
+	response, err := s.do(ctx, func() (*resty.Response, error) {
+		request := s.client.R().
+			SetContext(ctx).
+			SetResult(result)
+		if recursive {
+			request.SetQueryParam("recursive", "1")
+		}
+
+		return request.Get(endpoint)
+	})
+	if err != nil {
 		return nil, oops.
 			Code("GITHUB_API_ERROR").
 			With("repo", s.source.Repo).
-			With("ref", ref).
-			Hint("Narrow the configured path to reduce tree size").
-			Errorf("github returned a truncated tree; contents fallback is not implemented")
+			With("tree", treeish).
+			Wrapf(err, "fetching tree")
+	}
+
+	if !response.IsSuccess() {
+		return nil, oops.
+			Code("GITHUB_API_ERROR").
+			With("repo", s.source.Repo).
+			With("tree", treeish).
+			With("status", response.StatusCode()).
+			Hint("Check repository, path, and ref in your config").
+			Errorf("github API returned status %d for tree", response.StatusCode())
 	}
 
 	return result, nil
@@ -383,11 +541,13 @@ func (s *githubSource) fetchContentSHA(ctx context.Context, ref string, filePath
 	endpoint := fmt.Sprintf("/repos/%s/%s/contents/%s", s.owner, s.repo, escapeRepoPath(filePath))
 	result := &githubContentResponse{}
 
-	response, err := s.client.R().
-		SetContext(ctx).
-		SetQueryParam("ref", ref).
-		SetResult(result).
-		Get(endpoint)
+	response, err := s.do(ctx, func() (*resty.Response, error) {
+		return s.client.R().
+			SetContext(ctx).
+			SetQueryParam("ref", ref).
+			SetResult(result).
+			Get(endpoint)
+	})
 	if err != nil {
 		return "", oops.
 			Code("GITHUB_API_ERROR").
@@ -406,10 +566,6 @@ func (s *githubSource) fetchContentSHA(ctx context.Context, ref string, filePath
 			Errorf("github API returned status %d for content metadata", response.StatusCode())
 	}
 
-	if rlErr := s.checkRateLimit(response); rlErr != nil {
-		return "", rlErr
-	}
-
 	if result.Type != "file" || result.SHA == "" {
 		return "", oops.
 			Code("GITHUB_API_ERROR").
@@ -425,10 +581,12 @@ func (s *githubSource) fetchBlobContent(ctx context.Context, sha string) ([]byte
 	endpoint := fmt.Sprintf("/repos/%s/%s/git/blobs/%s", s.owner, s.repo, sha)
 	result := &githubBlobResponse{}
 
-	response, err := s.client.R().
-		SetContext(ctx).
-		SetResult(result).
-		Get(endpoint)
+	response, err := s.do(ctx, func() (*resty.Response, error) {
+		return s.client.R().
+			SetContext(ctx).
+			SetResult(result).
+			Get(endpoint)
+	})
 	if err != nil {
 		return nil, oops.
 			Code("DOWNLOAD_FAILED").
@@ -446,24 +604,26 @@ func (s *githubSource) fetchBlobContent(ctx context.Context, sha string) ([]byte
 			Errorf("github API returned status %d for blob", response.StatusCode())
 	}
 
-	if rlErr := s.checkRateLimit(response); rlErr != nil {
-		return nil, rlErr
-	}
+	return decodeBlobContent(s.source.Repo, sha, result)
+}
 
-	if result.Encoding != "base64" {
+// decodeBlobContent decodes a git blob payload as returned by the GitHub and
+// Gitea blob APIs.
+func decodeBlobContent(repo string, sha string, blob *githubBlobResponse) ([]byte, error) {
+	if blob.Encoding != "base64" {
 		return nil, oops.
 			Code("DOWNLOAD_FAILED").
-			With("repo", s.source.Repo).
+			With("repo", repo).
 			With("sha", sha).
-			Errorf("unsupported blob encoding %q", result.Encoding)
+			Errorf("unsupported blob encoding %q", blob.Encoding)
 	}
 
-	normalized := strings.ReplaceAll(result.Content, "\n", "")
+	normalized := strings.ReplaceAll(blob.Content, "\n", "")
 	content, err := base64.StdEncoding.DecodeString(normalized)
 	if err != nil {
 		return nil, oops.
 			Code("DOWNLOAD_FAILED").
-			With("repo", s.source.Repo).
+			With("repo", repo).
 			With("sha", sha).
 			Wrapf(err, "decoding blob content")
 	}
@@ -471,25 +631,35 @@ func (s *githubSource) fetchBlobContent(ctx context.Context, sha string) ([]byte
 	return content, nil
 }
 
-func (s *githubSource) buildFileMap(treeEntries []githubTreeEntry) (map[string]string, error) {
-	basePath := normalizeRepoPath(s.source.Path)
-	patterns := s.source.Patterns
-	if len(patterns) == 0 {
-		patterns = config.DefaultPatterns()
-	}
-
-	files := make(map[string]string)
+// collectTreeBlobs adds the blob entries of a tree listing to blobs, keyed by
+// their repository path below prefix.
+func collectTreeBlobs(blobs map[string]string, prefix string, treeEntries []githubTreeEntry) {
 	for _, entry := range treeEntries {
 		if entry.Type != "blob" || entry.Path == "" || entry.SHA == "" {
 			continue
 		}
 
-		relativePath, ok := relativePathWithinBase(entry.Path, basePath)
+		blobs[path.Join(prefix, entry.Path)] = entry.SHA
+	}
+}
+
+// filterTreeFiles maps repository blob paths to SHAs relative to the source
+// path, keeping only files that match the source patterns and excludes.
+func filterTreeFiles(blobs map[string]string, src config.Source) (map[string]string, error) {
+	basePath := normalizeRepoPath(src.Path)
+	patterns := src.Patterns
+	if len(patterns) == 0 {
+		patterns = config.DefaultPatterns()
+	}
+
+	files := make(map[string]string)
+	for _, blobPath := range sortedKeys(blobs) {
+		relativePath, ok := relativePathWithinBase(blobPath, basePath)
 		if !ok {
 			continue
 		}
 
-		include, err := shouldIncludeFile(relativePath, patterns, s.source.Exclude)
+		include, err := shouldIncludeFile(relativePath, patterns, src.Exclude)
 		if err != nil {
 			return nil, err
 		}
@@ -498,12 +668,40 @@ func (s *githubSource) buildFileMap(treeEntries []githubTreeEntry) (map[string]s
 			continue
 		}
 
-		files[relativePath] = entry.SHA
+		files[relativePath] = blobs[blobPath]
 	}
 
 	return files, nil
 }
 
+// skippedResult reuses the previous lock entry for a source whose upstream
+// content has not changed since the last sync.
+func skippedResult(prevLock *lockfile.LockEntry, sourceType string, ref string) *SyncResult {
+	lockEntry := cloneLockEntry(prevLock)
+	if lockEntry == nil {
+		lockEntry = &lockfile.LockEntry{}
+	}
+
+	lockEntry.Type = sourceType
+	lockEntry.RefResolved = ref
+	lockEntry.SyncedAt = time.Now().UTC()
+
+	return &SyncResult{
+		Skipped:   true,
+		LockEntry: lockEntry,
+	}
+}
+
+// withRef records the branch or tag name a git source resolved its commit
+// from in the lock entry of a sync result.
+func withRef(result *SyncResult, ref string) *SyncResult {
+	if result != nil && result.LockEntry != nil {
+		result.LockEntry.Ref = ref
+	}
+
+	return result
+}
+
 func diffDownloads(newFiles map[string]string, oldFiles map[string]string, force bool) map[string]string {
 	toDownload := make(map[string]string)
 
@@ -593,6 +791,36 @@ func matchesAny(patterns []string, candidate string) (bool, error) {
 	return false, nil
 }
 
+func writeSourceFile(sourceName string, destDir string, relativePath string, content []byte) error {
+	localPath := filepath.Join(destDir, filepath.FromSlash(relativePath))
+	if mkdirErr := os.MkdirAll(filepath.Dir(localPath), 0o750); mkdirErr != nil {
+		return oops.
+			Code("WRITE_FAILED").
+			With("source", sourceName).
+			With("path", filepath.Dir(localPath)).
+			Wrapf(mkdirErr, "creating destination directory")
+	}
+
+	return writeFileAtomic(localPath, content)
+}
+
+func deleteStaleFiles(sourceName string, destDir string, toDelete map[string]struct{}) error {
+	for _, relativePath := range sortedKeys(toDelete) {
+		localPath := filepath.Join(destDir, filepath.FromSlash(relativePath))
+		if removeErr := os.Remove(localPath); removeErr != nil && !os.IsNotExist(removeErr) {
+			return oops.
+				Code("WRITE_FAILED").
+				With("source", sourceName).
+				With("path", localPath).
+				Wrapf(removeErr, "deleting stale file")
+		}
+
+		cleanupEmptyDirs(filepath.Dir(localPath), destDir)
+	}
+
+	return nil
+}
+
 func cleanupEmptyDirs(startDir string, stopDir string) {
 	current := startDir
 	cleanStop := filepath.Clean(stopDir)
@@ -668,9 +896,37 @@ func parseRepo(repo string) (string, string, error) {
 	return parts[0], parts[1], nil
 }
 
-func newGitHubClient(token string) *resty.Client {
+// githubAPIBaseURL returns the REST API root for a host: api.github.com for
+// github.com, and /api/v3 on the host itself for GitHub Enterprise Server.
+// An explicit api_url wins over both.
+func githubAPIBaseURL(host string, apiURL string) string {
+	if apiURL = strings.TrimSpace(apiURL); apiURL != "" {
+		return strings.TrimSuffix(apiURL, "/")
+	}
+
+	host = strings.TrimSpace(host)
+	if host == "" || strings.EqualFold(host, githubDefaultHost) {
+		return githubPublicAPIURL
+	}
+
+	return "https://" + host + githubEnterpriseAPIPath
+}
+
+// githubRawBaseURL returns the root raw files are served from:
+// raw.githubusercontent.com for github.com, and /raw on the host itself for
+// GitHub Enterprise Server.
+func githubRawBaseURL(host string) string {
+	host = strings.TrimSpace(host)
+	if host == "" || strings.EqualFold(host, githubDefaultHost) {
+		return githubPublicRawURL
+	}
+
+	return "https://" + host + "/raw"
+}
+
+func newGitHubClient(baseURL string, token string) *resty.Client {
 	client := resty.New()
-	client.SetBaseURL(githubAPIBaseURL)
+	client.SetBaseURL(baseURL)
 	client.SetHeader("Accept", "application/vnd.github.v3+json")
 	client.SetHeader("User-Agent", userAgent)
 	client.SetRetryCount(httpRetryCount)
@@ -683,31 +939,3 @@ func newGitHubClient(token string) *resty.Client {
 
 	return client
 }
-
-func (s *githubSource) checkRateLimit(response *resty.Response) error {
-	remainingRaw := response.Header().Get("X-Ratelimit-Remaining")
-	if remainingRaw == "" {
-		return nil
-	}
-
-	remaining, err := strconv.Atoi(remainingRaw)
-	if err != nil {
-		return nil //nolint:nilerr // Non-critical header parsing; malformed value is safely ignored.
-	}
-
-	if remaining == 0 {
-		reset := response.Header().Get("X-Ratelimit-Reset")
-		return oops.
-			Code("GITHUB_RATE_LIMIT").
-			With("repo", s.source.Repo).
-			With("reset", reset).
-			Hint("Set github_token, GITHUB_TOKEN, or GH_TOKEN to increase limits").
-			Errorf("github API rate limit exhausted")
-	}
-
-	if remaining <= rateLimitWarnThresh && !s.warnedLowRL {
-		s.warnedLowRL = true
-	}
-
-	return nil
-}
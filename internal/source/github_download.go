@@ -0,0 +1,244 @@
+package source
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"crypto/sha1" //nolint:gosec // Git object IDs are SHA-1; used for integrity checks, not security.
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	neturl "net/url"
+	"strconv"
+	"strings"
+
+	"github.com/samber/oops"
+	"resty.dev/v3"
+)
+
+const (
+	githubPublicRawURL = "https://raw.githubusercontent.com"
+
+	downloadAPI     = "api"
+	downloadRaw     = "raw"
+	downloadTarball = "tarball"
+
+	// tarballMinFiles is the number of changed files from which auto mode
+	// fetches one tarball of the ref instead of individual raw files.
+	tarballMinFiles = 50
+)
+
+// downloadStrategy picks how changed files are fetched. Raw files and the
+// tarball do not count against the REST API quota the way blob requests do;
+// the tarball is a single request but carries the whole repository, so it
+// only pays off once many files changed.
+func (s *githubSource) downloadStrategy(changed int) string {
+	switch s.source.Download {
+	case downloadAPI, downloadRaw, downloadTarball:
+		return s.source.Download
+	}
+
+	if changed >= tarballMinFiles {
+		return downloadTarball
+	}
+
+	return downloadRaw
+}
+
+// fetchRawFile downloads a file from the raw file host and checks it
+// against the blob SHA from the tree listing, so the lock never records a
+// SHA for content other than what was written.
+func (s *githubSource) fetchRawFile(ctx context.Context, ref string, repoPath string, sha string) ([]byte, error) {
+	rawURL := fmt.Sprintf("%s/%s/%s/%s/%s",
+		strings.TrimSuffix(s.rawBaseURL, "/"), s.owner, s.repo, neturl.PathEscape(ref), escapeRepoPath(repoPath))
+
+	response, err := s.client.R().
+		SetContext(ctx).
+		Get(rawURL)
+	if err != nil {
+		return nil, oops.
+			Code("DOWNLOAD_FAILED").
+			With("repo", s.source.Repo).
+			With("path", repoPath).
+			Wrapf(err, "downloading raw file")
+	}
+
+	if !response.IsSuccess() {
+		return nil, oops.
+			Code("GITHUB_API_ERROR").
+			With("repo", s.source.Repo).
+			With("path", repoPath).
+			With("status", response.StatusCode()).
+			Errorf("github returned status %d for raw file", response.StatusCode())
+	}
+
+	content := response.Bytes()
+	if gotSHA := gitBlobSHA(content); gotSHA != sha {
+		return nil, oops.
+			Code("DOWNLOAD_FAILED").
+			With("repo", s.source.Repo).
+			With("path", repoPath).
+			With("expected_sha", sha).
+			With("actual_sha", gotSHA).
+			Hint("The ref changed during sync; run dox sync again").
+			Errorf("raw file %q does not match the listed blob", repoPath)
+	}
+
+	return content, nil
+}
+
+// downloadTarball streams the ref's tarball and writes the wanted files as
+// they appear in the archive. Archives do not carry every blob as listed:
+// export-ignore paths are left out, export-subst files are rewritten, and
+// symlinks are not regular entries. Files missing from the archive or whose
+// content does not match the listed blob SHA are fetched from the raw
+// endpoint instead.
+func (s *githubSource) downloadTarball(
+	ctx context.Context,
+	destDir string,
+	ref string,
+	toDownload map[string]string,
+	opts SyncOptions,
+) (map[string]string, error) {
+	endpoint := fmt.Sprintf("/repos/%s/%s/tarball/%s", s.owner, s.repo, neturl.PathEscape(ref))
+
+	response, err := s.do(ctx, func() (*resty.Response, error) {
+		return s.client.R().
+			SetContext(ctx).
+			SetDoNotParseResponse(true).
+			Get(endpoint)
+	})
+	if err != nil {
+		return nil, oops.
+			Code("DOWNLOAD_FAILED").
+			With("repo", s.source.Repo).
+			With("ref", ref).
+			Wrapf(err, "downloading tarball")
+	}
+	defer response.Body.Close()
+
+	if !response.IsSuccess() {
+		return nil, oops.
+			Code("GITHUB_API_ERROR").
+			With("repo", s.source.Repo).
+			With("ref", ref).
+			With("status", response.StatusCode()).
+			Errorf("github API returned status %d for tarball", response.StatusCode())
+	}
+
+	written, err := s.extractTarball(response.Body, destDir, toDownload)
+	if err != nil {
+		return written, err
+	}
+
+	missing := make(map[string]string)
+	for relativePath, sha := range toDownload {
+		if _, ok := written[relativePath]; !ok {
+			missing[relativePath] = sha
+		}
+	}
+
+	if len(missing) == 0 {
+		return written, nil
+	}
+
+	fetched, err := s.fetchFiles(ctx, destDir, ref, downloadRaw, missing, opts)
+	maps.Copy(written, fetched)
+
+	return written, err
+}
+
+func (s *githubSource) extractTarball(
+	body io.Reader,
+	destDir string,
+	toDownload map[string]string,
+) (map[string]string, error) {
+	gzipReader, err := gzip.NewReader(body)
+	if err != nil {
+		return nil, oops.
+			Code("DOWNLOAD_FAILED").
+			With("repo", s.source.Repo).
+			Wrapf(err, "reading tarball")
+	}
+	defer gzipReader.Close()
+
+	basePath := normalizeRepoPath(s.source.Path)
+	written := make(map[string]string, len(toDownload))
+	reader := tar.NewReader(gzipReader)
+
+	for {
+		header, nextErr := reader.Next()
+		if errors.Is(nextErr, io.EOF) {
+			return written, nil
+		}
+		if nextErr != nil {
+			return written, oops.
+				Code("DOWNLOAD_FAILED").
+				With("repo", s.source.Repo).
+				Wrapf(nextErr, "reading tarball")
+		}
+
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		// GitHub prefixes every entry with "{owner}-{repo}-{sha}/".
+		_, repoPath, found := strings.Cut(header.Name, "/")
+		if !found {
+			continue
+		}
+
+		relativePath, ok := relativePathWithinBase(repoPath, basePath)
+		if !ok {
+			continue
+		}
+
+		sha, wanted := toDownload[relativePath]
+		if !wanted {
+			continue
+		}
+
+		content, readErr := io.ReadAll(reader)
+		if readErr != nil {
+			return written, oops.
+				Code("DOWNLOAD_FAILED").
+				With("repo", s.source.Repo).
+				With("path", repoPath).
+				Wrapf(readErr, "reading tarball entry")
+		}
+
+		if gitBlobSHA(content) != sha {
+			continue
+		}
+
+		if writeErr := writeSourceFile(s.name, destDir, relativePath, content); writeErr != nil {
+			return written, writeErr
+		}
+		written[relativePath] = sha
+	}
+}
+
+// fetchFileContent downloads one file with the blob API or the raw endpoint.
+func (s *githubSource) fetchFileContent(
+	ctx context.Context,
+	strategy string,
+	ref string,
+	repoPath string,
+	sha string,
+) ([]byte, error) {
+	if strategy == downloadAPI {
+		return s.fetchBlobContent(ctx, sha)
+	}
+
+	return s.fetchRawFile(ctx, ref, repoPath, sha)
+}
+
+// gitBlobSHA returns the git object ID of content stored as a blob.
+func gitBlobSHA(content []byte) string {
+	hasher := sha1.New() //nolint:gosec // See import comment.
+	hasher.Write([]byte("blob " + strconv.Itoa(len(content)) + "\x00"))
+	hasher.Write(content)
+	return hex.EncodeToString(hasher.Sum(nil))
+}
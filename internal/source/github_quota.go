@@ -0,0 +1,221 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	stdsync "sync"
+	"time"
+
+	"github.com/samber/oops"
+	"resty.dev/v3"
+)
+
+// QuotaTracker follows the GitHub REST API quotas across every GitHub source
+// of a sync run. Quotas are tracked per API base URL: sources on one host
+// share its quota, while github.com and GitHub Enterprise hosts, which have
+// their own limits and tokens, never stall each other.
+//
+// By default an exhausted quota fails the remaining requests. With waiting
+// enabled, requests sleep until the quota resets and secondary rate limits
+// are retried after their Retry-After delay, as long as the wait stays below
+// the configured maximum.
+type QuotaTracker struct {
+	wait      bool
+	maxWait   time.Duration
+	onWarning func(sourceName string, message string)
+
+	mu     stdsync.Mutex
+	quotas map[string]*apiQuota
+}
+
+// apiQuota is the last quota one GitHub API reported.
+type apiQuota struct {
+	known     bool
+	remaining int
+	reset     time.Time
+	warned    bool
+}
+
+// NewQuotaTracker creates a tracker. onWarning is called once per API and run
+// when the remaining quota runs low; it may be nil.
+func NewQuotaTracker(
+	wait bool,
+	maxWait time.Duration,
+	onWarning func(sourceName string, message string),
+) *QuotaTracker {
+	return &QuotaTracker{
+		wait:      wait,
+		maxWait:   maxWait,
+		onWarning: onWarning,
+		quotas:    map[string]*apiQuota{},
+	}
+}
+
+// quota returns the quota of apiBase, creating it on first use. The caller
+// must hold q.mu.
+func (q *QuotaTracker) quota(apiBase string) *apiQuota {
+	quota, ok := q.quotas[apiBase]
+	if !ok {
+		quota = &apiQuota{}
+		q.quotas[apiBase] = quota
+	}
+
+	return quota
+}
+
+// observe records the quota reported by a response of the API at apiBase.
+func (q *QuotaTracker) observe(apiBase string, sourceName string, header http.Header) {
+	remaining, err := strconv.Atoi(header.Get("X-Ratelimit-Remaining"))
+	if err != nil {
+		return
+	}
+
+	reset := parseRateLimitReset(header.Get("X-Ratelimit-Reset"))
+
+	q.mu.Lock()
+	quota := q.quota(apiBase)
+	quota.known = true
+	quota.remaining = remaining
+	quota.reset = reset
+	warn := remaining > 0 && remaining <= rateLimitWarnThresh && !quota.warned
+	if warn {
+		quota.warned = true
+	}
+	q.mu.Unlock()
+
+	if warn && q.onWarning != nil {
+		q.onWarning(sourceName, fmt.Sprintf("GitHub API quota low: %d request(s) left, resets at %s",
+			remaining, reset.Local().Format(time.TimeOnly)))
+	}
+}
+
+// exhausted reports whether the quota of apiBase is used up and when it
+// resets.
+func (q *QuotaTracker) exhausted(apiBase string) (time.Time, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	quota := q.quota(apiBase)
+	if !quota.known || quota.remaining > 0 {
+		return time.Time{}, false
+	}
+
+	if !quota.reset.After(time.Now()) {
+		quota.known = false
+		return time.Time{}, false
+	}
+
+	return quota.reset, true
+}
+
+// do sends a GitHub API request through the quota tracker. It refuses or
+// waits out an exhausted quota before sending, and with waiting enabled
+// retries responses rejected by a secondary rate limit.
+func (s *githubSource) do(ctx context.Context, send func() (*resty.Response, error)) (*resty.Response, error) {
+	for attempt := 0; ; attempt++ {
+		if err := s.awaitQuota(ctx); err != nil {
+			return nil, err
+		}
+
+		response, err := send()
+		if err != nil {
+			return response, err
+		}
+
+		s.quota.observe(s.client.BaseURL(), s.name, response.Header())
+
+		delay, limited := rateLimitDelay(response)
+		if !limited || !s.quota.wait || attempt >= httpRetryCount {
+			return response, nil
+		}
+
+		if response.Body != nil {
+			_ = response.Body.Close()
+		}
+
+		if waitErr := s.waitFor(ctx, delay); waitErr != nil {
+			return nil, waitErr
+		}
+	}
+}
+
+func (s *githubSource) awaitQuota(ctx context.Context) error {
+	reset, exhausted := s.quota.exhausted(s.client.BaseURL())
+	if !exhausted {
+		return nil
+	}
+
+	if !s.quota.wait {
+		return oops.
+			Code("GITHUB_RATE_LIMIT").
+			With("repo", s.source.Repo).
+			With("reset", reset.Format(time.RFC3339)).
+			Hint("Set github_token, GITHUB_TOKEN, or GH_TOKEN to increase limits, or set rate_limit_wait = true").
+			Errorf("github API rate limit exhausted")
+	}
+
+	return s.waitFor(ctx, time.Until(reset))
+}
+
+func (s *githubSource) waitFor(ctx context.Context, delay time.Duration) error {
+	if delay > s.quota.maxWait {
+		return oops.
+			Code("GITHUB_RATE_LIMIT").
+			With("repo", s.source.Repo).
+			With("wait", delay.Round(time.Second).String()).
+			Hint("Raise rate_limit_max_wait or set a GitHub token").
+			Errorf("github API rate limit resets in %s, longer than the maximum wait of %s",
+				delay.Round(time.Second), s.quota.maxWait)
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return oops.
+			Code("GITHUB_RATE_LIMIT").
+			With("repo", s.source.Repo).
+			Wrapf(ctx.Err(), "waiting for github API rate limit")
+	case <-timer.C:
+		return nil
+	}
+}
+
+// rateLimitDelay reports how long to wait before retrying a response that was
+// rejected by a primary or secondary rate limit.
+func rateLimitDelay(response *resty.Response) (time.Duration, bool) {
+	status := response.StatusCode()
+	if status != http.StatusForbidden && status != http.StatusTooManyRequests {
+		return 0, false
+	}
+
+	header := response.Header()
+	if retryAfter := header.Get("Retry-After"); retryAfter != "" {
+		if seconds, err := strconv.Atoi(retryAfter); err == nil {
+			return time.Duration(seconds) * time.Second, true
+		}
+
+		if date, err := http.ParseTime(retryAfter); err == nil {
+			return max(time.Until(date), 0), true
+		}
+	}
+
+	// An exhausted primary quota is waited out by awaitQuota before the retry.
+	if header.Get("X-Ratelimit-Remaining") == "0" {
+		return 0, true
+	}
+
+	return 0, false
+}
+
+func parseRateLimitReset(value string) time.Time {
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+
+	return time.Unix(seconds, 0)
+}
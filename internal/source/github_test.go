@@ -1,12 +1,24 @@
 package source_test
 
 import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/base64"
+	"maps"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/g5becks/dox/internal/cache"
 	"github.com/g5becks/dox/internal/config"
 	"github.com/g5becks/dox/internal/lockfile"
 	"github.com/g5becks/dox/internal/source"
@@ -50,17 +62,16 @@ func TestBuildFileMapFiltersByBaseAndPatterns(t *testing.T) {
 		Patterns: []string{"**/*.md", "**/*.txt"},
 		Exclude:  []string{"**/skip.md"},
 	}, source.NewMockGitHubClient(t, map[string]source.MockHTTPResponse{
-		"/repos/acme/widgets/git/trees/main?recursive=1": {
+		"/repos/acme/widgets/git/trees/main:docs?recursive=1": {
 			Body: `{
   "sha": "tree-sha",
   "truncated": false,
   "tree": [
-    {"path":"docs/getting-started.md","type":"blob","sha":"sha-1"},
-    {"path":"docs/skip.md","type":"blob","sha":"sha-2"},
-    {"path":"docs/sub/notes.txt","type":"blob","sha":"sha-3"},
-    {"path":"docs/ignored.go","type":"blob","sha":"sha-4"},
-    {"path":"other/outside.md","type":"blob","sha":"sha-5"},
-    {"path":"docs/subdir","type":"tree","sha":"sha-tree"}
+    {"path":"getting-started.md","type":"blob","sha":"sha-1"},
+    {"path":"skip.md","type":"blob","sha":"sha-2"},
+    {"path":"sub/notes.txt","type":"blob","sha":"sha-3"},
+    {"path":"ignored.go","type":"blob","sha":"sha-4"},
+    {"path":"subdir","type":"tree","sha":"sha-tree"}
   ]
 }`,
 		},
@@ -96,14 +107,14 @@ func TestSyncDirectoryDryRunComputesDiff(t *testing.T) {
 		Path:     "docs",
 		Patterns: []string{"**/*.md", "**/*.txt"},
 	}, source.NewMockGitHubClient(t, map[string]source.MockHTTPResponse{
-		"/repos/acme/widgets/git/trees/main?recursive=1": {
+		"/repos/acme/widgets/git/trees/main:docs?recursive=1": {
 			Body: `{
   "sha": "tree-new",
   "truncated": false,
   "tree": [
-    {"path":"docs/a.md","type":"blob","sha":"sha-a-new"},
-    {"path":"docs/b.txt","type":"blob","sha":"sha-b"},
-    {"path":"docs/ignored.go","type":"blob","sha":"sha-go"}
+    {"path":"a.md","type":"blob","sha":"sha-a-new"},
+    {"path":"b.txt","type":"blob","sha":"sha-b"},
+    {"path":"ignored.go","type":"blob","sha":"sha-go"}
   ]
 }`,
 		},
@@ -144,7 +155,7 @@ func TestSyncDirectorySkipsWhenTreeSHAUnchanged(t *testing.T) {
 		Path:     "docs",
 		Patterns: []string{"**/*.md"},
 	}, source.NewMockGitHubClient(t, map[string]source.MockHTTPResponse{
-		"/repos/acme/widgets/git/trees/main?recursive=1": {
+		"/repos/acme/widgets/git/trees/main:docs?recursive=1": {
 			Body: `{"sha":"tree-same","truncated":false,"tree":[]}`,
 		},
 	}), "main")
@@ -167,6 +178,57 @@ func TestSyncDirectorySkipsWhenTreeSHAUnchanged(t *testing.T) {
 	}
 }
 
+func TestSyncDirectoryWalksTruncatedTree(t *testing.T) {
+	t.Parallel()
+
+	src := source.TestableGitHubSource(t, "website", config.Source{
+		Repo:     "acme/website",
+		Path:     "content/en",
+		Patterns: []string{"**/*.md"},
+	}, source.NewMockGitHubClient(t, map[string]source.MockHTTPResponse{
+		"/repos/acme/website/git/trees/main:content/en?recursive=1": {
+			Body: `{"sha":"en-sha","truncated":true,"tree":[{"path":"index.md","type":"blob","sha":"sha-index"}]}`,
+		},
+		"/repos/acme/website/git/trees/en-sha": {
+			Body: `{
+  "sha": "en-sha",
+  "truncated": false,
+  "tree": [
+    {"path":"index.md","type":"blob","sha":"sha-index"},
+    {"path":"docs","type":"tree","sha":"docs-sha"}
+  ]
+}`,
+		},
+		"/repos/acme/website/git/trees/docs-sha": {
+			Body: `{
+  "sha": "docs-sha",
+  "truncated": false,
+  "tree": [
+    {"path":"setup.md","type":"blob","sha":"sha-setup"},
+    {"path":"logo.png","type":"blob","sha":"sha-logo"}
+  ]
+}`,
+		},
+	}), "main")
+
+	result, err := src.Sync(context.Background(), t.TempDir(), nil, source.SyncOptions{DryRun: true})
+	if err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+
+	if result.Downloaded != 2 {
+		t.Fatalf("Downloaded = %d, want 2", result.Downloaded)
+	}
+
+	if result.LockEntry.TreeSHA != "en-sha" {
+		t.Fatalf("TreeSHA = %q, want en-sha", result.LockEntry.TreeSHA)
+	}
+
+	if result.LockEntry.Files["docs/setup.md"] != "sha-setup" {
+		t.Fatalf("Files[docs/setup.md] = %q, want sha-setup", result.LockEntry.Files["docs/setup.md"])
+	}
+}
+
 func TestSyncSingleFileDownloadsChangedBlob(t *testing.T) {
 	t.Parallel()
 
@@ -174,8 +236,9 @@ func TestSyncSingleFileDownloadsChangedBlob(t *testing.T) {
 	encoded := base64.StdEncoding.EncodeToString([]byte("hello docs"))
 
 	src := source.TestableGitHubSource(t, "widgets", config.Source{
-		Repo: "acme/widgets",
-		Path: "docs/overview.md",
+		Repo:     "acme/widgets",
+		Path:     "docs/overview.md",
+		Download: "api",
 	}, source.NewMockGitHubClient(t, map[string]source.MockHTTPResponse{
 		"/repos/acme/widgets/contents/docs/overview.md?ref=main": {
 			Body: `{"type":"file","sha":"blob-1"}`,
@@ -245,3 +308,632 @@ func TestSyncSingleFileSkipsWhenSHAUnchanged(t *testing.T) {
 		t.Fatalf("overview.md exists unexpectedly")
 	}
 }
+
+func TestSyncDirectoryConcurrentDownloadRecordsPartialProgress(t *testing.T) {
+	t.Parallel()
+
+	encoded := base64.StdEncoding.EncodeToString([]byte("doc"))
+	blob := source.MockHTTPResponse{Body: `{"encoding":"base64","content":"` + encoded + `"}`}
+	missing := source.MockHTTPResponse{Status: http.StatusNotFound, Body: `{"message":"Not Found"}`}
+
+	src := source.TestableGitHubSource(t, "widgets", config.Source{
+		Repo:     "acme/widgets",
+		Path:     "docs",
+		Patterns: []string{"**/*.md"},
+		Download: "api",
+	}, source.NewMockGitHubClient(t, map[string]source.MockHTTPResponse{
+		"/repos/acme/widgets/git/trees/main:docs?recursive=1": {
+			Body: `{
+  "sha": "tree-new",
+  "truncated": false,
+  "tree": [
+    {"path":"a.md","type":"blob","sha":"sha-a"},
+    {"path":"b.md","type":"blob","sha":"sha-b"},
+    {"path":"c.md","type":"blob","sha":"sha-c"},
+    {"path":"d.md","type":"blob","sha":"sha-d"},
+    {"path":"same.md","type":"blob","sha":"sha-same"}
+  ]
+}`,
+		},
+		"/repos/acme/widgets/git/blobs/sha-a": blob,
+		"/repos/acme/widgets/git/blobs/sha-b": missing,
+		"/repos/acme/widgets/git/blobs/sha-c": blob,
+		"/repos/acme/widgets/git/blobs/sha-d": missing,
+	}), "main")
+
+	prevLock := &lockfile.LockEntry{
+		Type:    "github",
+		TreeSHA: "tree-old",
+		Files: map[string]string{
+			"b.md":    "sha-b-old",
+			"same.md": "sha-same",
+		},
+	}
+
+	destDir := t.TempDir()
+	result, err := src.Sync(context.Background(), destDir, prevLock, source.SyncOptions{FileParallel: 4})
+	if err == nil {
+		t.Fatalf("Sync() error = nil, want download failure")
+	}
+
+	if !strings.Contains(err.Error(), "2 of 4") {
+		t.Fatalf("Sync() error = %q, want failure count", err.Error())
+	}
+
+	if result == nil || result.LockEntry == nil {
+		t.Fatalf("Sync() result = %v, want partial progress", result)
+	}
+
+	want := map[string]string{"a.md": "sha-a", "b.md": "sha-b-old", "c.md": "sha-c", "same.md": "sha-same"}
+	if !maps.Equal(result.LockEntry.Files, want) {
+		t.Fatalf("Files = %v, want %v", result.LockEntry.Files, want)
+	}
+
+	if result.LockEntry.TreeSHA != "" {
+		t.Fatalf("TreeSHA = %q, want empty after partial sync", result.LockEntry.TreeSHA)
+	}
+
+	if _, statErr := os.Stat(filepath.Join(destDir, "c.md")); statErr != nil {
+		t.Fatalf("Stat(c.md) error = %v", statErr)
+	}
+}
+
+func TestSyncDirectoryDownloadsChangedFilesFromRaw(t *testing.T) {
+	t.Parallel()
+
+	guideSHA := source.GitBlobSHA([]byte("# Guide"))
+	src := source.TestableGitHubSource(t, "widgets", config.Source{
+		Repo:     "acme/widgets",
+		Path:     "docs",
+		Patterns: []string{"**/*.md"},
+	}, source.NewMockGitHubClient(t, map[string]source.MockHTTPResponse{
+		"/repos/acme/widgets/git/trees/main:docs?recursive=1": {
+			Body: `{
+  "sha": "tree-new",
+  "truncated": false,
+  "tree": [
+    {"path":"guide.md","type":"blob","sha":"` + guideSHA + `"},
+    {"path":"same.md","type":"blob","sha":"sha-same"}
+  ]
+}`,
+		},
+		"/acme/widgets/main/docs/guide.md": {Body: "# Guide"},
+	}), "main")
+
+	prevLock := &lockfile.LockEntry{Type: "github", Files: map[string]string{"same.md": "sha-same"}}
+
+	destDir := t.TempDir()
+	result, err := src.Sync(context.Background(), destDir, prevLock, source.SyncOptions{})
+	if err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+
+	if result.Downloaded != 1 {
+		t.Fatalf("Downloaded = %d, want 1", result.Downloaded)
+	}
+
+	content, err := os.ReadFile(filepath.Join(destDir, "guide.md"))
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+
+	if string(content) != "# Guide" {
+		t.Fatalf("content = %q, want %q", string(content), "# Guide")
+	}
+}
+
+func TestSyncDirectoryRejectsRawContentWithDifferentSHA(t *testing.T) {
+	t.Parallel()
+
+	src := source.TestableGitHubSource(t, "widgets", config.Source{
+		Repo:     "acme/widgets",
+		Path:     "docs",
+		Patterns: []string{"**/*.md"},
+		Download: "raw",
+	}, source.NewMockGitHubClient(t, map[string]source.MockHTTPResponse{
+		"/repos/acme/widgets/git/trees/main:docs?recursive=1": {
+			Body: `{"sha":"tree","truncated":false,"tree":[{"path":"guide.md","type":"blob","sha":"sha-listed"}]}`,
+		},
+		"/acme/widgets/main/docs/guide.md": {Body: "# Newer guide"},
+	}), "main")
+
+	result, err := src.Sync(context.Background(), t.TempDir(), nil, source.SyncOptions{})
+	if err == nil {
+		t.Fatalf("Sync() error = nil, want SHA mismatch")
+	}
+
+	if result == nil || len(result.LockEntry.Files) != 0 {
+		t.Fatalf("Sync() result = %+v, want no recorded files", result)
+	}
+}
+
+// githubTarball builds a repository tarball the way GitHub lays it out, with
+// every entry under an "{owner}-{repo}-{sha}/" directory.
+func githubTarball(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	var archive bytes.Buffer
+	gzipWriter := gzip.NewWriter(&archive)
+	tarWriter := tar.NewWriter(gzipWriter)
+	for _, name := range slices.Sorted(maps.Keys(files)) {
+		content := files[name]
+		header := &tar.Header{Name: "acme-widgets-abc123/" + name, Mode: 0o644, Size: int64(len(content))}
+		if err := tarWriter.WriteHeader(header); err != nil {
+			t.Fatalf("WriteHeader() error = %v", err)
+		}
+		if _, err := tarWriter.Write([]byte(content)); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	_ = tarWriter.Close()
+	_ = gzipWriter.Close()
+
+	return archive.String()
+}
+
+func TestSyncDirectoryExtractsTarball(t *testing.T) {
+	t.Parallel()
+
+	archive := githubTarball(t, map[string]string{
+		"docs/guide.md":      "# Guide",
+		"docs/nested/api.md": "# API",
+		"docs/same.md":       "# Same",
+		"src/main.go":        "package main",
+	})
+
+	guideSHA := source.GitBlobSHA([]byte("# Guide"))
+	apiSHA := source.GitBlobSHA([]byte("# API"))
+	sameSHA := source.GitBlobSHA([]byte("# Same"))
+
+	src := source.TestableGitHubSource(t, "widgets", config.Source{
+		Repo:     "acme/widgets",
+		Path:     "docs",
+		Patterns: []string{"**/*.md"},
+		Download: "tarball",
+	}, source.NewMockGitHubClient(t, map[string]source.MockHTTPResponse{
+		"/repos/acme/widgets/git/trees/main:docs?recursive=1": {
+			Body: `{
+  "sha": "tree-new",
+  "truncated": false,
+  "tree": [
+    {"path":"guide.md","type":"blob","sha":"` + guideSHA + `"},
+    {"path":"nested/api.md","type":"blob","sha":"` + apiSHA + `"},
+    {"path":"same.md","type":"blob","sha":"` + sameSHA + `"}
+  ]
+}`,
+		},
+		"/repos/acme/widgets/tarball/main": {Body: archive},
+	}), "main")
+
+	prevLock := &lockfile.LockEntry{Type: "github", Files: map[string]string{"same.md": sameSHA}}
+
+	destDir := t.TempDir()
+	result, err := src.Sync(context.Background(), destDir, prevLock, source.SyncOptions{})
+	if err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+
+	if result.Downloaded != 2 {
+		t.Fatalf("Downloaded = %d, want 2", result.Downloaded)
+	}
+
+	content, err := os.ReadFile(filepath.Join(destDir, "nested", "api.md"))
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+
+	if string(content) != "# API" {
+		t.Fatalf("content = %q, want %q", string(content), "# API")
+	}
+
+	if _, statErr := os.Stat(filepath.Join(destDir, "same.md")); !os.IsNotExist(statErr) {
+		t.Fatalf("expected unchanged same.md not to be rewritten")
+	}
+}
+
+func TestSyncDirectoryFetchesFilesTheTarballDoesNotCarry(t *testing.T) {
+	t.Parallel()
+
+	// The archive leaves out an export-ignore path and rewrites an
+	// export-subst file, so both must come from the raw endpoint.
+	archive := githubTarball(t, map[string]string{
+		"docs/guide.md":   "# Guide",
+		"docs/version.md": "Version: 1.2.3",
+	})
+
+	guideSHA := source.GitBlobSHA([]byte("# Guide"))
+	versionSHA := source.GitBlobSHA([]byte("Version: $Format:%H$"))
+	ignoredSHA := source.GitBlobSHA([]byte("# Ignored"))
+
+	src := source.TestableGitHubSource(t, "widgets", config.Source{
+		Repo:     "acme/widgets",
+		Path:     "docs",
+		Patterns: []string{"**/*.md"},
+		Download: "tarball",
+	}, source.NewMockGitHubClient(t, map[string]source.MockHTTPResponse{
+		"/repos/acme/widgets/git/trees/main:docs?recursive=1": {
+			Body: `{
+  "sha": "tree-new",
+  "truncated": false,
+  "tree": [
+    {"path":"guide.md","type":"blob","sha":"` + guideSHA + `"},
+    {"path":"ignored.md","type":"blob","sha":"` + ignoredSHA + `"},
+    {"path":"version.md","type":"blob","sha":"` + versionSHA + `"}
+  ]
+}`,
+		},
+		"/repos/acme/widgets/tarball/main":   {Body: archive},
+		"/acme/widgets/main/docs/ignored.md": {Body: "# Ignored"},
+		"/acme/widgets/main/docs/version.md": {Body: "Version: $Format:%H$"},
+	}), "main")
+
+	sharedCache, err := cache.Open(config.Cache{Dir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("cache.Open() error = %v", err)
+	}
+
+	destDir := t.TempDir()
+	result, err := src.Sync(context.Background(), destDir, nil, source.SyncOptions{Cache: sharedCache})
+	if err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+
+	if result.Downloaded != 3 || len(result.LockEntry.Files) != 3 {
+		t.Fatalf("Sync() = %+v, want all 3 files downloaded and locked", result)
+	}
+
+	want := map[string]string{
+		"guide.md":   "# Guide",
+		"ignored.md": "# Ignored",
+		"version.md": "Version: $Format:%H$",
+	}
+	for name, wantContent := range want {
+		content, readErr := os.ReadFile(filepath.Join(destDir, name))
+		if readErr != nil {
+			t.Fatalf("ReadFile(%s) error = %v", name, readErr)
+		}
+		if string(content) != wantContent {
+			t.Errorf("%s content = %q, want %q", name, content, wantContent)
+		}
+		if _, cached := sharedCache.Path(cache.GitBlobKey(result.LockEntry.Files[name])); !cached {
+			t.Errorf("%s missing from the cache", name)
+		}
+	}
+}
+
+func TestSyncWarnsOnceWhenQuotaRunsLow(t *testing.T) {
+	t.Parallel()
+
+	lowQuota := http.Header{}
+	lowQuota.Set("X-Ratelimit-Remaining", "5")
+	lowQuota.Set("X-Ratelimit-Reset", strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10))
+
+	src := source.TestableGitHubSource(t, "widgets", config.Source{
+		Repo:     "acme/widgets",
+		Path:     "docs",
+		Patterns: []string{"**/*.md"},
+	}, source.NewMockGitHubClient(t, map[string]source.MockHTTPResponse{
+		"/repos/acme/widgets/git/trees/main:docs?recursive=1": {
+			Body:   `{"sha":"tree","truncated":false,"tree":[]}`,
+			Header: lowQuota,
+		},
+	}), "main")
+
+	var warnings []string
+	quota := source.NewQuotaTracker(false, time.Minute, func(sourceName string, message string) {
+		warnings = append(warnings, sourceName+": "+message)
+	})
+
+	for range 2 {
+		if _, err := src.Sync(context.Background(), t.TempDir(), nil, source.SyncOptions{GitHubQuota: quota}); err != nil {
+			t.Fatalf("Sync() error = %v", err)
+		}
+	}
+
+	if len(warnings) != 1 || !strings.HasPrefix(warnings[0], "widgets: GitHub API quota low: 5") {
+		t.Fatalf("warnings = %v, want one low quota warning", warnings)
+	}
+}
+
+func TestSyncFailsFastOnExhaustedQuotaWithoutWaiting(t *testing.T) {
+	t.Parallel()
+
+	exhausted := http.Header{}
+	exhausted.Set("X-Ratelimit-Remaining", "0")
+	exhausted.Set("X-Ratelimit-Reset", strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10))
+
+	src := source.TestableGitHubSource(t, "widgets", config.Source{
+		Repo:     "acme/widgets",
+		Path:     "docs",
+		Patterns: []string{"**/*.md"},
+		Download: "api",
+	}, source.NewMockGitHubClient(t, map[string]source.MockHTTPResponse{
+		"/repos/acme/widgets/git/trees/main:docs?recursive=1": {
+			Body:   `{"sha":"tree","truncated":false,"tree":[{"path":"a.md","type":"blob","sha":"sha-a"}]}`,
+			Header: exhausted,
+		},
+	}), "main")
+
+	quota := source.NewQuotaTracker(false, time.Minute, nil)
+	_, err := src.Sync(context.Background(), t.TempDir(), nil, source.SyncOptions{GitHubQuota: quota})
+	if err == nil || !strings.Contains(err.Error(), "rate limit exhausted") {
+		t.Fatalf("Sync() error = %v, want rate limit error", err)
+	}
+}
+
+func TestSyncTracksQuotaPerAPIHost(t *testing.T) {
+	t.Parallel()
+
+	exhausted := http.Header{}
+	exhausted.Set("X-Ratelimit-Remaining", "0")
+	exhausted.Set("X-Ratelimit-Reset", strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10))
+
+	responses := map[string]source.MockHTTPResponse{
+		"/repos/acme/widgets/git/trees/main:docs?recursive=1": {
+			Body:   `{"sha":"tree","truncated":false,"tree":[]}`,
+			Header: exhausted,
+		},
+	}
+	cfg := config.Source{Repo: "acme/widgets", Path: "docs", Patterns: []string{"**/*.md"}}
+
+	public := source.TestableGitHubSource(t, "public", cfg, source.NewMockGitHubClient(t, responses), "main")
+	enterprise := source.TestableGitHubSource(t, "enterprise", cfg,
+		source.NewMockGitHubClient(t, responses).SetBaseURL("https://github.mycorp.test"), "main")
+
+	quota := source.NewQuotaTracker(false, time.Minute, nil)
+	opts := source.SyncOptions{GitHubQuota: quota}
+
+	if _, err := public.Sync(context.Background(), t.TempDir(), nil, opts); err != nil {
+		t.Fatalf("public Sync() error = %v", err)
+	}
+
+	if _, err := public.Sync(context.Background(), t.TempDir(), nil, opts); err == nil {
+		t.Fatal("public Sync() after exhausting its quota: got nil error")
+	}
+
+	if _, err := enterprise.Sync(context.Background(), t.TempDir(), nil, opts); err != nil {
+		t.Fatalf("enterprise Sync() error = %v, want its own quota", err)
+	}
+}
+
+func TestSyncWaitsForRetryAfterOnSecondaryLimit(t *testing.T) {
+	t.Parallel()
+
+	var calls atomic.Int32
+	client := source.NewMockRestyClient(func(req *http.Request) *http.Response {
+		if calls.Add(1) == 1 {
+			headers := http.Header{}
+			headers.Set("Retry-After", "0")
+			return source.NewHTTPResponse(req, http.StatusForbidden, `{"message":"secondary rate limit"}`, headers)
+		}
+
+		headers := http.Header{}
+		headers.Set("Content-Type", "application/json")
+		return source.NewHTTPResponse(req, http.StatusOK, `{"sha":"tree","truncated":false,"tree":[]}`, headers)
+	})
+
+	src := source.TestableGitHubSource(t, "widgets", config.Source{
+		Repo: "acme/widgets",
+		Path: "docs",
+	}, client, "main")
+
+	quota := source.NewQuotaTracker(true, time.Minute, nil)
+	result, err := src.Sync(context.Background(), t.TempDir(), nil, source.SyncOptions{GitHubQuota: quota})
+	if err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+
+	if result.LockEntry.TreeSHA != "tree" || calls.Load() != 2 {
+		t.Fatalf("TreeSHA = %q after %d calls, want tree after 2 calls", result.LockEntry.TreeSHA, calls.Load())
+	}
+}
+
+func TestSyncRefusesRateLimitWaitBeyondMaximum(t *testing.T) {
+	t.Parallel()
+
+	client := source.NewMockRestyClient(func(req *http.Request) *http.Response {
+		headers := http.Header{}
+		headers.Set("Retry-After", "3600")
+		return source.NewHTTPResponse(req, http.StatusTooManyRequests, `{}`, headers)
+	})
+	client.SetRetryCount(0)
+
+	src := source.TestableGitHubSource(t, "widgets", config.Source{
+		Repo: "acme/widgets",
+		Path: "docs",
+	}, client, "main")
+
+	quota := source.NewQuotaTracker(true, time.Minute, nil)
+	_, err := src.Sync(context.Background(), t.TempDir(), nil, source.SyncOptions{GitHubQuota: quota})
+	if err == nil || !strings.Contains(err.Error(), "longer than the maximum wait") {
+		t.Fatalf("Sync() error = %v, want max wait error", err)
+	}
+}
+
+func TestGitHubEndpointsForHost(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		host    string
+		apiURL  string
+		wantAPI string
+		wantRaw string
+	}{
+		{host: "", wantAPI: "https://api.github.com", wantRaw: "https://raw.githubusercontent.com"},
+		{host: "github.com", wantAPI: "https://api.github.com", wantRaw: "https://raw.githubusercontent.com"},
+		{
+			host:    "github.mycorp.com",
+			wantAPI: "https://github.mycorp.com/api/v3",
+			wantRaw: "https://github.mycorp.com/raw",
+		},
+		{
+			host:    "github.mycorp.com",
+			apiURL:  "https://ghe-api.mycorp.com/v3/",
+			wantAPI: "https://ghe-api.mycorp.com/v3",
+			wantRaw: "https://github.mycorp.com/raw",
+		},
+	}
+
+	for _, tc := range testCases {
+		if got := source.GitHubAPIBaseURL(tc.host, tc.apiURL); got != tc.wantAPI {
+			t.Errorf("GitHubAPIBaseURL(%q, %q) = %q, want %q", tc.host, tc.apiURL, got, tc.wantAPI)
+		}
+		if got := source.GitHubRawBaseURL(tc.host); got != tc.wantRaw {
+			t.Errorf("GitHubRawBaseURL(%q) = %q, want %q", tc.host, got, tc.wantRaw)
+		}
+	}
+}
+
+func TestGitHubSyncUsesAPIURL(t *testing.T) {
+	t.Parallel()
+
+	content := []byte("# Enterprise guide\n")
+	sha := source.GitBlobSHA(content)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v3/repos/acme/widgets/commits/main", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("0123456789abcdef0123456789abcdef01234567"))
+	})
+	mux.HandleFunc("GET /api/v3/repos/acme/widgets/contents/docs/guide.md", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"type":"file","sha":"` + sha + `"}`))
+	})
+	mux.HandleFunc("GET /api/v3/repos/acme/widgets/git/blobs/"+sha, func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"encoding":"base64","content":"` + base64.StdEncoding.EncodeToString(content) + `"}`))
+	})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer enterprise-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	src, err := source.NewGitHub("ghe", config.Source{
+		Type:     "github",
+		Host:     "github.mycorp.com",
+		APIURL:   server.URL + "/api/v3",
+		Repo:     "acme/widgets",
+		Path:     "docs/guide.md",
+		Ref:      "main",
+		Download: "api",
+	}, "enterprise-token")
+	if err != nil {
+		t.Fatalf("NewGitHub() error = %v", err)
+	}
+	t.Cleanup(func() { _ = src.Close() })
+
+	destDir := t.TempDir()
+	result, err := src.Sync(context.Background(), destDir, nil, source.SyncOptions{})
+	if err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+
+	if result.LockEntry.Files["guide.md"] != sha {
+		t.Errorf("locked sha = %q, want %q", result.LockEntry.Files["guide.md"], sha)
+	}
+
+	written, err := os.ReadFile(filepath.Join(destDir, "guide.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(written) != string(content) {
+		t.Errorf("written content = %q, want %q", written, content)
+	}
+}
+
+func TestGitHubSyncResolvesVersionTag(t *testing.T) {
+	t.Parallel()
+
+	content := []byte("# v5.2.1\n")
+	sha := source.GitBlobSHA(content)
+	commit := "4f2a9c1e0b7d3a5f6c8e9d0b1a2c3e4f5a6b7c8d"
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/acme/widgets/tags", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`[{"name":"v6.0.0"},{"name":"v5.3.0-rc.1"},{"name":"v5.2.1"},{"name":"v5.0.0"},{"name":"nightly"}]`))
+	})
+	mux.HandleFunc("GET /repos/acme/widgets/commits/{ref}", func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("ref") != "v5.2.1" || r.Header.Get("Accept") != "application/vnd.github.sha" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(commit))
+	})
+	mux.HandleFunc("GET /repos/acme/widgets/contents/docs/guide.md", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("ref") != commit {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"type":"file","sha":"` + sha + `"}`))
+	})
+	mux.HandleFunc("GET /repos/acme/widgets/git/blobs/"+sha, func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"encoding":"base64","content":"` + base64.StdEncoding.EncodeToString(content) + `"}`))
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	src, err := source.NewGitHub("widgets", config.Source{
+		Type:     "github",
+		APIURL:   server.URL,
+		Repo:     "acme/widgets",
+		Path:     "docs/guide.md",
+		Version:  "^5.0",
+		Download: "api",
+	}, "")
+	if err != nil {
+		t.Fatalf("NewGitHub() error = %v", err)
+	}
+	t.Cleanup(func() { _ = src.Close() })
+
+	result, err := src.Sync(context.Background(), t.TempDir(), nil, source.SyncOptions{})
+	if err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+
+	if result.LockEntry.Ref != "v5.2.1" {
+		t.Errorf("Ref = %q, want the highest tag matching ^5.0", result.LockEntry.Ref)
+	}
+	if result.LockEntry.RefResolved != commit {
+		t.Errorf("RefResolved = %q, want the tag's commit %q", result.LockEntry.RefResolved, commit)
+	}
+}
+
+func TestMatchVersionTag(t *testing.T) {
+	t.Parallel()
+
+	tags := []string{"v4.9.0", "5.0.0", "v5.2.1", "v5.3.0-beta.1", "v6.0.0", "nightly"}
+	testCases := []struct {
+		version string
+		want    string
+		wantErr string
+	}{
+		{version: "^5.0", want: "v5.2.1"},
+		{version: "~5.0.0", want: "5.0.0"},
+		{version: ">=5.3.0-0, <6", want: "v5.3.0-beta.1"},
+		{version: ">=6", want: "v6.0.0"},
+		{version: "^7", wantErr: "no tag of acme/widgets satisfies"},
+		{version: "five", wantErr: "invalid version"},
+	}
+
+	for _, tc := range testCases {
+		got, err := source.MatchVersionTag("widgets", "acme/widgets", tc.version, tags)
+		if tc.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("MatchVersionTag(%q) error = %v, want %q", tc.version, err, tc.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != tc.want {
+			t.Errorf("MatchVersionTag(%q) = %q, %v, want %q", tc.version, got, err, tc.want)
+		}
+	}
+}
@@ -0,0 +1,476 @@
+package source
+
+import (
+	"context"
+	neturl "net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/samber/oops"
+	"resty.dev/v3"
+
+	"github.com/g5becks/dox/internal/config"
+	"github.com/g5becks/dox/internal/lockfile"
+)
+
+const (
+	sourceTypeGitLab  = "gitlab"
+	gitlabDefaultHost = "gitlab.com"
+	gitlabAPIPath     = "/api/v4"
+	gitlabTreePerPage = 100
+)
+
+type gitlabSource struct {
+	name        string
+	source      config.Source
+	project     string
+	client      *resty.Client
+	resolvedRef string
+	commit      string
+}
+
+type gitlabProjectResponse struct {
+	DefaultBranch string `json:"default_branch"`
+}
+
+type gitlabCommitResponse struct {
+	ID string `json:"id"`
+}
+
+type gitlabTagResponse struct {
+	Name string `json:"name"`
+}
+
+type gitlabTreeEntry struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+	Path string `json:"path"`
+}
+
+func NewGitLab(name string, cfg config.Source, token string) (Source, error) {
+	return newGitLabSource(name, cfg, token)
+}
+
+func newGitLabSource(name string, cfg config.Source, token string) (*gitlabSource, error) {
+	project := strings.Trim(strings.TrimSpace(cfg.Repo), "/")
+	if !strings.Contains(project, "/") {
+		return nil, oops.
+			Code("CONFIG_INVALID").
+			With("repo", cfg.Repo).
+			Hint("Expected repo format: group/project").
+			Errorf("invalid gitlab repo format %q", cfg.Repo)
+	}
+
+	client := newGitLabClient(gitlabAPIBaseURL(cfg.Host), token)
+	if err := applyHTTPSettings(client, name, cfg.HTTP); err != nil {
+		return nil, err
+	}
+
+	return &gitlabSource{
+		name:    name,
+		source:  cfg,
+		project: project,
+		client:  client,
+	}, nil
+}
+
+func (s *gitlabSource) Close() error {
+	return s.client.Close()
+}
+
+func (s *gitlabSource) Sync(
+	ctx context.Context,
+	destDir string,
+	prevLock *lockfile.LockEntry,
+	opts SyncOptions,
+) (*SyncResult, error) {
+	result, err := s.syncFiles(ctx, destDir, prevLock, opts)
+	return withRef(result, s.resolvedRef), err
+}
+
+func (s *gitlabSource) syncFiles(
+	ctx context.Context,
+	destDir string,
+	prevLock *lockfile.LockEntry,
+	opts SyncOptions,
+) (*SyncResult, error) {
+	ref, err := s.resolveCommit(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var newFiles map[string]string
+	if isSingleFilePath(s.source.Path) {
+		newFiles, err = s.fetchSingleFile(ctx, ref)
+	} else {
+		newFiles, err = s.fetchFileMap(ctx, ref)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	oldFiles := map[string]string{}
+	if prevLock != nil && prevLock.Files != nil {
+		oldFiles = prevLock.Files
+	}
+
+	toDownload := diffDownloads(newFiles, oldFiles, opts.Force)
+	toDelete := diffDeletes(oldFiles, newFiles)
+
+	if !opts.Force && prevLock != nil && len(toDownload) == 0 && len(toDelete) == 0 {
+		return skippedResult(prevLock, sourceTypeGitLab, ref), nil
+	}
+
+	if !opts.DryRun {
+		if written, downloadErr := s.downloadFiles(ctx, destDir, ref, toDownload, opts); downloadErr != nil {
+			return partialResult(sourceTypeGitLab, ref, oldFiles, newFiles, toDownload, written), downloadErr
+		}
+
+		if deleteErr := deleteStaleFiles(s.name, destDir, toDelete); deleteErr != nil {
+			return nil, deleteErr
+		}
+	}
+
+	return &SyncResult{
+		Downloaded: len(toDownload),
+		Deleted:    len(toDelete),
+		LockEntry: &lockfile.LockEntry{
+			Type:        sourceTypeGitLab,
+			RefResolved: ref,
+			SyncedAt:    time.Now().UTC(),
+			Files:       newFiles,
+		},
+	}, nil
+}
+
+func (s *gitlabSource) downloadFiles(
+	ctx context.Context,
+	destDir string,
+	ref string,
+	toDownload map[string]string,
+	opts SyncOptions,
+) (map[string]string, error) {
+	basePath := normalizeRepoPath(s.source.Path)
+	singleFile := isSingleFilePath(s.source.Path)
+
+	return downloadCached(opts, destDir, toDownload, func(pending map[string]string) (map[string]string, error) {
+		return fetchConcurrently(ctx, opts, pending, func(ctx context.Context, relativePath string, _ string) error {
+			remotePath := path.Join(basePath, relativePath)
+			if singleFile {
+				remotePath = basePath
+			}
+
+			content, fetchErr := s.fetchRawFile(ctx, ref, remotePath)
+			if fetchErr != nil {
+				return fetchErr
+			}
+
+			return writeSourceFile(s.name, destDir, relativePath, content)
+		})
+	})
+}
+
+func (s *gitlabSource) resolveRef(ctx context.Context) (string, error) {
+	if s.resolvedRef != "" {
+		return s.resolvedRef, nil
+	}
+
+	if s.source.Ref != "" {
+		s.resolvedRef = s.source.Ref
+		return s.resolvedRef, nil
+	}
+
+	if s.source.Version != "" {
+		tags, err := s.listTags(ctx)
+		if err != nil {
+			return "", err
+		}
+
+		s.resolvedRef, err = matchVersionTag(s.name, s.source.Repo, s.source.Version, tags)
+		return s.resolvedRef, err
+	}
+
+	result := &gitlabProjectResponse{}
+	response, err := s.client.R().
+		SetContext(ctx).
+		SetResult(result).
+		Get(s.projectEndpoint(""))
+	if err != nil {
+		return "", oops.
+			Code("GITLAB_API_ERROR").
+			With("repo", s.source.Repo).
+			Wrapf(err, "fetching project metadata")
+	}
+
+	if !response.IsSuccess() {
+		return "", oops.
+			Code("GITLAB_API_ERROR").
+			With("repo", s.source.Repo).
+			With("status", response.StatusCode()).
+			Hint("Check that the project exists and set gitlab_token for private projects").
+			Errorf("gitlab API returned status %d for project metadata", response.StatusCode())
+	}
+
+	if result.DefaultBranch == "" {
+		return "", oops.
+			Code("GITLAB_API_ERROR").
+			With("repo", s.source.Repo).
+			Errorf("gitlab project metadata did not include default branch")
+	}
+
+	s.resolvedRef = result.DefaultBranch
+	return s.resolvedRef, nil
+}
+
+// resolveCommit pins the resolved ref to a commit SHA so every request of a
+// sync reads the same snapshot.
+func (s *gitlabSource) resolveCommit(ctx context.Context) (string, error) {
+	if s.commit != "" {
+		return s.commit, nil
+	}
+
+	ref, err := s.resolveRef(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	if commitSHAPattern.MatchString(ref) {
+		s.commit = strings.ToLower(ref)
+		return s.commit, nil
+	}
+
+	result := &gitlabCommitResponse{}
+	response, err := s.client.R().
+		SetContext(ctx).
+		SetResult(result).
+		Get(s.projectEndpoint("/repository/commits/" + neturl.PathEscape(ref)))
+	if err != nil {
+		return "", oops.
+			Code("GITLAB_API_ERROR").
+			With("repo", s.source.Repo).
+			With("ref", ref).
+			Wrapf(err, "resolving commit")
+	}
+
+	if !response.IsSuccess() {
+		return "", oops.
+			Code("GITLAB_API_ERROR").
+			With("repo", s.source.Repo).
+			With("ref", ref).
+			With("status", response.StatusCode()).
+			Hint("Check that the branch, tag, or commit exists in the project").
+			Errorf("gitlab API returned status %d for commit of %q", response.StatusCode(), ref)
+	}
+
+	if !commitSHAPattern.MatchString(result.ID) {
+		return "", oops.
+			Code("GITLAB_API_ERROR").
+			With("repo", s.source.Repo).
+			With("ref", ref).
+			Errorf("gitlab API returned no commit SHA for %q", ref)
+	}
+
+	s.commit = strings.ToLower(result.ID)
+	return s.commit, nil
+}
+
+// listTags returns the names of the project's tags, following GitLab's
+// page-based pagination.
+func (s *gitlabSource) listTags(ctx context.Context) ([]string, error) {
+	tags := []string{}
+
+	page := "1"
+	for pages := 0; page != "" && pages < maxTagPages; pages++ {
+		result := []gitlabTagResponse{}
+		response, err := s.client.R().
+			SetContext(ctx).
+			SetQueryParam("per_page", strconv.Itoa(tagsPerPage)).
+			SetQueryParam("page", page).
+			SetResult(&result).
+			Get(s.projectEndpoint("/repository/tags"))
+		if err != nil {
+			return nil, oops.
+				Code("GITLAB_API_ERROR").
+				With("repo", s.source.Repo).
+				With("page", page).
+				Wrapf(err, "listing tags")
+		}
+
+		if !response.IsSuccess() {
+			return nil, oops.
+				Code("GITLAB_API_ERROR").
+				With("repo", s.source.Repo).
+				With("status", response.StatusCode()).
+				Hint("Check that the project exists and set gitlab_token for private projects").
+				Errorf("gitlab API returned status %d for tags", response.StatusCode())
+		}
+
+		for _, tag := range result {
+			tags = append(tags, tag.Name)
+		}
+		page = strings.TrimSpace(response.Header().Get("X-Next-Page"))
+	}
+
+	return tags, nil
+}
+
+// fetchFileMap lists the repository tree below the configured path, following
+// GitLab's page-based pagination, and returns the matching blob SHAs.
+func (s *gitlabSource) fetchFileMap(ctx context.Context, ref string) (map[string]string, error) {
+	basePath := normalizeRepoPath(s.source.Path)
+	blobs := make(map[string]string)
+
+	page := "1"
+	for page != "" {
+		entries, nextPage, err := s.fetchTreePage(ctx, ref, basePath, page)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, entry := range entries {
+			if entry.Type == "blob" && entry.Path != "" && entry.ID != "" {
+				blobs[entry.Path] = entry.ID
+			}
+		}
+
+		page = nextPage
+	}
+
+	return filterTreeFiles(blobs, s.source)
+}
+
+func (s *gitlabSource) fetchTreePage(
+	ctx context.Context,
+	ref string,
+	basePath string,
+	page string,
+) ([]gitlabTreeEntry, string, error) {
+	entries := []gitlabTreeEntry{}
+	request := s.client.R().
+		SetContext(ctx).
+		SetQueryParam("ref", ref).
+		SetQueryParam("recursive", "true").
+		SetQueryParam("per_page", strconv.Itoa(gitlabTreePerPage)).
+		SetQueryParam("page", page).
+		SetResult(&entries)
+	if basePath != "" {
+		request.SetQueryParam("path", basePath)
+	}
+
+	response, err := request.Get(s.projectEndpoint("/repository/tree"))
+	if err != nil {
+		return nil, "", oops.
+			Code("GITLAB_API_ERROR").
+			With("repo", s.source.Repo).
+			With("ref", ref).
+			With("page", page).
+			Wrapf(err, "fetching tree")
+	}
+
+	if !response.IsSuccess() {
+		return nil, "", oops.
+			Code("GITLAB_API_ERROR").
+			With("repo", s.source.Repo).
+			With("ref", ref).
+			With("status", response.StatusCode()).
+			Hint("Check repository, path, and ref in your config").
+			Errorf("gitlab API returned status %d for tree", response.StatusCode())
+	}
+
+	return entries, strings.TrimSpace(response.Header().Get("X-Next-Page")), nil
+}
+
+// fetchSingleFile reads the blob ID of a single configured file from the
+// metadata headers GitLab returns for a HEAD request.
+func (s *gitlabSource) fetchSingleFile(ctx context.Context, ref string) (map[string]string, error) {
+	filePath := normalizeRepoPath(s.source.Path)
+
+	response, err := s.client.R().
+		SetContext(ctx).
+		SetQueryParam("ref", ref).
+		Head(s.projectEndpoint("/repository/files/" + neturl.PathEscape(filePath)))
+	if err != nil {
+		return nil, oops.
+			Code("GITLAB_API_ERROR").
+			With("repo", s.source.Repo).
+			With("path", filePath).
+			Wrapf(err, "fetching file metadata")
+	}
+
+	if !response.IsSuccess() {
+		return nil, oops.
+			Code("GITLAB_API_ERROR").
+			With("repo", s.source.Repo).
+			With("path", filePath).
+			With("status", response.StatusCode()).
+			Hint("Check repository path and ref in your config").
+			Errorf("gitlab API returned status %d for file metadata", response.StatusCode())
+	}
+
+	blobID := response.Header().Get("X-Gitlab-Blob-Id")
+	if blobID == "" {
+		return nil, oops.
+			Code("GITLAB_API_ERROR").
+			With("repo", s.source.Repo).
+			With("path", filePath).
+			Errorf("gitlab file metadata did not include a blob id for %q", filePath)
+	}
+
+	return map[string]string{path.Base(filePath): blobID}, nil
+}
+
+func (s *gitlabSource) fetchRawFile(ctx context.Context, ref string, filePath string) ([]byte, error) {
+	response, err := s.client.R().
+		SetContext(ctx).
+		SetQueryParam("ref", ref).
+		Get(s.projectEndpoint("/repository/files/" + neturl.PathEscape(filePath) + "/raw"))
+	if err != nil {
+		return nil, oops.
+			Code("DOWNLOAD_FAILED").
+			With("repo", s.source.Repo).
+			With("path", filePath).
+			Wrapf(err, "downloading raw file")
+	}
+
+	if !response.IsSuccess() {
+		return nil, oops.
+			Code("GITLAB_API_ERROR").
+			With("repo", s.source.Repo).
+			With("path", filePath).
+			With("status", response.StatusCode()).
+			Errorf("gitlab API returned status %d for raw file", response.StatusCode())
+	}
+
+	return response.Bytes(), nil
+}
+
+func (s *gitlabSource) projectEndpoint(suffix string) string {
+	return "/projects/" + neturl.PathEscape(s.project) + suffix
+}
+
+func gitlabAPIBaseURL(host string) string {
+	host = strings.TrimSpace(host)
+	if host == "" {
+		host = gitlabDefaultHost
+	}
+
+	return "https://" + host + gitlabAPIPath
+}
+
+func newGitLabClient(baseURL string, token string) *resty.Client {
+	client := resty.New()
+	client.SetBaseURL(baseURL)
+	client.SetHeader("Accept", "application/json")
+	client.SetHeader("User-Agent", userAgent)
+	client.SetRetryCount(httpRetryCount)
+	client.SetRetryWaitTime(1 * time.Second)
+	client.SetRetryMaxWaitTime(httpRetryMaxWaitSec * time.Second)
+
+	if token != "" {
+		client.SetHeader("PRIVATE-TOKEN", token)
+	}
+
+	return client
+}
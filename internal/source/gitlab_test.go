@@ -0,0 +1,229 @@
+package source_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/g5becks/dox/internal/config"
+	"github.com/g5becks/dox/internal/lockfile"
+	"github.com/g5becks/dox/internal/source"
+)
+
+const (
+	gitlabProjectPath = "/api/v4/projects/acme%2Fwidgets"
+	gitlabTrunkCommit = "2d4f6a8c0e1b3d5f7a9c2e4f6b8d0a1c3e5f7b9d"
+)
+
+func newGitLabTestServer(t *testing.T, files map[string]string) *httptest.Server {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Private-Token") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	mux.HandleFunc("GET "+gitlabProjectPath, func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"default_branch":"trunk"}`))
+	})
+
+	mux.HandleFunc("GET "+gitlabProjectPath+"/repository/commits/trunk", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"` + gitlabTrunkCommit + `"}`))
+	})
+
+	mux.HandleFunc("GET "+gitlabProjectPath+"/repository/tree", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("ref") != gitlabTrunkCommit || r.URL.Query().Get("path") != "docs" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Query().Get("page") {
+		case "1":
+			w.Header().Set("X-Next-Page", "2")
+			_, _ = w.Write([]byte(`[
+  {"id":"sha-a","type":"blob","path":"docs/a.md"},
+  {"id":"sha-sub","type":"tree","path":"docs/sub"}
+]`))
+		case "2":
+			w.Header().Set("X-Next-Page", "")
+			_, _ = w.Write([]byte(`[
+  {"id":"sha-b","type":"blob","path":"docs/sub/b.md"},
+  {"id":"sha-c","type":"blob","path":"docs/sub/c.go"}
+]`))
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	})
+
+	mux.HandleFunc("GET "+gitlabProjectPath+"/repository/files/{file}/raw", func(w http.ResponseWriter, r *http.Request) {
+		content, ok := files[r.PathValue("file")]
+		if !ok || r.URL.Query().Get("ref") != gitlabTrunkCommit {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		_, _ = w.Write([]byte(content))
+	})
+
+	mux.HandleFunc("HEAD "+gitlabProjectPath+"/repository/files/{file}", func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := files[r.PathValue("file")]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("X-Gitlab-Blob-Id", "sha-"+strings.TrimSuffix(filepath.Base(r.PathValue("file")), ".md"))
+	})
+
+	return server
+}
+
+func TestGitLabSyncDirectoryPaginatesAndDownloads(t *testing.T) {
+	t.Parallel()
+
+	server := newGitLabTestServer(t, map[string]string{
+		"docs/a.md":     "alpha",
+		"docs/sub/b.md": "bravo",
+	})
+
+	src := source.TestableGitLabSource(t, "widgets", config.Source{
+		Type:     "gitlab",
+		Repo:     "acme/widgets",
+		Path:     "docs",
+		Patterns: []string{"**/*.md"},
+	}, server.URL+"/api/v4", "secret")
+
+	destDir := t.TempDir()
+	prevLock := &lockfile.LockEntry{
+		Type:  "gitlab",
+		Files: map[string]string{"a.md": "sha-a", "gone.md": "sha-gone"},
+	}
+
+	if err := os.WriteFile(filepath.Join(destDir, "gone.md"), []byte("stale"), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	result, err := src.Sync(context.Background(), destDir, prevLock, source.SyncOptions{})
+	if err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+
+	if result.Downloaded != 1 || result.Deleted != 1 {
+		t.Fatalf("Downloaded = %d, Deleted = %d, want 1 and 1", result.Downloaded, result.Deleted)
+	}
+
+	if result.LockEntry.RefResolved != gitlabTrunkCommit || result.LockEntry.Ref != "trunk" {
+		t.Fatalf("LockEntry refs = %q/%q, want commit %q of trunk",
+			result.LockEntry.Ref, result.LockEntry.RefResolved, gitlabTrunkCommit)
+	}
+
+	if result.LockEntry.Files["sub/b.md"] != "sha-b" || len(result.LockEntry.Files) != 2 {
+		t.Fatalf("Files = %v, want a.md and sub/b.md", result.LockEntry.Files)
+	}
+
+	content, err := os.ReadFile(filepath.Join(destDir, "sub", "b.md"))
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+
+	if string(content) != "bravo" {
+		t.Fatalf("file content = %q, want bravo", string(content))
+	}
+
+	if _, statErr := os.Stat(filepath.Join(destDir, "gone.md")); !os.IsNotExist(statErr) {
+		t.Fatalf("gone.md still exists after sync")
+	}
+}
+
+func TestGitLabSyncSkipsWhenBlobsUnchanged(t *testing.T) {
+	t.Parallel()
+
+	server := newGitLabTestServer(t, nil)
+
+	src := source.TestableGitLabSource(t, "widgets", config.Source{
+		Type:     "gitlab",
+		Repo:     "acme/widgets",
+		Path:     "docs",
+		Patterns: []string{"**/*.md"},
+	}, server.URL+"/api/v4", "secret")
+
+	prevLock := &lockfile.LockEntry{
+		Type:  "gitlab",
+		Files: map[string]string{"a.md": "sha-a", "sub/b.md": "sha-b"},
+	}
+
+	result, err := src.Sync(context.Background(), t.TempDir(), prevLock, source.SyncOptions{})
+	if err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+
+	if !result.Skipped {
+		t.Fatalf("Skipped = %v, want true", result.Skipped)
+	}
+}
+
+func TestGitLabSyncSingleFile(t *testing.T) {
+	t.Parallel()
+
+	server := newGitLabTestServer(t, map[string]string{"docs/guide.md": "guide body"})
+
+	src := source.TestableGitLabSource(t, "widgets", config.Source{
+		Type: "gitlab",
+		Repo: "acme/widgets",
+		Path: "docs/guide.md",
+		Ref:  "trunk",
+	}, server.URL+"/api/v4", "secret")
+
+	destDir := t.TempDir()
+
+	result, err := src.Sync(context.Background(), destDir, nil, source.SyncOptions{})
+	if err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+
+	if result.LockEntry.Files["guide.md"] != "sha-guide" {
+		t.Fatalf("Files = %v, want guide.md => sha-guide", result.LockEntry.Files)
+	}
+
+	content, err := os.ReadFile(filepath.Join(destDir, "guide.md"))
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+
+	if string(content) != "guide body" {
+		t.Fatalf("file content = %q, want %q", string(content), "guide body")
+	}
+}
+
+func TestGitLabSyncRequiresToken(t *testing.T) {
+	t.Parallel()
+
+	server := newGitLabTestServer(t, nil)
+
+	src := source.TestableGitLabSource(t, "widgets", config.Source{
+		Type: "gitlab",
+		Repo: "acme/widgets",
+		Path: "docs",
+	}, server.URL+"/api/v4", "")
+
+	_, err := src.Sync(context.Background(), t.TempDir(), nil, source.SyncOptions{DryRun: true})
+	if err == nil {
+		t.Fatalf("Sync() error = nil, want non-nil")
+	}
+
+	if !strings.Contains(err.Error(), "status 401") {
+		t.Fatalf("Sync() error = %q, expected status 401", err.Error())
+	}
+}
@@ -0,0 +1,461 @@
+package source
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/samber/oops"
+	"golang.org/x/mod/module"
+	gosemver "golang.org/x/mod/semver"
+	"golang.org/x/mod/sumdb/dirhash"
+	"resty.dev/v3"
+
+	"github.com/g5becks/dox/internal/cache"
+	"github.com/g5becks/dox/internal/config"
+	"github.com/g5becks/dox/internal/lockfile"
+)
+
+const (
+	sourceTypeGoMod      = "gomod"
+	goModDefaultProxy    = "https://proxy.golang.org"
+	goModLatestVersion   = "latest"
+	goModMaxMetadata     = 1 << 20
+	goModProxyEnvVar     = "GOPROXY"
+	goModProxyDirect     = "direct"
+	goModProxyDisabled   = "off"
+	goModFileProxyScheme = "file://"
+)
+
+// goModSource syncs the documentation of a Go module fetched through the
+// GOPROXY protocol. Besides the README and doc files in the module zip, it
+// renders API docs for every public package from the module's Go sources.
+type goModSource struct {
+	name   string
+	source config.Source
+	proxy  string
+	client *resty.Client
+}
+
+type goModInfo struct {
+	Version string    `json:"Version"`
+	Time    time.Time `json:"Time"`
+}
+
+func NewGoMod(name string, cfg config.Source) (Source, error) {
+	return newGoModSource(name, cfg)
+}
+
+func newGoModSource(name string, cfg config.Source) (*goModSource, error) {
+	if err := module.CheckPath(strings.TrimSpace(cfg.Module)); err != nil {
+		return nil, oops.
+			Code("CONFIG_INVALID").
+			With("source", name).
+			With("module", cfg.Module).
+			Hint("Set module to a Go module path, e.g. \"github.com/spf13/cobra\"").
+			Wrapf(err, "gomod source %q has an invalid module path", name)
+	}
+	cfg.Module = strings.TrimSpace(cfg.Module)
+
+	client, err := newHTTPClient(name, cfg.HTTP)
+	if err != nil {
+		return nil, err
+	}
+
+	proxy := goModProxy(cfg.GoProxy)
+	return &goModSource{
+		name:   name,
+		source: cfg,
+		proxy:  proxy,
+		client: client.SetBaseURL(proxy),
+	}, nil
+}
+
+// goModProxy picks the proxy to use: the configured one, else the first
+// proxy listed in GOPROXY, else the public Go module mirror.
+func goModProxy(configured string) string {
+	if configured != "" {
+		return strings.TrimSuffix(configured, "/")
+	}
+
+	entries := strings.FieldsFunc(os.Getenv(goModProxyEnvVar), func(r rune) bool {
+		return r == ',' || r == '|'
+	})
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry != "" && entry != goModProxyDirect && entry != goModProxyDisabled {
+			return strings.TrimSuffix(entry, "/")
+		}
+	}
+
+	return goModDefaultProxy
+}
+
+func (s *goModSource) Close() error {
+	return s.client.Close()
+}
+
+func (s *goModSource) Sync(
+	ctx context.Context,
+	destDir string,
+	prevLock *lockfile.LockEntry,
+	opts SyncOptions,
+) (*SyncResult, error) {
+	version, err := s.resolveVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Module versions are immutable, so an unchanged version has nothing new.
+	if !opts.Force && prevLock != nil && prevLock.RefResolved == version {
+		return skippedResult(prevLock, sourceTypeGoMod, version), nil
+	}
+
+	key := cache.Key(sourceTypeGoMod, s.source.Module+"@"+version)
+	fetched, err := fetchArchiveCached(opts, key, func() (*fetchedArchive, error) {
+		return s.downloadZip(ctx, version)
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer fetched.cleanup()
+
+	if fetched.digest == "" {
+		if fetched.digest, err = s.hashZip(fetched.path, version); err != nil {
+			return nil, err
+		}
+	}
+
+	oldFiles := map[string]string{}
+	if prevLock != nil && prevLock.Files != nil {
+		oldFiles = prevLock.Files
+	}
+
+	// Module zip members live under "<module>@<version>/".
+	extractCfg := s.source
+	extractCfg.StripComponents = strings.Count(s.source.Module, "/") + 1
+	extraction := newArchiveExtraction(s.name, extractCfg, destDir, oldFiles, opts)
+
+	goFiles := map[string][]byte{}
+	if extractErr := extraction.extract(ctx, fetched.path, extraction.collecting(isGoSourceFile, goFiles)); extractErr != nil {
+		return nil, extractErr
+	}
+
+	if docErr := renderGoModuleDocs(extraction, s.source.Module, goFiles); docErr != nil {
+		return nil, docErr
+	}
+
+	deleted, err := extraction.finish()
+	if err != nil {
+		return nil, err
+	}
+
+	return &SyncResult{
+		Downloaded: extraction.written,
+		Deleted:    deleted,
+		LockEntry: &lockfile.LockEntry{
+			Type:        sourceTypeGoMod,
+			RefResolved: version,
+			Digest:      fetched.digest,
+			SyncedAt:    time.Now().UTC(),
+			Files:       extraction.files,
+		},
+	}, nil
+}
+
+func isGoSourceFile(name string) bool {
+	return strings.HasSuffix(name, ".go") && !strings.HasSuffix(name, "_test.go")
+}
+
+// resolveVersion turns the configured version into a concrete module
+// version. An empty version or "latest" asks the proxy for the latest
+// version, a semver range picks the highest listed version satisfying it,
+// and anything else (an exact version, branch, or commit) is passed to the
+// proxy as a version query.
+func (s *goModSource) resolveVersion(ctx context.Context) (string, error) {
+	requested := strings.TrimSpace(s.source.Version)
+	if requested == "" || requested == goModLatestVersion {
+		return s.latestVersion(ctx)
+	}
+
+	if !gosemver.IsValid(requested) {
+		if constraint, err := semver.NewConstraint(requested); err == nil {
+			return s.matchVersion(ctx, requested, constraint)
+		}
+	}
+
+	info, found, err := s.fetchInfo(ctx, requested)
+	if err != nil {
+		return "", err
+	}
+	if !found {
+		return "", s.versionNotFound(requested)
+	}
+
+	return info.Version, nil
+}
+
+func (s *goModSource) latestVersion(ctx context.Context) (string, error) {
+	body, found, err := s.fetchMetadata(ctx, "@latest")
+	if err != nil {
+		return "", err
+	}
+
+	if found {
+		info := goModInfo{}
+		if jsonErr := json.Unmarshal(body, &info); jsonErr != nil {
+			return "", s.proxyError(jsonErr, "@latest")
+		}
+		if info.Version != "" {
+			return info.Version, nil
+		}
+	}
+
+	versions, err := s.listVersions(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	best := ""
+	for _, version := range versions {
+		if best == "" || newerGoVersion(version, best) {
+			best = version
+		}
+	}
+
+	if best == "" {
+		return "", s.versionNotFound(goModLatestVersion)
+	}
+
+	return best, nil
+}
+
+// newerGoVersion reports whether candidate is a better "latest" than
+// current: releases beat pre-releases, otherwise the higher version wins.
+func newerGoVersion(candidate string, current string) bool {
+	candidateRelease := gosemver.Prerelease(candidate) == ""
+	currentRelease := gosemver.Prerelease(current) == ""
+	if candidateRelease != currentRelease {
+		return candidateRelease
+	}
+
+	return gosemver.Compare(candidate, current) > 0
+}
+
+func (s *goModSource) matchVersion(ctx context.Context, requested string, constraint *semver.Constraints) (string, error) {
+	versions, err := s.listVersions(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	var best *semver.Version
+	bestName := ""
+	for _, name := range versions {
+		candidate, parseErr := semver.NewVersion(name)
+		if parseErr != nil || !constraint.Check(candidate) {
+			continue
+		}
+
+		if best == nil || candidate.GreaterThan(best) {
+			best = candidate
+			bestName = name
+		}
+	}
+
+	if best == nil {
+		return "", s.versionNotFound(requested)
+	}
+
+	return bestName, nil
+}
+
+// listVersions returns the tagged versions the proxy knows for the module.
+func (s *goModSource) listVersions(ctx context.Context) ([]string, error) {
+	body, found, err := s.fetchMetadata(ctx, "@v/list")
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, s.moduleNotFound()
+	}
+
+	versions := []string{}
+	for _, line := range strings.Split(string(body), "\n") {
+		if version := strings.TrimSpace(line); gosemver.IsValid(version) {
+			versions = append(versions, version)
+		}
+	}
+
+	return versions, nil
+}
+
+func (s *goModSource) fetchInfo(ctx context.Context, version string) (*goModInfo, bool, error) {
+	escaped, err := module.EscapeVersion(version)
+	if err != nil {
+		return nil, false, oops.
+			Code("CONFIG_INVALID").
+			With("source", s.name).
+			With("version", version).
+			Hint("Use a version like \"v1.2.3\", a semver range like \"^1.2\", or \"latest\"").
+			Wrapf(err, "invalid version %q", version)
+	}
+
+	endpoint := "@v/" + escaped + ".info"
+	body, found, err := s.fetchMetadata(ctx, endpoint)
+	if err != nil || !found {
+		return nil, found, err
+	}
+
+	info := &goModInfo{}
+	if jsonErr := json.Unmarshal(body, info); jsonErr != nil {
+		return nil, false, s.proxyError(jsonErr, endpoint)
+	}
+
+	return info, true, nil
+}
+
+func (s *goModSource) fetchMetadata(ctx context.Context, endpoint string) ([]byte, bool, error) {
+	body, found, err := s.open(ctx, endpoint)
+	if err != nil || !found {
+		return nil, found, err
+	}
+	defer body.Close()
+
+	content, err := io.ReadAll(io.LimitReader(body, goModMaxMetadata))
+	if err != nil {
+		return nil, false, s.proxyError(err, endpoint)
+	}
+
+	return content, true, nil
+}
+
+// downloadZip fetches the module zip and records its go.sum-style hash.
+func (s *goModSource) downloadZip(ctx context.Context, version string) (*fetchedArchive, error) {
+	escaped, err := module.EscapeVersion(version)
+	if err != nil {
+		return nil, s.proxyError(err, version)
+	}
+
+	endpoint := "@v/" + escaped + ".zip"
+	body, found, err := s.open(ctx, endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, s.versionNotFound(version)
+	}
+	defer body.Close()
+
+	location := s.proxy + "/" + s.source.Module + "/" + endpoint
+	fetched, err := spoolArchive(s.name, location, body, archiveLimitsFor(s.source).maxSize)
+	if err != nil {
+		return nil, err
+	}
+
+	if fetched.digest, err = s.hashZip(fetched.path, version); err != nil {
+		fetched.cleanup()
+		return nil, err
+	}
+
+	return fetched, nil
+}
+
+// hashZip computes the go.sum h1: hash of a module zip.
+func (s *goModSource) hashZip(zipPath string, version string) (string, error) {
+	digest, err := dirhash.HashZip(zipPath, dirhash.Hash1)
+	if err != nil {
+		return "", oops.
+			Code("ARCHIVE_ERROR").
+			With("source", s.name).
+			With("module", s.source.Module).
+			With("version", version).
+			Wrapf(err, "hashing module zip")
+	}
+
+	return digest, nil
+}
+
+// open returns the body of a proxy endpoint below the module path. A
+// missing endpoint is reported as not found rather than as an error, since
+// proxies answer 404 or 410 for unknown versions.
+func (s *goModSource) open(ctx context.Context, endpoint string) (io.ReadCloser, bool, error) {
+	escapedModule, err := module.EscapePath(s.source.Module)
+	if err != nil {
+		return nil, false, s.proxyError(err, endpoint)
+	}
+	requestPath := escapedModule + "/" + endpoint
+
+	if strings.HasPrefix(s.proxy, goModFileProxyScheme) {
+		file, openErr := os.Open(path.Join(localFilePath(s.proxy), requestPath))
+		if os.IsNotExist(openErr) {
+			return nil, false, nil
+		}
+		if openErr != nil {
+			return nil, false, s.proxyError(openErr, endpoint)
+		}
+
+		return file, true, nil
+	}
+
+	response, err := s.client.R().
+		SetContext(ctx).
+		SetDoNotParseResponse(true).
+		Get("/" + requestPath)
+	if err != nil {
+		return nil, false, s.proxyError(err, endpoint)
+	}
+
+	status := response.StatusCode()
+	if status == http.StatusNotFound || status == http.StatusGone {
+		response.Body.Close()
+		return nil, false, nil
+	}
+
+	if !response.IsSuccess() {
+		response.Body.Close()
+		return nil, false, oops.
+			Code("GOPROXY_ERROR").
+			With("source", s.name).
+			With("module", s.source.Module).
+			With("proxy", config.RedactURL(s.proxy)).
+			With("status", status).
+			Errorf("module proxy returned status %d for %s", status, endpoint)
+	}
+
+	return response.Body, true, nil
+}
+
+func (s *goModSource) proxyError(err error, endpoint string) error {
+	return oops.
+		Code("GOPROXY_ERROR").
+		With("source", s.name).
+		With("module", s.source.Module).
+		With("proxy", config.RedactURL(s.proxy)).
+		Wrapf(redactURLError(err), "fetching %s from module proxy", endpoint)
+}
+
+func (s *goModSource) moduleNotFound() error {
+	return oops.
+		Code("GOPROXY_ERROR").
+		With("source", s.name).
+		With("module", s.source.Module).
+		With("proxy", config.RedactURL(s.proxy)).
+		Hint("Check the module path and goproxy in your config").
+		Errorf("module proxy does not know module %s", s.source.Module)
+}
+
+func (s *goModSource) versionNotFound(requested string) error {
+	return oops.
+		Code("VERSION_NOT_FOUND").
+		With("source", s.name).
+		With("module", s.source.Module).
+		With("version", requested).
+		Hint("Check the published versions of the module").
+		Errorf("no version of %s matches %q", s.source.Module, requested)
+}
@@ -0,0 +1,248 @@
+package source
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/doc"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"io"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/samber/oops"
+)
+
+const (
+	// goDocDir holds the rendered API docs of a module's packages; the root
+	// package is written to goDocRootFile next to it.
+	goDocDir      = "api"
+	goDocRootFile = "api.md"
+	goDocLinkBase = "https://pkg.go.dev"
+
+	// Build constraints are evaluated for one fixed platform so the rendered
+	// docs do not depend on the machine running dox.
+	goDocGOOS   = "linux"
+	goDocGOARCH = "amd64"
+
+	goDocSectionLevel = 3
+	goDocMemberLevel  = 4
+)
+
+// renderGoModuleDocs renders a markdown API reference for every public
+// package of a module from its Go sources, keyed by module-relative path,
+// and adds the pages to the extraction. Pages matching the source's
+// excludes are skipped, so excluding "api/**" and "api.md" turns them off.
+func renderGoModuleDocs(x *archiveExtraction, modulePath string, goFiles map[string][]byte) error {
+	packages := map[string][]string{}
+	for name := range goFiles {
+		dir := path.Dir(name)
+		if isPublicPackageDir(dir) {
+			packages[dir] = append(packages[dir], name)
+		}
+	}
+
+	dirs := make([]string, 0, len(packages))
+	for dir := range packages {
+		dirs = append(dirs, dir)
+	}
+	slices.Sort(dirs)
+
+	for _, dir := range dirs {
+		importPath := modulePath
+		outputPath := goDocRootFile
+		if dir != "." {
+			importPath = modulePath + "/" + dir
+			outputPath = goDocDir + "/" + dir + ".md"
+		}
+
+		excluded, err := matchesAny(x.source.Exclude, outputPath)
+		if err != nil {
+			return err
+		}
+		if excluded {
+			continue
+		}
+
+		page, err := renderGoPackageDoc(importPath, packages[dir], goFiles)
+		if err != nil {
+			return oops.
+				With("source", x.sourceName).
+				With("package", importPath).
+				Wrap(err)
+		}
+		if page == nil {
+			continue
+		}
+
+		if addErr := x.add(outputPath, page); addErr != nil {
+			return addErr
+		}
+	}
+
+	return nil
+}
+
+// isPublicPackageDir reports whether packages in dir can be imported by
+// other modules and are worth documenting.
+func isPublicPackageDir(dir string) bool {
+	if dir == "." {
+		return true
+	}
+
+	for _, element := range strings.Split(dir, "/") {
+		if element == "internal" || element == "testdata" || element == "vendor" ||
+			strings.HasPrefix(element, ".") || strings.HasPrefix(element, "_") {
+			return false
+		}
+	}
+
+	return true
+}
+
+// renderGoPackageDoc renders the API reference of one package. It returns
+// nil for directories holding only commands or files excluded by build
+// constraints.
+func renderGoPackageDoc(importPath string, names []string, goFiles map[string][]byte) ([]byte, error) {
+	fset := token.NewFileSet()
+	files, err := parseGoPackage(fset, names, goFiles)
+	if err != nil || len(files) == 0 {
+		return nil, err
+	}
+
+	pkg, err := doc.NewFromFiles(fset, files, importPath)
+	if err != nil {
+		return nil, oops.
+			Code("GODOC_ERROR").
+			Wrapf(err, "reading package documentation")
+	}
+
+	page := &goDocPage{fset: fset, pkg: pkg}
+	page.render()
+
+	return append(bytes.TrimRight(page.buf.Bytes(), "\n"), '\n'), nil
+}
+
+// parseGoPackage parses the files of a package directory that build for
+// the documentation platform. Commands (package main) yield no files.
+func parseGoPackage(fset *token.FileSet, names []string, goFiles map[string][]byte) ([]*ast.File, error) {
+	slices.Sort(names)
+
+	buildCtx := build.Default
+	buildCtx.GOOS = goDocGOOS
+	buildCtx.GOARCH = goDocGOARCH
+	buildCtx.CgoEnabled = false
+	buildCtx.JoinPath = path.Join
+	buildCtx.OpenFile = func(name string) (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(goFiles[name])), nil
+	}
+
+	files := []*ast.File{}
+	packageName := ""
+	for _, name := range names {
+		match, matchErr := buildCtx.MatchFile(path.Dir(name), path.Base(name))
+		if matchErr != nil || !match {
+			continue
+		}
+
+		file, parseErr := parser.ParseFile(fset, name, goFiles[name], parser.ParseComments)
+		if parseErr != nil {
+			return nil, oops.
+				Code("GODOC_ERROR").
+				With("file", name).
+				Wrapf(parseErr, "parsing Go source")
+		}
+
+		// Stray files of another package (like ignored generators) are
+		// left out instead of failing the whole package.
+		if packageName == "" {
+			packageName = file.Name.Name
+		}
+		if file.Name.Name != packageName {
+			continue
+		}
+
+		files = append(files, file)
+	}
+
+	if packageName == "main" {
+		return nil, nil
+	}
+
+	return files, nil
+}
+
+// goDocPage renders a package's documentation as markdown.
+type goDocPage struct {
+	fset *token.FileSet
+	pkg  *doc.Package
+	buf  bytes.Buffer
+}
+
+func (p *goDocPage) render() {
+	fmt.Fprintf(&p.buf, "# %s\n\n", p.pkg.ImportPath)
+	fmt.Fprintf(&p.buf, "```go\nimport %q\n```\n\n", p.pkg.ImportPath)
+	p.comment(p.pkg.Doc, goDocSectionLevel)
+
+	if len(p.pkg.Funcs) > 0 {
+		p.buf.WriteString("## Functions\n\n")
+		for _, fn := range p.pkg.Funcs {
+			p.function(fn, goDocSectionLevel)
+		}
+	}
+
+	if len(p.pkg.Types) > 0 {
+		p.buf.WriteString("## Types\n\n")
+		for _, typ := range p.pkg.Types {
+			fmt.Fprintf(&p.buf, "%s type %s\n\n", strings.Repeat("#", goDocSectionLevel), typ.Name)
+			p.code(typ.Decl)
+			p.comment(typ.Doc, goDocMemberLevel)
+
+			for _, fn := range typ.Funcs {
+				p.function(fn, goDocMemberLevel)
+			}
+			for _, method := range typ.Methods {
+				p.function(method, goDocMemberLevel)
+			}
+		}
+	}
+}
+
+func (p *goDocPage) function(fn *doc.Func, level int) {
+	heading := "func " + fn.Name
+	if fn.Recv != "" {
+		heading = fmt.Sprintf("func (%s) %s", fn.Recv, fn.Name)
+	}
+
+	fmt.Fprintf(&p.buf, "%s %s\n\n", strings.Repeat("#", level), heading)
+	p.code(fn.Decl)
+	p.comment(fn.Doc, level+1)
+}
+
+// code prints a declaration without its doc comment or body.
+func (p *goDocPage) code(node ast.Node) {
+	var decl bytes.Buffer
+	if err := printer.Fprint(&decl, p.fset, node); err != nil {
+		return
+	}
+
+	fmt.Fprintf(&p.buf, "```go\n%s\n```\n\n", decl.String())
+}
+
+// comment renders a doc comment, nesting its headings below level.
+func (p *goDocPage) comment(text string, level int) {
+	if strings.TrimSpace(text) == "" {
+		return
+	}
+
+	docPrinter := p.pkg.Printer()
+	docPrinter.HeadingLevel = level
+	docPrinter.DocLinkBaseURL = goDocLinkBase
+
+	p.buf.Write(docPrinter.Markdown(p.pkg.Parser().Parse(text)))
+	p.buf.WriteString("\n")
+}
@@ -0,0 +1,268 @@
+package source_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/mod/module"
+
+	"github.com/g5becks/dox/internal/config"
+	"github.com/g5becks/dox/internal/source"
+)
+
+const widgetSource = `// Package widget builds widgets.
+//
+// # Usage
+//
+// Call [New] and render the result.
+package widget
+
+// Widget is a renderable widget.
+type Widget struct {
+	Name string
+	size int
+}
+
+// New returns a widget with the given name.
+func New(name string) *Widget {
+	return &Widget{Name: name}
+}
+
+// Render draws the widget.
+func (w *Widget) Render() string {
+	return w.Name
+}
+
+// Version reports the library version.
+func Version() string { return "1" }
+
+func helper() {}
+`
+
+// writeGoProxyVersion publishes one module version in a file-based proxy
+// laid out like GOPROXY=file://proxyDir.
+func writeGoProxyVersion(t *testing.T, proxyDir string, modulePath string, version string, members ...archiveMember) {
+	t.Helper()
+
+	escapedPath, err := module.EscapePath(modulePath)
+	if err != nil {
+		t.Fatalf("EscapePath() error = %v", err)
+	}
+
+	versionDir := filepath.Join(proxyDir, filepath.FromSlash(escapedPath), "@v")
+	if err := os.MkdirAll(versionDir, 0o755); err != nil {
+		t.Fatalf("MkdirAll() error = %v", err)
+	}
+
+	info := fmt.Sprintf(`{"Version":%q,"Time":"2024-01-02T03:04:05Z"}`, version)
+	if err := os.WriteFile(filepath.Join(versionDir, version+".info"), []byte(info), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	prefixed := make([]archiveMember, 0, len(members))
+	for _, member := range members {
+		prefixed = append(prefixed, archiveMember{name: modulePath + "@" + version + "/" + member.name, content: member.content})
+	}
+	writeZip(t, filepath.Join(versionDir, version+".zip"), prefixed...)
+
+	list, err := os.OpenFile(filepath.Join(versionDir, "list"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		t.Fatalf("OpenFile() error = %v", err)
+	}
+	defer list.Close()
+
+	if _, err := list.WriteString(version + "\n"); err != nil {
+		t.Fatalf("WriteString() error = %v", err)
+	}
+}
+
+func widgetModule(readme string) []archiveMember {
+	return []archiveMember{
+		{name: "README.md", content: readme},
+		{name: "go.mod", content: "module example.com/widget\n"},
+		{name: "widget.go", content: widgetSource},
+		{name: "widget_test.go", content: "package widget\n\nfunc TestHidden() {}\n"},
+		{name: "widget_windows.go", content: "package widget\n\n// WindowsOnly is skipped.\nfunc WindowsOnly() {}\n"},
+		{name: "shapes/circle.go", content: "// Package shapes has shapes.\npackage shapes\n\n// Circle is round.\ntype Circle struct{}\n"},
+		{name: "internal/secret/secret.go", content: "package secret\n\nfunc Hidden() {}\n"},
+		{name: "cmd/widgetctl/main.go", content: "package main\n\nfunc main() {}\n"},
+	}
+}
+
+func TestGoModSyncResolvesRangeAndRendersAPIDocs(t *testing.T) {
+	t.Parallel()
+
+	proxyDir := t.TempDir()
+	for _, version := range []string{"v1.0.0", "v1.2.0", "v1.3.0-rc.1"} {
+		writeGoProxyVersion(t, proxyDir, "example.com/widget", version, widgetModule("# Widget "+version)...)
+	}
+
+	src, err := source.New("widget", config.Source{
+		Type:    "gomod",
+		Module:  "example.com/widget",
+		Version: "^1.1",
+		GoProxy: "file://" + proxyDir,
+	}, "")
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	destDir := t.TempDir()
+	first, err := src.Sync(context.Background(), destDir, nil, source.SyncOptions{})
+	if err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+
+	if first.LockEntry.RefResolved != "v1.2.0" {
+		t.Fatalf("RefResolved = %q, want %q", first.LockEntry.RefResolved, "v1.2.0")
+	}
+	if !strings.HasPrefix(first.LockEntry.Digest, "h1:") {
+		t.Fatalf("Digest = %q, want a go.sum hash", first.LockEntry.Digest)
+	}
+
+	if first.Downloaded != 3 {
+		t.Fatalf("Downloaded = %d, want README and two API pages (files: %v)", first.Downloaded, first.LockEntry.Files)
+	}
+
+	api, err := os.ReadFile(filepath.Join(destDir, "api.md"))
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+
+	for _, want := range []string{
+		"# example.com/widget",
+		"Package widget builds widgets.",
+		"### Usage",
+		"### func Version",
+		"func New(name string) *Widget",
+		"#### func (*Widget) Render",
+		"Render draws the widget.",
+	} {
+		if !strings.Contains(string(api), want) {
+			t.Fatalf("api.md is missing %q:\n%s", want, api)
+		}
+	}
+
+	for _, unwanted := range []string{"helper", "WindowsOnly", "TestHidden", "return w.Name"} {
+		if strings.Contains(string(api), unwanted) {
+			t.Fatalf("api.md contains %q:\n%s", unwanted, api)
+		}
+	}
+
+	if _, statErr := os.Stat(filepath.Join(destDir, "api", "shapes.md")); statErr != nil {
+		t.Fatalf("expected shapes API page: %v", statErr)
+	}
+
+	second, err := src.Sync(context.Background(), destDir, first.LockEntry, source.SyncOptions{})
+	if err != nil {
+		t.Fatalf("second Sync() error = %v", err)
+	}
+
+	if !second.Skipped {
+		t.Fatalf("Skipped = false, want true for an unchanged version")
+	}
+}
+
+func TestGoModSyncLatestFromListWithEscapedPath(t *testing.T) {
+	t.Parallel()
+
+	proxyDir := t.TempDir()
+	for _, version := range []string{"v1.0.0", "v1.1.0", "v1.2.0-rc.1"} {
+		writeGoProxyVersion(t, proxyDir, "example.com/BigCo/Widget", version, widgetModule("# Widget "+version)...)
+	}
+
+	src, err := source.New("widget", config.Source{
+		Type:    "gomod",
+		Module:  "example.com/BigCo/Widget",
+		GoProxy: "file://" + proxyDir,
+		Exclude: []string{"api.md", "api/**"},
+	}, "")
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	destDir := t.TempDir()
+	result, err := src.Sync(context.Background(), destDir, nil, source.SyncOptions{})
+	if err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+
+	if result.LockEntry.RefResolved != "v1.1.0" {
+		t.Fatalf("RefResolved = %q, want the highest release", result.LockEntry.RefResolved)
+	}
+
+	if len(result.LockEntry.Files) != 1 {
+		t.Fatalf("Files = %v, want only README with API pages excluded", result.LockEntry.Files)
+	}
+}
+
+func TestGoModSyncOverHTTPUsesLatestQuery(t *testing.T) {
+	t.Parallel()
+
+	zipPath := filepath.Join(t.TempDir(), "module.zip")
+	writeZip(t, zipPath, archiveMember{name: "github.com/!acme/widget@v0.4.0/README.md", content: "# Widget"})
+	zipContent, err := os.ReadFile(zipPath)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+
+	requests := []string{}
+	client := source.NewMockRestyClient(func(req *http.Request) *http.Response {
+		requests = append(requests, req.URL.Path)
+		switch req.URL.Path {
+		case "/github.com/!acme/widget/@latest":
+			return source.NewHTTPResponse(req, http.StatusOK, `{"Version":"v0.4.0"}`, nil)
+		case "/github.com/!acme/widget/@v/v0.4.0.zip":
+			return source.NewHTTPResponse(req, http.StatusOK, string(zipContent), nil)
+		default:
+			return source.NewHTTPResponse(req, http.StatusNotFound, "not found", nil)
+		}
+	})
+
+	src, setClient := source.TestableGoModSource(t, "widget", config.Source{
+		Type:    "gomod",
+		Module:  "github.com/Acme/widget",
+		GoProxy: "https://goproxy.test",
+	})
+	setClient(client)
+
+	result, err := src.Sync(context.Background(), t.TempDir(), nil, source.SyncOptions{})
+	if err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+
+	if result.LockEntry.RefResolved != "v0.4.0" || result.Downloaded != 1 {
+		t.Fatalf("Sync() = %+v, want v0.4.0 with the README", result.LockEntry)
+	}
+
+	if len(requests) != 2 {
+		t.Fatalf("requests = %v, want @latest and the zip", requests)
+	}
+}
+
+func TestGoModSyncNoMatchingVersion(t *testing.T) {
+	t.Parallel()
+
+	proxyDir := t.TempDir()
+	writeGoProxyVersion(t, proxyDir, "example.com/widget", "v1.0.0", widgetModule("# Widget")...)
+
+	src, err := source.New("widget", config.Source{
+		Type:    "gomod",
+		Module:  "example.com/widget",
+		Version: "v1.9.9",
+		GoProxy: "file://" + proxyDir,
+	}, "")
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	_, syncErr := src.Sync(context.Background(), t.TempDir(), nil, source.SyncOptions{})
+	if syncErr == nil || !strings.Contains(syncErr.Error(), "no version of example.com/widget") {
+		t.Fatalf("Sync() error = %v, want version error", syncErr)
+	}
+}
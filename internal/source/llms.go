@@ -0,0 +1,231 @@
+package source
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	neturl "net/url"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/samber/oops"
+
+	"github.com/g5becks/dox/internal/config"
+	"github.com/g5becks/dox/internal/lockfile"
+)
+
+// llmsLinkPattern matches a link item of an llms.txt section:
+// "- [Title](url): optional description".
+var llmsLinkPattern = regexp.MustCompile(
+	`^[-*+]\s+\[([^\]]+)\]\(\s*<?([^)\s>]+)>?(?:\s+"[^"]*")?\s*\)\s*(?::\s*(.*))?$`,
+)
+
+// parseLLMSIndex returns the links listed in an llms.txt file, in order,
+// with the title of the "##" section they appear under. Links are resolved
+// against base; links that are not HTTP or HTTPS are ignored.
+func parseLLMSIndex(content string, base *neturl.URL) []mirrorLink {
+	links := []mirrorLink{}
+	seen := map[string]bool{}
+	section := ""
+
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		if title, ok := strings.CutPrefix(line, "## "); ok {
+			section = strings.TrimSpace(title)
+			continue
+		}
+
+		match := llmsLinkPattern.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+
+		target, err := base.Parse(match[2])
+		if err != nil || (target.Scheme != "http" && target.Scheme != "https") {
+			continue
+		}
+		target.Fragment, target.RawFragment = "", ""
+
+		if seen[target.String()] {
+			continue
+		}
+		seen[target.String()] = true
+
+		links = append(links, mirrorLink{
+			url:         target.String(),
+			section:     section,
+			title:       strings.TrimSpace(match[1]),
+			description: strings.TrimSpace(match[3]),
+		})
+	}
+
+	return links
+}
+
+// llmsLinkPath maps a linked resource to its path in the mirror: relative
+// to the directory of the index for links on the same host, and below the
+// host name otherwise. Paths without an extension get ".md".
+func llmsLinkPath(index *neturl.URL, link *neturl.URL) string {
+	relativePath := link.Host + link.Path
+	if strings.EqualFold(link.Host, index.Host) {
+		var found bool
+		relativePath, found = strings.CutPrefix(link.Path, siteBaseDir(index.Path))
+		if !found {
+			relativePath = strings.TrimPrefix(link.Path, "/")
+		}
+	}
+
+	switch {
+	case relativePath == "" || strings.HasSuffix(relativePath, "/"):
+		relativePath += "index.md"
+	case path.Ext(relativePath) == "":
+		relativePath += ".md"
+	}
+
+	return strings.TrimPrefix(path.Clean("/"+relativePath), "/")
+}
+
+// syncIndex downloads the llms.txt index and mirrors every resource it
+// links to. Each resource is fetched with its own validators, so a sync
+// only downloads what changed even when the index itself did not.
+func (s *urlSource) syncIndex(
+	ctx context.Context,
+	destDir string,
+	prevLock *lockfile.LockEntry,
+	opts SyncOptions,
+) (*SyncResult, error) {
+	index, err := neturl.Parse(s.source.URL)
+	if err != nil {
+		return nil, oops.
+			Code("CONFIG_INVALID").
+			With("source", s.name).
+			With("url", config.RedactURL(s.source.URL)).
+			Wrapf(err, "parsing llms.txt url")
+	}
+
+	mirror := newURLMirror(s, destDir, prevLock, opts)
+
+	indexEntry, links, err := s.fetchIndex(ctx, index, mirror, prevLock, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// Links mapping to the same path go to the first URL in sorted order,
+	// so the winner does not depend on whether the links were parsed from
+	// the index or rebuilt from the lock, or on their order in the index.
+	slices.SortStableFunc(links, func(a mirrorLink, b mirrorLink) int {
+		return strings.Compare(a.url, b.url)
+	})
+
+	toDownload := map[string]string{}
+	linksByURL := map[string]mirrorLink{}
+	for _, link := range links {
+		linkURL, parseErr := neturl.Parse(link.url)
+		if parseErr != nil {
+			continue
+		}
+
+		relativePath := llmsLinkPath(index, linkURL)
+		if _, taken := toDownload[relativePath]; taken || relativePath == s.filename {
+			continue
+		}
+
+		toDownload[relativePath] = link.url
+		linksByURL[link.url] = link
+	}
+
+	return mirror.run(ctx, indexEntry, toDownload, linksByURL)
+}
+
+// fetchIndex downloads the llms.txt file itself and returns the lock entry
+// for it along with its links. When the index is unchanged its links are
+// taken from the previous lock entry.
+func (s *urlSource) fetchIndex(
+	ctx context.Context,
+	index *neturl.URL,
+	mirror *urlMirror,
+	prevLock *lockfile.LockEntry,
+	opts SyncOptions,
+) (*lockfile.LockEntry, []mirrorLink, error) {
+	var etag, lastMod string
+	if !opts.Force && prevLock != nil && revalidates(mirror.oldFiles[s.filename], s.pin) {
+		etag, lastMod = prevLock.ETag, prevLock.LastMod
+	}
+
+	response, err := s.conditionalGet(ctx, s.source.URL, etag, lastMod)
+	if err != nil {
+		return nil, nil, oops.
+			Code("DOWNLOAD_FAILED").
+			With("source", s.name).
+			With("url", config.RedactURL(s.source.URL)).
+			Wrapf(err, "downloading llms.txt index")
+	}
+
+	entry := &lockfile.LockEntry{
+		Type:     "url",
+		ETag:     response.Header().Get("ETag"),
+		LastMod:  response.Header().Get("Last-Modified"),
+		SyncedAt: time.Now().UTC(),
+	}
+
+	if response.StatusCode() == http.StatusNotModified && prevLock != nil {
+		entry.ETag, entry.LastMod = prevLock.ETag, prevLock.LastMod
+		if sha, ok := mirror.oldFiles[s.filename]; ok {
+			mirror.files[s.filename] = sha
+		}
+
+		return entry, indexLinksFromLock(prevLock), nil
+	}
+
+	if !response.IsSuccess() {
+		return nil, nil, oops.
+			Code("DOWNLOAD_FAILED").
+			With("source", s.name).
+			With("url", config.RedactURL(s.source.URL)).
+			With("status", response.StatusCode()).
+			Errorf("url source returned non-success status %d", response.StatusCode())
+	}
+
+	content, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, nil, oops.
+			Code("DOWNLOAD_FAILED").
+			With("source", s.name).
+			With("url", config.RedactURL(s.source.URL)).
+			Wrapf(err, "reading response body")
+	}
+
+	sha := contentSHA256(content)
+	if pinErr := s.checkPin(s.source.URL, s.pin, sha); pinErr != nil {
+		return nil, nil, pinErr
+	}
+
+	if writeErr := mirror.store(s.filename, content, sha); writeErr != nil {
+		return nil, nil, writeErr
+	}
+
+	return entry, parseLLMSIndex(string(content), index), nil
+}
+
+// indexLinksFromLock rebuilds the links of an unchanged index from the
+// pages recorded when it was last downloaded.
+func indexLinksFromLock(prevLock *lockfile.LockEntry) []mirrorLink {
+	links := make([]mirrorLink, 0, len(prevLock.Pages))
+	for _, rawURL := range sortedKeys(prevLock.Pages) {
+		page := prevLock.Pages[rawURL]
+		links = append(links, mirrorLink{
+			url:         rawURL,
+			section:     page.Section,
+			title:       page.Title,
+			description: page.Description,
+		})
+	}
+
+	return links
+}
@@ -0,0 +1,337 @@
+package source
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/samber/oops"
+
+	"github.com/g5becks/dox/internal/config"
+	"github.com/g5becks/dox/internal/lockfile"
+)
+
+const (
+	sourceTypeLocal = "local"
+
+	localModeCopy     = "copy"
+	localModeHardlink = "hardlink"
+	localModeSymlink  = "symlink"
+
+	localLockFileName = ".dox.lock"
+)
+
+// localSource syncs documentation from a directory on the local filesystem.
+// Files are copied, hard-linked, or symlinked into the output directory and
+// tracked by the SHA-256 of their content.
+type localSource struct {
+	name   string
+	source config.Source
+	root   string
+	mode   string
+}
+
+func NewLocal(name string, cfg config.Source) (Source, error) {
+	return newLocalSource(name, cfg)
+}
+
+func newLocalSource(name string, cfg config.Source) (*localSource, error) {
+	root, err := filepath.Abs(cfg.Dir)
+	if err != nil {
+		return nil, oops.
+			Code("CONFIG_INVALID").
+			With("source", name).
+			With("dir", cfg.Dir).
+			Wrapf(err, "resolving local directory")
+	}
+
+	mode := cfg.Mode
+	if mode == "" {
+		mode = localModeCopy
+	}
+
+	return &localSource{
+		name:   name,
+		source: cfg,
+		root:   root,
+		mode:   mode,
+	}, nil
+}
+
+func (s *localSource) Close() error {
+	return nil
+}
+
+func (s *localSource) Sync(
+	ctx context.Context,
+	destDir string,
+	prevLock *lockfile.LockEntry,
+	opts SyncOptions,
+) (*SyncResult, error) {
+	candidates, err := s.listFiles(destDir)
+	if err != nil {
+		return nil, err
+	}
+
+	selected, err := filterTreeFiles(candidates, s.source)
+	if err != nil {
+		return nil, err
+	}
+
+	newFiles, err := s.hashFiles(ctx, selected)
+	if err != nil {
+		return nil, err
+	}
+
+	oldFiles := map[string]string{}
+	if prevLock != nil && prevLock.Files != nil {
+		oldFiles = prevLock.Files
+	}
+
+	toPlace := diffDownloads(newFiles, oldFiles, opts.Force)
+	for relativePath, sha := range newFiles {
+		if s.needsPlacement(selected[relativePath], destDir, relativePath) {
+			toPlace[relativePath] = sha
+		}
+	}
+	toDelete := diffDeletes(oldFiles, newFiles)
+
+	if prevLock != nil && len(toPlace) == 0 && len(toDelete) == 0 {
+		return skippedResult(prevLock, sourceTypeLocal, ""), nil
+	}
+
+	if !opts.DryRun {
+		for _, relativePath := range sortedKeys(toPlace) {
+			if placeErr := s.placeFile(selected[relativePath], destDir, relativePath); placeErr != nil {
+				return nil, placeErr
+			}
+		}
+
+		if deleteErr := deleteStaleFiles(s.name, destDir, toDelete); deleteErr != nil {
+			return nil, deleteErr
+		}
+	}
+
+	return &SyncResult{
+		Downloaded: len(toPlace),
+		Deleted:    len(toDelete),
+		LockEntry: &lockfile.LockEntry{
+			Type:     sourceTypeLocal,
+			SyncedAt: time.Now().UTC(),
+			Files:    newFiles,
+		},
+	}, nil
+}
+
+// listFiles returns the regular files below the source directory, keyed by
+// slash-separated relative path with the absolute path as value. Symlinked
+// files map to the file they resolve to, so links and copies are made of
+// the target rather than the link; symlinked directories are not followed, and the destination directory and any dox
+// output root (marked by its lock file) are skipped so a source directory
+// containing them does not copy synced output back in.
+func (s *localSource) listFiles(destDir string) (map[string]string, error) {
+	info, err := os.Stat(s.root)
+	if err != nil || !info.IsDir() {
+		if err == nil {
+			err = oops.Errorf("not a directory")
+		}
+
+		return nil, oops.
+			Code("LOCAL_ERROR").
+			With("source", s.name).
+			With("dir", s.root).
+			Hint("Check dir in your config").
+			Wrapf(err, "reading local directory")
+	}
+
+	skipDir, _ := filepath.Abs(destDir)
+	files := make(map[string]string)
+
+	walkErr := filepath.WalkDir(s.root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if entry.IsDir() {
+			if path == skipDir || isOutputRoot(path) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		filePath := path
+		if entry.Type()&fs.ModeSymlink != 0 {
+			target, statErr := os.Stat(path)
+			if statErr != nil || !target.Mode().IsRegular() {
+				return nil //nolint:nilerr // dangling links and links to directories are skipped
+			}
+
+			resolved, evalErr := filepath.EvalSymlinks(path)
+			if evalErr != nil {
+				return evalErr
+			}
+			if filePath, evalErr = filepath.Abs(resolved); evalErr != nil {
+				return evalErr
+			}
+		} else if !entry.Type().IsRegular() {
+			return nil
+		}
+
+		relativePath, relErr := filepath.Rel(s.root, path)
+		if relErr != nil {
+			return relErr
+		}
+
+		files[filepath.ToSlash(relativePath)] = filePath
+		return nil
+	})
+	if walkErr != nil {
+		return nil, oops.
+			Code("LOCAL_ERROR").
+			With("source", s.name).
+			With("dir", s.root).
+			Wrapf(walkErr, "walking local directory")
+	}
+
+	return files, nil
+}
+
+func (s *localSource) hashFiles(ctx context.Context, selected map[string]string) (map[string]string, error) {
+	hashes := make(map[string]string, len(selected))
+	for _, relativePath := range sortedKeys(selected) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		sha, err := fileSHA256(selected[relativePath])
+		if err != nil {
+			return nil, oops.
+				Code("LOCAL_ERROR").
+				With("source", s.name).
+				With("path", selected[relativePath]).
+				Wrapf(err, "hashing file")
+		}
+
+		hashes[relativePath] = sha
+	}
+
+	return hashes, nil
+}
+
+// needsPlacement reports whether the output file is missing or was placed
+// with a different mode than the configured one, so unchanged content is
+// still re-placed after the mode changes or the output file was removed.
+func (s *localSource) needsPlacement(sourcePath string, destDir string, relativePath string) bool {
+	destPath := filepath.Join(destDir, filepath.FromSlash(relativePath))
+	destInfo, err := os.Lstat(destPath)
+	if err != nil {
+		return true
+	}
+
+	isLink := destInfo.Mode()&fs.ModeSymlink != 0
+	if s.mode == localModeSymlink {
+		target, readErr := os.Readlink(destPath)
+		return !isLink || readErr != nil || target != sourcePath
+	}
+
+	if isLink {
+		return true
+	}
+
+	sourceInfo, err := os.Stat(sourcePath)
+	if err != nil {
+		return true
+	}
+
+	// A copy must not share the source's inode, a hard link must.
+	return os.SameFile(sourceInfo, destInfo) != (s.mode == localModeHardlink)
+}
+
+func (s *localSource) placeFile(sourcePath string, destDir string, relativePath string) error {
+	if s.mode == localModeCopy {
+		content, err := os.ReadFile(sourcePath)
+		if err != nil {
+			return oops.
+				Code("LOCAL_ERROR").
+				With("source", s.name).
+				With("path", sourcePath).
+				Wrapf(err, "reading file")
+		}
+
+		return writeSourceFile(s.name, destDir, relativePath, content)
+	}
+
+	destPath := filepath.Join(destDir, filepath.FromSlash(relativePath))
+	if err := os.MkdirAll(filepath.Dir(destPath), 0o750); err != nil {
+		return oops.
+			Code("WRITE_FAILED").
+			With("source", s.name).
+			With("path", filepath.Dir(destPath)).
+			Wrapf(err, "creating destination directory")
+	}
+
+	// Links are replaced rather than written through, which would modify the
+	// source file.
+	if err := os.Remove(destPath); err != nil && !os.IsNotExist(err) {
+		return oops.
+			Code("WRITE_FAILED").
+			With("source", s.name).
+			With("path", destPath).
+			Wrapf(err, "removing previous file")
+	}
+
+	if s.mode == localModeSymlink {
+		if err := os.Symlink(sourcePath, destPath); err != nil {
+			return oops.
+				Code("WRITE_FAILED").
+				With("source", s.name).
+				With("path", destPath).
+				Wrapf(err, "creating symlink")
+		}
+
+		return nil
+	}
+
+	if err := os.Link(sourcePath, destPath); err != nil {
+		return oops.
+			Code("WRITE_FAILED").
+			With("source", s.name).
+			With("path", destPath).
+			Hint("Hard links need dir and the output directory on the same filesystem; use mode = \"copy\"").
+			Wrapf(err, "creating hard link")
+	}
+
+	return nil
+}
+
+func isOutputRoot(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, localLockFileName))
+	return err == nil
+}
+
+// fileSHA256 returns the hex SHA-256 of a file's content.
+func fileSHA256(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hasher := sha256.New()
+	if _, copyErr := io.Copy(hasher, file); copyErr != nil {
+		return "", copyErr
+	}
+
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// contentSHA256 returns the hex SHA-256 of content.
+func contentSHA256(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
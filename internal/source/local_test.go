@@ -0,0 +1,236 @@
+package source_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/g5becks/dox/internal/config"
+	"github.com/g5becks/dox/internal/source"
+)
+
+func writeLocalFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("MkdirAll() error = %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("WriteFile() error = %v", err)
+		}
+	}
+}
+
+func newLocalSource(t *testing.T, dir string, mode string) source.Source {
+	t.Helper()
+
+	src, err := source.New("design", config.Source{
+		Type:     "local",
+		Dir:      dir,
+		Mode:     mode,
+		Patterns: config.DefaultPatterns(),
+		Exclude:  []string{"drafts/**"},
+	}, "")
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	return src
+}
+
+func TestLocalSyncCopiesMatchingFilesAndTracksChanges(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeLocalFiles(t, dir, map[string]string{
+		"guide.md":        "# Guide",
+		"api/ref.md":      "# Ref",
+		"drafts/wip.md":   "# WIP",
+		"diagram.png":     "png",
+		"notes/today.txt": "notes",
+	})
+
+	src := newLocalSource(t, dir, "")
+	destDir := t.TempDir()
+
+	first, err := src.Sync(context.Background(), destDir, nil, source.SyncOptions{})
+	if err != nil {
+		t.Fatalf("first Sync() error = %v", err)
+	}
+
+	if first.Downloaded != 3 {
+		t.Fatalf("Downloaded = %d, want 3", first.Downloaded)
+	}
+
+	sum := sha256.Sum256([]byte("# Guide"))
+	if got, want := first.LockEntry.Files["guide.md"], hex.EncodeToString(sum[:]); got != want {
+		t.Fatalf("Files[guide.md] = %q, want %q", got, want)
+	}
+
+	if _, statErr := os.Stat(filepath.Join(destDir, "drafts", "wip.md")); !os.IsNotExist(statErr) {
+		t.Fatalf("expected drafts/wip.md to be excluded")
+	}
+
+	second, err := src.Sync(context.Background(), destDir, first.LockEntry, source.SyncOptions{})
+	if err != nil {
+		t.Fatalf("second Sync() error = %v", err)
+	}
+
+	if !second.Skipped {
+		t.Fatalf("Skipped = false, want true when nothing changed")
+	}
+
+	writeLocalFiles(t, dir, map[string]string{"guide.md": "# Guide v2"})
+	if removeErr := os.Remove(filepath.Join(dir, "api", "ref.md")); removeErr != nil {
+		t.Fatalf("Remove() error = %v", removeErr)
+	}
+
+	third, err := src.Sync(context.Background(), destDir, second.LockEntry, source.SyncOptions{})
+	if err != nil {
+		t.Fatalf("third Sync() error = %v", err)
+	}
+
+	if third.Downloaded != 1 || third.Deleted != 1 {
+		t.Fatalf("third Sync() = %+v, want 1 download and 1 delete", third)
+	}
+
+	content, err := os.ReadFile(filepath.Join(destDir, "guide.md"))
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	if string(content) != "# Guide v2" {
+		t.Fatalf("content = %q, want %q", string(content), "# Guide v2")
+	}
+
+	if _, statErr := os.Stat(filepath.Join(destDir, "api")); !os.IsNotExist(statErr) {
+		t.Fatalf("expected empty api directory to be removed")
+	}
+}
+
+func TestLocalSyncSymlinkMode(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeLocalFiles(t, dir, map[string]string{"guide.md": "# Guide"})
+
+	destDir := t.TempDir()
+	copied, err := newLocalSource(t, dir, "copy").Sync(context.Background(), destDir, nil, source.SyncOptions{})
+	if err != nil {
+		t.Fatalf("copy Sync() error = %v", err)
+	}
+
+	// Switching modes re-places unchanged files.
+	linked, err := newLocalSource(t, dir, "symlink").Sync(
+		context.Background(), destDir, copied.LockEntry, source.SyncOptions{})
+	if err != nil {
+		t.Fatalf("symlink Sync() error = %v", err)
+	}
+
+	if linked.Downloaded != 1 {
+		t.Fatalf("Downloaded = %d, want 1 after switching to symlink mode", linked.Downloaded)
+	}
+
+	target, err := os.Readlink(filepath.Join(destDir, "guide.md"))
+	if err != nil {
+		t.Fatalf("Readlink() error = %v", err)
+	}
+	if target != filepath.Join(dir, "guide.md") {
+		t.Fatalf("symlink target = %q, want %q", target, filepath.Join(dir, "guide.md"))
+	}
+}
+
+func TestLocalSyncHardlinkMode(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeLocalFiles(t, dir, map[string]string{"guide.md": "# Guide"})
+
+	destDir := filepath.Join(dir, ".dox", "design")
+	result, err := newLocalSource(t, dir, "hardlink").Sync(context.Background(), destDir, nil, source.SyncOptions{})
+	if err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+
+	if result.Downloaded != 1 {
+		t.Fatalf("Downloaded = %d, want 1", result.Downloaded)
+	}
+
+	sourceInfo, err := os.Stat(filepath.Join(dir, "guide.md"))
+	if err != nil {
+		t.Fatalf("Stat() error = %v", err)
+	}
+	destInfo, err := os.Stat(filepath.Join(destDir, "guide.md"))
+	if err != nil {
+		t.Fatalf("Stat() error = %v", err)
+	}
+	if !os.SameFile(sourceInfo, destInfo) {
+		t.Fatalf("expected guide.md to be hard-linked")
+	}
+
+	// The output directory lives inside dir and must not be synced into itself.
+	again, err := newLocalSource(t, dir, "hardlink").Sync(
+		context.Background(), destDir, result.LockEntry, source.SyncOptions{})
+	if err != nil {
+		t.Fatalf("second Sync() error = %v", err)
+	}
+	if !again.Skipped {
+		t.Fatalf("Skipped = false, want true; files = %v", again.LockEntry.Files)
+	}
+}
+
+func TestLocalSyncHardlinksSymlinkTargets(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeLocalFiles(t, dir, map[string]string{"shared/guide.md": "# Guide"})
+	if err := os.Symlink(filepath.Join("shared", "guide.md"), filepath.Join(dir, "linked.md")); err != nil {
+		t.Fatalf("Symlink() error = %v", err)
+	}
+
+	destDir := t.TempDir()
+	result, err := newLocalSource(t, dir, "hardlink").Sync(context.Background(), destDir, nil, source.SyncOptions{})
+	if err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+
+	destPath := filepath.Join(destDir, "linked.md")
+	destInfo, err := os.Lstat(destPath)
+	if err != nil {
+		t.Fatalf("Lstat() error = %v", err)
+	}
+	targetInfo, err := os.Stat(filepath.Join(dir, "shared", "guide.md"))
+	if err != nil {
+		t.Fatalf("Stat() error = %v", err)
+	}
+	if !destInfo.Mode().IsRegular() || !os.SameFile(destInfo, targetInfo) {
+		t.Fatalf("linked.md mode = %v, want a hard link to shared/guide.md", destInfo.Mode())
+	}
+
+	content, err := os.ReadFile(destPath)
+	if err != nil || string(content) != "# Guide" {
+		t.Fatalf("linked.md = %q, %v, want the target's content", content, err)
+	}
+
+	again, err := newLocalSource(t, dir, "hardlink").Sync(
+		context.Background(), destDir, result.LockEntry, source.SyncOptions{})
+	if err != nil {
+		t.Fatalf("second Sync() error = %v", err)
+	}
+	if !again.Skipped {
+		t.Fatalf("Skipped = false, want the linked file left in place")
+	}
+}
+
+func TestLocalSyncMissingDirReturnsError(t *testing.T) {
+	t.Parallel()
+
+	src := newLocalSource(t, filepath.Join(t.TempDir(), "missing"), "")
+	if _, err := src.Sync(context.Background(), t.TempDir(), nil, source.SyncOptions{}); err == nil {
+		t.Fatalf("Sync() error = nil, want error for missing dir")
+	}
+}
@@ -0,0 +1,365 @@
+package source
+
+import (
+	"context"
+	"crypto/sha1" //nolint:gosec // npm still publishes SHA-1 shasums for old packages; used for integrity checks only.
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"hash"
+	"net/http"
+	neturl "net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/samber/oops"
+	"resty.dev/v3"
+
+	"github.com/g5becks/dox/internal/cache"
+	"github.com/g5becks/dox/internal/config"
+	"github.com/g5becks/dox/internal/lockfile"
+)
+
+const (
+	sourceTypeNPM      = "npm"
+	npmDefaultRegistry = "https://registry.npmjs.org"
+	npmLatestTag       = "latest"
+	// npmAbbreviatedMetadata asks the registry for the install metadata only,
+	// which is much smaller than the full packument.
+	npmAbbreviatedMetadata = "application/vnd.npm.install-v1+json; q=1.0, application/json; q=0.8"
+	// npmPackageRoot is the directory npm places package contents under.
+	npmPackageRoot = 1
+)
+
+// npmSource syncs the documentation and type declarations shipped inside an
+// npm package tarball. The version is resolved from a dist-tag or semver
+// range against the registry, and the tarball is verified against the
+// registry's integrity hash before anything is extracted.
+type npmSource struct {
+	name     string
+	source   config.Source
+	registry string
+	client   *resty.Client
+}
+
+type npmPackument struct {
+	DistTags map[string]string         `json:"dist-tags"`
+	Versions map[string]npmVersionInfo `json:"versions"`
+}
+
+type npmVersionInfo struct {
+	Dist npmDist `json:"dist"`
+}
+
+type npmDist struct {
+	Tarball   string `json:"tarball"`
+	Integrity string `json:"integrity"`
+	Shasum    string `json:"shasum"`
+}
+
+func NewNPM(name string, cfg config.Source) (Source, error) {
+	return newNPMSource(name, cfg)
+}
+
+func newNPMSource(name string, cfg config.Source) (*npmSource, error) {
+	if strings.TrimSpace(cfg.Package) == "" {
+		return nil, oops.
+			Code("CONFIG_INVALID").
+			With("source", name).
+			Hint("Set package to the npm package name").
+			Errorf("npm source %q has no package", name)
+	}
+
+	registry := strings.TrimSuffix(cfg.Registry, "/")
+	if registry == "" {
+		registry = npmDefaultRegistry
+	}
+
+	if len(cfg.Patterns) == 0 {
+		cfg.Patterns = config.DefaultNPMPatterns()
+	}
+
+	client, err := newHTTPClient(name, cfg.HTTP)
+	if err != nil {
+		return nil, err
+	}
+
+	return &npmSource{
+		name:     name,
+		source:   cfg,
+		registry: registry,
+		client:   client.SetBaseURL(registry),
+	}, nil
+}
+
+func (s *npmSource) Close() error {
+	return s.client.Close()
+}
+
+func (s *npmSource) Sync(
+	ctx context.Context,
+	destDir string,
+	prevLock *lockfile.LockEntry,
+	opts SyncOptions,
+) (*SyncResult, error) {
+	packument, err := s.fetchPackument(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	version, err := resolvePackageVersion(s.name, s.source.Package, s.source.Version, packument)
+	if err != nil {
+		return nil, err
+	}
+
+	dist := packument.Versions[version].Dist
+	if !opts.Force && prevLock != nil && prevLock.RefResolved == version && prevLock.Digest == dist.integrity() {
+		return skippedResult(prevLock, sourceTypeNPM, version), nil
+	}
+
+	fetched, err := fetchArchiveCached(opts, cache.Key(sourceTypeNPM, dist.integrity()), func() (*fetchedArchive, error) {
+		return s.downloadTarball(ctx, version, dist)
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer fetched.cleanup()
+
+	oldFiles := map[string]string{}
+	if prevLock != nil && prevLock.Files != nil {
+		oldFiles = prevLock.Files
+	}
+
+	extractCfg := s.source
+	extractCfg.StripComponents = npmPackageRoot
+	extraction := newArchiveExtraction(s.name, extractCfg, destDir, oldFiles, opts)
+	deleted, err := extraction.run(ctx, fetched.path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SyncResult{
+		Downloaded: extraction.written,
+		Deleted:    deleted,
+		LockEntry: &lockfile.LockEntry{
+			Type:        sourceTypeNPM,
+			RefResolved: version,
+			Digest:      dist.integrity(),
+			SyncedAt:    time.Now().UTC(),
+			Files:       extraction.files,
+		},
+	}, nil
+}
+
+func (s *npmSource) fetchPackument(ctx context.Context) (*npmPackument, error) {
+	packument := &npmPackument{}
+	response, err := s.client.R().
+		SetContext(ctx).
+		SetHeader("Accept", npmAbbreviatedMetadata).
+		SetResult(packument).
+		Get("/" + npmPackagePath(s.source.Package))
+	if err != nil {
+		return nil, oops.
+			Code("NPM_ERROR").
+			With("source", s.name).
+			With("package", s.source.Package).
+			With("registry", config.RedactURL(s.registry)).
+			Wrapf(redactURLError(err), "fetching package metadata")
+	}
+
+	if !response.IsSuccess() {
+		builder := oops.
+			Code("NPM_ERROR").
+			With("source", s.name).
+			With("package", s.source.Package).
+			With("registry", config.RedactURL(s.registry)).
+			With("status", response.StatusCode())
+		if response.StatusCode() == http.StatusNotFound {
+			builder = builder.Hint("Check the package name and registry in your config")
+		}
+
+		return nil, builder.Errorf("npm registry returned status %d for package metadata", response.StatusCode())
+	}
+
+	return packument, nil
+}
+
+// downloadTarball fetches the package tarball and checks it against the
+// registry's integrity hash, falling back to the legacy SHA-1 shasum.
+func (s *npmSource) downloadTarball(ctx context.Context, version string, dist npmDist) (*fetchedArchive, error) {
+	if dist.Tarball == "" {
+		return nil, oops.
+			Code("NPM_ERROR").
+			With("source", s.name).
+			With("package", s.source.Package).
+			With("version", version).
+			Errorf("npm registry lists no tarball for %s@%s", s.source.Package, version)
+	}
+
+	algorithm, expected, err := dist.expectedDigest()
+	if err != nil {
+		return nil, oops.
+			Code("NPM_ERROR").
+			With("source", s.name).
+			With("package", s.source.Package).
+			With("version", version).
+			Wrap(err)
+	}
+
+	response, err := s.client.R().
+		SetContext(ctx).
+		SetDoNotParseResponse(true).
+		Get(dist.Tarball)
+	if err != nil {
+		return nil, oops.
+			Code("DOWNLOAD_FAILED").
+			With("source", s.name).
+			With("url", config.RedactURL(dist.Tarball)).
+			Wrapf(redactURLError(err), "downloading package tarball")
+	}
+	defer response.Body.Close()
+
+	if !response.IsSuccess() {
+		return nil, oops.
+			Code("DOWNLOAD_FAILED").
+			With("source", s.name).
+			With("url", config.RedactURL(dist.Tarball)).
+			With("status", response.StatusCode()).
+			Errorf("npm registry returned status %d for package tarball", response.StatusCode())
+	}
+
+	hasher := algorithm()
+	fetched, err := spoolArchive(s.name, dist.Tarball, response.Body, archiveLimitsFor(s.source).maxSize, hasher)
+	if err != nil {
+		return nil, err
+	}
+
+	if actual := hasher.Sum(nil); !slices.Equal(actual, expected) {
+		fetched.cleanup()
+		return nil, oops.
+			Code("INTEGRITY_MISMATCH").
+			With("source", s.name).
+			With("package", s.source.Package).
+			With("version", version).
+			With("expected", dist.integrity()).
+			Hint("The tarball does not match the registry metadata; check the registry or retry later").
+			Errorf("integrity check failed for %s@%s", s.source.Package, version)
+	}
+
+	return fetched, nil
+}
+
+// integrity returns the tarball's Subresource Integrity string, or the
+// legacy shasum for packages published before npm recorded one.
+func (d npmDist) integrity() string {
+	if d.Integrity != "" {
+		return d.Integrity
+	}
+
+	return d.Shasum
+}
+
+// expectedDigest picks the strongest hash the registry published.
+func (d npmDist) expectedDigest() (func() hash.Hash, []byte, error) {
+	algorithms := map[string]func() hash.Hash{
+		"sha512": sha512.New,
+		"sha384": sha512.New384,
+		"sha256": sha256.New,
+	}
+
+	// An SRI string may list several hashes; the strongest supported one wins.
+	for _, name := range []string{"sha512", "sha384", "sha256"} {
+		for _, entry := range strings.Fields(d.Integrity) {
+			encoded, found := strings.CutPrefix(entry, name+"-")
+			if !found {
+				continue
+			}
+
+			expected, err := base64.StdEncoding.DecodeString(encoded)
+			if err != nil {
+				return nil, nil, oops.Wrapf(err, "decoding integrity %q", entry)
+			}
+
+			return algorithms[name], expected, nil
+		}
+	}
+
+	if d.Shasum != "" {
+		expected, err := hex.DecodeString(d.Shasum)
+		if err != nil {
+			return nil, nil, oops.Wrapf(err, "decoding shasum %q", d.Shasum)
+		}
+
+		return sha1.New, expected, nil
+	}
+
+	return nil, nil, oops.
+		Hint("The registry published no integrity hash for this version").
+		Errorf("no supported integrity hash")
+}
+
+// resolvePackageVersion picks the version to sync: a dist-tag when the
+// configured version names one (an empty version means "latest"), otherwise
+// the highest published version satisfying the semver range.
+func resolvePackageVersion(sourceName string, pkg string, requested string, packument *npmPackument) (string, error) {
+	requested = strings.TrimSpace(requested)
+	if requested == "" {
+		requested = npmLatestTag
+	}
+
+	if tagged, ok := packument.DistTags[requested]; ok {
+		if _, published := packument.Versions[tagged]; published {
+			return tagged, nil
+		}
+	}
+
+	constraint, err := semver.NewConstraint(requested)
+	if err != nil {
+		return "", oops.
+			Code("CONFIG_INVALID").
+			With("source", sourceName).
+			With("version", requested).
+			Hint("Use a semver range like \"^1.2.0\", an exact version, or a dist-tag like \"latest\"").
+			Wrapf(err, "invalid version %q", requested)
+	}
+
+	var best *semver.Version
+	bestName := ""
+	for name := range packument.Versions {
+		candidate, parseErr := semver.NewVersion(name)
+		if parseErr != nil || !constraint.Check(candidate) {
+			continue
+		}
+
+		if best == nil || candidate.GreaterThan(best) {
+			best = candidate
+			bestName = name
+		}
+	}
+
+	if best == nil {
+		return "", oops.
+			Code("VERSION_NOT_FOUND").
+			With("source", sourceName).
+			With("package", pkg).
+			With("version", requested).
+			Hint("Check the published versions of the package").
+			Errorf("no published version of %s satisfies %q", pkg, requested)
+	}
+
+	return bestName, nil
+}
+
+// npmPackagePath escapes a package name for the registry URL; the slash of a
+// scoped package name is encoded so "@scope/name" stays one path segment.
+func npmPackagePath(pkg string) string {
+	pkg = strings.TrimSpace(pkg)
+	if scope, name, scoped := strings.Cut(pkg, "/"); scoped && strings.HasPrefix(scope, "@") {
+		return neturl.PathEscape(scope) + "%2F" + neturl.PathEscape(name)
+	}
+
+	return neturl.PathEscape(pkg)
+}
@@ -0,0 +1,208 @@
+package source_test
+
+import (
+	"context"
+	"crypto/sha512"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/g5becks/dox/internal/config"
+	"github.com/g5becks/dox/internal/source"
+)
+
+// npmRegistry serves one package's metadata and tarballs from memory.
+type npmRegistry struct {
+	metadata string
+	tarballs map[string][]byte
+	requests []string
+}
+
+func newNPMRegistry(t *testing.T, pkg string, latest string, tarballs map[string][]byte, integrity map[string]string) *npmRegistry {
+	t.Helper()
+
+	versions := make([]string, 0, len(tarballs))
+	paths := make(map[string][]byte, len(tarballs))
+	for version, tarball := range tarballs {
+		sri := integrity[version]
+		if sri == "" {
+			sum := sha512.Sum512(tarball)
+			sri = "sha512-" + base64.StdEncoding.EncodeToString(sum[:])
+		}
+
+		tarballPath := fmt.Sprintf("/%s/-/widget-%s.tgz", pkg, version)
+		paths[tarballPath] = tarball
+		versions = append(versions, fmt.Sprintf(
+			`%q:{"dist":{"tarball":"https://registry.test%s","integrity":%q}}`, version, tarballPath, sri))
+	}
+
+	return &npmRegistry{
+		metadata: fmt.Sprintf(`{"dist-tags":{"latest":%q},"versions":{%s}}`, latest, strings.Join(versions, ",")),
+		tarballs: paths,
+	}
+}
+
+func (r *npmRegistry) handle(req *http.Request) *http.Response {
+	r.requests = append(r.requests, req.URL.EscapedPath())
+
+	if tarball, ok := r.tarballs[req.URL.Path]; ok {
+		return source.NewHTTPResponse(req, http.StatusOK, string(tarball), nil)
+	}
+
+	header := http.Header{}
+	header.Set("Content-Type", "application/vnd.npm.install-v1+json")
+	return source.NewHTTPResponse(req, http.StatusOK, r.metadata, header)
+}
+
+func npmTarball(t *testing.T, version string) []byte {
+	t.Helper()
+
+	return buildTarGz(t,
+		archiveMember{name: "package/README.md", content: "# Widget " + version},
+		archiveMember{name: "package/docs/usage.md", content: "# Usage"},
+		archiveMember{name: "package/dist/index.d.ts", content: "export declare const widget: string;"},
+		archiveMember{name: "package/dist/index.js", content: "export const widget = 'w';"},
+		archiveMember{name: "package/package.json", content: `{"name":"widget"}`},
+	)
+}
+
+func TestNPMSyncResolvesRangeAndExtractsDocs(t *testing.T) {
+	t.Parallel()
+
+	registry := newNPMRegistry(t, "widget", "2.0.0", map[string][]byte{
+		"1.2.0": npmTarball(t, "1.2.0"),
+		"1.4.1": npmTarball(t, "1.4.1"),
+		"2.0.0": npmTarball(t, "2.0.0"),
+	}, nil)
+
+	src, setClient := source.TestableNPMSource(t, "widget", config.Source{
+		Type:     "npm",
+		Package:  "widget",
+		Version:  "^1.2.0",
+		Registry: "https://registry.test",
+	})
+	setClient(source.NewMockRestyClient(registry.handle))
+
+	destDir := t.TempDir()
+	first, err := src.Sync(context.Background(), destDir, nil, source.SyncOptions{})
+	if err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+
+	if first.LockEntry.RefResolved != "1.4.1" {
+		t.Fatalf("RefResolved = %q, want %q", first.LockEntry.RefResolved, "1.4.1")
+	}
+
+	if first.Downloaded != 3 {
+		t.Fatalf("Downloaded = %d, want README, usage, and typings", first.Downloaded)
+	}
+
+	content, err := os.ReadFile(filepath.Join(destDir, "README.md"))
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	if string(content) != "# Widget 1.4.1" {
+		t.Fatalf("README = %q, want version 1.4.1", string(content))
+	}
+
+	if _, statErr := os.Stat(filepath.Join(destDir, "dist", "index.d.ts")); statErr != nil {
+		t.Fatalf("expected typings to be extracted: %v", statErr)
+	}
+	if _, statErr := os.Stat(filepath.Join(destDir, "dist", "index.js")); !os.IsNotExist(statErr) {
+		t.Fatalf("expected index.js to be filtered out")
+	}
+
+	second, err := src.Sync(context.Background(), destDir, first.LockEntry, source.SyncOptions{})
+	if err != nil {
+		t.Fatalf("second Sync() error = %v", err)
+	}
+
+	if !second.Skipped {
+		t.Fatalf("Skipped = false, want true for an unchanged resolved version")
+	}
+
+	if got := len(registry.requests); got != 3 {
+		t.Fatalf("requests = %v, want metadata, tarball, metadata", registry.requests)
+	}
+}
+
+func TestNPMSyncScopedPackageUsesLatestTag(t *testing.T) {
+	t.Parallel()
+
+	registry := newNPMRegistry(t, "@acme/widget", "1.0.0", map[string][]byte{
+		"1.0.0":        npmTarball(t, "1.0.0"),
+		"2.0.0-beta.1": npmTarball(t, "2.0.0-beta.1"),
+	}, nil)
+
+	src, setClient := source.TestableNPMSource(t, "widget", config.Source{
+		Type:     "npm",
+		Package:  "@acme/widget",
+		Registry: "https://registry.test",
+	})
+	setClient(source.NewMockRestyClient(registry.handle))
+
+	result, err := src.Sync(context.Background(), t.TempDir(), nil, source.SyncOptions{})
+	if err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+
+	if result.LockEntry.RefResolved != "1.0.0" {
+		t.Fatalf("RefResolved = %q, want the latest dist-tag", result.LockEntry.RefResolved)
+	}
+
+	if registry.requests[0] != "/@acme%2Fwidget" {
+		t.Fatalf("metadata request = %q, want scoped name as one path segment", registry.requests[0])
+	}
+}
+
+func TestNPMSyncRejectsIntegrityMismatch(t *testing.T) {
+	t.Parallel()
+
+	registry := newNPMRegistry(t, "widget", "1.0.0", map[string][]byte{
+		"1.0.0": npmTarball(t, "1.0.0"),
+	}, map[string]string{
+		"1.0.0": "sha512-" + base64.StdEncoding.EncodeToString(make([]byte, sha512.Size)),
+	})
+
+	src, setClient := source.TestableNPMSource(t, "widget", config.Source{
+		Type:     "npm",
+		Package:  "widget",
+		Registry: "https://registry.test",
+	})
+	setClient(source.NewMockRestyClient(registry.handle))
+
+	destDir := t.TempDir()
+	_, err := src.Sync(context.Background(), destDir, nil, source.SyncOptions{})
+	if err == nil || !strings.Contains(err.Error(), "integrity check failed") {
+		t.Fatalf("Sync() error = %v, want integrity error", err)
+	}
+
+	if entries, _ := os.ReadDir(destDir); len(entries) != 0 {
+		t.Fatalf("expected nothing to be extracted, found %d entries", len(entries))
+	}
+}
+
+func TestNPMSyncNoMatchingVersion(t *testing.T) {
+	t.Parallel()
+
+	registry := newNPMRegistry(t, "widget", "1.0.0", map[string][]byte{
+		"1.0.0": npmTarball(t, "1.0.0"),
+	}, nil)
+
+	src, setClient := source.TestableNPMSource(t, "widget", config.Source{
+		Type:     "npm",
+		Package:  "widget",
+		Version:  ">=3",
+		Registry: "https://registry.test",
+	})
+	setClient(source.NewMockRestyClient(registry.handle))
+
+	_, err := src.Sync(context.Background(), t.TempDir(), nil, source.SyncOptions{})
+	if err == nil || !strings.Contains(err.Error(), "no published version") {
+		t.Fatalf("Sync() error = %v, want version error", err)
+	}
+}
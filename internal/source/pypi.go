@@ -0,0 +1,585 @@
+package source
+
+import (
+	"context"
+	"net/http"
+	neturl "net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/samber/oops"
+	"resty.dev/v3"
+
+	"github.com/g5becks/dox/internal/cache"
+	"github.com/g5becks/dox/internal/config"
+	"github.com/g5becks/dox/internal/lockfile"
+)
+
+const (
+	sourceTypePyPI       = "pypi"
+	pypiDefaultIndex     = "https://pypi.org/pypi"
+	pypiLatestVersion    = "latest"
+	pypiSimpleSuffix     = "/simple"
+	pypiSimpleJSON       = "application/vnd.pypi.simple.v1+json"
+	pypiDigestPrefix     = "sha256:"
+	pypiDistAuto         = "auto"
+	pypiDistSdist        = "sdist"
+	pypiDistWheel        = "wheel"
+	pypiPackageTypeSdist = "sdist"
+	pypiPackageTypeWheel = "bdist_wheel"
+	// pypiSdistRoot is the "<name>-<version>/" directory sdists unpack into.
+	pypiSdistRoot = 1
+)
+
+//nolint:gochecknoglobals // Compiled once; PEP 503 name normalization.
+var pypiNameSeparators = regexp.MustCompile(`[-_.]+`)
+
+// pypiSource syncs the documentation shipped in a Python package's sdist or
+// wheel. The version is resolved from a PEP 440 specifier against a
+// PyPI-compatible index, and the distribution is verified against the
+// index's SHA-256 before anything is extracted.
+type pypiSource struct {
+	name   string
+	source config.Source
+	index  string
+	simple bool
+	client *resty.Client
+}
+
+// pypiFile is one distribution file of a release.
+type pypiFile struct {
+	Filename    string            `json:"filename"`
+	URL         string            `json:"url"`
+	PackageType string            `json:"packagetype"`
+	Digests     map[string]string `json:"digests"`
+	Hashes      map[string]string `json:"hashes"`
+	Yanked      any               `json:"yanked"`
+}
+
+// pypiProject is the response of the JSON API (/pypi/<name>/json).
+type pypiProject struct {
+	Info struct {
+		Version string `json:"version"`
+	} `json:"info"`
+	Releases map[string][]pypiFile `json:"releases"`
+}
+
+// pypiSimpleProject is the response of the JSON simple API (PEP 691).
+type pypiSimpleProject struct {
+	Files []pypiFile `json:"files"`
+}
+
+// pypiReleases lists the files of every release and the version the index
+// reports as latest, if any.
+type pypiReleases struct {
+	latest   string
+	releases map[string][]pypiFile
+}
+
+func NewPyPI(name string, cfg config.Source) (Source, error) {
+	return newPyPISource(name, cfg)
+}
+
+func newPyPISource(name string, cfg config.Source) (*pypiSource, error) {
+	if strings.TrimSpace(cfg.Package) == "" {
+		return nil, oops.
+			Code("CONFIG_INVALID").
+			With("source", name).
+			Hint("Set package to the PyPI project name").
+			Errorf("pypi source %q has no package", name)
+	}
+
+	index := strings.TrimSuffix(cfg.Registry, "/")
+	if index == "" {
+		index = pypiDefaultIndex
+	}
+
+	if len(cfg.Patterns) == 0 {
+		cfg.Patterns = config.DefaultPyPIPatterns()
+	}
+
+	client, err := newHTTPClient(name, cfg.HTTP)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pypiSource{
+		name:   name,
+		source: cfg,
+		index:  index,
+		simple: strings.HasSuffix(index, pypiSimpleSuffix),
+		client: client.SetBaseURL(index),
+	}, nil
+}
+
+func (s *pypiSource) Close() error {
+	return s.client.Close()
+}
+
+func (s *pypiSource) Sync(
+	ctx context.Context,
+	destDir string,
+	prevLock *lockfile.LockEntry,
+	opts SyncOptions,
+) (*SyncResult, error) {
+	releases, err := s.fetchReleases(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	version, err := resolvePyPIVersion(s.name, s.source.Package, s.source.Version, releases)
+	if err != nil {
+		return nil, err
+	}
+
+	var pinned string
+	if prevLock != nil && prevLock.RefResolved == version {
+		pinned = prevLock.Digest
+	}
+
+	file, err := s.pickFile(version, releases.releases[version], pinned)
+	if err != nil {
+		return nil, err
+	}
+
+	digest := pypiDigestPrefix + file.sha256()
+	if !opts.Force && pinned != "" && pinned == digest {
+		return skippedResult(prevLock, sourceTypePyPI, version), nil
+	}
+
+	fetched, err := fetchArchiveCached(opts, cache.SHA256Key(file.sha256()), func() (*fetchedArchive, error) {
+		return s.download(ctx, file)
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer fetched.cleanup()
+
+	oldFiles := map[string]string{}
+	if prevLock != nil && prevLock.Files != nil {
+		oldFiles = prevLock.Files
+	}
+
+	extractCfg := s.source
+	if file.isSdist() {
+		extractCfg.StripComponents = pypiSdistRoot
+	}
+	extraction := newArchiveExtraction(s.name, extractCfg, destDir, oldFiles, opts)
+
+	pyFiles := map[string][]byte{}
+	keep := func(string) bool { return false }
+	if s.source.Docstrings {
+		keep = isPythonSourceFile
+	}
+
+	if extractErr := extraction.extract(ctx, fetched.path, extraction.collecting(keep, pyFiles)); extractErr != nil {
+		return nil, extractErr
+	}
+
+	if docErr := renderPythonDocstrings(extraction, pyFiles); docErr != nil {
+		return nil, docErr
+	}
+
+	deleted, err := extraction.finish()
+	if err != nil {
+		return nil, err
+	}
+
+	return &SyncResult{
+		Downloaded: extraction.written,
+		Deleted:    deleted,
+		LockEntry: &lockfile.LockEntry{
+			Type:        sourceTypePyPI,
+			RefResolved: version,
+			Digest:      digest,
+			SyncedAt:    time.Now().UTC(),
+			Files:       extraction.files,
+		},
+	}, nil
+}
+
+// fetchReleases reads the project's releases from the JSON API, or from the
+// JSON simple API when the index URL ends in /simple.
+func (s *pypiSource) fetchReleases(ctx context.Context) (*pypiReleases, error) {
+	normalized := normalizePyPIName(s.source.Package)
+
+	request := s.client.R().SetContext(ctx)
+	var (
+		endpoint string
+		project  pypiProject
+		simple   pypiSimpleProject
+	)
+	if s.simple {
+		endpoint = "/" + neturl.PathEscape(normalized) + "/"
+		request.SetHeader("Accept", pypiSimpleJSON).SetResult(&simple)
+	} else {
+		endpoint = "/" + neturl.PathEscape(normalized) + "/json"
+		request.SetResult(&project)
+	}
+
+	response, err := request.Get(endpoint)
+	if err != nil {
+		return nil, oops.
+			Code("PYPI_ERROR").
+			With("source", s.name).
+			With("package", s.source.Package).
+			With("index", config.RedactURL(s.index)).
+			Wrapf(redactURLError(err), "fetching package metadata")
+	}
+
+	if !response.IsSuccess() {
+		builder := oops.
+			Code("PYPI_ERROR").
+			With("source", s.name).
+			With("package", s.source.Package).
+			With("index", config.RedactURL(s.index)).
+			With("status", response.StatusCode())
+		if response.StatusCode() == http.StatusNotFound {
+			builder = builder.Hint("Check the package name and registry in your config")
+		}
+
+		return nil, builder.Errorf("package index returned status %d for package metadata", response.StatusCode())
+	}
+
+	if !s.simple {
+		return &pypiReleases{latest: project.Info.Version, releases: project.Releases}, nil
+	}
+
+	releases := &pypiReleases{releases: map[string][]pypiFile{}}
+	base := s.index + endpoint
+	for _, file := range simple.Files {
+		version, ok := pypiFileVersion(file.Filename)
+		if !ok {
+			continue
+		}
+
+		file.URL = resolveReference(base, file.URL)
+		releases.releases[version] = append(releases.releases[version], file)
+	}
+
+	return releases, nil
+}
+
+// pickFile chooses the distribution file to sync. A file pinned by the lock
+// wins while it is of the configured dist type, so repeated syncs of the
+// same version stay reproducible; otherwise the dist setting decides, with
+// auto preferring the sdist since wheels rarely ship documentation.
+func (s *pypiSource) pickFile(version string, files []pypiFile, pinned string) (*pypiFile, error) {
+	dist := s.source.Dist
+	if dist == "" {
+		dist = pypiDistAuto
+	}
+
+	for i := range files {
+		if pinned != "" && pypiDigestPrefix+files[i].sha256() == pinned && files[i].matchesDist(dist) {
+			return &files[i], nil
+		}
+	}
+
+	// Yanked files are only used from a release pinned exactly whose files
+	// were all yanked.
+	skipYanked := hasUsableFile(files)
+
+	var sdist, wheel *pypiFile
+	for i := range files {
+		file := &files[i]
+		switch {
+		case skipYanked && file.isYanked():
+			continue
+		case file.isSdist() && sdist == nil:
+			sdist = file
+		case file.isWheel() && (wheel == nil || strings.HasSuffix(file.Filename, "-none-any.whl")):
+			wheel = file
+		}
+	}
+
+	var picked *pypiFile
+	switch dist {
+	case pypiDistSdist:
+		picked = sdist
+	case pypiDistWheel:
+		picked = wheel
+	default:
+		picked = sdist
+		if picked == nil {
+			picked = wheel
+		}
+	}
+
+	if picked == nil {
+		return nil, oops.
+			Code("VERSION_NOT_FOUND").
+			With("source", s.name).
+			With("package", s.source.Package).
+			With("version", version).
+			With("dist", dist).
+			Hint("Set dist to the distribution type the release publishes").
+			Errorf("%s %s has no %s distribution", s.source.Package, version, dist)
+	}
+
+	if picked.sha256() == "" {
+		return nil, oops.
+			Code("PYPI_ERROR").
+			With("source", s.name).
+			With("file", picked.Filename).
+			Hint("The index published no SHA-256 for this file").
+			Errorf("no sha256 hash for %s", picked.Filename)
+	}
+
+	return picked, nil
+}
+
+// download fetches a distribution file and checks it against the index's
+// SHA-256.
+func (s *pypiSource) download(ctx context.Context, file *pypiFile) (*fetchedArchive, error) {
+	response, err := s.client.R().
+		SetContext(ctx).
+		SetDoNotParseResponse(true).
+		Get(file.URL)
+	if err != nil {
+		return nil, oops.
+			Code("DOWNLOAD_FAILED").
+			With("source", s.name).
+			With("url", config.RedactURL(file.URL)).
+			Wrapf(redactURLError(err), "downloading distribution")
+	}
+	defer response.Body.Close()
+
+	if !response.IsSuccess() {
+		return nil, oops.
+			Code("DOWNLOAD_FAILED").
+			With("source", s.name).
+			With("url", config.RedactURL(file.URL)).
+			With("status", response.StatusCode()).
+			Errorf("package index returned status %d for %s", response.StatusCode(), file.Filename)
+	}
+
+	fetched, err := spoolArchive(s.name, file.URL, response.Body, archiveLimitsFor(s.source).maxSize)
+	if err != nil {
+		return nil, err
+	}
+
+	if fetched.digest != strings.ToLower(file.sha256()) {
+		fetched.cleanup()
+		return nil, oops.
+			Code("INTEGRITY_MISMATCH").
+			With("source", s.name).
+			With("file", file.Filename).
+			With("expected", file.sha256()).
+			With("actual", fetched.digest).
+			Hint("The download does not match the index metadata; check the registry or retry later").
+			Errorf("sha256 check failed for %s", file.Filename)
+	}
+
+	return fetched, nil
+}
+
+func (f *pypiFile) sha256() string {
+	if digest := f.Digests["sha256"]; digest != "" {
+		return digest
+	}
+
+	return f.Hashes["sha256"]
+}
+
+func (f *pypiFile) isSdist() bool {
+	if f.PackageType != "" {
+		return f.PackageType == pypiPackageTypeSdist
+	}
+
+	return strings.HasSuffix(f.Filename, ".tar.gz") || strings.HasSuffix(f.Filename, ".zip")
+}
+
+func (f *pypiFile) isWheel() bool {
+	if f.PackageType != "" {
+		return f.PackageType == pypiPackageTypeWheel
+	}
+
+	return strings.HasSuffix(f.Filename, ".whl")
+}
+
+// matchesDist reports whether the file is of the given dist type.
+func (f *pypiFile) matchesDist(dist string) bool {
+	switch dist {
+	case pypiDistSdist:
+		return f.isSdist()
+	case pypiDistWheel:
+		return f.isWheel()
+	default:
+		return true
+	}
+}
+
+// isYanked reports whether the file was withdrawn (PEP 592). Indexes send
+// either a boolean or the reason as a string.
+func (f *pypiFile) isYanked() bool {
+	switch yanked := f.Yanked.(type) {
+	case bool:
+		return yanked
+	case string:
+		return true
+	default:
+		return false
+	}
+}
+
+// resolvePyPIVersion picks the release to sync. An empty version or
+// "latest" takes the index's latest release, an exact version is used as
+// is, and a PEP 440 specifier picks the highest release satisfying it.
+// Yanked releases are only used when pinned exactly.
+func resolvePyPIVersion(sourceName string, pkg string, requested string, releases *pypiReleases) (string, error) {
+	requested = strings.TrimSpace(requested)
+	exact := strings.TrimPrefix(strings.TrimPrefix(requested, "==="), "==")
+	if exact != "" && !strings.ContainsAny(exact, "<>=!~,*") {
+		if _, ok := releases.releases[exact]; ok {
+			return exact, nil
+		}
+	}
+
+	if requested == "" || requested == pypiLatestVersion {
+		if files := releases.releases[releases.latest]; releases.latest != "" && hasUsableFile(files) {
+			return releases.latest, nil
+		}
+		requested = "*"
+	}
+
+	constraint, err := pep440Constraint(requested)
+	if err != nil {
+		return "", oops.
+			Code("CONFIG_INVALID").
+			With("source", sourceName).
+			With("version", requested).
+			Hint("Use a version specifier like \">=2.0,<3\", \"~=2.31\", an exact version, or \"latest\"").
+			Wrapf(err, "invalid version %q", requested)
+	}
+
+	var best *semver.Version
+	bestName := ""
+	for name, files := range releases.releases {
+		candidate, parseErr := semver.NewVersion(name)
+		if parseErr != nil || !constraint.Check(candidate) || !hasUsableFile(files) {
+			continue
+		}
+
+		if best == nil || candidate.GreaterThan(best) {
+			best = candidate
+			bestName = name
+		}
+	}
+
+	if best == nil {
+		return "", oops.
+			Code("VERSION_NOT_FOUND").
+			With("source", sourceName).
+			With("package", pkg).
+			With("version", requested).
+			Hint("Check the published versions of the package").
+			Errorf("no published version of %s satisfies %q", pkg, requested)
+	}
+
+	return bestName, nil
+}
+
+func hasUsableFile(files []pypiFile) bool {
+	return slices.ContainsFunc(files, func(file pypiFile) bool { return !file.isYanked() })
+}
+
+// pep440Constraint translates a PEP 440 version specifier into a semver
+// constraint. Clauses are joined with AND; "~=X.Y" becomes ">=X.Y, <X+1"
+// and "==X.*" becomes a prefix match.
+func pep440Constraint(specifier string) (*semver.Constraints, error) {
+	clauses := []string{}
+	for _, clause := range strings.Split(specifier, ",") {
+		clause = strings.TrimSpace(clause)
+		switch {
+		case clause == "":
+			continue
+		case strings.HasPrefix(clause, "~="):
+			translated, err := compatibleRelease(strings.TrimSpace(clause[2:]))
+			if err != nil {
+				return nil, err
+			}
+			clause = translated
+		case strings.HasPrefix(clause, "==="):
+			clause = "=" + strings.TrimSpace(clause[3:])
+		case strings.HasPrefix(clause, "=="):
+			version := strings.TrimSpace(clause[2:])
+			if prefix, wildcard := strings.CutSuffix(version, ".*"); wildcard {
+				clause = "~" + prefix
+			} else {
+				clause = "=" + version
+			}
+		}
+
+		clauses = append(clauses, clause)
+	}
+
+	return semver.NewConstraint(strings.Join(clauses, ", "))
+}
+
+// compatibleRelease expands "~=" to the range it stands for: "~=2.2" is
+// ">=2.2, <3" and "~=1.4.5" is ">=1.4.5, <1.5".
+func compatibleRelease(version string) (string, error) {
+	parts := strings.Split(version, ".")
+	if len(parts) < 2 { //nolint:mnd // ~= needs at least two release segments.
+		return "", oops.Errorf("compatible release %q needs at least two version segments", version)
+	}
+
+	upper := slices.Clone(parts[:len(parts)-1])
+	last, err := strconv.Atoi(upper[len(upper)-1])
+	if err != nil {
+		return "", oops.Wrapf(err, "invalid compatible release %q", version)
+	}
+	upper[len(upper)-1] = strconv.Itoa(last + 1)
+
+	return ">=" + version + ", <" + strings.Join(upper, "."), nil
+}
+
+// normalizePyPIName normalizes a project name for index URLs (PEP 503).
+func normalizePyPIName(name string) string {
+	return pypiNameSeparators.ReplaceAllString(strings.ToLower(strings.TrimSpace(name)), "-")
+}
+
+// pypiFileVersion extracts the version from a wheel or sdist filename, as
+// the simple API lists files without versions.
+func pypiFileVersion(filename string) (string, bool) {
+	if stem, ok := strings.CutSuffix(filename, ".whl"); ok {
+		parts := strings.Split(stem, "-")
+		if len(parts) < 5 { //nolint:mnd // name-version-python-abi-platform.
+			return "", false
+		}
+		return parts[1], true
+	}
+
+	for _, extension := range []string{".tar.gz", ".zip"} {
+		if stem, ok := strings.CutSuffix(filename, extension); ok {
+			separator := strings.LastIndex(stem, "-")
+			if separator <= 0 {
+				return "", false
+			}
+			return stem[separator+1:], true
+		}
+	}
+
+	return "", false
+}
+
+// resolveReference resolves a possibly relative file URL from the simple
+// API against the page it was listed on.
+func resolveReference(base string, reference string) string {
+	baseURL, err := neturl.Parse(base)
+	if err != nil {
+		return reference
+	}
+
+	resolved, err := baseURL.Parse(reference)
+	if err != nil {
+		return reference
+	}
+
+	return resolved.String()
+}
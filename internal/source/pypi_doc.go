@@ -0,0 +1,431 @@
+package source
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+const (
+	// pyDocDir holds the markdown rendered from a package's docstrings, one
+	// page per module.
+	pyDocDir  = "api"
+	pyTabSize = 8
+)
+
+// pySkippedDirs are directories whose modules are not part of a package's API.
+//
+//nolint:gochecknoglobals // Read-only lookup table.
+var pySkippedDirs = map[string]bool{
+	"tests": true, "test": true, "testing": true, "docs": true, "doc": true,
+	"examples": true, "benchmarks": true, "scripts": true,
+}
+
+// pySkippedFiles are build and test helpers found at a project root.
+//
+//nolint:gochecknoglobals // Read-only lookup table.
+var pySkippedFiles = map[string]bool{
+	"setup.py": true, "conftest.py": true, "noxfile.py": true, "fabfile.py": true,
+}
+
+func isPythonSourceFile(name string) bool {
+	return strings.HasSuffix(name, ".py")
+}
+
+// renderPythonDocstrings renders a markdown page per public module from the
+// docstrings of the collected Python files, keyed by their path inside the
+// distribution, and adds the pages to the extraction as api/<module>.md.
+// Pages matching the source's excludes are skipped.
+func renderPythonDocstrings(x *archiveExtraction, pyFiles map[string][]byte) error {
+	names := make([]string, 0, len(pyFiles))
+	for name := range pyFiles {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		moduleName, ok := pythonModuleName(name)
+		if !ok {
+			continue
+		}
+
+		outputPath := pyDocDir + "/" + moduleName + ".md"
+		excluded, err := matchesAny(x.source.Exclude, outputPath)
+		if err != nil {
+			return err
+		}
+		if excluded {
+			continue
+		}
+
+		page := renderPythonModule(moduleName, string(pyFiles[name]))
+		if page == nil {
+			continue
+		}
+
+		if addErr := x.add(outputPath, page); addErr != nil {
+			return addErr
+		}
+	}
+
+	return nil
+}
+
+// pythonModuleName maps a file path to its dotted module name. Tests,
+// private modules, and project tooling yield no module.
+func pythonModuleName(name string) (string, bool) {
+	name = strings.TrimPrefix(name, "src/")
+	if pySkippedFiles[name] {
+		return "", false
+	}
+
+	elements := strings.Split(strings.TrimSuffix(name, ".py"), "/")
+	last := elements[len(elements)-1]
+	if last == "__init__" {
+		elements = elements[:len(elements)-1]
+	} else if strings.HasPrefix(last, "test_") || strings.HasSuffix(last, "_test") {
+		return "", false
+	}
+
+	if len(elements) == 0 {
+		return "", false
+	}
+
+	for _, element := range elements {
+		if pySkippedDirs[element] || strings.HasPrefix(element, "_") || strings.HasPrefix(element, ".") ||
+			strings.Contains(element, "-") || strings.Contains(element, ".") {
+			return "", false
+		}
+	}
+
+	return strings.Join(elements, "."), true
+}
+
+// pyLine is a logical line of Python source: a statement with its
+// bracketed, backslash, and triple-quoted continuations joined.
+type pyLine struct {
+	indent int
+	text   string
+}
+
+// pyDef is a def or class statement with its docstring.
+type pyDef struct {
+	name      string
+	signature string
+	doc       string
+	isClass   bool
+	methods   []pyDef
+}
+
+// renderPythonModule renders a module's docstring and the signatures and
+// docstrings of its public functions, classes, and methods. It returns nil
+// for modules without documentation or public definitions.
+func renderPythonModule(moduleName string, source string) []byte {
+	lines := pyLogicalLines(source)
+
+	moduleDoc := ""
+	if len(lines) > 0 && lines[0].indent == 0 {
+		moduleDoc, _ = pyStringLiteral(lines[0].text)
+	}
+
+	functions, classes := []pyDef{}, []pyDef{}
+	for i, line := range lines {
+		if line.indent != 0 {
+			continue
+		}
+
+		def, ok := parsePyDef(lines, i)
+		if !ok || strings.HasPrefix(def.name, "_") {
+			continue
+		}
+
+		if def.isClass {
+			def.methods = pyMethods(lines, i)
+			classes = append(classes, def)
+		} else {
+			functions = append(functions, def)
+		}
+	}
+
+	if moduleDoc == "" && len(functions) == 0 && len(classes) == 0 {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# %s\n\n", moduleName)
+	writePyDoc(&buf, moduleDoc)
+
+	if len(functions) > 0 {
+		buf.WriteString("## Functions\n\n")
+		for _, def := range functions {
+			writePyDef(&buf, "###", def.name, def)
+		}
+	}
+
+	if len(classes) > 0 {
+		buf.WriteString("## Classes\n\n")
+		for _, def := range classes {
+			writePyDef(&buf, "###", "class "+def.name, def)
+			for _, method := range def.methods {
+				writePyDef(&buf, "####", def.name+"."+method.name, method)
+			}
+		}
+	}
+
+	return append(bytes.TrimRight(buf.Bytes(), "\n"), '\n')
+}
+
+func writePyDef(buf *bytes.Buffer, level string, heading string, def pyDef) {
+	fmt.Fprintf(buf, "%s %s\n\n```python\n%s\n```\n\n", level, heading, def.signature)
+	writePyDoc(buf, def.doc)
+}
+
+func writePyDoc(buf *bytes.Buffer, doc string) {
+	if doc == "" {
+		return
+	}
+
+	buf.WriteString(doc)
+	buf.WriteString("\n\n")
+}
+
+// pyMethods returns the public methods (and __init__) defined directly in
+// the body of the class at lines[classIndex].
+func pyMethods(lines []pyLine, classIndex int) []pyDef {
+	classIndent := lines[classIndex].indent
+	bodyIndent := -1
+
+	methods := []pyDef{}
+	for i := classIndex + 1; i < len(lines) && lines[i].indent > classIndent; i++ {
+		if bodyIndent < 0 {
+			bodyIndent = lines[i].indent
+		}
+		if lines[i].indent != bodyIndent {
+			continue
+		}
+
+		def, ok := parsePyDef(lines, i)
+		if !ok || def.isClass || (strings.HasPrefix(def.name, "_") && def.name != "__init__") {
+			continue
+		}
+
+		methods = append(methods, def)
+	}
+
+	return methods
+}
+
+// parsePyDef parses the def or class statement at lines[index] and picks
+// up the docstring opening its body.
+func parsePyDef(lines []pyLine, index int) (pyDef, bool) {
+	text := lines[index].text
+	isClass := strings.HasPrefix(text, "class ")
+	if !isClass && !strings.HasPrefix(text, "def ") && !strings.HasPrefix(text, "async def ") {
+		return pyDef{}, false
+	}
+
+	header, body, found := cutPyHeader(text)
+	if !found {
+		return pyDef{}, false
+	}
+
+	keyword := "def "
+	if isClass {
+		keyword = "class "
+	}
+	_, rest, _ := strings.Cut(header, keyword)
+	name := rest
+	if end := strings.IndexAny(rest, "(:"); end >= 0 {
+		name = rest[:end]
+	}
+
+	def := pyDef{
+		name:      strings.TrimSpace(name),
+		signature: collapsePySignature(header),
+		isClass:   isClass,
+	}
+
+	// A body on the header line ("def f(): ...") has no docstring.
+	if strings.TrimSpace(body) == "" && index+1 < len(lines) && lines[index+1].indent > lines[index].indent {
+		def.doc, _ = pyStringLiteral(lines[index+1].text)
+	}
+
+	return def, def.name != ""
+}
+
+// cutPyHeader splits a def or class statement at the colon that ends its
+// header, skipping colons inside brackets (annotations, defaults, lambdas)
+// and strings.
+func cutPyHeader(text string) (string, string, bool) {
+	depth := 0
+	for i := 0; i < len(text); i++ {
+		switch text[i] {
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			depth--
+		case '"', '\'':
+			i = skipPyString(text, i) - 1
+		case ':':
+			if depth == 0 {
+				return text[:i], text[i+1:], true
+			}
+		}
+	}
+
+	return "", "", false
+}
+
+// collapsePySignature joins a header spread over several lines into one.
+func collapsePySignature(header string) string {
+	collapsed := strings.Join(strings.Fields(header), " ")
+	collapsed = strings.ReplaceAll(collapsed, "( ", "(")
+	collapsed = strings.ReplaceAll(collapsed, ", )", ")")
+	collapsed = strings.ReplaceAll(collapsed, ",)", ")")
+
+	return strings.ReplaceAll(collapsed, " )", ")")
+}
+
+// pyLogicalLines splits Python source into logical lines, dropping blank
+// lines and comments. Tabs advance the indentation to the next multiple of
+// eight like the Python tokenizer.
+func pyLogicalLines(source string) []pyLine {
+	lines := []pyLine{}
+	i := 0
+	for i < len(source) {
+		indent := 0
+		for i < len(source) && (source[i] == ' ' || source[i] == '\t') {
+			if source[i] == '\t' {
+				indent += pyTabSize - indent%pyTabSize
+			} else {
+				indent++
+			}
+			i++
+		}
+
+		var text strings.Builder
+		i = scanPyLogicalLine(source, i, &text)
+
+		if statement := strings.TrimSpace(text.String()); statement != "" {
+			lines = append(lines, pyLine{indent: indent, text: statement})
+		}
+	}
+
+	return lines
+}
+
+// scanPyLogicalLine copies one logical line starting at i into text and
+// returns the index after its terminating newline.
+func scanPyLogicalLine(source string, i int, text *strings.Builder) int {
+	depth := 0
+	for i < len(source) {
+		switch c := source[i]; c {
+		case '#':
+			for i < len(source) && source[i] != '\n' {
+				i++
+			}
+		case '"', '\'':
+			end := skipPyString(source, i)
+			text.WriteString(source[i:end])
+			i = end
+		case '\\':
+			if i+1 < len(source) && source[i+1] == '\n' {
+				text.WriteByte(' ')
+				i += 2
+				continue
+			}
+			text.WriteByte(c)
+			i++
+		case '\n':
+			i++
+			if depth <= 0 {
+				return i
+			}
+			text.WriteByte('\n')
+		default:
+			switch c {
+			case '(', '[', '{':
+				depth++
+			case ')', ']', '}':
+				depth--
+			}
+			text.WriteByte(c)
+			i++
+		}
+	}
+
+	return i
+}
+
+// skipPyString returns the index after the string literal whose opening
+// quote is at start. Unterminated single-quoted strings end at the newline.
+func skipPyString(source string, start int) int {
+	quote := source[start : start+1]
+	if strings.HasPrefix(source[start:], strings.Repeat(quote, 3)) {
+		quote = strings.Repeat(quote, 3)
+	}
+
+	for i := start + len(quote); i < len(source); i++ {
+		switch {
+		case source[i] == '\\':
+			i++
+		case len(quote) == 1 && source[i] == '\n':
+			return i
+		case strings.HasPrefix(source[i:], quote):
+			return i + len(quote)
+		}
+	}
+
+	return len(source)
+}
+
+// pyStringLiteral returns the cleaned content of a statement consisting of
+// a string literal, as Python's inspect.cleandoc would for a docstring.
+func pyStringLiteral(statement string) (string, bool) {
+	prefixEnd := strings.IndexAny(statement, `"'`)
+	if prefixEnd < 0 || prefixEnd > 2 || strings.Trim(strings.ToLower(statement[:prefixEnd]), "ru") != "" {
+		return "", false
+	}
+
+	end := skipPyString(statement, prefixEnd)
+	literal := statement[prefixEnd:end]
+
+	quote := literal[:1]
+	if strings.HasPrefix(literal, strings.Repeat(quote, 3)) {
+		quote = strings.Repeat(quote, 3)
+	}
+	if len(literal) < 2*len(quote) || !strings.HasSuffix(literal, quote) {
+		return "", false
+	}
+
+	return cleanPyDoc(literal[len(quote) : len(literal)-len(quote)]), true
+}
+
+// cleanPyDoc strips a docstring's indentation: the first line is trimmed,
+// the common indentation of the remaining lines removed, and leading and
+// trailing blank lines dropped.
+func cleanPyDoc(doc string) string {
+	lines := strings.Split(strings.ReplaceAll(doc, "\t", strings.Repeat(" ", pyTabSize)), "\n")
+	lines[0] = strings.TrimSpace(lines[0])
+
+	margin := -1
+	for _, line := range lines[1:] {
+		content := strings.TrimLeft(line, " ")
+		if content == "" {
+			continue
+		}
+		if indent := len(line) - len(content); margin < 0 || indent < margin {
+			margin = indent
+		}
+	}
+
+	for i := 1; i < len(lines); i++ {
+		if len(lines[i]) >= margin && margin > 0 {
+			lines[i] = lines[i][margin:]
+		}
+		lines[i] = strings.TrimRight(lines[i], " ")
+	}
+
+	return strings.Trim(strings.Join(lines, "\n"), "\n")
+}
@@ -0,0 +1,396 @@
+package source_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/g5becks/dox/internal/config"
+	"github.com/g5becks/dox/internal/source"
+)
+
+const clientModule = `"""HTTP client helpers.
+
+Use :class:` + "`Client`" + ` for sessions.
+"""
+
+import os
+
+
+def get(url: str, *, timeout: float = 5.0,
+        headers: dict[str, str] | None = None) -> "Response":
+    """Send a GET request.
+
+    Args:
+        url: The URL to fetch.
+    """
+    return Client().get(url)
+
+
+def _private():
+    """Hidden."""
+
+
+class Client(Base):
+    """A reusable session."""
+
+    def __init__(self, retries=3):
+        """Create a client."""
+        self.retries = retries
+
+    def get(self, url):
+        """Fetch url.
+
+        Returns the response.
+        """
+        def nested():
+            """Not a method."""
+
+    def _retry(self):
+        pass
+`
+
+// pypiIndex serves one project's metadata and distribution files from memory.
+type pypiIndex struct {
+	metadata string
+	files    map[string][]byte
+	requests []string
+}
+
+type pypiRelease struct {
+	version string
+	files   map[string][]byte
+	// corrupt publishes a wrong hash for every file of the release.
+	corrupt bool
+}
+
+func newPyPIIndex(t *testing.T, latest string, releases ...pypiRelease) *pypiIndex {
+	t.Helper()
+
+	index := &pypiIndex{files: map[string][]byte{}}
+	entries := make([]string, 0, len(releases))
+	for _, release := range releases {
+		files := make([]string, 0, len(release.files))
+		for filename, content := range release.files {
+			sum := sha256.Sum256(content)
+			digest := hex.EncodeToString(sum[:])
+			if release.corrupt {
+				digest = strings.Repeat("0", len(digest))
+			}
+
+			packageType := "sdist"
+			if strings.HasSuffix(filename, ".whl") {
+				packageType = "bdist_wheel"
+			}
+
+			index.files["/files/"+filename] = content
+			files = append(files, fmt.Sprintf(
+				`{"filename":%q,"url":"https://files.pypi.test/files/%s","packagetype":%q,"digests":{"sha256":%q}}`,
+				filename, filename, packageType, digest))
+		}
+		entries = append(entries, fmt.Sprintf(`%q:[%s]`, release.version, strings.Join(files, ",")))
+	}
+
+	index.metadata = fmt.Sprintf(`{"info":{"version":%q},"releases":{%s}}`, latest, strings.Join(entries, ","))
+	return index
+}
+
+func (i *pypiIndex) handle(req *http.Request) *http.Response {
+	i.requests = append(i.requests, req.URL.Path)
+
+	if content, ok := i.files[req.URL.Path]; ok {
+		return source.NewHTTPResponse(req, http.StatusOK, string(content), nil)
+	}
+
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+	return source.NewHTTPResponse(req, http.StatusOK, i.metadata, header)
+}
+
+func clientSdist(t *testing.T, version string) []byte {
+	t.Helper()
+
+	root := "http_client-" + version + "/"
+	return buildTarGz(t,
+		archiveMember{name: root + "README.rst", content: "HTTP Client " + version},
+		archiveMember{name: root + "docs/index.rst", content: "Welcome"},
+		archiveMember{name: root + "docs/conf.py", content: "project = 'x'"},
+		archiveMember{name: root + "src/http_client/__init__.py", content: clientModule},
+		archiveMember{name: root + "src/http_client/_internal.py", content: `"""Private."""`},
+		archiveMember{name: root + "tests/test_client.py", content: `"""Tests."""`},
+		archiveMember{name: root + "setup.py", content: `"""Setup."""`},
+	)
+}
+
+func TestPyPISyncResolvesSpecifierAndRendersDocstrings(t *testing.T) {
+	t.Parallel()
+
+	index := newPyPIIndex(t, "3.0.0",
+		pypiRelease{version: "2.1.0", files: map[string][]byte{"http_client-2.1.0.tar.gz": clientSdist(t, "2.1.0")}},
+		pypiRelease{version: "2.4.1", files: map[string][]byte{"http_client-2.4.1.tar.gz": clientSdist(t, "2.4.1")}},
+		pypiRelease{version: "3.0.0", files: map[string][]byte{"http_client-3.0.0.tar.gz": clientSdist(t, "3.0.0")}},
+	)
+
+	src, setClient := source.TestablePyPISource(t, "client", config.Source{
+		Type:       "pypi",
+		Package:    "HTTP_Client",
+		Version:    "~=2.1",
+		Registry:   "https://pypi.test/pypi",
+		Docstrings: true,
+	})
+	setClient(source.NewMockRestyClient(index.handle))
+
+	destDir := t.TempDir()
+	first, err := src.Sync(context.Background(), destDir, nil, source.SyncOptions{})
+	if err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+
+	if first.LockEntry.RefResolved != "2.4.1" {
+		t.Fatalf("RefResolved = %q, want %q", first.LockEntry.RefResolved, "2.4.1")
+	}
+	if !strings.HasPrefix(first.LockEntry.Digest, "sha256:") {
+		t.Fatalf("Digest = %q, want a sha256 pin", first.LockEntry.Digest)
+	}
+	if index.requests[0] != "/pypi/http-client/json" {
+		t.Fatalf("metadata request = %q, want normalized project name", index.requests[0])
+	}
+
+	if len(first.LockEntry.Files) != 3 {
+		t.Fatalf("Files = %v, want README, docs/index.rst, and one API page", first.LockEntry.Files)
+	}
+
+	page, err := os.ReadFile(filepath.Join(destDir, "api", "http_client.md"))
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+
+	for _, want := range []string{
+		"# http_client",
+		"HTTP client helpers.",
+		"def get(url: str, *, timeout: float = 5.0, headers: dict[str, str] | None = None) -> \"Response\"",
+		"Args:\n    url: The URL to fetch.",
+		"### class Client",
+		"class Client(Base)",
+		"#### Client.__init__",
+		"#### Client.get",
+		"Returns the response.",
+	} {
+		if !strings.Contains(string(page), want) {
+			t.Fatalf("API page is missing %q:\n%s", want, page)
+		}
+	}
+
+	for _, unwanted := range []string{"_private", "_retry", "nested", "import os"} {
+		if strings.Contains(string(page), unwanted) {
+			t.Fatalf("API page contains %q:\n%s", unwanted, page)
+		}
+	}
+
+	second, err := src.Sync(context.Background(), destDir, first.LockEntry, source.SyncOptions{})
+	if err != nil {
+		t.Fatalf("second Sync() error = %v", err)
+	}
+
+	if !second.Skipped {
+		t.Fatalf("Skipped = false, want true for the locked file")
+	}
+}
+
+func TestPyPISyncSimpleIndexPicksWheel(t *testing.T) {
+	t.Parallel()
+
+	wheelPath := filepath.Join(t.TempDir(), "client.whl")
+	writeZip(t, wheelPath,
+		archiveMember{name: "http_client/__init__.py", content: clientModule},
+		archiveMember{name: "http_client-1.0.0.dist-info/METADATA", content: "Name: http-client"},
+		archiveMember{name: "http_client/README.md", content: "# Client"},
+	)
+	wheel, err := os.ReadFile(wheelPath)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	sum := sha256.Sum256(wheel)
+
+	client := source.NewMockRestyClient(func(req *http.Request) *http.Response {
+		if req.URL.Path == "/packages/http_client-1.0.0-py3-none-any.whl" {
+			return source.NewHTTPResponse(req, http.StatusOK, string(wheel), nil)
+		}
+
+		if req.Header.Get("Accept") != "application/vnd.pypi.simple.v1+json" {
+			return source.NewHTTPResponse(req, http.StatusNotAcceptable, "", nil)
+		}
+
+		header := http.Header{}
+		header.Set("Content-Type", "application/vnd.pypi.simple.v1+json")
+		return source.NewHTTPResponse(req, http.StatusOK, fmt.Sprintf(`{"files":[
+			{"filename":"http_client-0.9.0.tar.gz","url":"../../packages/http_client-0.9.0.tar.gz","hashes":{"sha256":"00"},"yanked":"broken"},
+			{"filename":"http_client-1.0.0-py3-none-any.whl","url":"../../packages/http_client-1.0.0-py3-none-any.whl","hashes":{"sha256":%q}}
+		]}`, hex.EncodeToString(sum[:])), header)
+	})
+
+	src, setClient := source.TestablePyPISource(t, "client", config.Source{
+		Type:     "pypi",
+		Package:  "http-client",
+		Registry: "https://mirror.test/simple",
+		Dist:     "wheel",
+	})
+	setClient(client)
+
+	destDir := t.TempDir()
+	result, err := src.Sync(context.Background(), destDir, nil, source.SyncOptions{})
+	if err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+
+	if result.LockEntry.RefResolved != "1.0.0" {
+		t.Fatalf("RefResolved = %q, want the only unyanked release", result.LockEntry.RefResolved)
+	}
+
+	if _, statErr := os.Stat(filepath.Join(destDir, "http_client", "README.md")); statErr != nil {
+		t.Fatalf("expected README from the wheel: %v", statErr)
+	}
+	if _, statErr := os.Stat(filepath.Join(destDir, "api")); !os.IsNotExist(statErr) {
+		t.Fatalf("expected no docstring pages without docstrings = true")
+	}
+}
+
+func TestPyPISyncSkipsYankedFileOfRelease(t *testing.T) {
+	t.Parallel()
+
+	wheelPath := filepath.Join(t.TempDir(), "client.whl")
+	writeZip(t, wheelPath, archiveMember{name: "http_client/README.md", content: "# Client"})
+	wheel, err := os.ReadFile(wheelPath)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	sum := sha256.Sum256(wheel)
+
+	client := source.NewMockRestyClient(func(req *http.Request) *http.Response {
+		if req.URL.Path == "/packages/http_client-1.0.0-py3-none-any.whl" {
+			return source.NewHTTPResponse(req, http.StatusOK, string(wheel), nil)
+		}
+
+		header := http.Header{}
+		header.Set("Content-Type", "application/vnd.pypi.simple.v1+json")
+		return source.NewHTTPResponse(req, http.StatusOK, fmt.Sprintf(`{"files":[
+			{"filename":"http_client-1.0.0.tar.gz","url":"../../packages/http_client-1.0.0.tar.gz","hashes":{"sha256":"00"},"yanked":true},
+			{"filename":"http_client-1.0.0-py3-none-any.whl","url":"../../packages/http_client-1.0.0-py3-none-any.whl","hashes":{"sha256":%q}}
+		]}`, hex.EncodeToString(sum[:])), header)
+	})
+
+	src, setClient := source.TestablePyPISource(t, "client", config.Source{
+		Type:     "pypi",
+		Package:  "http-client",
+		Registry: "https://mirror.test/simple",
+	})
+	setClient(client)
+
+	destDir := t.TempDir()
+	if _, err = src.Sync(context.Background(), destDir, nil, source.SyncOptions{}); err != nil {
+		t.Fatalf("Sync() error = %v, want the unyanked wheel over the yanked sdist", err)
+	}
+
+	if _, statErr := os.Stat(filepath.Join(destDir, "http_client", "README.md")); statErr != nil {
+		t.Fatalf("expected README from the wheel: %v", statErr)
+	}
+}
+
+func TestPyPISyncFollowsChangedDistOfLockedVersion(t *testing.T) {
+	t.Parallel()
+
+	wheelPath := filepath.Join(t.TempDir(), "client.whl")
+	writeZip(t, wheelPath, archiveMember{name: "http_client/README.md", content: "# Client"})
+	wheel, err := os.ReadFile(wheelPath)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+
+	index := newPyPIIndex(t, "1.0.0", pypiRelease{version: "1.0.0", files: map[string][]byte{
+		"http_client-1.0.0.tar.gz":           clientSdist(t, "1.0.0"),
+		"http_client-1.0.0-py3-none-any.whl": wheel,
+	}})
+
+	cfg := config.Source{Type: "pypi", Package: "http-client", Registry: "https://pypi.test/pypi", Dist: "sdist"}
+	sdistSrc, setSdistClient := source.TestablePyPISource(t, "client", cfg)
+	setSdistClient(source.NewMockRestyClient(index.handle))
+
+	destDir := t.TempDir()
+	first, err := sdistSrc.Sync(context.Background(), destDir, nil, source.SyncOptions{})
+	if err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+
+	cfg.Dist = "wheel"
+	wheelSrc, setWheelClient := source.TestablePyPISource(t, "client", cfg)
+	setWheelClient(source.NewMockRestyClient(index.handle))
+
+	second, err := wheelSrc.Sync(context.Background(), destDir, first.LockEntry, source.SyncOptions{})
+	if err != nil {
+		t.Fatalf("Sync() with dist = wheel error = %v", err)
+	}
+
+	sum := sha256.Sum256(wheel)
+	if second.Skipped || second.LockEntry.Digest != "sha256:"+hex.EncodeToString(sum[:]) {
+		t.Fatalf("Sync() = %+v, want the wheel of the locked version", second)
+	}
+
+	if _, statErr := os.Stat(filepath.Join(destDir, "http_client", "README.md")); statErr != nil {
+		t.Fatalf("expected README from the wheel: %v", statErr)
+	}
+	if _, statErr := os.Stat(filepath.Join(destDir, "README.rst")); !os.IsNotExist(statErr) {
+		t.Fatalf("expected README.rst of the sdist to be deleted")
+	}
+}
+
+func TestPyPISyncRejectsHashMismatch(t *testing.T) {
+	t.Parallel()
+
+	index := newPyPIIndex(t, "1.0.0",
+		pypiRelease{version: "1.0.0", files: map[string][]byte{"http_client-1.0.0.tar.gz": clientSdist(t, "1.0.0")}, corrupt: true},
+	)
+
+	src, setClient := source.TestablePyPISource(t, "client", config.Source{
+		Type:     "pypi",
+		Package:  "http-client",
+		Registry: "https://pypi.test/pypi",
+	})
+	setClient(source.NewMockRestyClient(index.handle))
+
+	destDir := t.TempDir()
+	_, err := src.Sync(context.Background(), destDir, nil, source.SyncOptions{})
+	if err == nil || !strings.Contains(err.Error(), "sha256 check failed") {
+		t.Fatalf("Sync() error = %v, want hash error", err)
+	}
+
+	if entries, _ := os.ReadDir(destDir); len(entries) != 0 {
+		t.Fatalf("expected nothing to be extracted, found %d entries", len(entries))
+	}
+}
+
+func TestPyPISyncNoMatchingVersion(t *testing.T) {
+	t.Parallel()
+
+	index := newPyPIIndex(t, "1.0.0",
+		pypiRelease{version: "1.0.0", files: map[string][]byte{"http_client-1.0.0.tar.gz": clientSdist(t, "1.0.0")}},
+	)
+
+	src, setClient := source.TestablePyPISource(t, "client", config.Source{
+		Type:     "pypi",
+		Package:  "http-client",
+		Version:  ">=2,<3",
+		Registry: "https://pypi.test/pypi",
+	})
+	setClient(source.NewMockRestyClient(index.handle))
+
+	_, err := src.Sync(context.Background(), t.TempDir(), nil, source.SyncOptions{})
+	if err == nil || !strings.Contains(err.Error(), "no published version") {
+		t.Fatalf("Sync() error = %v, want version error", err)
+	}
+}
@@ -0,0 +1,687 @@
+package source
+
+import (
+	"cmp"
+	"context"
+	"encoding/xml"
+	"io"
+	"net/http"
+	neturl "net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/andybalholm/cascadia"
+	"github.com/samber/oops"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"golang.org/x/sync/errgroup"
+	"resty.dev/v3"
+
+	"github.com/g5becks/dox/internal/config"
+	"github.com/g5becks/dox/internal/lockfile"
+)
+
+const (
+	sourceTypeSite = "site"
+	// siteUserAgent identifies the crawler to servers and picks its group
+	// in robots.txt.
+	siteUserAgent       = "dox"
+	siteDefaultSelector = "main, article, [role=main]"
+	siteDefaultMaxDepth = 3
+	siteDefaultMaxPages = 200
+	siteMaxDocumentSize = 10 << 20
+)
+
+// siteSource crawls a documentation website and converts its pages to
+// markdown. The crawl starts from a root URL and the pages of an optional
+// sitemap, follows same-origin links below the configured path prefixes,
+// and honors robots.txt. Each page's validators are kept in the lock entry
+// so a recrawl only downloads pages that changed.
+type siteSource struct {
+	name   string
+	source config.Source
+	root   *neturl.URL
+	// baseDir is the directory of the root URL; output paths are relative
+	// to it.
+	baseDir  string
+	prefixes []string
+	selector cascadia.SelectorGroup
+	maxDepth int
+	maxPages int
+	client   *resty.Client
+}
+
+// sitePage is the outcome of fetching one page.
+type sitePage struct {
+	// skipped is set for pages that failed or are not HTML.
+	skipped     bool
+	notModified bool
+	etag        string
+	lastMod     string
+	content     []byte
+	links       []string
+}
+
+// sitemapDocument is a sitemap.xml urlset or a sitemap index.
+type sitemapDocument struct {
+	URLs     []sitemapLocation `xml:"url"`
+	Sitemaps []sitemapLocation `xml:"sitemap"`
+}
+
+type sitemapLocation struct {
+	Loc string `xml:"loc"`
+}
+
+func NewSite(name string, cfg config.Source) (Source, error) {
+	return newSiteSource(name, cfg)
+}
+
+func newSiteSource(name string, cfg config.Source) (*siteSource, error) {
+	start := cfg.URL
+	if start == "" {
+		start = cfg.Sitemap
+	}
+
+	root, ok := normalizePageURL(nil, start)
+	if !ok {
+		return nil, oops.
+			Code("CONFIG_INVALID").
+			With("source", name).
+			With("url", config.RedactURL(start)).
+			Hint("Site sources crawl HTTP/HTTPS URLs such as https://example.com/docs/").
+			Errorf("invalid site url %q for source %q", config.RedactURL(start), name)
+	}
+
+	selectorText := cfg.Selector
+	if strings.TrimSpace(selectorText) == "" {
+		selectorText = siteDefaultSelector
+	}
+
+	selector, err := cascadia.ParseGroup(selectorText)
+	if err != nil {
+		return nil, oops.
+			Code("CONFIG_INVALID").
+			With("source", name).
+			With("selector", selectorText).
+			Hint("selector is a CSS selector for the page's main content, e.g. \"main\" or \"div.content\"").
+			Wrapf(err, "invalid selector for source %q", name)
+	}
+
+	baseDir := "/"
+	if cfg.URL != "" {
+		baseDir = siteBaseDir(root.Path)
+	}
+
+	prefixes := cfg.Prefixes
+	if len(prefixes) == 0 {
+		prefixes = []string{baseDir}
+	}
+
+	client, err := newHTTPClient(name, cfg.HTTP)
+	if err != nil {
+		return nil, err
+	}
+
+	return &siteSource{
+		name:     name,
+		source:   cfg,
+		root:     root,
+		baseDir:  baseDir,
+		prefixes: prefixes,
+		selector: selector,
+		maxDepth: cmp.Or(cfg.MaxDepth, siteDefaultMaxDepth),
+		maxPages: cmp.Or(cfg.MaxPages, siteDefaultMaxPages),
+		client:   client.SetHeader("User-Agent", siteUserAgent),
+	}, nil
+}
+
+func (s *siteSource) Close() error {
+	return s.client.Close()
+}
+
+func (s *siteSource) Sync(
+	ctx context.Context,
+	destDir string,
+	prevLock *lockfile.LockEntry,
+	opts SyncOptions,
+) (*SyncResult, error) {
+	robots, err := s.fetchRobots(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	crawl := newSiteCrawl(s, destDir, prevLock, opts, robots)
+
+	seeds, err := s.seeds(ctx, crawl)
+	if err != nil {
+		return nil, err
+	}
+
+	if crawlErr := crawl.run(ctx, seeds); crawlErr != nil {
+		return nil, crawlErr
+	}
+
+	toDelete := diffDeletes(crawl.oldFiles, crawl.files)
+	if !opts.DryRun {
+		if deleteErr := deleteStaleFiles(s.name, destDir, toDelete); deleteErr != nil {
+			return nil, deleteErr
+		}
+	}
+
+	return &SyncResult{
+		Downloaded: crawl.written,
+		Deleted:    len(toDelete),
+		Skipped:    prevLock != nil && crawl.written == 0 && len(toDelete) == 0,
+		LockEntry: &lockfile.LockEntry{
+			Type:     sourceTypeSite,
+			SyncedAt: time.Now().UTC(),
+			Files:    crawl.files,
+			Pages:    crawl.pages,
+		},
+	}, nil
+}
+
+// seeds returns the pages the crawl starts from: the root URL and the
+// in-scope pages listed by the sitemap.
+func (s *siteSource) seeds(ctx context.Context, crawl *siteCrawl) ([]string, error) {
+	seeds := []string{}
+	if s.source.URL != "" {
+		if !crawl.robots.allowed(s.root.EscapedPath()) {
+			return nil, oops.
+				Code("DOWNLOAD_FAILED").
+				With("source", s.name).
+				With("url", config.RedactURL(s.root.String())).
+				Hint("The site's robots.txt disallows crawling this URL for user agent "+siteUserAgent).
+				Errorf("robots.txt disallows crawling %s", config.RedactURL(s.root.String()))
+		}
+		seeds = append(seeds, s.root.String())
+	}
+
+	if s.source.Sitemap == "" {
+		return seeds, nil
+	}
+
+	locations, err := s.fetchSitemap(ctx, s.source.Sitemap, true)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, location := range locations {
+		if pageURL, ok := normalizePageURL(s.root, location); ok && crawl.inScope(pageURL) {
+			seeds = append(seeds, pageURL.String())
+		}
+	}
+
+	return seeds, nil
+}
+
+// fetchRobots loads the robots.txt of the site's origin. A missing file
+// allows everything.
+func (s *siteSource) fetchRobots(ctx context.Context) (*robotsPolicy, error) {
+	robotsURL := &neturl.URL{Scheme: s.root.Scheme, Host: s.root.Host, Path: "/robots.txt"}
+
+	response, err := s.client.R().SetContext(ctx).Get(robotsURL.String())
+	if err != nil {
+		return nil, oops.
+			Code("DOWNLOAD_FAILED").
+			With("source", s.name).
+			With("url", config.RedactURL(robotsURL.String())).
+			Wrapf(redactURLError(err), "downloading robots.txt")
+	}
+
+	if !response.IsSuccess() {
+		return &robotsPolicy{}, nil
+	}
+
+	return parseRobots(response.String(), siteUserAgent), nil
+}
+
+// fetchSitemap returns the page locations of a sitemap, following the
+// sitemaps of a sitemap index one level deep.
+func (s *siteSource) fetchSitemap(ctx context.Context, location string, followIndex bool) ([]string, error) {
+	response, err := s.client.R().
+		SetContext(ctx).
+		SetDoNotParseResponse(true).
+		Get(location)
+	if err != nil {
+		return nil, oops.
+			Code("DOWNLOAD_FAILED").
+			With("source", s.name).
+			With("url", config.RedactURL(location)).
+			Wrapf(redactURLError(err), "downloading sitemap")
+	}
+	defer response.Body.Close()
+
+	if !response.IsSuccess() {
+		return nil, oops.
+			Code("DOWNLOAD_FAILED").
+			With("source", s.name).
+			With("url", config.RedactURL(location)).
+			With("status", response.StatusCode()).
+			Errorf("sitemap returned non-success status %d", response.StatusCode())
+	}
+
+	document := sitemapDocument{}
+	decoder := xml.NewDecoder(io.LimitReader(response.Body, siteMaxDocumentSize))
+	if decodeErr := decoder.Decode(&document); decodeErr != nil {
+		return nil, oops.
+			Code("DOWNLOAD_FAILED").
+			With("source", s.name).
+			With("url", config.RedactURL(location)).
+			Wrapf(decodeErr, "parsing sitemap")
+	}
+
+	locations := make([]string, 0, len(document.URLs))
+	for _, entry := range document.URLs {
+		locations = append(locations, strings.TrimSpace(entry.Loc))
+	}
+
+	if !followIndex {
+		return locations, nil
+	}
+
+	for _, entry := range document.Sitemaps {
+		nested, nestedErr := s.fetchSitemap(ctx, strings.TrimSpace(entry.Loc), false)
+		if nestedErr != nil {
+			return nil, nestedErr
+		}
+		locations = append(locations, nested...)
+	}
+
+	return locations, nil
+}
+
+// fetchPage downloads a page, conditionally when it was crawled before, and
+// converts its main content to markdown. Pages that fail or are not HTML
+// are skipped unless required.
+func (s *siteSource) fetchPage(
+	ctx context.Context,
+	pageURL *neturl.URL,
+	prev *lockfile.PageEntry,
+	required bool,
+) (*sitePage, error) {
+	request := s.client.R().
+		SetContext(ctx).
+		SetDoNotParseResponse(true)
+	if prev != nil {
+		if prev.ETag != "" {
+			request.SetHeader("If-None-Match", prev.ETag)
+		}
+		if prev.LastMod != "" {
+			request.SetHeader("If-Modified-Since", prev.LastMod)
+		}
+	}
+
+	response, err := request.Get(pageURL.String())
+	if err != nil {
+		return nil, oops.
+			Code("DOWNLOAD_FAILED").
+			With("source", s.name).
+			With("url", config.RedactURL(pageURL.String())).
+			Wrapf(redactURLError(err), "downloading page")
+	}
+	defer response.Body.Close()
+
+	switch {
+	case response.StatusCode() == http.StatusNotModified && prev != nil:
+		return &sitePage{notModified: true}, nil
+	case !response.IsSuccess() && required:
+		return nil, oops.
+			Code("DOWNLOAD_FAILED").
+			With("source", s.name).
+			With("url", config.RedactURL(pageURL.String())).
+			With("status", response.StatusCode()).
+			Errorf("site root returned non-success status %d", response.StatusCode())
+	case !response.IsSuccess():
+		return &sitePage{skipped: true}, nil
+	}
+
+	if contentType := response.Header().Get("Content-Type"); contentType != "" && !strings.Contains(contentType, "html") {
+		return &sitePage{skipped: true}, nil
+	}
+
+	document, err := html.Parse(io.LimitReader(response.Body, siteMaxDocumentSize))
+	if err != nil {
+		return nil, oops.
+			Code("DOWNLOAD_FAILED").
+			With("source", s.name).
+			With("url", config.RedactURL(pageURL.String())).
+			Wrapf(err, "parsing page")
+	}
+
+	content := cascadia.Query(document, s.selector)
+	if content == nil {
+		content = findElement(document, atom.Body)
+	}
+	if content == nil {
+		content = document
+	}
+
+	return &sitePage{
+		etag:    response.Header().Get("ETag"),
+		lastMod: response.Header().Get("Last-Modified"),
+		content: sitePageMarkdown(document, content, pageURL),
+		links:   pageLinks(document, pageURL),
+	}, nil
+}
+
+// pagePath maps a page URL to its markdown file, relative to the directory
+// of the root URL: "/docs/guide/" becomes "guide/index.md" and
+// "/docs/api.html" becomes "api.md".
+func (s *siteSource) pagePath(pageURL *neturl.URL) string {
+	relativePath, found := strings.CutPrefix(pageURL.Path, s.baseDir)
+	if !found {
+		relativePath = strings.TrimPrefix(pageURL.Path, "/")
+	}
+
+	if relativePath == "" || strings.HasSuffix(relativePath, "/") {
+		relativePath += "index"
+	}
+
+	if ext := path.Ext(relativePath); ext == ".html" || ext == ".htm" {
+		relativePath = strings.TrimSuffix(relativePath, ext)
+	}
+
+	return strings.TrimPrefix(path.Clean("/"+relativePath), "/") + ".md"
+}
+
+// siteCrawl is the state of one breadth-first crawl of a site.
+type siteCrawl struct {
+	source   *siteSource
+	destDir  string
+	opts     SyncOptions
+	robots   *robotsPolicy
+	oldFiles map[string]string
+	oldPages map[string]*lockfile.PageEntry
+	files    map[string]string
+	pages    map[string]*lockfile.PageEntry
+	queued   map[string]bool
+	fetched  int
+	written  int
+}
+
+func newSiteCrawl(
+	s *siteSource,
+	destDir string,
+	prevLock *lockfile.LockEntry,
+	opts SyncOptions,
+	robots *robotsPolicy,
+) *siteCrawl {
+	crawl := &siteCrawl{
+		source:   s,
+		destDir:  destDir,
+		opts:     opts,
+		robots:   robots,
+		oldFiles: map[string]string{},
+		oldPages: map[string]*lockfile.PageEntry{},
+		files:    map[string]string{},
+		pages:    map[string]*lockfile.PageEntry{},
+		queued:   map[string]bool{},
+	}
+
+	if prevLock != nil {
+		if prevLock.Files != nil {
+			crawl.oldFiles = prevLock.Files
+		}
+		// Forced syncs, which include syncs of a source whose settings
+		// changed, drop the validators so every page is rendered again.
+		if prevLock.Pages != nil && !opts.Force {
+			crawl.oldPages = prevLock.Pages
+		}
+	}
+
+	return crawl
+}
+
+// run crawls level by level from the seeds until max_depth or max_pages is
+// reached. Pages of a level are fetched concurrently and recorded in order,
+// so output paths and the lock entry do not depend on response timing.
+func (c *siteCrawl) run(ctx context.Context, seeds []string) error {
+	level := c.enqueue(seeds)
+
+	for depth := 0; len(level) > 0 && c.fetched < c.source.maxPages; depth++ {
+		level = level[:min(len(level), c.source.maxPages-c.fetched)]
+		c.fetched += len(level)
+
+		fetched, err := c.fetchLevel(ctx, level)
+		if err != nil {
+			return err
+		}
+
+		next := []string{}
+		for i, page := range fetched {
+			links, recordErr := c.record(level[i], page)
+			if recordErr != nil {
+				return recordErr
+			}
+
+			if depth < c.source.maxDepth {
+				next = append(next, links...)
+			}
+		}
+
+		level = c.enqueue(next)
+	}
+
+	return nil
+}
+
+// enqueue returns the URLs that have not been crawled or queued yet.
+func (c *siteCrawl) enqueue(urls []string) []string {
+	level := []string{}
+	for _, pageURL := range urls {
+		if !c.queued[pageURL] {
+			c.queued[pageURL] = true
+			level = append(level, pageURL)
+		}
+	}
+
+	return level
+}
+
+func (c *siteCrawl) fetchLevel(ctx context.Context, level []string) ([]*sitePage, error) {
+	fetched := make([]*sitePage, len(level))
+
+	group, groupCtx := errgroup.WithContext(ctx)
+	group.SetLimit(max(c.opts.FileParallel, 1))
+
+	for i, rawURL := range level {
+		group.Go(func() error {
+			if c.opts.Requests != nil {
+				if err := c.opts.Requests.Acquire(groupCtx, 1); err != nil {
+					return err
+				}
+				defer c.opts.Requests.Release(1)
+			}
+
+			pageURL, _ := neturl.Parse(rawURL)
+			required := c.source.source.URL != "" && rawURL == c.source.root.String()
+
+			page, err := c.source.fetchPage(groupCtx, pageURL, c.oldPages[rawURL], required)
+			fetched[i] = page
+			return err
+		})
+	}
+
+	if err := group.Wait(); err != nil {
+		return nil, err
+	}
+
+	return fetched, nil
+}
+
+// record writes a fetched page and returns the in-scope links to follow
+// from it. An unchanged page keeps its file and the links found when it
+// was last downloaded unless it is now excluded.
+func (c *siteCrawl) record(rawURL string, page *sitePage) ([]string, error) {
+	if page.skipped {
+		return nil, nil
+	}
+
+	if page.notModified {
+		prev := c.oldPages[rawURL]
+		excluded, err := matchesAny(c.source.source.Exclude, prev.Path)
+		if err != nil || excluded {
+			return prev.Links, err
+		}
+		if _, taken := c.files[prev.Path]; !taken {
+			c.files[prev.Path] = c.oldFiles[prev.Path]
+			c.pages[rawURL] = prev
+		}
+		return prev.Links, nil
+	}
+
+	pageURL, _ := neturl.Parse(rawURL)
+	relativePath := c.source.pagePath(pageURL)
+	if _, taken := c.files[relativePath]; taken {
+		return page.links, nil
+	}
+
+	excluded, err := matchesAny(c.source.source.Exclude, relativePath)
+	if err != nil || excluded {
+		return page.links, err
+	}
+
+	links := make([]string, 0, len(page.links))
+	for _, link := range page.links {
+		if linkURL, ok := normalizePageURL(nil, link); ok && c.inScope(linkURL) {
+			links = append(links, link)
+		}
+	}
+
+	sha := contentSHA256(page.content)
+	c.files[relativePath] = sha
+	c.pages[rawURL] = &lockfile.PageEntry{
+		Path:    relativePath,
+		ETag:    page.etag,
+		LastMod: page.lastMod,
+		Links:   links,
+	}
+
+	if !c.opts.Force && c.oldFiles[relativePath] == sha {
+		return links, nil
+	}
+
+	c.written++
+	if c.opts.DryRun {
+		return links, nil
+	}
+
+	return links, writeSourceFile(c.source.name, c.destDir, relativePath, page.content)
+}
+
+// inScope reports whether a page belongs to the crawl: same origin as the
+// root URL, below one of the prefixes, and allowed by robots.txt.
+func (c *siteCrawl) inScope(pageURL *neturl.URL) bool {
+	root := c.source.root
+	if pageURL.Scheme != root.Scheme || !strings.EqualFold(pageURL.Host, root.Host) {
+		return false
+	}
+
+	for _, prefix := range c.source.prefixes {
+		if strings.HasPrefix(pageURL.Path, prefix) {
+			return c.robots.allowed(pageURL.EscapedPath())
+		}
+	}
+
+	return false
+}
+
+// normalizePageURL resolves reference against base and drops the parts that
+// do not identify a page: the query, the fragment, and any credentials.
+// Only HTTP and HTTPS URLs are accepted.
+func normalizePageURL(base *neturl.URL, reference string) (*neturl.URL, bool) {
+	reference = strings.TrimSpace(reference)
+	if reference == "" {
+		return nil, false
+	}
+
+	pageURL, err := neturl.Parse(reference)
+	if err != nil {
+		return nil, false
+	}
+	if base != nil {
+		pageURL = base.ResolveReference(pageURL)
+	}
+
+	if (pageURL.Scheme != "http" && pageURL.Scheme != "https") || pageURL.Host == "" {
+		return nil, false
+	}
+
+	pageURL.User = nil
+	pageURL.RawQuery, pageURL.ForceQuery = "", false
+	pageURL.Fragment, pageURL.RawFragment = "", ""
+	if pageURL.Path == "" {
+		pageURL.Path = "/"
+	}
+
+	return pageURL, true
+}
+
+// siteBaseDir returns the directory of a URL path, with a trailing slash.
+func siteBaseDir(urlPath string) string {
+	if strings.HasSuffix(urlPath, "/") {
+		return urlPath
+	}
+
+	dir := path.Dir(urlPath)
+	if dir == "." || dir == "/" {
+		return "/"
+	}
+
+	return dir + "/"
+}
+
+// sitePageMarkdown renders a page's content as markdown, adding the page
+// title as a heading when the content does not open with one.
+func sitePageMarkdown(document *html.Node, content *html.Node, pageURL *neturl.URL) []byte {
+	markdown := htmlToMarkdown(content, pageURL)
+
+	if title := findElement(document, atom.Title); title != nil && !strings.HasPrefix(markdown, "# ") {
+		if text := collapseSpaces(textContent(title)); strings.TrimSpace(text) != "" {
+			markdown = "# " + strings.TrimSpace(text) + "\n\n" + markdown
+		}
+	}
+
+	return []byte(strings.TrimSpace(markdown) + "\n")
+}
+
+// pageLinks returns the absolute, normalized targets of a page's anchors in
+// document order, without duplicates.
+func pageLinks(document *html.Node, pageURL *neturl.URL) []string {
+	links := []string{}
+	seen := map[string]bool{}
+
+	var visit func(*html.Node)
+	visit = func(node *html.Node) {
+		if node.Type == html.ElementNode && node.DataAtom == atom.A {
+			if linkURL, ok := normalizePageURL(pageURL, attribute(node, "href")); ok && !seen[linkURL.String()] {
+				seen[linkURL.String()] = true
+				links = append(links, linkURL.String())
+			}
+		}
+
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			visit(child)
+		}
+	}
+	visit(document)
+
+	return links
+}
+
+// findElement returns the first element of the given type in document order.
+func findElement(node *html.Node, element atom.Atom) *html.Node {
+	if node.Type == html.ElementNode && node.DataAtom == element {
+		return node
+	}
+
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		if found := findElement(child, element); found != nil {
+			return found
+		}
+	}
+
+	return nil
+}
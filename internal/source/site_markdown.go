@@ -0,0 +1,373 @@
+package source
+
+import (
+	"fmt"
+	neturl "net/url"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// markdownSkipped are elements that never carry page content.
+//
+//nolint:gochecknoglobals // Read-only lookup table.
+var markdownSkipped = map[atom.Atom]bool{
+	atom.Script: true, atom.Style: true, atom.Noscript: true, atom.Template: true,
+	atom.Svg: true, atom.Canvas: true, atom.Iframe: true, atom.Form: true,
+	atom.Button: true, atom.Nav: true, atom.Footer: true, atom.Aside: true,
+	atom.Head: true,
+}
+
+// markdownBlocks are elements rendered as blocks of their own; everything
+// else is rendered inline.
+//
+//nolint:gochecknoglobals // Read-only lookup table.
+var markdownBlocks = map[atom.Atom]bool{
+	atom.Address: true, atom.Article: true, atom.Blockquote: true, atom.Body: true,
+	atom.Dd: true, atom.Details: true, atom.Dialog: true, atom.Div: true,
+	atom.Dl: true, atom.Dt: true, atom.Fieldset: true, atom.Figcaption: true,
+	atom.Figure: true, atom.H1: true, atom.H2: true, atom.H3: true,
+	atom.H4: true, atom.H5: true, atom.H6: true, atom.Header: true,
+	atom.Hr: true, atom.Li: true, atom.Main: true, atom.Ol: true,
+	atom.P: true, atom.Pre: true, atom.Section: true, atom.Summary: true,
+	atom.Table: true, atom.Ul: true, atom.Html: true,
+}
+
+// markdownConverter renders an HTML subtree as markdown, resolving link and
+// image targets against the page URL.
+type markdownConverter struct {
+	base *neturl.URL
+}
+
+// htmlToMarkdown converts the HTML subtree rooted at node to markdown.
+func htmlToMarkdown(node *html.Node, base *neturl.URL) string {
+	converter := &markdownConverter{base: base}
+	return strings.Join(converter.blocks(node), "\n\n")
+}
+
+// blocks renders the children of node as a list of markdown blocks. Runs of
+// inline content between block children become paragraphs.
+func (c *markdownConverter) blocks(node *html.Node) []string {
+	blocks := []string{}
+	var paragraph strings.Builder
+
+	flush := func() {
+		if text := strings.TrimSpace(collapseSpaces(paragraph.String())); text != "" {
+			blocks = append(blocks, text)
+		}
+		paragraph.Reset()
+	}
+
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == html.ElementNode && markdownSkipped[child.DataAtom] {
+			continue
+		}
+
+		if child.Type == html.ElementNode && markdownBlocks[child.DataAtom] {
+			flush()
+			if block := c.block(child); block != "" {
+				blocks = append(blocks, block)
+			}
+			continue
+		}
+
+		paragraph.WriteString(c.inline(child))
+	}
+	flush()
+
+	return blocks
+}
+
+func (c *markdownConverter) block(node *html.Node) string {
+	switch node.DataAtom {
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		level, _ := strconv.Atoi(node.Data[1:])
+		text := strings.TrimSpace(collapseSpaces(c.inlineChildren(node)))
+		if text == "" {
+			return ""
+		}
+		return strings.Repeat("#", level) + " " + text
+
+	case atom.Pre:
+		return codeFence(node)
+
+	case atom.Hr:
+		return "---"
+
+	case atom.Ul, atom.Ol:
+		return c.list(node)
+
+	case atom.Blockquote:
+		return prefixLines(strings.Join(c.blocks(node), "\n\n"), "> ", "> ")
+
+	case atom.Table:
+		return c.table(node)
+
+	case atom.Dt:
+		text := strings.TrimSpace(collapseSpaces(c.inlineChildren(node)))
+		if text == "" {
+			return ""
+		}
+		return "**" + text + "**"
+
+	case atom.Dd:
+		return prefixLines(strings.Join(c.blocks(node), "\n\n"), ": ", "  ")
+
+	default:
+		return strings.Join(c.blocks(node), "\n\n")
+	}
+}
+
+// list renders ul and ol elements; nested lists are indented under their item.
+func (c *markdownConverter) list(node *html.Node) string {
+	items := []string{}
+	number := 1
+	if start, err := strconv.Atoi(attribute(node, "start")); err == nil {
+		number = start
+	}
+
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type != html.ElementNode || child.DataAtom != atom.Li {
+			continue
+		}
+
+		marker := "- "
+		if node.DataAtom == atom.Ol {
+			marker = strconv.Itoa(number) + ". "
+			number++
+		}
+
+		content := strings.Join(c.blocks(child), "\n")
+		items = append(items, prefixLines(content, marker, strings.Repeat(" ", len(marker))))
+	}
+
+	return strings.Join(items, "\n")
+}
+
+// table renders a table with its first row as the header.
+func (c *markdownConverter) table(node *html.Node) string {
+	rows := [][]string{}
+	columns := 0
+
+	var visit func(*html.Node)
+	visit = func(n *html.Node) {
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			if child.Type != html.ElementNode {
+				continue
+			}
+
+			switch child.DataAtom {
+			case atom.Thead, atom.Tbody, atom.Tfoot:
+				visit(child)
+			case atom.Tr:
+				row := []string{}
+				for cell := child.FirstChild; cell != nil; cell = cell.NextSibling {
+					if cell.Type == html.ElementNode && (cell.DataAtom == atom.Th || cell.DataAtom == atom.Td) {
+						text := strings.TrimSpace(collapseSpaces(c.inlineChildren(cell)))
+						row = append(row, strings.ReplaceAll(text, "|", `\|`))
+					}
+				}
+				columns = max(columns, len(row))
+				rows = append(rows, row)
+			}
+		}
+	}
+	visit(node)
+
+	if len(rows) == 0 || columns == 0 {
+		return ""
+	}
+
+	lines := make([]string, 0, len(rows)+1)
+	for i, row := range rows {
+		for len(row) < columns {
+			row = append(row, "")
+		}
+		lines = append(lines, "| "+strings.Join(row, " | ")+" |")
+
+		if i == 0 {
+			lines = append(lines, "|"+strings.Repeat(" --- |", columns))
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func (c *markdownConverter) inlineChildren(node *html.Node) string {
+	var text strings.Builder
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		text.WriteString(c.inline(child))
+	}
+
+	return text.String()
+}
+
+func (c *markdownConverter) inline(node *html.Node) string {
+	if node.Type == html.TextNode {
+		return node.Data
+	}
+	if node.Type != html.ElementNode || markdownSkipped[node.DataAtom] {
+		return ""
+	}
+
+	switch node.DataAtom {
+	case atom.Br:
+		return "  \n"
+
+	case atom.Code, atom.Kbd, atom.Samp, atom.Tt:
+		text := textContent(node)
+		if strings.TrimSpace(text) == "" {
+			return text
+		}
+		fence := "`"
+		for strings.Contains(text, fence) {
+			fence += "`"
+		}
+		return fence + text + fence
+
+	case atom.Strong, atom.B:
+		return wrapInline(c.inlineChildren(node), "**")
+
+	case atom.Em, atom.I:
+		return wrapInline(c.inlineChildren(node), "*")
+
+	case atom.A:
+		text := strings.TrimSpace(collapseSpaces(c.inlineChildren(node)))
+		href := c.resolve(attribute(node, "href"))
+		if href == "" || strings.HasPrefix(href, "#") || strings.HasPrefix(href, "javascript:") {
+			return text
+		}
+		if text == "" {
+			return ""
+		}
+		return "[" + text + "](" + href + ")"
+
+	case atom.Img:
+		src := c.resolve(attribute(node, "src"))
+		if src == "" {
+			return ""
+		}
+		return "![" + attribute(node, "alt") + "](" + src + ")"
+
+	default:
+		return c.inlineChildren(node)
+	}
+}
+
+// resolve makes a link target absolute so it still works outside the site.
+func (c *markdownConverter) resolve(reference string) string {
+	reference = strings.TrimSpace(reference)
+	if reference == "" || strings.HasPrefix(reference, "#") || c.base == nil {
+		return reference
+	}
+
+	resolved, err := c.base.Parse(reference)
+	if err != nil {
+		return reference
+	}
+
+	return resolved.String()
+}
+
+// codeFence renders a pre element as a fenced code block, taking the
+// language from a "language-*" or "lang-*" class on it or its code child.
+func codeFence(node *html.Node) string {
+	language := codeLanguage(node)
+	for child := node.FirstChild; child != nil && language == ""; child = child.NextSibling {
+		if child.Type == html.ElementNode && child.DataAtom == atom.Code {
+			language = codeLanguage(child)
+		}
+	}
+
+	code := strings.Trim(textContent(node), "\n")
+	fence := "```"
+	for strings.Contains(code, fence) {
+		fence += "`"
+	}
+
+	return fmt.Sprintf("%s%s\n%s\n%s", fence, language, code, fence)
+}
+
+func codeLanguage(node *html.Node) string {
+	for _, class := range strings.Fields(attribute(node, "class")) {
+		for _, prefix := range []string{"language-", "lang-"} {
+			if language, ok := strings.CutPrefix(class, prefix); ok {
+				return language
+			}
+		}
+	}
+
+	return ""
+}
+
+// textContent returns the text of a subtree with its whitespace kept.
+func textContent(node *html.Node) string {
+	if node.Type == html.TextNode {
+		return node.Data
+	}
+
+	var text strings.Builder
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		text.WriteString(textContent(child))
+	}
+
+	return text.String()
+}
+
+func attribute(node *html.Node, key string) string {
+	for _, attr := range node.Attr {
+		if attr.Key == key {
+			return attr.Val
+		}
+	}
+
+	return ""
+}
+
+// wrapInline wraps text in a markdown emphasis marker, keeping surrounding
+// whitespace outside the markers so the emphasis still parses.
+func wrapInline(text string, marker string) string {
+	trimmed := strings.TrimSpace(text)
+	if trimmed == "" {
+		return text
+	}
+
+	leading := text[:strings.Index(text, trimmed)]
+	trailing := text[len(leading)+len(trimmed):]
+
+	return leading + marker + trimmed + marker + trailing
+}
+
+// collapseSpaces collapses whitespace runs to one space like HTML rendering
+// does, keeping the hard line breaks produced for br elements.
+func collapseSpaces(text string) string {
+	lines := strings.Split(text, "  \n")
+	for i, line := range lines {
+		lines[i] = strings.Join(strings.Fields(line), " ")
+		if i > 0 {
+			lines[i] = strings.TrimLeft(lines[i], " ")
+		}
+	}
+
+	return strings.Join(lines, "  \n")
+}
+
+// prefixLines prefixes the first line of text with first and the remaining
+// non-empty lines with rest.
+func prefixLines(text string, first string, rest string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		switch {
+		case i == 0:
+			lines[i] = first + line
+		case line != "":
+			lines[i] = rest + line
+		default:
+			lines[i] = strings.TrimRight(rest, " ")
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,128 @@
+package source
+
+import (
+	"bufio"
+	"strings"
+)
+
+// robotsRule is an Allow or Disallow line of a robots.txt group.
+type robotsRule struct {
+	pattern string
+	allow   bool
+}
+
+// robotsPolicy holds the robots.txt rules that apply to the crawler.
+type robotsPolicy struct {
+	rules []robotsRule
+}
+
+// parseRobots reads the group of a robots.txt file that applies to agent,
+// falling back to the "*" group. A missing file allows everything.
+func parseRobots(content string, agent string) *robotsPolicy {
+	agent = strings.ToLower(agent)
+
+	var (
+		agentRules, anyRules []robotsRule
+		matchesAgent         bool
+		matchesAny           bool
+		foundAgent           bool
+		inRules              bool
+	)
+
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		key, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			// Consecutive user-agent lines share one group of rules.
+			if inRules {
+				matchesAgent, matchesAny, inRules = false, false, false
+			}
+			name := strings.ToLower(value)
+			if name == "*" {
+				matchesAny = true
+			} else if strings.HasPrefix(agent, name) {
+				matchesAgent, foundAgent = true, true
+			}
+
+		case "allow", "disallow":
+			inRules = true
+			// An empty Disallow allows everything and adds no rule.
+			if value == "" {
+				continue
+			}
+
+			rule := robotsRule{pattern: value, allow: key == "allow"}
+			if matchesAgent {
+				agentRules = append(agentRules, rule)
+			}
+			if matchesAny {
+				anyRules = append(anyRules, rule)
+			}
+		}
+	}
+
+	if foundAgent {
+		return &robotsPolicy{rules: agentRules}
+	}
+
+	return &robotsPolicy{rules: anyRules}
+}
+
+// allowed reports whether path may be crawled. The longest matching rule
+// wins and Allow wins a tie, as in RFC 9309.
+func (p *robotsPolicy) allowed(path string) bool {
+	if p == nil {
+		return true
+	}
+
+	allow, longest := true, -1
+	for _, rule := range p.rules {
+		if !robotsMatch(rule.pattern, path) {
+			continue
+		}
+
+		if len(rule.pattern) > longest || (len(rule.pattern) == longest && rule.allow) {
+			allow, longest = rule.allow, len(rule.pattern)
+		}
+	}
+
+	return allow
+}
+
+// robotsMatch matches a robots.txt path pattern, where "*" matches any run
+// of characters and a trailing "$" anchors the pattern at the end of path.
+func robotsMatch(pattern string, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	pattern = strings.TrimSuffix(pattern, "$")
+
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	rest := path[len(parts[0]):]
+
+	for _, part := range parts[1:] {
+		index := strings.Index(rest, part)
+		if index < 0 {
+			return false
+		}
+		rest = rest[index+len(part):]
+	}
+
+	if !anchored {
+		return true
+	}
+
+	// An anchored pattern must end exactly at the end of path; retry with
+	// the last literal matched as late as possible.
+	last := parts[len(parts)-1]
+	return rest == "" || (len(parts) > 1 && strings.HasSuffix(path, last))
+}
@@ -0,0 +1,340 @@
+package source_test
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/g5becks/dox/internal/config"
+	"github.com/g5becks/dox/internal/source"
+)
+
+// mockSite serves pages from memory, answering conditional requests with
+// 304 when the page's ETag matches.
+type mockSite struct {
+	mu       sync.Mutex
+	pages    map[string]string
+	etags    map[string]string
+	requests []string
+	agents   map[string]bool
+}
+
+func newMockSite(pages map[string]string) *mockSite {
+	etags := map[string]string{}
+	for pagePath := range pages {
+		etags[pagePath] = `"v1"`
+	}
+
+	return &mockSite{pages: pages, etags: etags, agents: map[string]bool{}}
+}
+
+func (m *mockSite) handle(req *http.Request) *http.Response {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.requests = append(m.requests, req.URL.Path)
+	m.agents[req.Header.Get("User-Agent")] = true
+
+	body, ok := m.pages[req.URL.Path]
+	if !ok {
+		return source.NewHTTPResponse(req, http.StatusNotFound, "not found", nil)
+	}
+
+	header := http.Header{}
+	header.Set("ETag", m.etags[req.URL.Path])
+	switch {
+	case strings.HasSuffix(req.URL.Path, ".xml"):
+		header.Set("Content-Type", "application/xml")
+	case strings.HasSuffix(req.URL.Path, ".txt"):
+		header.Set("Content-Type", "text/plain")
+	default:
+		header.Set("Content-Type", "text/html; charset=utf-8")
+	}
+
+	if match := req.Header.Get("If-None-Match"); match != "" && match == m.etags[req.URL.Path] {
+		return source.NewHTTPResponse(req, http.StatusNotModified, "", header)
+	}
+
+	return source.NewHTTPResponse(req, http.StatusOK, body, header)
+}
+
+func (m *mockSite) requested(pagePath string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, requested := range m.requests {
+		if requested == pagePath {
+			return true
+		}
+	}
+
+	return false
+}
+
+const guideIndexPage = `<!doctype html>
+<html><head><title>Guide | Example</title></head>
+<body>
+<nav><a href="/docs/deep/1">Deep dive</a> Site navigation</nav>
+<main>
+  <h1>Guide</h1>
+  <p>Start with the <a href="install">Install</a> page, then read the
+     <a href="/docs/api.html#section">API</a>.</p>
+  <pre><code class="language-go">func main() {
+	run()
+}</code></pre>
+  <ul>
+    <li>Fast</li>
+    <li>Small<ul><li>Nested</li></ul></li>
+  </ul>
+  <p>Skip <a href="/docs/private/secret">secrets</a>, the <a href="/blog/post">blog</a>,
+     and <a href="https://other.test/docs/x">other sites</a>.</p>
+</main>
+<footer>Copyright</footer>
+</body></html>`
+
+func TestSiteSyncCrawlsAndRecrawlsIncrementally(t *testing.T) {
+	t.Parallel()
+
+	site := newMockSite(map[string]string{
+		"/robots.txt":   "User-agent: *\nDisallow: /docs/private/\n",
+		"/docs/":        guideIndexPage,
+		"/docs/install": `<main><h1>Install</h1><p>Run <code>go install</code>.</p></main>`,
+		"/docs/api.html": `<title>API Reference</title><main><table>` +
+			`<tr><th>Name</th><th>Type</th></tr><tr><td>id</td><td>int</td></tr></table></main>`,
+		"/docs/deep/1":         `<main><h1>One</h1><a href="2">Next</a></main>`,
+		"/docs/deep/2":         `<main><h1>Two</h1><a href="3">Next</a></main>`,
+		"/docs/deep/3":         `<main><h1>Three</h1></main>`,
+		"/docs/private/secret": `<main><h1>Secret</h1></main>`,
+		"/blog/post":           `<main><h1>Post</h1></main>`,
+	})
+
+	src, setClient := source.TestableSiteSource(t, "guide", config.Source{
+		Type:     "site",
+		URL:      "https://site.test/docs/",
+		MaxDepth: 2,
+	})
+	setClient(source.NewMockRestyClient(site.handle))
+
+	destDir := t.TempDir()
+	first, err := src.Sync(context.Background(), destDir, nil, source.SyncOptions{FileParallel: 4})
+	if err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+
+	wantFiles := []string{"index.md", "install.md", "api.md", "deep/1.md", "deep/2.md"}
+	if len(first.LockEntry.Files) != len(wantFiles) {
+		t.Fatalf("Files = %v, want %v", first.LockEntry.Files, wantFiles)
+	}
+	for _, want := range wantFiles {
+		if _, ok := first.LockEntry.Files[want]; !ok {
+			t.Fatalf("Files = %v, missing %q", first.LockEntry.Files, want)
+		}
+	}
+
+	for _, unwanted := range []string{"/docs/deep/3", "/docs/private/secret", "/blog/post"} {
+		if site.requested(unwanted) {
+			t.Fatalf("crawled %s, want it out of scope", unwanted)
+		}
+	}
+	if !site.agents["dox"] || len(site.agents) != 1 {
+		t.Fatalf("User-Agent headers = %v, want dox", site.agents)
+	}
+
+	page, err := os.ReadFile(filepath.Join(destDir, "index.md"))
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	for _, want := range []string{
+		"# Guide\n\nStart with the [Install](https://site.test/docs/install) page",
+		"[API](https://site.test/docs/api.html#section)",
+		"```go\nfunc main() {\n\trun()\n}\n```",
+		"- Fast\n- Small\n  - Nested",
+	} {
+		if !strings.Contains(string(page), want) {
+			t.Fatalf("index.md is missing %q:\n%s", want, page)
+		}
+	}
+	for _, unwanted := range []string{"Site navigation", "Copyright", "Guide | Example"} {
+		if strings.Contains(string(page), unwanted) {
+			t.Fatalf("index.md contains %q:\n%s", unwanted, page)
+		}
+	}
+
+	api, err := os.ReadFile(filepath.Join(destDir, "api.md"))
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	if want := "# API Reference\n\n| Name | Type |\n| --- | --- |\n| id | int |\n"; string(api) != want {
+		t.Fatalf("api.md = %q, want %q", api, want)
+	}
+
+	if entry := first.LockEntry.Pages["https://site.test/docs/"]; entry == nil || entry.ETag != `"v1"` {
+		t.Fatalf("Pages = %v, want the root page with its ETag", first.LockEntry.Pages)
+	}
+
+	site.pages["/docs/install"] = `<main><h1>Install</h1><p>Run <code>go get</code>.</p></main>`
+	site.etags["/docs/install"] = `"v2"`
+	delete(site.pages, "/docs/deep/2")
+
+	second, err := src.Sync(context.Background(), destDir, first.LockEntry, source.SyncOptions{})
+	if err != nil {
+		t.Fatalf("second Sync() error = %v", err)
+	}
+
+	if second.Downloaded != 1 || second.Deleted != 1 {
+		t.Fatalf("Downloaded = %d, Deleted = %d, want 1 and 1", second.Downloaded, second.Deleted)
+	}
+	if _, statErr := os.Stat(filepath.Join(destDir, "deep", "2.md")); !os.IsNotExist(statErr) {
+		t.Fatalf("expected deep/2.md to be removed")
+	}
+	if _, ok := second.LockEntry.Files["deep/1.md"]; !ok {
+		t.Fatalf("Files = %v, want the unchanged deep/1.md kept", second.LockEntry.Files)
+	}
+
+	install, err := os.ReadFile(filepath.Join(destDir, "install.md"))
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	if !strings.Contains(string(install), "`go get`") {
+		t.Fatalf("install.md = %q, want the updated page", install)
+	}
+}
+
+func TestSiteSyncReappliesSettingsToUnchangedPages(t *testing.T) {
+	t.Parallel()
+
+	site := newMockSite(map[string]string{
+		"/docs/":        `<main><h1>Guide</h1><a href="install">Install</a></main>`,
+		"/docs/install": `<main><h1>Install</h1></main>`,
+	})
+
+	cfg := config.Source{Type: "site", URL: "https://site.test/docs/"}
+	src, setClient := source.TestableSiteSource(t, "guide", cfg)
+	setClient(source.NewMockRestyClient(site.handle))
+
+	destDir := t.TempDir()
+	first, err := src.Sync(context.Background(), destDir, nil, source.SyncOptions{})
+	if err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+	if _, ok := first.LockEntry.Files["install.md"]; !ok {
+		t.Fatalf("Files = %v, want install.md", first.LockEntry.Files)
+	}
+
+	cfg.Exclude = []string{"install.md"}
+	excluding, setExcludingClient := source.TestableSiteSource(t, "guide", cfg)
+	setExcludingClient(source.NewMockRestyClient(site.handle))
+
+	second, err := excluding.Sync(context.Background(), destDir, first.LockEntry, source.SyncOptions{})
+	if err != nil {
+		t.Fatalf("second Sync() error = %v", err)
+	}
+
+	if _, ok := second.LockEntry.Files["install.md"]; ok || second.Deleted != 1 {
+		t.Fatalf("Files = %v, Deleted = %d, want install.md dropped", second.LockEntry.Files, second.Deleted)
+	}
+	if _, statErr := os.Stat(filepath.Join(destDir, "install.md")); !os.IsNotExist(statErr) {
+		t.Fatalf("expected the excluded install.md to be removed")
+	}
+
+	// A sync forces sources whose settings changed, so a new selector
+	// renders the unchanged pages again.
+	cfg.Selector = "h1"
+	selecting, setSelectingClient := source.TestableSiteSource(t, "guide", cfg)
+	setSelectingClient(source.NewMockRestyClient(site.handle))
+
+	third, err := selecting.Sync(context.Background(), destDir, second.LockEntry, source.SyncOptions{Force: true})
+	if err != nil {
+		t.Fatalf("third Sync() error = %v", err)
+	}
+
+	index, err := os.ReadFile(filepath.Join(destDir, "index.md"))
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	if third.Downloaded != 1 || strings.Contains(string(index), "Install") {
+		t.Fatalf("Downloaded = %d, index.md = %q, want it rendered from the h1 only", third.Downloaded, index)
+	}
+}
+
+func TestSiteSyncFromSitemapWithSelector(t *testing.T) {
+	t.Parallel()
+
+	page := func(title string) string {
+		return `<html><body><div class="sidebar">Sidebar</div><div class="content"><h1>` +
+			title + `</h1><p>Body of ` + title + `.</p></div></body></html>`
+	}
+
+	site := newMockSite(map[string]string{
+		"/sitemap.xml": `<?xml version="1.0"?>
+<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <sitemap><loc>https://site.test/sitemap-guide.xml</loc></sitemap>
+</sitemapindex>`,
+		"/sitemap-guide.xml": `<?xml version="1.0"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url><loc>https://site.test/guide/a</loc></url>
+  <url><loc>https://site.test/guide/b</loc></url>
+  <url><loc>https://site.test/other/c</loc></url>
+</urlset>`,
+		"/guide/a": page("A"),
+		"/guide/b": page("B"),
+		"/other/c": page("C"),
+	})
+
+	src, setClient := source.TestableSiteSource(t, "guide", config.Source{
+		Type:     "site",
+		Sitemap:  "https://site.test/sitemap.xml",
+		Prefixes: []string{"/guide/"},
+		Selector: "div.content",
+		Exclude:  []string{"guide/b.md"},
+	})
+	setClient(source.NewMockRestyClient(site.handle))
+
+	destDir := t.TempDir()
+	result, err := src.Sync(context.Background(), destDir, nil, source.SyncOptions{})
+	if err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+
+	if len(result.LockEntry.Files) != 1 {
+		t.Fatalf("Files = %v, want only guide/a.md", result.LockEntry.Files)
+	}
+	if site.requested("/other/c") {
+		t.Fatalf("crawled /other/c outside the prefixes")
+	}
+
+	content, err := os.ReadFile(filepath.Join(destDir, "guide", "a.md"))
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	if want := "# A\n\nBody of A.\n"; string(content) != want {
+		t.Fatalf("guide/a.md = %q, want %q", content, want)
+	}
+}
+
+func TestSiteSyncHonorsRobotsForRoot(t *testing.T) {
+	t.Parallel()
+
+	site := newMockSite(map[string]string{
+		"/robots.txt": "User-agent: *\nAllow: /\n\nUser-agent: dox\nDisallow: /docs\n",
+		"/docs/":      `<main><h1>Docs</h1></main>`,
+	})
+
+	src, setClient := source.TestableSiteSource(t, "guide", config.Source{
+		Type: "site",
+		URL:  "https://site.test/docs/",
+	})
+	setClient(source.NewMockRestyClient(site.handle))
+
+	_, err := src.Sync(context.Background(), t.TempDir(), nil, source.SyncOptions{})
+	if err == nil || !strings.Contains(err.Error(), "robots.txt disallows") {
+		t.Fatalf("Sync() error = %v, want robots error", err)
+	}
+	if site.requested("/docs/") {
+		t.Fatalf("fetched a page disallowed by robots.txt")
+	}
+}
@@ -4,7 +4,9 @@ import (
 	"context"
 
 	"github.com/samber/oops"
+	"golang.org/x/sync/semaphore"
 
+	"github.com/g5becks/dox/internal/cache"
 	"github.com/g5becks/dox/internal/config"
 	"github.com/g5becks/dox/internal/lockfile"
 )
@@ -21,9 +23,25 @@ type SyncResult struct {
 type SyncOptions struct {
 	Force  bool
 	DryRun bool
+	// FileParallel bounds concurrent file downloads within a source.
+	// Values below one download files sequentially.
+	FileParallel int
+	// Requests is a request budget shared by all sources of a run.
+	// Nil means downloads are only bounded by FileParallel.
+	Requests *semaphore.Weighted
+	// GitHubQuota is shared by all GitHub sources of a run and tracks each
+	// API host separately. Nil gives each source its own tracker that fails
+	// fast on an exhausted quota.
+	GitHubQuota *QuotaTracker
+	// Cache is the content-addressed cache shared across projects, consulted
+	// before files are downloaded. Nil disables it.
+	Cache *cache.Cache
 }
 
 // Source defines a documentation source that can be synced.
+//
+// When Sync fails after some files were written it may return a non-nil
+// result alongside the error; its LockEntry records the partial progress.
 type Source interface {
 	Sync(
 		ctx context.Context,
@@ -39,13 +57,31 @@ func New(name string, cfg config.Source, token string) (Source, error) {
 	switch cfg.Type {
 	case "github":
 		return NewGitHub(name, cfg, token)
+	case "gitlab":
+		return NewGitLab(name, cfg, token)
+	case "codeberg", "gitea", "forgejo":
+		return NewGitea(name, cfg, token)
+	case "git":
+		return NewGit(name, cfg, token)
 	case "url":
 		return NewURL(name, cfg)
+	case "local":
+		return NewLocal(name, cfg)
+	case "archive":
+		return NewArchive(name, cfg)
+	case "npm":
+		return NewNPM(name, cfg)
+	case "gomod":
+		return NewGoMod(name, cfg)
+	case "pypi":
+		return NewPyPI(name, cfg)
+	case "site":
+		return NewSite(name, cfg)
 	default:
 		return nil, oops.
 			Code("UNKNOWN_SOURCE_TYPE").
 			With("type", cfg.Type).
-			Hint("Supported types: github, url").
+			Hint("Supported types: github, gitlab, codeberg, gitea, forgejo, git, url, local, archive, npm, gomod, pypi, site").
 			Errorf("unknown source type %q for source %q", cfg.Type, name)
 	}
 }
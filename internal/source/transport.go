@@ -0,0 +1,205 @@
+package source
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"net/http"
+	neturl "net/url"
+	"os"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/samber/oops"
+	"golang.org/x/net/http/httpproxy"
+	"resty.dev/v3"
+
+	"github.com/g5becks/dox/internal/config"
+)
+
+// newHTTPClient returns a resty client with the http settings of a source.
+func newHTTPClient(sourceName string, settings config.HTTP) (*resty.Client, error) {
+	client := resty.New()
+	if err := applyHTTPSettings(client, sourceName, settings); err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	return client, nil
+}
+
+// redactURLError hides the credentials and token parameters of the URL a
+// request error carries, which net/http only strips of passwords, before
+// the error reaches a terminal or CI log.
+func redactURLError(err error) error {
+	var urlErr *neturl.Error
+	if errors.As(err, &urlErr) {
+		urlErr.URL = config.RedactURL(urlErr.URL)
+	}
+
+	return err
+}
+
+// applyHTTPSettings configures client with the proxy, TLS, timeout, and
+// retry settings of a source. It runs after a client's own defaults so the
+// configured max_retries replaces them.
+func applyHTTPSettings(client *resty.Client, sourceName string, settings config.HTTP) error {
+	if settings.Proxy != "" || settings.NoProxy != nil || settings.CABundle != "" ||
+		settings.ClientCert != "" || settings.ConnectTimeout > 0 {
+		transport, err := httpTransport(sourceName, settings)
+		if err != nil {
+			return err
+		}
+		client.SetTransport(transport)
+	}
+
+	if settings.Timeout > 0 {
+		client.SetTimeout(settings.Timeout)
+	}
+	if settings.MaxRetries != nil {
+		client.SetRetryCount(*settings.MaxRetries)
+	}
+
+	return nil
+}
+
+// httpTransport builds a transport that routes through the configured proxy,
+// trusts the configured CA bundle on top of the system roots, and presents
+// the configured client certificate.
+func httpTransport(sourceName string, settings config.HTTP) (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone() //nolint:forcetypeassert // Always an *http.Transport
+	transport.Proxy = proxyFunc(settings)
+
+	if settings.ConnectTimeout > 0 {
+		dialer := &net.Dialer{Timeout: settings.ConnectTimeout}
+		transport.DialContext = dialer.DialContext
+		transport.TLSHandshakeTimeout = settings.ConnectTimeout
+	}
+
+	files, err := loadTLSFiles(sourceName, settings)
+	if err != nil {
+		return nil, err
+	}
+
+	if files.caBundle != nil || files.clientCert != nil {
+		tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+		if files.caBundle != nil {
+			roots, poolErr := x509.SystemCertPool()
+			if poolErr != nil {
+				roots = x509.NewCertPool()
+			}
+			if !roots.AppendCertsFromPEM(files.caBundle) {
+				return nil, tlsError(sourceName, settings.CABundle, "ca_bundle contains no PEM certificates", nil)
+			}
+			tlsConfig.RootCAs = roots
+		}
+
+		if files.clientCert != nil {
+			certificate, pairErr := tls.X509KeyPair(files.clientCert, files.clientKey)
+			if pairErr != nil {
+				return nil, tlsError(sourceName, settings.ClientCert, "loading client certificate", pairErr)
+			}
+			tlsConfig.Certificates = []tls.Certificate{certificate}
+		}
+
+		transport.TLSClientConfig = tlsConfig
+	}
+
+	return transport, nil
+}
+
+// proxyFunc resolves the proxy of a request from the environment, with the
+// configured proxy and no_proxy taking precedence over HTTP_PROXY,
+// HTTPS_PROXY, and NO_PROXY.
+func proxyFunc(settings config.HTTP) func(*http.Request) (*neturl.URL, error) {
+	proxyConfig := httpproxy.FromEnvironment()
+	if settings.Proxy != "" {
+		proxyConfig.HTTPProxy, proxyConfig.HTTPSProxy = settings.Proxy, settings.Proxy
+	}
+	if settings.NoProxy != nil {
+		proxyConfig.NoProxy = strings.Join(settings.NoProxy, ",")
+	}
+
+	resolve := proxyConfig.ProxyFunc()
+	return func(req *http.Request) (*neturl.URL, error) {
+		return resolve(req.URL)
+	}
+}
+
+// tlsFiles holds the PEM files named by the http settings of a source.
+type tlsFiles struct {
+	caBundle   []byte
+	clientCert []byte
+	clientKey  []byte
+}
+
+func loadTLSFiles(sourceName string, settings config.HTTP) (tlsFiles, error) {
+	files := tlsFiles{}
+	for _, file := range []struct {
+		path   string
+		target *[]byte
+	}{
+		{settings.CABundle, &files.caBundle},
+		{settings.ClientCert, &files.clientCert},
+		{settings.ClientKey, &files.clientKey},
+	} {
+		if file.path == "" {
+			continue
+		}
+
+		content, err := os.ReadFile(file.path)
+		if err != nil {
+			return tlsFiles{}, tlsError(sourceName, file.path, "reading "+file.path, err)
+		}
+		*file.target = content
+	}
+
+	return files, nil
+}
+
+func tlsError(sourceName string, path string, message string, err error) error {
+	builder := oops.
+		Code("CONFIG_INVALID").
+		With("source", sourceName).
+		With("path", path).
+		Hint("Check the ca_bundle, client_cert, and client_key paths in the [http] config")
+	if err == nil {
+		return builder.Errorf("%s", message)
+	}
+
+	return builder.Wrapf(err, "%s", message)
+}
+
+// gitTransportOptions holds the http settings of a git source in the form
+// go-git takes them on clone and list requests.
+type gitTransportOptions struct {
+	tlsFiles
+
+	proxy transport.ProxyOptions
+}
+
+// newGitTransportOptions resolves the http settings of a git source for
+// its remote. The proxy is looked up for the remote itself, so no_proxy
+// applies as it does to the other clients.
+func newGitTransportOptions(sourceName string, remote string, settings config.HTTP) (gitTransportOptions, error) {
+	files, err := loadTLSFiles(sourceName, settings)
+	if err != nil {
+		return gitTransportOptions{}, err
+	}
+
+	options := gitTransportOptions{tlsFiles: files}
+
+	remoteURL, parseErr := neturl.Parse(remote)
+	if parseErr != nil || (remoteURL.Scheme != "http" && remoteURL.Scheme != "https") {
+		return options, nil
+	}
+
+	proxyURL, proxyErr := proxyFunc(settings)(&http.Request{URL: remoteURL})
+	if proxyErr == nil && proxyURL != nil {
+		options.proxy = transport.ProxyOptions{URL: proxyURL.String()}
+	}
+
+	return options, nil
+}
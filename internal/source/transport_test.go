@@ -0,0 +1,129 @@
+package source_test
+
+import (
+	"context"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/g5becks/dox/internal/config"
+	"github.com/g5becks/dox/internal/source"
+)
+
+func TestHTTPClientRoutesThroughProxy(t *testing.T) {
+	t.Parallel()
+
+	var proxied atomic.Value
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied.Store(r.URL.String())
+		_, _ = w.Write([]byte("via proxy"))
+	}))
+	defer proxy.Close()
+
+	client, err := source.NewHTTPClient("docs", config.HTTP{Proxy: proxy.URL})
+	if err != nil {
+		t.Fatalf("NewHTTPClient() error = %v", err)
+	}
+	defer client.Close()
+
+	response, err := client.R().SetContext(context.Background()).Get("http://docs.test/guide.md")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if response.String() != "via proxy" || proxied.Load() != "http://docs.test/guide.md" {
+		t.Fatalf("proxy saw %v and returned %q, want the request routed through it", proxied.Load(), response)
+	}
+
+	bypass, err := source.NewHTTPClient("docs", config.HTTP{Proxy: proxy.URL, NoProxy: []string{"direct.test"}})
+	if err != nil {
+		t.Fatalf("NewHTTPClient() error = %v", err)
+	}
+	defer bypass.Close()
+
+	proxied.Store("")
+	_, _ = bypass.R().SetContext(context.Background()).SetTimeout(time.Second).Get("http://direct.test/guide.md")
+	if proxied.Load() != "" {
+		t.Fatalf("proxy saw %v, want no_proxy hosts requested directly", proxied.Load())
+	}
+}
+
+func TestHTTPClientTrustsCABundle(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	bundle := filepath.Join(t.TempDir(), "ca.pem")
+	certificate := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(bundle, certificate, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	untrusted, err := source.NewHTTPClient("docs", config.HTTP{})
+	if err != nil {
+		t.Fatalf("NewHTTPClient() error = %v", err)
+	}
+	defer untrusted.Close()
+
+	if _, getErr := untrusted.R().SetContext(context.Background()).Get(server.URL); getErr == nil {
+		t.Fatalf("Get() without the CA bundle succeeded, want a certificate error")
+	}
+
+	trusted, err := source.NewHTTPClient("docs", config.HTTP{CABundle: bundle})
+	if err != nil {
+		t.Fatalf("NewHTTPClient() error = %v", err)
+	}
+	defer trusted.Close()
+
+	response, err := trusted.R().SetContext(context.Background()).Get(server.URL)
+	if err != nil || response.String() != "ok" {
+		t.Fatalf("Get() = %q, %v, want the server trusted through the CA bundle", response, err)
+	}
+
+	_, err = source.NewHTTPClient("docs", config.HTTP{CABundle: filepath.Join(t.TempDir(), "missing.pem")})
+	if err == nil || !strings.Contains(err.Error(), "missing.pem") {
+		t.Fatalf("NewHTTPClient() error = %v, want the missing bundle reported", err)
+	}
+}
+
+func TestHTTPClientAppliesRetriesAndTimeout(t *testing.T) {
+	t.Parallel()
+
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		if r.URL.Path == "/slow" {
+			time.Sleep(200 * time.Millisecond)
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	retries := 2
+	client, err := source.NewHTTPClient("docs", config.HTTP{MaxRetries: &retries, Timeout: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("NewHTTPClient() error = %v", err)
+	}
+	defer client.Close()
+	client.SetRetryWaitTime(time.Millisecond).SetRetryMaxWaitTime(time.Millisecond)
+
+	if _, getErr := client.R().SetContext(context.Background()).Get(server.URL + "/busy"); getErr != nil {
+		t.Fatalf("Get() error = %v", getErr)
+	}
+	if attempts.Load() != 3 {
+		t.Fatalf("attempts = %d, want the request and two retries", attempts.Load())
+	}
+
+	client.SetRetryCount(0)
+	if _, getErr := client.R().SetContext(context.Background()).Get(server.URL + "/slow"); getErr == nil {
+		t.Fatalf("Get() error = nil, want the timeout to abort the slow request")
+	}
+}
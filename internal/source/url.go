@@ -9,11 +9,13 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/samber/oops"
 	"resty.dev/v3"
 
+	"github.com/g5becks/dox/internal/cache"
 	"github.com/g5becks/dox/internal/config"
 	"github.com/g5becks/dox/internal/lockfile"
 )
@@ -22,7 +24,14 @@ type urlSource struct {
 	name     string
 	source   config.Source
 	filename string
-	client   *resty.Client
+	// files maps the relative path of each file listed in urls to its URL.
+	files map[string]string
+	// pin is the sha256 the file at url must have, if any.
+	pin string
+	// headers are sent, with any credentials, to the hosts in hosts only.
+	headers map[string]string
+	hosts   map[string]bool
+	client  *resty.Client
 }
 
 func NewURL(name string, cfg config.Source) (Source, error) {
@@ -31,14 +40,84 @@ func NewURL(name string, cfg config.Source) (Source, error) {
 		filename = filenameFromURL(name, cfg.URL)
 	}
 
-	client := resty.New()
+	files, err := urlListFiles(name, cfg.URLs)
+	if err != nil {
+		return nil, err
+	}
+
+	headers, err := urlHeaders(name, cfg)
+	if err != nil {
+		return nil, err
+	}
 
-	return &urlSource{
+	src := &urlSource{
 		name:     name,
 		source:   cfg,
 		filename: filename,
-		client:   client,
-	}, nil
+		files:    files,
+		pin:      strings.ToLower(cfg.SHA256),
+		headers:  headers,
+		hosts:    credentialHosts(cfg),
+	}
+	src.useClient(resty.New())
+	if httpErr := applyHTTPSettings(src.client, name, cfg.HTTP); httpErr != nil {
+		return nil, httpErr
+	}
+
+	return src, nil
+}
+
+// useClient configures client with the User-Agent and retry policy the
+// forge API clients use, and a redirect policy that keeps the configured
+// headers from following a redirect to another host.
+func (s *urlSource) useClient(client *resty.Client) {
+	client.SetHeader("User-Agent", userAgent)
+	client.SetRetryCount(httpRetryCount)
+	client.SetRetryWaitTime(1 * time.Second)
+	client.SetRetryMaxWaitTime(httpRetryMaxWaitSec * time.Second)
+	client.SetRedirectPolicy(resty.RedirectPolicyFunc(s.checkRedirect))
+
+	s.client = client
+}
+
+// urlListFiles maps the entries of urls to the paths they are written to.
+// Two entries may not share a URL or a path.
+func urlListFiles(sourceName string, entries []config.URLFile) (map[string]string, error) {
+	files := make(map[string]string, len(entries))
+	urls := make(map[string]bool, len(entries))
+
+	for _, entry := range entries {
+		filename := entry.Filename
+		if filename == "" {
+			filename = filenameFromURL(sourceName, entry.URL)
+		}
+
+		relativePath, ok := cleanArchivePath(filename)
+		if !ok {
+			return nil, oops.
+				Code("CONFIG_INVALID").
+				With("source", sourceName).
+				With("filename", filename).
+				Hint("filename must be a relative path inside the source directory").
+				Errorf("invalid filename %q in urls of source %q", filename, sourceName)
+		}
+
+		if _, taken := files[relativePath]; taken || urls[entry.URL] {
+			return nil, oops.
+				Code("CONFIG_INVALID").
+				With("source", sourceName).
+				With("url", config.RedactURL(entry.URL)).
+				With("filename", relativePath).
+				Hint("Give each urls entry its own url and a distinct filename").
+				Errorf("urls of source %q list %s or %q more than once",
+					sourceName, config.RedactURL(entry.URL), relativePath)
+		}
+
+		files[relativePath] = entry.URL
+		urls[entry.URL] = true
+	}
+
+	return files, nil
 }
 
 func (s *urlSource) Close() error {
@@ -51,22 +130,29 @@ func (s *urlSource) Sync(
 	prevLock *lockfile.LockEntry,
 	opts SyncOptions,
 ) (*SyncResult, error) {
-	request := s.client.R().SetContext(ctx)
-	if !opts.Force && prevLock != nil {
-		if prevLock.ETag != "" {
-			request.SetHeader("If-None-Match", prevLock.ETag)
-		}
-		if prevLock.LastMod != "" {
-			request.SetHeader("If-Modified-Since", prevLock.LastMod)
-		}
+	if len(s.files) > 0 {
+		return s.syncFiles(ctx, destDir, prevLock, opts)
+	}
+
+	if s.source.Expand {
+		return s.syncIndex(ctx, destDir, prevLock, opts)
 	}
 
-	response, err := request.Get(s.source.URL)
+	if result, ok := s.linkPinned(destDir, prevLock, opts); ok {
+		return result, nil
+	}
+
+	var etag, lastMod string
+	if !opts.Force && prevLock != nil && revalidates(prevLock.Files[s.filename], s.pin) {
+		etag, lastMod = prevLock.ETag, prevLock.LastMod
+	}
+
+	response, err := s.conditionalGet(ctx, s.source.URL, etag, lastMod)
 	if err != nil {
 		return nil, oops.
 			Code("DOWNLOAD_FAILED").
 			With("source", s.name).
-			With("url", s.source.URL).
+			With("url", config.RedactURL(s.source.URL)).
 			Wrapf(err, "downloading url source")
 	}
 
@@ -80,6 +166,9 @@ func (s *urlSource) Sync(
 
 		lock.Type = "url"
 		lock.SyncedAt = time.Now().UTC()
+		if _, ok := lock.Files[s.filename]; !ok {
+			lock.Files = s.hashExisting(destDir)
+		}
 
 		return &SyncResult{
 			Skipped:   true,
@@ -91,33 +180,30 @@ func (s *urlSource) Sync(
 		return nil, oops.
 			Code("DOWNLOAD_FAILED").
 			With("source", s.name).
-			With("url", s.source.URL).
+			With("url", config.RedactURL(s.source.URL)).
 			With("status", response.StatusCode()).
 			Errorf("url source returned non-success status %d", response.StatusCode())
 	}
 
-	filePath := filepath.Join(destDir, s.filename)
 	content, err := io.ReadAll(response.Body)
 	if err != nil {
 		return nil, oops.
 			Code("DOWNLOAD_FAILED").
 			With("source", s.name).
-			With("url", s.source.URL).
+			With("url", config.RedactURL(s.source.URL)).
 			Wrapf(err, "reading response body")
 	}
 
-	if !opts.DryRun {
-		if mkdirErr := os.MkdirAll(destDir, 0o750); mkdirErr != nil {
-			return nil, oops.
-				Code("WRITE_FAILED").
-				With("source", s.name).
-				With("path", destDir).
-				Wrapf(mkdirErr, "creating destination directory")
-		}
+	sha := contentSHA256(content)
+	if pinErr := s.checkPin(s.source.URL, s.pin, sha); pinErr != nil {
+		return nil, pinErr
+	}
 
-		if writeErr := writeFileAtomic(filePath, content); writeErr != nil {
+	if !opts.DryRun {
+		if writeErr := s.writeFile(destDir, content); writeErr != nil {
 			return nil, writeErr
 		}
+		cacheFile(opts, destDir, s.filename, cache.SHA256Key(sha))
 	}
 
 	return &SyncResult{
@@ -127,10 +213,129 @@ func (s *urlSource) Sync(
 			ETag:     response.Header().Get("ETag"),
 			LastMod:  response.Header().Get("Last-Modified"),
 			SyncedAt: time.Now().UTC(),
+			Files:    map[string]string{s.filename: sha},
 		},
 	}, nil
 }
 
+// syncFiles downloads the files listed in urls concurrently, each with its
+// own validators, and deletes the files of entries that were removed.
+func (s *urlSource) syncFiles(
+	ctx context.Context,
+	destDir string,
+	prevLock *lockfile.LockEntry,
+	opts SyncOptions,
+) (*SyncResult, error) {
+	links := make(map[string]mirrorLink, len(s.files))
+	for _, file := range s.source.URLs {
+		links[file.URL] = mirrorLink{url: file.URL, sha256: strings.ToLower(file.SHA256)}
+	}
+
+	entry := &lockfile.LockEntry{
+		Type:     "url",
+		SyncedAt: time.Now().UTC(),
+	}
+
+	return newURLMirror(s, destDir, prevLock, opts).run(ctx, entry, s.files, links)
+}
+
+// linkPinned places the pinned file of a single url source from the shared
+// cache without a request. Like the files of urls, it is only consulted when
+// the pin changed since the last sync.
+func (s *urlSource) linkPinned(
+	destDir string,
+	prevLock *lockfile.LockEntry,
+	opts SyncOptions,
+) (*SyncResult, bool) {
+	if s.pin == "" || opts.DryRun || (!opts.Force && prevLock != nil && prevLock.Files[s.filename] == s.pin) {
+		return nil, false
+	}
+
+	if !linkCachedFile(opts, destDir, s.filename, cache.SHA256Key(s.pin)) {
+		return nil, false
+	}
+
+	return &SyncResult{
+		Downloaded: 1,
+		LockEntry: &lockfile.LockEntry{
+			Type:     "url",
+			SyncedAt: time.Now().UTC(),
+			Files:    map[string]string{s.filename: s.pin},
+		},
+	}, true
+}
+
+// writeFile writes the file of a single url source.
+func (s *urlSource) writeFile(destDir string, content []byte) error {
+	if err := os.MkdirAll(destDir, 0o750); err != nil {
+		return oops.
+			Code("WRITE_FAILED").
+			With("source", s.name).
+			With("path", destDir).
+			Wrapf(err, "creating destination directory")
+	}
+
+	return writeFileAtomic(filepath.Join(destDir, s.filename), content)
+}
+
+// hashExisting records the file of a lock entry written before url sources
+// recorded hashes, so an unchanged file still gets one.
+func (s *urlSource) hashExisting(destDir string) map[string]string {
+	content, err := os.ReadFile(filepath.Join(destDir, s.filename))
+	if err != nil {
+		return nil
+	}
+
+	return map[string]string{s.filename: contentSHA256(content)}
+}
+
+// revalidates reports whether a file downloaded before with sha may be
+// fetched conditionally. A pinned file whose recorded hash differs from the
+// pin is downloaded again so the pin is checked.
+func revalidates(sha string, pin string) bool {
+	return pin == "" || sha == pin
+}
+
+// checkPin fails when a file pinned with sha256 downloaded with another
+// hash.
+func (s *urlSource) checkPin(rawURL string, pin string, sha string) error {
+	if pin == "" || sha == pin {
+		return nil
+	}
+
+	return oops.
+		Code("INTEGRITY_MISMATCH").
+		With("source", s.name).
+		With("url", config.RedactURL(rawURL)).
+		With("expected", pin).
+		With("actual", sha).
+		Hint("The file changed upstream; review it and update sha256 in the config").
+		Errorf("sha256 check failed for %s", config.RedactURL(rawURL))
+}
+
+// conditionalGet requests rawURL, sending the validators of a previous
+// download so the server can answer 304 Not Modified.
+func (s *urlSource) conditionalGet(
+	ctx context.Context,
+	rawURL string,
+	etag string,
+	lastMod string,
+) (*resty.Response, error) {
+	request := s.client.R().SetContext(ctx)
+	if s.sendsHeaders(rawURL) {
+		request.SetHeaders(s.headers)
+	}
+	if etag != "" {
+		request.SetHeader("If-None-Match", etag)
+	}
+	if lastMod != "" {
+		request.SetHeader("If-Modified-Since", lastMod)
+	}
+
+	response, err := request.Get(rawURL)
+	return response, redactURLError(err)
+}
+
 func filenameFromURL(sourceName string, rawURL string) string {
 	parsed, err := neturl.Parse(rawURL)
 	if err == nil {
@@ -193,6 +398,9 @@ func cloneLockEntry(entry *lockfile.LockEntry) *lockfile.LockEntry {
 		cloned.Files = make(map[string]string, len(entry.Files))
 		maps.Copy(cloned.Files, entry.Files)
 	}
+	if entry.Pages != nil {
+		cloned.Pages = maps.Clone(entry.Pages)
+	}
 
 	return &cloned
 }
@@ -0,0 +1,126 @@
+package source
+
+import (
+	"encoding/base64"
+	"maps"
+	"net/http"
+	neturl "net/url"
+	"os"
+	"strings"
+
+	"github.com/samber/oops"
+
+	"github.com/g5becks/dox/internal/config"
+)
+
+// maxURLRedirects matches the redirect limit of net/http.
+const maxURLRedirects = 10
+
+// urlHeaders returns the headers a url source sends: its configured headers
+// plus the Authorization or API-key header of its auth table. Secrets named
+// by token_env and password_env are read from the environment here, so a
+// missing variable fails before anything is downloaded.
+func urlHeaders(sourceName string, cfg config.Source) (map[string]string, error) {
+	headers := make(map[string]string, len(cfg.Headers)+1)
+	maps.Copy(headers, cfg.Headers)
+
+	auth := cfg.Auth
+	if auth.Type == "" {
+		return headers, nil
+	}
+
+	if auth.Type == "basic" {
+		password, err := authSecret(sourceName, "password", auth.Password, auth.PasswordEnv)
+		if err != nil {
+			return nil, err
+		}
+		credentials := base64.StdEncoding.EncodeToString([]byte(auth.Username + ":" + password))
+		headers["Authorization"] = "Basic " + credentials
+
+		return headers, nil
+	}
+
+	token, err := authSecret(sourceName, "token", auth.Token, auth.TokenEnv)
+	if err != nil {
+		return nil, err
+	}
+
+	if auth.Type == "header" {
+		headers[auth.Header] = token
+	} else {
+		headers["Authorization"] = "Bearer " + token
+	}
+
+	return headers, nil
+}
+
+// authSecret returns a literal secret, or the value of the environment
+// variable named by envVar.
+func authSecret(sourceName string, field string, literal string, envVar string) (string, error) {
+	if envVar == "" {
+		return literal, nil
+	}
+
+	value, ok := os.LookupEnv(envVar)
+	if !ok || value == "" {
+		return "", oops.
+			Code("AUTH_MISSING").
+			With("source", sourceName).
+			With("env", envVar).
+			Hint("Export "+envVar+" before running dox").
+			Errorf("environment variable %s for the auth %s of source %q is not set", envVar, field, sourceName)
+	}
+
+	return value, nil
+}
+
+// credentialHosts returns the hosts of the configured URLs of a url source.
+// Headers and credentials are only sent to these hosts, so files an
+// llms.txt index links to on other hosts never receive them.
+func credentialHosts(cfg config.Source) map[string]bool {
+	hosts := map[string]bool{}
+
+	rawURLs := []string{cfg.URL}
+	for _, file := range cfg.URLs {
+		rawURLs = append(rawURLs, file.URL)
+	}
+
+	for _, rawURL := range rawURLs {
+		if parsed, err := neturl.Parse(rawURL); err == nil && parsed.Host != "" {
+			hosts[strings.ToLower(parsed.Host)] = true
+		}
+	}
+
+	return hosts
+}
+
+// sendsHeaders reports whether the configured headers go with a request
+// for rawURL.
+func (s *urlSource) sendsHeaders(rawURL string) bool {
+	if len(s.headers) == 0 {
+		return false
+	}
+
+	parsed, err := neturl.Parse(rawURL)
+	return err == nil && s.hosts[strings.ToLower(parsed.Host)]
+}
+
+// checkRedirect drops the configured headers when a redirect leaves the
+// configured hosts.
+func (s *urlSource) checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= maxURLRedirects {
+		return oops.
+			Code("DOWNLOAD_FAILED").
+			With("source", s.name).
+			With("url", config.RedactURL(via[0].URL.String())).
+			Errorf("stopped after %d redirects", maxURLRedirects)
+	}
+
+	if !s.hosts[strings.ToLower(req.URL.Host)] {
+		for key := range s.headers {
+			req.Header.Del(key)
+		}
+	}
+
+	return nil
+}
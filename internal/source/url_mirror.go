@@ -0,0 +1,272 @@
+package source
+
+import (
+	"context"
+	"io"
+	"maps"
+	"net/http"
+	stdsync "sync"
+	"time"
+
+	"github.com/samber/oops"
+
+	"github.com/g5becks/dox/internal/cache"
+	"github.com/g5becks/dox/internal/config"
+	"github.com/g5becks/dox/internal/lockfile"
+)
+
+// mirrorLink is a remote file mirrored by a url source, with the section,
+// title, and description an llms.txt index gives it, or the sha256 its urls
+// entry pins.
+type mirrorLink struct {
+	url         string
+	section     string
+	title       string
+	description string
+	sha256      string
+}
+
+// urlMirror downloads a set of remote files into a url source's directory:
+// the files listed in urls, or the resources an llms.txt index links to.
+// Every file is fetched with its own validators and recorded in the lock
+// entry's Pages, so a sync only downloads what changed. It is shared by the
+// concurrent fetches.
+type urlMirror struct {
+	source   *urlSource
+	destDir  string
+	opts     SyncOptions
+	synced   bool
+	oldFiles map[string]string
+	oldPages map[string]*lockfile.PageEntry
+
+	mu      stdsync.Mutex
+	files   map[string]string
+	pages   map[string]*lockfile.PageEntry
+	written int
+}
+
+func newURLMirror(
+	s *urlSource,
+	destDir string,
+	prevLock *lockfile.LockEntry,
+	opts SyncOptions,
+) *urlMirror {
+	mirror := &urlMirror{
+		source:   s,
+		destDir:  destDir,
+		opts:     opts,
+		synced:   prevLock != nil,
+		oldFiles: map[string]string{},
+		oldPages: map[string]*lockfile.PageEntry{},
+		files:    map[string]string{},
+		pages:    map[string]*lockfile.PageEntry{},
+	}
+
+	if prevLock != nil {
+		if prevLock.Files != nil {
+			mirror.oldFiles = prevLock.Files
+		}
+		if prevLock.Pages != nil {
+			mirror.oldPages = prevLock.Pages
+		}
+	}
+
+	return mirror
+}
+
+// run downloads every file in toDownload, keyed by relative path, deletes
+// the files of the previous sync that are no longer listed, and completes
+// entry with the mirrored files and pages.
+func (m *urlMirror) run(
+	ctx context.Context,
+	entry *lockfile.LockEntry,
+	toDownload map[string]string,
+	links map[string]mirrorLink,
+) (*SyncResult, error) {
+	fetch := func(ctx context.Context, relativePath string, rawURL string) error {
+		return m.fetch(ctx, relativePath, links[rawURL])
+	}
+
+	if _, fetchErr := fetchConcurrently(ctx, m.opts, toDownload, fetch); fetchErr != nil {
+		return m.partialResult(toDownload), fetchErr
+	}
+
+	toDelete := diffDeletes(m.oldFiles, m.files)
+	if !m.opts.DryRun {
+		if deleteErr := deleteStaleFiles(m.source.name, m.destDir, toDelete); deleteErr != nil {
+			return nil, deleteErr
+		}
+	}
+
+	entry.Files = m.files
+	entry.Pages = m.pages
+
+	return &SyncResult{
+		Downloaded: m.written,
+		Deleted:    len(toDelete),
+		Skipped:    m.synced && m.written == 0 && len(toDelete) == 0,
+		LockEntry:  entry,
+	}, nil
+}
+
+// fetch mirrors one file, conditionally when it was downloaded before.
+func (m *urlMirror) fetch(ctx context.Context, relativePath string, link mirrorLink) error {
+	if m.linkPinned(relativePath, link) {
+		return nil
+	}
+
+	prev := m.oldPages[link.url]
+
+	var etag, lastMod string
+	if !m.opts.Force && prev != nil && prev.Path == relativePath &&
+		revalidates(m.oldFiles[relativePath], link.sha256) {
+		etag, lastMod = prev.ETag, prev.LastMod
+	}
+
+	response, err := m.source.conditionalGet(ctx, link.url, etag, lastMod)
+	if err != nil {
+		return oops.
+			Code("DOWNLOAD_FAILED").
+			With("source", m.source.name).
+			With("url", config.RedactURL(link.url)).
+			Wrapf(err, "downloading %s", config.RedactURL(link.url))
+	}
+
+	page := &lockfile.PageEntry{
+		Path:        relativePath,
+		ETag:        response.Header().Get("ETag"),
+		LastMod:     response.Header().Get("Last-Modified"),
+		Section:     link.section,
+		Title:       link.title,
+		Description: link.description,
+	}
+
+	if response.StatusCode() == http.StatusNotModified && etag+lastMod != "" {
+		page.ETag, page.LastMod = prev.ETag, prev.LastMod
+		m.record(link.url, page, m.oldFiles[relativePath])
+		return nil
+	}
+
+	if !response.IsSuccess() {
+		return oops.
+			Code("DOWNLOAD_FAILED").
+			With("source", m.source.name).
+			With("url", config.RedactURL(link.url)).
+			With("status", response.StatusCode()).
+			Errorf("%s returned non-success status %d", config.RedactURL(link.url), response.StatusCode())
+	}
+
+	content, err := io.ReadAll(response.Body)
+	if err != nil {
+		return oops.
+			Code("DOWNLOAD_FAILED").
+			With("source", m.source.name).
+			With("url", config.RedactURL(link.url)).
+			Wrapf(err, "reading response body")
+	}
+
+	sha := contentSHA256(content)
+	if pinErr := m.source.checkPin(link.url, link.sha256, sha); pinErr != nil {
+		return pinErr
+	}
+
+	if storeErr := m.store(relativePath, content, sha); storeErr != nil {
+		return storeErr
+	}
+
+	m.record(link.url, page, "")
+	return nil
+}
+
+// linkPinned places a pinned file that the shared cache holds without a
+// request. It is only consulted when the pin changed since the last sync, so
+// an unchanged pin still revalidates against the server.
+func (m *urlMirror) linkPinned(relativePath string, link mirrorLink) bool {
+	if link.sha256 == "" || m.opts.DryRun || (!m.opts.Force && m.oldFiles[relativePath] == link.sha256) {
+		return false
+	}
+
+	if !linkCachedFile(m.opts, m.destDir, relativePath, cache.SHA256Key(link.sha256)) {
+		return false
+	}
+
+	m.mu.Lock()
+	m.written++
+	m.mu.Unlock()
+
+	m.record(link.url, &lockfile.PageEntry{
+		Path:        relativePath,
+		Section:     link.section,
+		Title:       link.title,
+		Description: link.description,
+	}, link.sha256)
+	return true
+}
+
+// store records a downloaded file and writes it when its content changed.
+// Written files are added to the shared cache by their sha256.
+func (m *urlMirror) store(relativePath string, content []byte, sha string) error {
+	m.mu.Lock()
+	m.files[relativePath] = sha
+	changed := m.opts.Force || m.oldFiles[relativePath] != sha
+	if changed {
+		m.written++
+	}
+	m.mu.Unlock()
+
+	if !changed || m.opts.DryRun {
+		return nil
+	}
+
+	if err := writeSourceFile(m.source.name, m.destDir, relativePath, content); err != nil {
+		return err
+	}
+
+	cacheFile(m.opts, m.destDir, relativePath, cache.SHA256Key(sha))
+	return nil
+}
+
+// record adds a page to the lock entry. A non-empty sha also records the
+// file, for pages that were not downloaded again.
+func (m *urlMirror) record(rawURL string, page *lockfile.PageEntry, sha string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.pages[rawURL] = page
+	if sha != "" {
+		m.files[page.Path] = sha
+	}
+}
+
+// partialResult records the progress of a mirror whose downloads partly
+// failed. Failed files keep their previous file and page, and the entry
+// carries no index validators so the next sync reads an llms.txt index
+// again.
+func (m *urlMirror) partialResult(toDownload map[string]string) *SyncResult {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	files := maps.Clone(m.files)
+	pages := maps.Clone(m.pages)
+	for relativePath, rawURL := range toDownload {
+		if _, done := pages[rawURL]; done {
+			continue
+		}
+		if sha, ok := m.oldFiles[relativePath]; ok {
+			files[relativePath] = sha
+		}
+		if prev, ok := m.oldPages[rawURL]; ok {
+			pages[rawURL] = prev
+		}
+	}
+
+	return &SyncResult{
+		Downloaded: m.written,
+		LockEntry: &lockfile.LockEntry{
+			Type:     "url",
+			SyncedAt: time.Now().UTC(),
+			Files:    files,
+			Pages:    pages,
+		},
+	}
+}
@@ -0,0 +1,58 @@
+package source
+
+import (
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/samber/oops"
+)
+
+const (
+	// tagsPerPage is the page size for listing repository tags.
+	tagsPerPage = 100
+	// maxTagPages bounds tag listings at 10,000 tags.
+	maxTagPages = 100
+)
+
+// matchVersionTag picks the tag a git hosting source syncs for its version
+// constraint: the highest tag that parses as a semantic version, with or
+// without a leading "v", and satisfies the constraint. Prereleases only
+// match constraints that name a prerelease.
+func matchVersionTag(sourceName string, repo string, requested string, tags []string) (string, error) {
+	requested = strings.TrimSpace(requested)
+	constraint, err := semver.NewConstraint(requested)
+	if err != nil {
+		return "", oops.
+			Code("CONFIG_INVALID").
+			With("source", sourceName).
+			With("version", requested).
+			Hint("Use a semver range like \"^5.0\" or \"~1.4.2\", or set ref to a branch or tag instead").
+			Wrapf(err, "invalid version %q", requested)
+	}
+
+	var best *semver.Version
+	bestTag := ""
+	for _, tag := range tags {
+		candidate, parseErr := semver.NewVersion(tag)
+		if parseErr != nil || !constraint.Check(candidate) {
+			continue
+		}
+
+		if best == nil || candidate.GreaterThan(best) {
+			best = candidate
+			bestTag = tag
+		}
+	}
+
+	if best == nil {
+		return "", oops.
+			Code("VERSION_NOT_FOUND").
+			With("source", sourceName).
+			With("repo", repo).
+			With("version", requested).
+			Hint("Check the tags of the repository; only tags named like v1.2.3 or 1.2.3 are considered").
+			Errorf("no tag of %s satisfies %q", repo, requested)
+	}
+
+	return bestTag, nil
+}
@@ -0,0 +1,266 @@
+package sync
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io/fs"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	stdsync "sync"
+
+	"github.com/samber/oops"
+
+	"github.com/g5becks/dox/internal/config"
+)
+
+// credentialStore resolves the token each source authenticates with. A
+// [[credentials]] entry for the source's host wins, then the global token of
+// its hosting type on that type's public host, then the host's ~/.netrc
+// entry. Global tokens are never sent to other hosts, so a github.com token
+// does not leak to a GitHub Enterprise server. Helper lookups are cached
+// per host so sources on one host run git or read ~/.netrc only once.
+type credentialStore struct {
+	cfg       *config.Config
+	netrcPath string
+
+	mu     stdsync.Mutex
+	tokens map[string]string
+}
+
+func newCredentialStore(cfg *config.Config) *credentialStore {
+	return &credentialStore{
+		cfg:       cfg,
+		netrcPath: netrcPath(),
+		tokens:    map[string]string{},
+	}
+}
+
+// token returns the token for a source, or "" when it has none.
+func (c *credentialStore) token(ctx context.Context, sourceName string, sourceCfg config.Source) (string, error) {
+	host := sourceHost(sourceCfg)
+	if host == "" {
+		return "", nil
+	}
+
+	for _, credential := range c.cfg.Credentials {
+		if strings.EqualFold(credential.Host, host) {
+			return c.credentialToken(ctx, sourceName, host, credential)
+		}
+	}
+
+	if host == config.DefaultHost(sourceCfg.Type) {
+		if token := resolveSourceToken(c.cfg, sourceCfg); token != "" {
+			return token, nil
+		}
+	}
+
+	return c.cached("netrc", host, func() (string, error) {
+		return netrcToken(c.netrcPath, host)
+	})
+}
+
+func (c *credentialStore) credentialToken(
+	ctx context.Context,
+	sourceName string,
+	host string,
+	credential config.Credential,
+) (string, error) {
+	switch {
+	case credential.TokenEnv != "":
+		token := os.Getenv(credential.TokenEnv)
+		if token == "" {
+			return "", oops.
+				Code("AUTH_MISSING").
+				With("source", sourceName).
+				With("host", host).
+				With("env", credential.TokenEnv).
+				Hint("Export "+credential.TokenEnv+" before running dox").
+				Errorf("environment variable %s for the credentials of %s is not set", credential.TokenEnv, host)
+		}
+		return token, nil
+	case credential.Helper == "git":
+		return c.cached("git", host, func() (string, error) {
+			return gitCredentialToken(ctx, host)
+		})
+	case credential.Helper == "netrc":
+		return c.cached("netrc", host, func() (string, error) {
+			return netrcToken(c.netrcPath, host)
+		})
+	default:
+		return credential.Token, nil
+	}
+}
+
+// cached runs lookup once per helper and host. Errors are not cached, so a
+// failed lookup is retried by the next source on the host.
+func (c *credentialStore) cached(helper string, host string, lookup func() (string, error)) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := helper + "\x00" + host
+	if token, ok := c.tokens[key]; ok {
+		return token, nil
+	}
+
+	token, err := lookup()
+	if err != nil {
+		return "", err
+	}
+
+	c.tokens[key] = token
+	return token, nil
+}
+
+// sourceHost returns the host a git hosting source authenticates against:
+// the host of its clone URL, or its configured host. Sources of other types,
+// and git sources cloned over ssh or from disk, have no host.
+func sourceHost(sourceCfg config.Source) string {
+	switch sourceCfg.Type {
+	case "github", "gitlab", "codeberg", "gitea", "forgejo", "git":
+	default:
+		return ""
+	}
+
+	if sourceCfg.URL == "" {
+		return strings.ToLower(sourceCfg.Host)
+	}
+
+	parsed, err := url.Parse(sourceCfg.URL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return ""
+	}
+
+	return strings.ToLower(parsed.Host)
+}
+
+// gitCredentialToken asks git's configured credential helpers for the
+// password of host. Prompting is disabled, so a host without stored
+// credentials fails instead of waiting for input.
+func gitCredentialToken(ctx context.Context, host string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", "credential", "fill")
+	cmd.Stdin = strings.NewReader("protocol=https\nhost=" + host + "\n\n")
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0", "GCM_INTERACTIVE=never")
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	output, err := cmd.Output()
+	if err != nil {
+		return "", oops.
+			Code("AUTH_MISSING").
+			With("host", host).
+			With("stderr", strings.TrimSpace(stderr.String())).
+			Hint("Store credentials for the host with 'git credential approve' or your credential manager").
+			Wrapf(err, "git credential helper has no credentials for %s", host)
+	}
+
+	for line := range strings.Lines(string(output)) {
+		if password, ok := strings.CutPrefix(strings.TrimRight(line, "\r\n"), "password="); ok {
+			return password, nil
+		}
+	}
+
+	return "", oops.
+		Code("AUTH_MISSING").
+		With("host", host).
+		Hint("Store credentials for the host with 'git credential approve' or your credential manager").
+		Errorf("git credential helper returned no password for %s", host)
+}
+
+// netrcPath returns the netrc file named by $NETRC, or ~/.netrc.
+func netrcPath() string {
+	if path := os.Getenv("NETRC"); path != "" {
+		return path
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(home, ".netrc")
+}
+
+// netrcToken returns the password of the machine entry for host in the
+// netrc file at path. A missing file or entry yields no token. The default
+// entry is ignored, so a catch-all password is never sent to an arbitrary
+// host.
+func netrcToken(path string, host string) (string, error) {
+	if path == "" {
+		return "", nil
+	}
+
+	content, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", oops.
+			Code("AUTH_MISSING").
+			With("path", path).
+			Wrapf(err, "reading netrc file")
+	}
+
+	return parseNetrc(content)[strings.ToLower(host)], nil
+}
+
+// parseNetrc maps the machine names of a netrc file to their passwords.
+func parseNetrc(content []byte) map[string]string {
+	passwords := map[string]string{}
+
+	tokens := netrcTokens(content)
+	machine := ""
+	for index := 0; index < len(tokens); index++ {
+		keyword := tokens[index]
+		if keyword == "default" {
+			machine = ""
+			continue
+		}
+		if index+1 == len(tokens) {
+			break
+		}
+
+		// Every other keyword takes a value: machine, login, password, account.
+		index++
+		switch keyword {
+		case "machine":
+			machine = strings.ToLower(tokens[index])
+		case "password":
+			if _, seen := passwords[machine]; machine != "" && !seen {
+				passwords[machine] = tokens[index]
+			}
+		}
+	}
+
+	return passwords
+}
+
+// netrcTokens splits a netrc file into its whitespace separated tokens,
+// leaving out macro definitions, which run from macdef to the next blank
+// line.
+func netrcTokens(content []byte) []string {
+	tokens := []string{}
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	inMacro := false
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if inMacro {
+			inMacro = len(fields) > 0
+			continue
+		}
+
+		if macro := slices.Index(fields, "macdef"); macro >= 0 {
+			fields, inMacro = fields[:macro], true
+		}
+		tokens = append(tokens, fields...)
+	}
+
+	return tokens
+}
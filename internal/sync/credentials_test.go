@@ -0,0 +1,195 @@
+package sync_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/g5becks/dox/internal/config"
+	"github.com/g5becks/dox/internal/sync"
+)
+
+func TestResolveCredentialRoutesByHost(t *testing.T) {
+	netrc := filepath.Join(t.TempDir(), "netrc")
+	if err := os.WriteFile(netrc, []byte("machine git.example.com login dox password netrc-token\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("NETRC", netrc)
+	t.Setenv("GITHUB_TOKEN", "")
+	t.Setenv("GH_TOKEN", "")
+	t.Setenv("GITLAB_TOKEN", "")
+	t.Setenv("GHE_TOKEN", "enterprise-token")
+
+	cfg := &config.Config{
+		GitHubToken: "public-token",
+		Credentials: []config.Credential{
+			{Host: "GitHub.Example.com", TokenEnv: "GHE_TOKEN"},
+			{Host: "gitlab.internal", Token: "gitlab-token"},
+		},
+	}
+
+	tests := []struct {
+		name   string
+		source config.Source
+		want   string
+	}{
+		{
+			name:   "credentials entry for an enterprise host",
+			source: config.Source{Type: "github", Host: "github.example.com"},
+			want:   "enterprise-token",
+		},
+		{
+			name:   "global token for a host without an entry",
+			source: config.Source{Type: "github", Host: "github.com"},
+			want:   "public-token",
+		},
+		{
+			name:   "credentials entry for a self-hosted gitlab",
+			source: config.Source{Type: "gitlab", Host: "gitlab.internal"},
+			want:   "gitlab-token",
+		},
+		{
+			name:   "netrc for a git clone URL",
+			source: config.Source{Type: "git", URL: "https://git.example.com/team/docs.git"},
+			want:   "netrc-token",
+		},
+		{
+			name:   "no token for an ssh clone URL",
+			source: config.Source{Type: "git", URL: "ssh://git@git.example.com/team/docs.git"},
+			want:   "",
+		},
+		{
+			name:   "no token for url sources",
+			source: config.Source{Type: "url", URL: "https://git.example.com/llms.txt"},
+			want:   "",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := sync.ResolveCredential(cfg, tc.source)
+			if err != nil {
+				t.Fatalf("ResolveCredential() error = %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("ResolveCredential() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestResolveCredentialMissingTokenEnv(t *testing.T) {
+	t.Setenv("GHE_TOKEN", "")
+	cfg := &config.Config{
+		Credentials: []config.Credential{{Host: "github.example.com", TokenEnv: "GHE_TOKEN"}},
+	}
+
+	_, err := sync.ResolveCredential(cfg, config.Source{Type: "github", Host: "github.example.com"})
+	if err == nil || !strings.Contains(err.Error(), "GHE_TOKEN") {
+		t.Fatalf("ResolveCredential() error = %v, want one naming GHE_TOKEN", err)
+	}
+}
+
+func TestResolveCredentialKeepsGlobalTokensOnPublicHosts(t *testing.T) {
+	netrc := filepath.Join(t.TempDir(), "netrc")
+	if err := os.WriteFile(netrc, []byte("machine gitlab.internal login dox password netrc-token\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("NETRC", netrc)
+	t.Setenv("GITHUB_TOKEN", "github-token")
+	t.Setenv("GITLAB_TOKEN", "gitlab-token")
+	t.Setenv("GITEA_TOKEN", "gitea-token")
+
+	cfg := &config.Config{}
+
+	tests := []struct {
+		name   string
+		source config.Source
+		want   string
+	}{
+		{
+			name:   "github.com gets GITHUB_TOKEN",
+			source: config.Source{Type: "github", Host: "github.com"},
+			want:   "github-token",
+		},
+		{
+			name:   "enterprise host gets no GITHUB_TOKEN",
+			source: config.Source{Type: "github", Host: "github.mycorp.com"},
+			want:   "",
+		},
+		{
+			name:   "self-hosted gitlab falls back to netrc",
+			source: config.Source{Type: "gitlab", Host: "gitlab.internal"},
+			want:   "netrc-token",
+		},
+		{
+			name:   "codeberg gets GITEA_TOKEN",
+			source: config.Source{Type: "codeberg", Host: "codeberg.org"},
+			want:   "gitea-token",
+		},
+		{
+			name:   "self-hosted forgejo gets no GITEA_TOKEN",
+			source: config.Source{Type: "forgejo", Host: "forgejo.internal"},
+			want:   "",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := sync.ResolveCredential(cfg, tc.source)
+			if err != nil {
+				t.Fatalf("ResolveCredential() error = %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("ResolveCredential() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestResolveCredentialFromGitHelper(t *testing.T) {
+	gitConfig := filepath.Join(t.TempDir(), "gitconfig")
+	helper := "[credential]\n\thelper = \"!f() { echo username=dox; echo password=helper-token; }; f\"\n"
+	if err := os.WriteFile(gitConfig, []byte(helper), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GIT_CONFIG_GLOBAL", gitConfig)
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+
+	cfg := &config.Config{
+		Credentials: []config.Credential{{Host: "gitea.internal", Helper: "git"}},
+	}
+
+	got, err := sync.ResolveCredential(cfg, config.Source{Type: "gitea", Host: "gitea.internal"})
+	if err != nil {
+		t.Fatalf("ResolveCredential() error = %v", err)
+	}
+	if got != "helper-token" {
+		t.Errorf("ResolveCredential() = %q, want %q", got, "helper-token")
+	}
+}
+
+func TestParseNetrc(t *testing.T) {
+	content := `
+machine github.com login octocat password first
+machine github.com password second
+
+macdef init
+machine ignored.example.com password macro
+
+machine GitLab.Internal
+  login dox
+  password multi-line
+default login anonymous password catch-all
+`
+
+	want := map[string]string{
+		"github.com":      "first",
+		"gitlab.internal": "multi-line",
+	}
+	if got := sync.ParseNetrc([]byte(content)); !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseNetrc() = %v, want %v", got, want)
+	}
+}
@@ -1,11 +1,31 @@
 package sync
 
+import (
+	"context"
+
+	"github.com/g5becks/dox/internal/config"
+)
+
 // Test-only exports for internal helper functions.
 
 //nolint:gochecknoglobals // Test-only exports
 var (
 	ResolveSourceNames     = resolveSourceNames
 	ResolveGitHubToken     = resolveGitHubToken
+	ResolveSourceToken     = resolveSourceToken
 	ResolveOutputRoot      = resolveOutputRoot
 	ResolveSourceOutputDir = resolveSourceOutputDir
+	ResolveTargets         = resolveTargets
+	ParseNetrc             = parseNetrc
+	NewerVersionMessage    = newerVersionMessage
+	CheckLockCoverage      = checkLockCoverage
+	PinToLock              = pinToLock
+	LockEntryChanged       = lockEntryChanged
+	SourceFingerprint      = sourceFingerprint
 )
+
+// ResolveCredential resolves the token of a source with a fresh credential
+// store, as a sync run does.
+func ResolveCredential(cfg *config.Config, sourceCfg config.Source) (string, error) {
+	return newCredentialStore(cfg).token(context.Background(), "test", sourceCfg)
+}
@@ -0,0 +1,317 @@
+package sync
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/samber/oops"
+
+	"github.com/g5becks/dox/internal/config"
+	"github.com/g5becks/dox/internal/lockfile"
+	"github.com/g5becks/dox/internal/source"
+)
+
+// checkLockCoverage makes sure the lock file describes exactly the targets
+// a frozen sync is about to reproduce: every target has an entry recorded
+// with the same settings, and when all sources are synced, every entry
+// still belongs to a configured target.
+func checkLockCoverage(lock *lockfile.LockFile, targets []config.Target, allSources bool) error {
+	problems := []string{}
+	expected := make(map[string]struct{}, len(targets))
+
+	for _, target := range targets {
+		expected[target.Name] = struct{}{}
+
+		entry := lock.GetEntry(target.Name)
+		switch {
+		case entry == nil:
+			problems = append(problems, "source "+target.Name+" is not in the lock file")
+		case entry.ConfigHash != "" && entry.ConfigHash != sourceFingerprint(target.Config):
+			problems = append(problems, "source "+target.Name+" changed since the lock file was written")
+		}
+	}
+
+	if allSources {
+		for _, name := range slices.Sorted(maps.Keys(lock.Sources)) {
+			if _, ok := expected[name]; !ok {
+				problems = append(problems, "lock file has source "+name+" that is not in the config")
+			}
+		}
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+
+	return oops.
+		Code("LOCK_MISMATCH").
+		With("problems", problems).
+		Hint("Run 'dox sync' to update the lock file, then commit it").
+		Errorf("config and lock file disagree: %s", strings.Join(problems, "; "))
+}
+
+// unpinnedSourceTypes fetch whatever their URL or directory holds now, so
+// the lock cannot pin them and frozen syncs stage their files instead.
+//
+//nolint:gochecknoglobals // Read-only lookup table
+var unpinnedSourceTypes = map[string]bool{
+	"url":     true,
+	"site":    true,
+	"archive": true,
+	"local":   true,
+}
+
+// pinToLock points a source at exactly what its lock entry recorded: the
+// resolved ref of a git source or the resolved version of a package, so a
+// frozen sync does not follow branches or version ranges. Git sources fetch
+// the pinned commit at depth 1 where the server allows fetching by SHA.
+func pinToLock(sourceCfg config.Source, entry *lockfile.LockEntry) config.Source {
+	if entry == nil || entry.RefResolved == "" {
+		return sourceCfg
+	}
+
+	switch {
+	case gitSourceTypes[sourceCfg.Type]:
+		sourceCfg.Ref = entry.RefResolved
+		sourceCfg.Version = ""
+	case sourceCfg.Type == "npm" || sourceCfg.Type == "pypi" || sourceCfg.Type == "gomod":
+		sourceCfg.Version = entry.RefResolved
+	}
+
+	return sourceCfg
+}
+
+// checkLockedEntry compares the lock entry a frozen or locked sync produced
+// for a target with the one recorded before the run.
+func checkLockedEntry(job syncJob, opts Options, entry *lockfile.LockEntry) error {
+	if (!opts.Frozen && !opts.Locked) || !lockEntryChanged(job.locked, entry) {
+		return nil
+	}
+
+	if opts.Frozen {
+		return oops.
+			Code("LOCK_MISMATCH").
+			With("source", job.target.Name).
+			Hint("The upstream content changed; run 'dox sync' to update the lock file").
+			Errorf("synced files of %q do not match the lock file", job.target.Name)
+	}
+
+	return oops.
+		Code("LOCK_OUTDATED").
+		With("source", job.target.Name).
+		Hint("Run 'dox sync' and commit the updated lock file").
+		Errorf("sync would change the lock entry of %q", job.target.Name)
+}
+
+// lockEntryChanged reports whether a sync result records different content
+// than the previous lock entry. Sync times and HTTP validators are ignored.
+func lockEntryChanged(previous *lockfile.LockEntry, current *lockfile.LockEntry) bool {
+	if previous == nil || current == nil {
+		return previous != current
+	}
+
+	if previous.ConfigHash != "" && previous.ConfigHash != current.ConfigHash {
+		return true
+	}
+
+	// Entries written before commits were recorded hold the branch or tag
+	// name as the resolved ref.
+	refChanged := previous.RefResolved != current.RefResolved &&
+		(previous.Ref != "" || previous.RefResolved != current.Ref)
+
+	return previous.Type != current.Type ||
+		previous.TreeSHA != current.TreeSHA ||
+		refChanged ||
+		previous.Digest != current.Digest ||
+		!maps.Equal(previous.Files, current.Files)
+}
+
+// sourceFingerprint hashes the settings of a source that decide which files
+// it syncs, so the lock file can tell when the config changed under it.
+// Local paths are left out because they differ between checkouts.
+func sourceFingerprint(sourceCfg config.Source) string {
+	remoteURL := sourceCfg.URL
+	if config.IsLocalURL(remoteURL) {
+		remoteURL = ""
+	}
+
+	urls := make([]string, 0, len(sourceCfg.URLs))
+	for _, file := range sourceCfg.URLs {
+		urls = append(urls, file.URL+" "+file.Filename)
+	}
+
+	settings := []any{
+		sourceCfg.Type, sourceCfg.Repo, sourceCfg.Host, sourceCfg.Path, sourceCfg.Ref, sourceCfg.Version,
+		sortedCopy(sourceCfg.Patterns), sortedCopy(sourceCfg.Exclude), remoteURL, urls, sourceCfg.Filename,
+		sourceCfg.StripComponents, sourceCfg.Package, sourceCfg.Registry, sourceCfg.Module, sourceCfg.GoProxy,
+		sourceCfg.Dist, sourceCfg.Docstrings, sourceCfg.Sitemap, sourceCfg.Prefixes, sourceCfg.MaxDepth,
+		sourceCfg.MaxPages, sourceCfg.Selector, sourceCfg.Expand,
+	}
+
+	data, _ := json.Marshal(settings) //nolint:errchkjson // plain strings, numbers, and slices always encode
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func sortedCopy(values []string) []string {
+	sorted := slices.Clone(values)
+	slices.Sort(sorted)
+	return sorted
+}
+
+// syncStaged runs a frozen sync of a source the lock cannot pin against a
+// staging copy of its output directory, and only applies the result when it
+// matches the lock entry, so drifted content never reaches the output.
+func syncStaged(
+	ctx context.Context,
+	job syncJob,
+	credentials *credentialStore,
+	opts Options,
+	shared source.SyncOptions,
+	emit func(Event),
+) runState {
+	stageDir, seeded, err := newStage(job.destinationDir)
+	if err != nil {
+		return runState{err: err}
+	}
+	defer os.RemoveAll(stageDir)
+
+	state := syncInto(ctx, job, stageDir, credentials, opts, shared, emit)
+	if state.err != nil {
+		// Partial progress only reached the discarded stage.
+		if state.result != nil {
+			state.result.LockEntry = nil
+		}
+		return state
+	}
+
+	if state.result != nil && !state.result.Skipped {
+		if state.err = applyStage(stageDir, job.destinationDir, seeded); state.err != nil {
+			state.result.LockEntry = nil
+		}
+	}
+
+	return state
+}
+
+// newStage creates a staging directory next to destDir holding hard links
+// to its files, and returns the relative paths it linked. Sources replace
+// files by rename rather than rewriting them, so syncing into the stage
+// leaves destDir untouched.
+func newStage(destDir string) (string, map[string]struct{}, error) {
+	parent := filepath.Dir(destDir)
+	if err := os.MkdirAll(parent, 0o750); err != nil {
+		return "", nil, stageError(parent, err, "creating output directory")
+	}
+
+	stageDir, err := os.MkdirTemp(parent, ".dox-frozen-*")
+	if err != nil {
+		return "", nil, stageError(parent, err, "creating staging directory")
+	}
+
+	seeded := map[string]struct{}{}
+	err = filepath.WalkDir(destDir, func(path string, entry fs.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			if errors.Is(walkErr, fs.ErrNotExist) {
+				return nil
+			}
+			return walkErr
+		}
+
+		relativePath, _ := filepath.Rel(destDir, path)
+		stagePath := filepath.Join(stageDir, relativePath)
+		if entry.IsDir() {
+			return os.MkdirAll(stagePath, 0o750)
+		}
+
+		seeded[relativePath] = struct{}{}
+		if os.Link(path, stagePath) == nil {
+			return nil
+		}
+		return copyFile(path, stagePath)
+	})
+	if err != nil {
+		_ = os.RemoveAll(stageDir)
+		return "", nil, stageError(destDir, err, "staging output directory")
+	}
+
+	return stageDir, seeded, nil
+}
+
+// applyStage moves the files a sync wrote into the stage over to destDir and
+// removes the seeded files the sync deleted. Files the sync left alone are
+// still the seeded hard links and are skipped.
+func applyStage(stageDir string, destDir string, seeded map[string]struct{}) error {
+	err := filepath.WalkDir(stageDir, func(path string, entry fs.DirEntry, walkErr error) error {
+		if walkErr != nil || entry.IsDir() {
+			return walkErr
+		}
+
+		relativePath, _ := filepath.Rel(stageDir, path)
+		destPath := filepath.Join(destDir, relativePath)
+		if _, ok := seeded[relativePath]; ok && sameFile(path, destPath) {
+			return nil
+		}
+
+		if mkdirErr := os.MkdirAll(filepath.Dir(destPath), 0o750); mkdirErr != nil {
+			return mkdirErr
+		}
+		return os.Rename(path, destPath)
+	})
+	if err != nil {
+		return stageError(destDir, err, "applying staged files")
+	}
+
+	for _, relativePath := range slices.Sorted(maps.Keys(seeded)) {
+		if _, statErr := os.Lstat(filepath.Join(stageDir, relativePath)); !errors.Is(statErr, fs.ErrNotExist) {
+			continue
+		}
+
+		if removeErr := os.Remove(filepath.Join(destDir, relativePath)); removeErr != nil &&
+			!errors.Is(removeErr, fs.ErrNotExist) {
+			return stageError(destDir, removeErr, "removing deleted file")
+		}
+	}
+
+	return nil
+}
+
+func sameFile(a string, b string) bool {
+	aInfo, aErr := os.Lstat(a)
+	bInfo, bErr := os.Lstat(b)
+	return aErr == nil && bErr == nil && os.SameFile(aInfo, bInfo)
+}
+
+func copyFile(srcPath string, destPath string) error {
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dest, err := os.OpenFile(destPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return err
+	}
+
+	_, copyErr := io.Copy(dest, src)
+	return cmp.Or(copyErr, dest.Close())
+}
+
+func stageError(path string, err error, action string) error {
+	return oops.
+		Code("WRITE_FAILED").
+		With("path", path).
+		Wrapf(err, "%s", action)
+}
@@ -0,0 +1,175 @@
+package sync_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/g5becks/dox/internal/config"
+	"github.com/g5becks/dox/internal/lockfile"
+	"github.com/g5becks/dox/internal/sync"
+)
+
+func TestCheckLockCoverage(t *testing.T) {
+	docs := config.Source{Type: "github", Repo: "owner/repo", Path: "docs", Patterns: []string{"**/*.md"}}
+	targets := []config.Target{{Name: "docs", Source: "docs", Config: docs}}
+
+	lock := lockfile.New()
+	lock.SetEntry("docs", &lockfile.LockEntry{Type: "github", ConfigHash: sync.SourceFingerprint(docs)})
+	if err := sync.CheckLockCoverage(lock, targets, true); err != nil {
+		t.Fatalf("CheckLockCoverage() error = %v, want nil", err)
+	}
+
+	lock.SetEntry("removed", &lockfile.LockEntry{Type: "url"})
+	err := sync.CheckLockCoverage(lock, targets, true)
+	if err == nil || !strings.Contains(err.Error(), "lock file has source removed that is not in the config") {
+		t.Fatalf("CheckLockCoverage() error = %v, want removed source reported", err)
+	}
+
+	if err = sync.CheckLockCoverage(lock, targets, false); err != nil {
+		t.Fatalf("CheckLockCoverage() for selected sources error = %v, want nil", err)
+	}
+
+	changed := docs
+	changed.Patterns = []string{"**/*.mdx"}
+	added := config.Target{Name: "added", Source: "added", Config: docs}
+	err = sync.CheckLockCoverage(lock, []config.Target{{Name: "docs", Config: changed}, added}, false)
+	if err == nil ||
+		!strings.Contains(err.Error(), "source docs changed since the lock file was written") ||
+		!strings.Contains(err.Error(), "source added is not in the lock file") {
+		t.Fatalf("CheckLockCoverage() error = %v, want changed and added sources reported", err)
+	}
+}
+
+func TestSourceFingerprintIgnoresPatternOrder(t *testing.T) {
+	first := config.Source{Type: "github", Repo: "owner/repo", Exclude: []string{"a/**", "b/**"}}
+	second := config.Source{Type: "github", Repo: "owner/repo", Exclude: []string{"b/**", "a/**"}}
+	if sync.SourceFingerprint(first) != sync.SourceFingerprint(second) {
+		t.Error("SourceFingerprint() differs for the same excludes in another order")
+	}
+
+	second.Path = "docs"
+	if sync.SourceFingerprint(first) == sync.SourceFingerprint(second) {
+		t.Error("SourceFingerprint() ignores a changed path")
+	}
+}
+
+func TestPinToLock(t *testing.T) {
+	entry := &lockfile.LockEntry{RefResolved: "4f2a9c1"}
+
+	git := sync.PinToLock(config.Source{Type: "github", Version: "^5.0"}, entry)
+	if git.Ref != "4f2a9c1" || git.Version != "" {
+		t.Errorf("github source pinned to ref %q version %q, want ref 4f2a9c1", git.Ref, git.Version)
+	}
+
+	npm := sync.PinToLock(config.Source{Type: "npm", Version: "^18"}, &lockfile.LockEntry{RefResolved: "18.3.1"})
+	if npm.Version != "18.3.1" {
+		t.Errorf("npm source pinned to version %q, want 18.3.1", npm.Version)
+	}
+
+	url := sync.PinToLock(config.Source{Type: "url", URL: "https://example.com/a.md"}, entry)
+	if url.Ref != "" || url.Version != "" {
+		t.Errorf("url source pinned to ref %q version %q, want unchanged", url.Ref, url.Version)
+	}
+}
+
+func TestLockEntryChanged(t *testing.T) {
+	previous := &lockfile.LockEntry{Type: "url", ETag: `"a"`, Files: map[string]string{"a.md": "sha-a"}}
+
+	same := &lockfile.LockEntry{Type: "url", ETag: `"b"`, Files: map[string]string{"a.md": "sha-a"}}
+	if sync.LockEntryChanged(previous, same) {
+		t.Error("LockEntryChanged() = true for a new ETag only")
+	}
+
+	edited := &lockfile.LockEntry{Type: "url", Files: map[string]string{"a.md": "sha-b"}}
+	if !sync.LockEntryChanged(previous, edited) {
+		t.Error("LockEntryChanged() = false for changed file content")
+	}
+
+	if !sync.LockEntryChanged(nil, same) {
+		t.Error("LockEntryChanged() = false for a new entry")
+	}
+
+	commit := &lockfile.LockEntry{Type: "github", TreeSHA: "tree", RefResolved: "4f2a9c1e", Ref: "main"}
+	if sync.LockEntryChanged(&lockfile.LockEntry{Type: "github", TreeSHA: "tree", RefResolved: "main"}, commit) {
+		t.Error("LockEntryChanged() = true for a branch entry now recorded with its commit")
+	}
+
+	moved := &lockfile.LockEntry{Type: "github", TreeSHA: "tree", RefResolved: "7b1d3e5f", Ref: "main"}
+	if !sync.LockEntryChanged(commit, moved) {
+		t.Error("LockEntryChanged() = false for a branch that moved to another commit")
+	}
+}
+
+func TestRunLockedAndFrozen(t *testing.T) {
+	root := t.TempDir()
+	docsDir := filepath.Join(root, "docs")
+	if err := os.MkdirAll(docsDir, 0o750); err != nil {
+		t.Fatal(err)
+	}
+	writeDoc := func(content string) {
+		if err := os.WriteFile(filepath.Join(docsDir, "guide.md"), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeDoc("# Guide\n")
+
+	cfg := &config.Config{
+		Output: filepath.Join(root, ".dox"),
+		Sources: map[string]config.Source{
+			"guide": {Type: "local", Dir: docsDir, Patterns: []string{"**/*.md"}},
+		},
+	}
+
+	if _, err := sync.Run(context.Background(), cfg, sync.Options{}); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	if _, err := sync.Run(context.Background(), cfg, sync.Options{Locked: true}); err != nil {
+		t.Fatalf("Run(Locked) on an up-to-date lock error = %v", err)
+	}
+
+	writeDoc("# Guide\n\nEdited.\n")
+	_, err := sync.Run(context.Background(), cfg, sync.Options{Locked: true})
+	if err == nil || !strings.Contains(err.Error(), "failed the lock check") {
+		t.Fatalf("Run(Locked) error = %v, want lock check failure", err)
+	}
+
+	_, err = sync.Run(context.Background(), cfg, sync.Options{Frozen: true})
+	if err == nil || !strings.Contains(err.Error(), "failed during sync") {
+		t.Fatalf("Run(Frozen) error = %v, want content mismatch", err)
+	}
+
+	// Drifted content must not reach the output the kept lock entry describes.
+	outputFile := filepath.Join(root, ".dox", "guide", "guide.md")
+	assertContent := func(want string) {
+		t.Helper()
+		content, readErr := os.ReadFile(outputFile)
+		if readErr != nil {
+			t.Fatalf("ReadFile() error = %v", readErr)
+		}
+		if string(content) != want {
+			t.Fatalf("output content = %q, want %q", content, want)
+		}
+	}
+	assertContent("# Guide\n")
+
+	writeDoc("# Guide\n")
+	if _, err = sync.Run(context.Background(), cfg, sync.Options{Frozen: true, Clean: true}); err != nil {
+		t.Fatalf("Run(Frozen, Clean) error = %v", err)
+	}
+	assertContent("# Guide\n")
+
+	staged, _ := filepath.Glob(filepath.Join(root, ".dox", ".dox-frozen-*"))
+	if len(staged) != 0 {
+		t.Fatalf("staging directories left behind: %v", staged)
+	}
+
+	cfg.Sources["extra"] = config.Source{Type: "local", Dir: docsDir, Patterns: []string{"**/*.md"}}
+	_, err = sync.Run(context.Background(), cfg, sync.Options{Frozen: true})
+	if err == nil || !strings.Contains(err.Error(), "source extra is not in the lock file") {
+		t.Fatalf("Run(Frozen) error = %v, want missing lock entry", err)
+	}
+}
@@ -2,15 +2,19 @@ package sync
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"path/filepath"
 	"runtime"
 	"slices"
 	stdsync "sync"
 
+	"github.com/Masterminds/semver/v3"
 	"github.com/samber/oops"
 	"golang.org/x/sync/errgroup"
+	"golang.org/x/sync/semaphore"
 
+	"github.com/g5becks/dox/internal/cache"
 	"github.com/g5becks/dox/internal/config"
 	"github.com/g5becks/dox/internal/lockfile"
 	"github.com/g5becks/dox/internal/manifest"
@@ -35,15 +39,17 @@ type EventKind int
 const (
 	EventSourceStart EventKind = iota
 	EventSourceDone
+	EventSourceWarning
 	EventManifestError
 )
 
 // Event is emitted during sync to report per-source progress.
 type Event struct {
-	Kind   EventKind
-	Source string
-	Result *source.SyncResult // nil for start events
-	Err    error              // non-nil if source failed
+	Kind    EventKind
+	Source  string
+	Result  *source.SyncResult // nil for start events
+	Err     error              // non-nil if source failed
+	Message string             // set for warning events
 }
 
 // RunResult contains aggregate counts from a completed sync run.
@@ -61,6 +67,8 @@ type Options struct {
 	DryRun      bool
 	MaxParallel int
 	Clean       bool
+	Frozen      bool        // sync exactly what the lock file records
+	Locked      bool        // fail if a sync would change the lock file; implies DryRun
 	OnEvent     func(Event) // optional; nil = silent
 }
 
@@ -69,6 +77,15 @@ type runState struct {
 	err    error
 }
 
+// syncJob is one target of a sync run and the lock state it starts from.
+type syncJob struct {
+	target         config.Target
+	sourceCfg      config.Source // target config, pinned to the lock entry in frozen mode
+	destinationDir string
+	previousLock   *lockfile.LockEntry
+	locked         *lockfile.LockEntry // entry before the run, checked by frozen and locked syncs
+}
+
 func Run(ctx context.Context, cfg *config.Config, opts Options) (*RunResult, error) {
 	if cfg == nil {
 		return nil, oops.
@@ -76,17 +93,12 @@ func Run(ctx context.Context, cfg *config.Config, opts Options) (*RunResult, err
 			Errorf("config is required")
 	}
 
-	outputDir := resolveOutputRoot(cfg)
-	if opts.Clean && !opts.DryRun {
-		if err := os.RemoveAll(outputDir); err != nil {
-			return nil, oops.
-				Code("WRITE_FAILED").
-				With("path", outputDir).
-				Wrapf(err, "cleaning output directory")
-		}
+	if opts.Locked {
+		opts.DryRun = true
 	}
 
-	lock, err := lockfile.Load(outputDir)
+	outputDir := resolveOutputRoot(cfg)
+	before, lock, err := loadLocks(outputDir, opts)
 	if err != nil {
 		return nil, err
 	}
@@ -96,16 +108,15 @@ func Run(ctx context.Context, cfg *config.Config, opts Options) (*RunResult, err
 		return nil, err
 	}
 
-	maxParallel := opts.MaxParallel
-	if maxParallel <= 0 {
-		// Check if config specifies a default, otherwise use smart default
-		if cfg.MaxParallel > 0 {
-			maxParallel = cfg.MaxParallel
-		} else {
-			maxParallel = getDefaultMaxParallel()
+	targets := resolveTargets(cfg, sourceNames)
+	if opts.Frozen {
+		if coverageErr := checkLockCoverage(before, targets, len(opts.SourceNames) == 0); coverageErr != nil {
+			return nil, coverageErr
 		}
 	}
 
+	maxParallel := resolveMaxParallel(cfg, opts)
+
 	emit := opts.OnEvent
 	if emit == nil {
 		emit = func(Event) {}
@@ -113,19 +124,24 @@ func Run(ctx context.Context, cfg *config.Config, opts Options) (*RunResult, err
 
 	results := make(map[string]runState, len(sourceNames))
 	var resultsMu stdsync.Mutex
-	token := resolveGitHubToken(cfg)
 	group, groupCtx := errgroup.WithContext(ctx)
 	group.SetLimit(maxParallel)
 
-	for _, sourceName := range sourceNames {
-		sourceCfg := cfg.Sources[sourceName]
-		destinationDir := resolveSourceOutputDir(outputDir, sourceName, sourceCfg)
-		previousLock := lock.GetEntry(sourceName)
+	shared, err := newSharedOptions(cfg, maxParallel, emit)
+	if err != nil {
+		return nil, err
+	}
+
+	credentials := newCredentialStore(cfg)
+	targetNames := make([]string, 0, len(targets))
+	for _, target := range targets {
+		targetNames = append(targetNames, target.Name)
+		job := newSyncJob(outputDir, target, lock, before, opts)
 
 		group.Go(func() error {
-			state := syncSource(groupCtx, sourceName, sourceCfg, destinationDir, previousLock, token, opts, emit)
+			state := syncSource(groupCtx, job, credentials, opts, shared, emit)
 			resultsMu.Lock()
-			results[sourceName] = state
+			results[target.Name] = state
 			resultsMu.Unlock()
 			return nil
 		})
@@ -137,7 +153,7 @@ func Run(ctx context.Context, cfg *config.Config, opts Options) (*RunResult, err
 
 	errorCount, downloadedCount, deletedCount, skippedCount := processResults(
 		lock,
-		sourceNames,
+		targetNames,
 		results,
 		opts.DryRun,
 	)
@@ -147,6 +163,9 @@ func Run(ctx context.Context, cfg *config.Config, opts Options) (*RunResult, err
 			return nil, saveErr
 		}
 
+		// Eviction is best effort; a cache left over its cap is pruned next run.
+		_, _ = shared.Cache.Prune()
+
 		// Generate manifest (non-fatal)
 		if genErr := manifest.Generate(ctx, cfg, lock); genErr != nil {
 			if opts.OnEvent != nil {
@@ -159,63 +178,260 @@ func Run(ctx context.Context, cfg *config.Config, opts Options) (*RunResult, err
 	}
 
 	runResult := &RunResult{
-		Sources:    len(sourceNames),
+		Sources:    len(targetNames),
 		Downloaded: downloadedCount,
 		Deleted:    deletedCount,
 		Skipped:    skippedCount,
 		Errors:     errorCount,
 	}
 
-	if errorCount > 0 {
-		return runResult, oops.
-			Code("DOWNLOAD_FAILED").
+	return runResult, runError(errorCount, opts)
+}
+
+// loadLocks returns the lock file as it was before the run and the one the
+// run updates. Frozen and locked syncs read the former before --clean
+// removes it, so a frozen sync can rebuild the output directory from it.
+func loadLocks(outputDir string, opts Options) (*lockfile.LockFile, *lockfile.LockFile, error) {
+	before := lockfile.New()
+	if opts.Frozen || opts.Locked {
+		var err error
+		if before, err = lockfile.Load(outputDir); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if opts.Clean && !opts.DryRun {
+		if err := os.RemoveAll(outputDir); err != nil {
+			return nil, nil, oops.
+				Code("WRITE_FAILED").
+				With("path", outputDir).
+				Wrapf(err, "cleaning output directory")
+		}
+	}
+
+	lock, err := lockfile.Load(outputDir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return before, lock, nil
+}
+
+func resolveMaxParallel(cfg *config.Config, opts Options) int {
+	if opts.MaxParallel > 0 {
+		return opts.MaxParallel
+	}
+
+	// Check if config specifies a default, otherwise use smart default
+	if cfg.MaxParallel > 0 {
+		return cfg.MaxParallel
+	}
+
+	return getDefaultMaxParallel()
+}
+
+func runError(errorCount int, opts Options) error {
+	if errorCount == 0 {
+		return nil
+	}
+
+	if opts.Locked {
+		return oops.
+			Code("LOCK_OUTDATED").
 			With("failed_sources", errorCount).
-			Errorf("%d source(s) failed during sync", errorCount)
+			Hint("Run 'dox sync' and commit the updated lock file").
+			Errorf("%d source(s) failed the lock check", errorCount)
 	}
 
-	return runResult, nil
+	return oops.
+		Code("DOWNLOAD_FAILED").
+		With("failed_sources", errorCount).
+		Errorf("%d source(s) failed during sync", errorCount)
+}
+
+// newSyncJob prepares the sync of a target. Frozen syncs pin the target to
+// the lock entry recorded before the run.
+func newSyncJob(
+	outputDir string,
+	target config.Target,
+	lock *lockfile.LockFile,
+	before *lockfile.LockFile,
+	opts Options,
+) syncJob {
+	job := syncJob{
+		target:         target,
+		sourceCfg:      target.Config,
+		destinationDir: resolveSourceOutputDir(outputDir, target.Name, target.Config),
+		previousLock:   lock.GetEntry(target.Name),
+		locked:         before.GetEntry(target.Name),
+	}
+
+	if opts.Frozen {
+		job.sourceCfg = pinToLock(target.Config, job.locked)
+	}
+
+	return job
 }
 
 func syncSource(
 	ctx context.Context,
-	sourceName string,
-	sourceCfg config.Source,
-	destinationDir string,
-	previousLock *lockfile.LockEntry,
-	token string,
+	job syncJob,
+	credentials *credentialStore,
 	opts Options,
+	shared source.SyncOptions,
 	emit func(Event),
 ) runState {
-	state := runState{}
+	emit(Event{Kind: EventSourceStart, Source: job.target.Name})
+
+	var state runState
+	if opts.Frozen && !opts.DryRun && unpinnedSourceTypes[job.sourceCfg.Type] {
+		state = syncStaged(ctx, job, credentials, opts, shared, emit)
+	} else {
+		state = syncInto(ctx, job, job.destinationDir, credentials, opts, shared, emit)
+	}
 
-	emit(Event{Kind: EventSourceStart, Source: sourceName})
+	emit(Event{
+		Kind:   EventSourceDone,
+		Source: job.target.Name,
+		Result: state.result,
+		Err:    state.err,
+	})
 
-	src, newErr := source.New(sourceName, sourceCfg, token)
+	return state
+}
+
+// syncInto syncs a target into destDir and fills in the lock entry the
+// sync produced.
+func syncInto(
+	ctx context.Context,
+	job syncJob,
+	destDir string,
+	credentials *credentialStore,
+	opts Options,
+	shared source.SyncOptions,
+	emit func(Event),
+) runState {
+	state := runState{}
+	sourceName := job.target.Name
+
+	src, newErr := newSource(ctx, sourceName, job.sourceCfg, credentials)
 	if newErr != nil {
 		state.err = newErr
 	} else {
 		defer src.Close()
 		state.result, state.err = src.Sync(
 			ctx,
-			destinationDir,
-			previousLock,
+			destDir,
+			job.previousLock,
 			source.SyncOptions{
-				Force:  opts.Force,
-				DryRun: opts.DryRun,
+				Force:        opts.Force || configChanged(job),
+				DryRun:       opts.DryRun,
+				FileParallel: job.sourceCfg.FileParallel,
+				Requests:     shared.Requests,
+				GitHubQuota:  shared.GitHubQuota,
+				Cache:        shared.Cache,
 			},
 		)
 	}
 
-	emit(Event{
-		Kind:   EventSourceDone,
-		Source: sourceName,
-		Result: state.result,
-		Err:    state.err,
-	})
+	if state.err == nil && state.result != nil && state.result.LockEntry != nil {
+		entry := state.result.LockEntry
+		entry.ConfigHash = sourceFingerprint(job.target.Config)
+
+		// A sync pinned to the locked commit still follows the locked branch or tag.
+		if opts.Frozen && job.locked != nil && job.locked.Ref != "" && entry.Ref == job.locked.RefResolved {
+			entry.Ref = job.locked.Ref
+		}
+
+		if message := newerVersionMessage(job.sourceCfg, job.previousLock, entry); message != "" {
+			emit(Event{Kind: EventSourceWarning, Source: sourceName, Message: message})
+		}
+
+		// A frozen sync keeps the recorded entry when the content drifted.
+		if state.err = checkLockedEntry(job, opts, entry); state.err != nil && opts.Frozen {
+			state.result.LockEntry = nil
+		}
+	}
 
 	return state
 }
 
+// configChanged reports whether the settings of a target changed since its
+// lock entry was written. Sources skip when their upstream content is
+// unchanged, so a changed config forces a full sync that applies it.
+func configChanged(job syncJob) bool {
+	previous := job.previousLock
+	return previous != nil && previous.ConfigHash != "" && previous.ConfigHash != sourceFingerprint(job.target.Config)
+}
+
+// newSharedOptions builds the state all sources of a run share. File
+// downloads of all sources draw from one request budget, so per-source file
+// parallelism never multiplies past the configured parallelism, GitHub
+// sources on one API host share its quota, and the cache is opened when
+// enabled.
+func newSharedOptions(cfg *config.Config, maxParallel int, emit func(Event)) (source.SyncOptions, error) {
+	shared := source.SyncOptions{
+		Requests: semaphore.NewWeighted(int64(maxParallel)),
+		GitHubQuota: source.NewQuotaTracker(cfg.RateLimitWait, cfg.RateLimitMaxWait, func(sourceName, message string) {
+			emit(Event{Kind: EventSourceWarning, Source: sourceName, Message: message})
+		}),
+	}
+
+	if !cfg.Cache.Enabled {
+		return shared, nil
+	}
+
+	sharedCache, err := cache.Open(cfg.Cache)
+	if err != nil {
+		return shared, err
+	}
+
+	shared.Cache = sharedCache
+	return shared, nil
+}
+
+// newerVersionMessage reports when a source following a version constraint
+// moved to a newer matching tag or release than its previous lock entry.
+// Git sources record the tag as ref next to the commit it resolved to.
+func newerVersionMessage(sourceCfg config.Source, previousLock *lockfile.LockEntry, entry *lockfile.LockEntry) string {
+	if sourceCfg.Version == "" || previousLock == nil || entry == nil {
+		return ""
+	}
+
+	previousTag, currentTag := lockedVersion(previousLock), lockedVersion(entry)
+	previous, previousErr := semver.NewVersion(previousTag)
+	current, currentErr := semver.NewVersion(currentTag)
+	if previousErr != nil || currentErr != nil || !current.GreaterThan(previous) {
+		return ""
+	}
+
+	return fmt.Sprintf("newer version %s matches %q (was %s)", currentTag, sourceCfg.Version, previousTag)
+}
+
+// lockedVersion returns the tag or release a lock entry was synced from.
+func lockedVersion(entry *lockfile.LockEntry) string {
+	if entry.Ref != "" {
+		return entry.Ref
+	}
+
+	return entry.RefResolved
+}
+
+// newSource builds the source with the token resolved for its host.
+func newSource(
+	ctx context.Context,
+	sourceName string,
+	sourceCfg config.Source,
+	credentials *credentialStore,
+) (source.Source, error) {
+	token, err := credentials.token(ctx, sourceName, sourceCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return source.New(sourceName, sourceCfg, token)
+}
+
 func resolveSourceNames(
 	sourceConfigs map[string]config.Source,
 	requestedNames []string,
@@ -253,6 +469,32 @@ func resolveSourceNames(
 	return sourceNames, nil
 }
 
+// resolveTargets expands the sources to sync into their targets, one per
+// version for sources that sync several versions side by side.
+func resolveTargets(cfg *config.Config, sourceNames []string) []config.Target {
+	targets := make([]config.Target, 0, len(sourceNames))
+	for _, sourceName := range sourceNames {
+		targets = append(targets, cfg.Targets(sourceName)...)
+	}
+
+	return targets
+}
+
+// resolveSourceToken picks the global API token matching the source's
+// hosting type, used when no [[credentials]] entry covers its host.
+func resolveSourceToken(cfg *config.Config, sourceCfg config.Source) string {
+	switch sourceCfg.Type {
+	case "github":
+		return resolveGitHubToken(cfg)
+	case "gitlab":
+		return resolveGitLabToken(cfg)
+	case "codeberg", "gitea", "forgejo":
+		return resolveGiteaToken(cfg)
+	default:
+		return ""
+	}
+}
+
 func resolveGitHubToken(cfg *config.Config) string {
 	if cfg.GitHubToken != "" {
 		return cfg.GitHubToken
@@ -265,6 +507,22 @@ func resolveGitHubToken(cfg *config.Config) string {
 	return os.Getenv("GH_TOKEN")
 }
 
+func resolveGitLabToken(cfg *config.Config) string {
+	if cfg.GitLabToken != "" {
+		return cfg.GitLabToken
+	}
+
+	return os.Getenv("GITLAB_TOKEN")
+}
+
+func resolveGiteaToken(cfg *config.Config) string {
+	if cfg.GiteaToken != "" {
+		return cfg.GiteaToken
+	}
+
+	return os.Getenv("GITEA_TOKEN")
+}
+
 func resolveOutputRoot(cfg *config.Config) string {
 	if filepath.IsAbs(cfg.Output) {
 		return cfg.Output
@@ -296,9 +554,9 @@ func processResults(
 		state := results[sourceName]
 		if state.err != nil {
 			errorCount++
-			continue
 		}
 
+		// Failed sources may still return partial progress worth keeping.
 		if state.result == nil {
 			continue
 		}
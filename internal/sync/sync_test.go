@@ -2,10 +2,17 @@ package sync_test
 
 import (
 	"context"
+	"os"
 	"path/filepath"
+	"slices"
 	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
 
 	"github.com/g5becks/dox/internal/config"
+	"github.com/g5becks/dox/internal/lockfile"
 	"github.com/g5becks/dox/internal/sync"
 )
 
@@ -220,3 +227,162 @@ func TestResolveSourceOutputDir(t *testing.T) {
 		})
 	}
 }
+
+func TestResolveTargetsExpandsVersions(t *testing.T) {
+	cfg := &config.Config{
+		Sources: map[string]config.Source{
+			"guide": {Type: "url", URL: "https://example.com/guide.md"},
+			"widgets": {
+				Type: "github",
+				Repo: "owner/widgets",
+				Out:  "widget-docs",
+				Versions: []config.SourceVersion{
+					{Name: "v4", Ref: "v4.2.0"},
+					{Name: "v5", Version: "^5.0"},
+				},
+			},
+		},
+	}
+
+	targets := sync.ResolveTargets(cfg, []string{"guide", "widgets"})
+
+	names := make([]string, 0, len(targets))
+	dirs := make([]string, 0, len(targets))
+	for _, target := range targets {
+		names = append(names, target.Name)
+		dirs = append(dirs, sync.ResolveSourceOutputDir("/output", target.Name, target.Config))
+	}
+
+	wantNames := []string{"guide", "widgets@v4", "widgets@v5"}
+	wantDirs := []string{"/output/guide", "/output/widget-docs/v4", "/output/widget-docs/v5"}
+	if !slices.Equal(names, wantNames) {
+		t.Errorf("target names = %v, want %v", names, wantNames)
+	}
+	if !slices.Equal(dirs, wantDirs) {
+		t.Errorf("target dirs = %v, want %v", dirs, wantDirs)
+	}
+
+	if targets[1].Config.Ref != "v4.2.0" || targets[2].Config.Version != "^5.0" || targets[2].Config.Ref != "" {
+		t.Errorf("version targets = %+v, want the ref and version of each entry applied", targets[1:])
+	}
+}
+
+func TestResolveSourceTokenByType(t *testing.T) {
+	t.Setenv("GITHUB_TOKEN", "")
+	t.Setenv("GH_TOKEN", "")
+	t.Setenv("GITLAB_TOKEN", "gitlab-env")
+
+	cfg := &config.Config{GitHubToken: "github-config"}
+
+	if got := sync.ResolveSourceToken(cfg, config.Source{Type: "github"}); got != "github-config" {
+		t.Errorf("ResolveSourceToken(github) = %q, want %q", got, "github-config")
+	}
+
+	if got := sync.ResolveSourceToken(cfg, config.Source{Type: "gitlab"}); got != "gitlab-env" {
+		t.Errorf("ResolveSourceToken(gitlab) = %q, want %q", got, "gitlab-env")
+	}
+
+	cfg.GitLabToken = "gitlab-config"
+	if got := sync.ResolveSourceToken(cfg, config.Source{Type: "gitlab"}); got != "gitlab-config" {
+		t.Errorf("ResolveSourceToken(gitlab) = %q, want %q", got, "gitlab-config")
+	}
+
+	t.Setenv("GITEA_TOKEN", "gitea-env")
+	for _, sourceType := range []string{"codeberg", "gitea", "forgejo"} {
+		if got := sync.ResolveSourceToken(cfg, config.Source{Type: sourceType}); got != "gitea-env" {
+			t.Errorf("ResolveSourceToken(%s) = %q, want %q", sourceType, got, "gitea-env")
+		}
+	}
+
+	if got := sync.ResolveSourceToken(cfg, config.Source{Type: "url"}); got != "" {
+		t.Errorf("ResolveSourceToken(url) = %q, want empty", got)
+	}
+}
+
+func TestNewerVersionMessage(t *testing.T) {
+	versioned := config.Source{Type: "github", Repo: "acme/widgets", Version: "^5.0"}
+	locked := &lockfile.LockEntry{RefResolved: "v5.1.0"}
+
+	got := sync.NewerVersionMessage(versioned, locked, &lockfile.LockEntry{RefResolved: "v5.2.0"})
+	if want := `newer version v5.2.0 matches "^5.0" (was v5.1.0)`; got != want {
+		t.Errorf("NewerVersionMessage() = %q, want %q", got, want)
+	}
+
+	got = sync.NewerVersionMessage(versioned,
+		&lockfile.LockEntry{RefResolved: "4f2a9c1e", Ref: "v5.1.0"},
+		&lockfile.LockEntry{RefResolved: "7b1d3e5f", Ref: "v5.2.0"})
+	if want := `newer version v5.2.0 matches "^5.0" (was v5.1.0)`; got != want {
+		t.Errorf("NewerVersionMessage() for commits = %q, want %q", got, want)
+	}
+
+	for name, tc := range map[string]struct {
+		source config.Source
+		prev   *lockfile.LockEntry
+		next   string
+	}{
+		"same tag":       {source: versioned, prev: locked, next: "v5.1.0"},
+		"first sync":     {source: versioned, prev: nil, next: "v5.2.0"},
+		"no constraint":  {source: config.Source{Type: "github"}, prev: locked, next: "v5.2.0"},
+		"commit refs":    {source: versioned, prev: &lockfile.LockEntry{RefResolved: "abc123"}, next: "def456"},
+		"older matching": {source: versioned, prev: locked, next: "v5.0.3"},
+	} {
+		if got := sync.NewerVersionMessage(tc.source, tc.prev, &lockfile.LockEntry{RefResolved: tc.next}); got != "" {
+			t.Errorf("%s: NewerVersionMessage() = %q, want no message", name, got)
+		}
+	}
+}
+
+func TestRunAppliesChangedConfigAtSameCommit(t *testing.T) {
+	root := t.TempDir()
+	repoDir := filepath.Join(root, "repo")
+	repo, err := git.PlainInit(repoDir, false)
+	if err != nil {
+		t.Fatalf("PlainInit() error = %v", err)
+	}
+	for name, content := range map[string]string{"docs/guide.md": "# Guide\n", "docs/notes.txt": "notes\n"} {
+		fullPath := filepath.Join(repoDir, filepath.FromSlash(name))
+		if mkdirErr := os.MkdirAll(filepath.Dir(fullPath), 0o750); mkdirErr != nil {
+			t.Fatal(mkdirErr)
+		}
+		if writeErr := os.WriteFile(fullPath, []byte(content), 0o600); writeErr != nil {
+			t.Fatal(writeErr)
+		}
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		t.Fatalf("Worktree() error = %v", err)
+	}
+	if _, err = worktree.Add("docs"); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	signature := &object.Signature{Name: "dox", Email: "dox@example.com", When: time.Now()}
+	if _, err = worktree.Commit("docs", &git.CommitOptions{Author: signature}); err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+
+	cfg := &config.Config{
+		Output: filepath.Join(root, ".dox"),
+		Sources: map[string]config.Source{
+			"docs": {Type: "git", URL: repoDir, Path: "docs", Patterns: []string{"**/*.md"}},
+		},
+	}
+	if _, err = sync.Run(context.Background(), cfg, sync.Options{}); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	notes := filepath.Join(root, ".dox", "docs", "notes.txt")
+	if _, statErr := os.Stat(notes); !os.IsNotExist(statErr) {
+		t.Fatalf("notes.txt synced before its pattern was configured")
+	}
+
+	widened := cfg.Sources["docs"]
+	widened.Patterns = []string{"**/*.md", "**/*.txt"}
+	cfg.Sources["docs"] = widened
+	if _, err = sync.Run(context.Background(), cfg, sync.Options{}); err != nil {
+		t.Fatalf("Run() after changing patterns error = %v", err)
+	}
+
+	if _, statErr := os.Stat(notes); statErr != nil {
+		t.Fatalf("notes.txt missing after adding its pattern: %v", statErr)
+	}
+}
@@ -0,0 +1,183 @@
+package sync
+
+import (
+	"crypto/sha1" //nolint:gosec // Git names blobs by SHA-1; it identifies content, it does not protect it.
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+
+	"github.com/samber/oops"
+
+	"github.com/g5becks/dox/internal/config"
+	"github.com/g5becks/dox/internal/lockfile"
+)
+
+// FileStatus describes how a file on disk differs from the lock file.
+type FileStatus string
+
+const (
+	FileModified   FileStatus = "modified"
+	FileMissing    FileStatus = "missing"
+	FileUnexpected FileStatus = "unexpected"
+)
+
+// FileProblem is a file whose content or presence does not match the lock.
+type FileProblem struct {
+	Source string     `json:"source"`
+	Path   string     `json:"path"`
+	Status FileStatus `json:"status"`
+}
+
+// VerifyResult reports which synced files no longer match the lock file.
+type VerifyResult struct {
+	Sources  int           `json:"sources"`
+	Files    int           `json:"files"`
+	Problems []FileProblem `json:"problems"`
+}
+
+// gitSourceTypes record files by git blob SHA; every other source type
+// records the SHA-256 of the file content.
+//
+//nolint:gochecknoglobals // Read-only lookup table
+var gitSourceTypes = map[string]bool{
+	"github":   true,
+	"gitlab":   true,
+	"codeberg": true,
+	"gitea":    true,
+	"forgejo":  true,
+	"git":      true,
+}
+
+// Verify rehashes the files of the synced sources against the hashes in the
+// lock file and reports modified, missing, and unexpected files. Sources
+// that were never synced are not checked.
+func Verify(cfg *config.Config, sourceNames []string) (*VerifyResult, error) {
+	if cfg == nil {
+		return nil, oops.
+			Code("CONFIG_INVALID").
+			Errorf("config is required")
+	}
+
+	lock, err := lockfile.Load(resolveOutputRoot(cfg))
+	if err != nil {
+		return nil, err
+	}
+
+	names, err := resolveSourceNames(cfg.Sources, sourceNames)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &VerifyResult{Problems: []FileProblem{}}
+	for _, target := range resolveTargets(cfg, names) {
+		entry := lock.GetEntry(target.Name)
+		if entry == nil {
+			continue
+		}
+
+		sourceDir := cfg.OutputDir(target.Name, target.Config)
+		problems, verifyErr := verifySource(target.Name, sourceDir, entry)
+		if verifyErr != nil {
+			return nil, verifyErr
+		}
+
+		result.Sources++
+		result.Files += len(entry.Files)
+		result.Problems = append(result.Problems, problems...)
+	}
+
+	return result, nil
+}
+
+func verifySource(sourceName string, sourceDir string, entry *lockfile.LockEntry) ([]FileProblem, error) {
+	problems := []FileProblem{}
+	report := func(relativePath string, status FileStatus) {
+		problems = append(problems, FileProblem{Source: sourceName, Path: relativePath, Status: status})
+	}
+
+	for _, relativePath := range slices.Sorted(maps.Keys(entry.Files)) {
+		expected := entry.Files[relativePath]
+		actual, err := hashFile(filepath.Join(sourceDir, filepath.FromSlash(relativePath)), entry.Type, expected)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			report(relativePath, FileMissing)
+		case err != nil:
+			return nil, oops.
+				Code("VERIFY_FAILED").
+				With("source", sourceName).
+				With("path", relativePath).
+				Wrapf(err, "hashing synced file")
+		case actual != expected:
+			report(relativePath, FileModified)
+		}
+	}
+
+	walkErr := filepath.WalkDir(sourceDir, func(path string, dirEntry fs.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			if errors.Is(walkErr, fs.ErrNotExist) {
+				return nil
+			}
+			return walkErr
+		}
+		if dirEntry.IsDir() {
+			return nil
+		}
+
+		relativePath, relErr := filepath.Rel(sourceDir, path)
+		if relErr != nil {
+			return relErr
+		}
+
+		if _, tracked := entry.Files[filepath.ToSlash(relativePath)]; !tracked {
+			report(filepath.ToSlash(relativePath), FileUnexpected)
+		}
+		return nil
+	})
+	if walkErr != nil {
+		return nil, oops.
+			Code("VERIFY_FAILED").
+			With("source", sourceName).
+			With("dir", sourceDir).
+			Wrapf(walkErr, "walking source output directory")
+	}
+
+	return problems, nil
+}
+
+// hashFile hashes a synced file the way its source type recorded it: as a
+// git blob for git hosted sources, whose recorded SHA length tells SHA-1
+// from SHA-256 repositories, and as plain SHA-256 content otherwise.
+func hashFile(path string, sourceType string, recorded string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	var hasher hash.Hash = sha256.New()
+	if gitSourceTypes[sourceType] {
+		info, statErr := file.Stat()
+		if statErr != nil {
+			return "", statErr
+		}
+
+		if len(recorded) != sha256.Size*2 {
+			hasher = sha1.New() //nolint:gosec // Git blob names are SHA-1.
+		}
+		hasher.Write([]byte("blob " + strconv.FormatInt(info.Size(), 10) + "\x00"))
+	}
+
+	if _, copyErr := io.Copy(hasher, file); copyErr != nil {
+		return "", copyErr
+	}
+
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
@@ -0,0 +1,79 @@
+package sync_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/g5becks/dox/internal/config"
+	"github.com/g5becks/dox/internal/lockfile"
+	"github.com/g5becks/dox/internal/sync"
+)
+
+func TestVerifyReportsModifiedMissingAndUnexpectedFiles(t *testing.T) {
+	outputDir := t.TempDir()
+	cfg := &config.Config{
+		Output: outputDir,
+		Sources: map[string]config.Source{
+			"docs":  {Type: "github", Repo: "owner/repo"},
+			"spec":  {Type: "url", URL: "https://example.com/openapi.yaml", Out: "api"},
+			"fresh": {Type: "url", URL: "https://example.com/llms.txt"},
+		},
+	}
+
+	writeFile := func(relativePath string, content string) {
+		path := filepath.Join(outputDir, filepath.FromSlash(relativePath))
+		if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	writeFile("docs/guide/intro.md", "hello\n")
+	writeFile("docs/changed.md", "edited locally\n")
+	writeFile("docs/notes.md", "not synced\n")
+	writeFile("api/openapi.yaml", "openapi: 3.1.0\n")
+
+	specSum := sha256.Sum256([]byte("openapi: 3.1.0\n"))
+
+	lock := lockfile.New()
+	lock.SetEntry("docs", &lockfile.LockEntry{
+		Type: "github",
+		Files: map[string]string{
+			// git hash-object of "hello\n"
+			"guide/intro.md": "ce013625030ba8dba906f756967f9e9ca394464a",
+			"changed.md":     "0000000000000000000000000000000000000000",
+			"removed.md":     "1111111111111111111111111111111111111111",
+		},
+	})
+	lock.SetEntry("spec", &lockfile.LockEntry{
+		Type:  "url",
+		Files: map[string]string{"openapi.yaml": hex.EncodeToString(specSum[:])},
+	})
+	if err := lock.Save(outputDir); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := sync.Verify(cfg, nil)
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+
+	if result.Sources != 2 || result.Files != 4 {
+		t.Fatalf("Sources = %d, Files = %d, want 2 and 4", result.Sources, result.Files)
+	}
+
+	want := []sync.FileProblem{
+		{Source: "docs", Path: "changed.md", Status: sync.FileModified},
+		{Source: "docs", Path: "removed.md", Status: sync.FileMissing},
+		{Source: "docs", Path: "notes.md", Status: sync.FileUnexpected},
+	}
+	if !reflect.DeepEqual(result.Problems, want) {
+		t.Fatalf("Problems = %+v, want %+v", result.Problems, want)
+	}
+}
@@ -6,4 +6,6 @@ package ui
 var (
 	RenderLocation = renderLocation
 	RenderStatus   = renderStatus
+	RenderRef      = renderRef
+	RenderCommit   = renderCommit
 )
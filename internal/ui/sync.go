@@ -70,6 +70,13 @@ func (p *SyncPrinter) HandleEvent(e doxsync.Event) {
 	case doxsync.EventSourceDone:
 		p.handleDone(e)
 
+	case doxsync.EventSourceWarning:
+		fmt.Fprintf(p.w, "%s %s: %s\n",
+			p.s.yellow.Sprint("⚠"),
+			p.s.bold.Sprint(e.Source),
+			e.Message,
+		)
+
 	case doxsync.EventManifestError:
 		fmt.Fprintf(p.w, "%s manifest generation failed: %v\n",
 			p.s.yellow.Sprint("⚠"),
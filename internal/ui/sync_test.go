@@ -92,6 +92,25 @@ func TestHandleEventDoneError(t *testing.T) {
 	}
 }
 
+func TestHandleEventWarning(t *testing.T) {
+	var buf bytes.Buffer
+	p := newTestPrinter(&buf, false)
+
+	p.HandleEvent(sync.Event{
+		Kind:    sync.EventSourceWarning,
+		Source:  "my-lib",
+		Message: "GitHub API quota low: 5 request(s) left",
+	})
+
+	out := buf.String()
+	if !strings.Contains(out, "my-lib") {
+		t.Errorf("warning event output missing source name, got: %q", out)
+	}
+	if !strings.Contains(out, "quota low") {
+		t.Errorf("warning event output missing message, got: %q", out)
+	}
+}
+
 func TestPrintSummary(t *testing.T) {
 	var buf bytes.Buffer
 	p := newTestPrinter(&buf, false)
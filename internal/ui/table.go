@@ -11,19 +11,27 @@ import (
 )
 
 type SourceStatus struct {
-	Name      string    `json:"name"`
-	Type      string    `json:"type"`
-	Repo      string    `json:"repo,omitempty"`
-	Path      string    `json:"path,omitempty"`
-	URL       string    `json:"url,omitempty"`
-	Ref       string    `json:"ref,omitempty"`
-	Patterns  []string  `json:"patterns,omitempty"`
-	OutputDir string    `json:"output_dir"`
-	Status    string    `json:"status"`
-	FileCount int       `json:"file_count,omitempty"`
-	SyncedAt  time.Time `json:"synced_at,omitzero"`
+	Name        string    `json:"name"`
+	Type        string    `json:"type"`
+	Repo        string    `json:"repo,omitempty"`
+	Path        string    `json:"path,omitempty"`
+	URL         string    `json:"url,omitempty"`
+	Dir         string    `json:"dir,omitempty"`
+	Package     string    `json:"package,omitempty"`
+	Module      string    `json:"module,omitempty"`
+	Version     string    `json:"version,omitempty"`
+	Ref         string    `json:"ref,omitempty"`
+	ResolvedRef string    `json:"resolved_ref,omitempty"`
+	Commit      string    `json:"commit,omitempty"`
+	Patterns    []string  `json:"patterns,omitempty"`
+	OutputDir   string    `json:"output_dir"`
+	Status      string    `json:"status"`
+	FileCount   int       `json:"file_count,omitempty"`
+	SyncedAt    time.Time `json:"synced_at,omitzero"`
 }
 
+const shortCommitLength = 12
+
 type ListOptions struct {
 	JSON    bool
 	Verbose bool
@@ -56,7 +64,9 @@ func renderSourceListTable(sources []SourceStatus, opts ListOptions) {
 	writer.SetStyle(table.StyleRounded)
 
 	if opts.Verbose {
-		writer.AppendHeader(table.Row{"SOURCE", "TYPE", "LOCATION", "STATUS", "REF", "PATTERNS", "OUTPUT DIR"})
+		writer.AppendHeader(table.Row{
+			"SOURCE", "TYPE", "LOCATION", "STATUS", "REF", "COMMIT", "PATTERNS", "OUTPUT DIR",
+		})
 	} else {
 		writer.AppendHeader(table.Row{"SOURCE", "TYPE", "LOCATION", "STATUS"})
 	}
@@ -71,7 +81,8 @@ func renderSourceListTable(sources []SourceStatus, opts ListOptions) {
 				source.Type,
 				location,
 				status,
-				source.Ref,
+				renderRef(source),
+				renderCommit(source),
 				strings.Join(source.Patterns, ", "),
 				source.OutputDir,
 			})
@@ -90,10 +101,21 @@ func renderSourceListTable(sources []SourceStatus, opts ListOptions) {
 }
 
 func renderLocation(source SourceStatus) string {
-	if source.Type == "url" {
+	if source.Type == "url" || source.Type == "archive" || source.Type == "site" {
 		return source.URL
 	}
 
+	if source.Type == "local" {
+		return source.Dir
+	}
+
+	if pkg := source.Package + source.Module; pkg != "" {
+		if source.Version == "" {
+			return pkg
+		}
+		return pkg + "@" + source.Version
+	}
+
 	location := source.Repo
 	if source.Path != "" {
 		location += "/" + source.Path
@@ -102,6 +124,27 @@ func renderLocation(source SourceStatus) string {
 	return strings.TrimPrefix(location, "/")
 }
 
+// renderRef shows the configured ref, followed by the branch or tag the last
+// sync resolved when that differs, as with version constraints.
+func renderRef(source SourceStatus) string {
+	switch {
+	case source.ResolvedRef == "" || source.ResolvedRef == source.Ref:
+		return source.Ref
+	case source.Ref == "":
+		return source.ResolvedRef
+	default:
+		return source.Ref + " (" + source.ResolvedRef + ")"
+	}
+}
+
+func renderCommit(source SourceStatus) string {
+	if len(source.Commit) > shortCommitLength {
+		return source.Commit[:shortCommitLength]
+	}
+
+	return source.Commit
+}
+
 func renderStatus(source SourceStatus, includeFiles bool) string {
 	if includeFiles && source.FileCount > 0 {
 		return fmt.Sprintf("%s (%d files)", source.Status, source.FileCount)
@@ -84,6 +84,32 @@ func TestRenderLocation(t *testing.T) {
 			},
 			want: "https://example.com/doc.pdf",
 		},
+		{
+			name: "local source shows dir",
+			source: ui.SourceStatus{
+				Type: "local",
+				Dir:  "/work/design-docs",
+			},
+			want: "/work/design-docs",
+		},
+		{
+			name: "package source shows package and version range",
+			source: ui.SourceStatus{
+				Type:    "npm",
+				Package: "@acme/widget",
+				Version: "^1.2.0",
+			},
+			want: "@acme/widget@^1.2.0",
+		},
+		{
+			name: "go module source shows module and version",
+			source: ui.SourceStatus{
+				Type:    "gomod",
+				Module:  "github.com/spf13/cobra",
+				Version: "v1.8.1",
+			},
+			want: "github.com/spf13/cobra@v1.8.1",
+		},
 		{
 			name: "github source with path shows repo/path",
 			source: ui.SourceStatus{
@@ -177,3 +203,49 @@ func TestRenderStatus(t *testing.T) {
 		})
 	}
 }
+
+func TestRenderRefAndCommit(t *testing.T) {
+	tests := []struct {
+		name       string
+		source     ui.SourceStatus
+		wantRef    string
+		wantCommit string
+	}{
+		{
+			name:    "configured ref only",
+			source:  ui.SourceStatus{Ref: "main"},
+			wantRef: "main",
+		},
+		{
+			name: "resolved branch matches configured ref",
+			source: ui.SourceStatus{
+				Ref:         "main",
+				ResolvedRef: "main",
+				Commit:      "4f2a9c1e0b7d3a5f6c8e9d0b1a2c3e4f5a6b7c8d",
+			},
+			wantRef:    "main",
+			wantCommit: "4f2a9c1e0b7d",
+		},
+		{
+			name:    "default branch resolved without configured ref",
+			source:  ui.SourceStatus{ResolvedRef: "trunk"},
+			wantRef: "trunk",
+		},
+		{
+			name:    "version tag resolved from configured ref",
+			source:  ui.SourceStatus{Ref: "v5", ResolvedRef: "v5.2.1"},
+			wantRef: "v5 (v5.2.1)",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := ui.RenderRef(tc.source); got != tc.wantRef {
+				t.Errorf("RenderRef() = %q, want %q", got, tc.wantRef)
+			}
+			if got := ui.RenderCommit(tc.source); got != tc.wantCommit {
+				t.Errorf("RenderCommit() = %q, want %q", got, tc.wantCommit)
+			}
+		})
+	}
+}